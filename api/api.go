@@ -57,7 +57,7 @@ func (s Server) Serve(ctx context.Context) (err error) {
 
 	node.LocalStreams.Print(fmt.Sprintf("qri version v%s\nconnecting...\n", APIVersion))
 
-	ws, err := websocket.NewHandler(ctx, s.Instance.Bus(), s.Instance.KeyStore())
+	ws, err := websocket.NewHandler(ctx, s.Instance.Bus(), s.Instance.KeyStore(), s.Instance.RevokedTokens(), s.Instance.AuthorizeEventSubscription)
 	if err != nil {
 		return err
 	}
@@ -221,6 +221,7 @@ func NewServerRoutes(s Server) *mux.Router {
 	m.Use(muxVarsToQueryParamMiddleware)
 	m.Use(refStringMiddleware)
 	m.Use(token.OAuthTokenMiddleware)
+	m.Use(lib.APICallMiddleware)
 
 	var routeParams refRouteParams
 
@@ -243,6 +244,9 @@ func NewServerRoutes(s Server) *mux.Router {
 	m.Handle(AEUnpack.String(), s.Middleware(UnpackHandler(AEUnpack.NoTrailingSlash())))
 	m.Handle(AESaveByUpload.String(), s.Middleware(SaveByUploadHandler(s.Instance, AESaveByUpload.NoTrailingSlash())))
 
+	// non POST/json automation endpoints
+	m.Handle(qhttp.AERunEvents.String(), s.Middleware(RunEventsHandler(s.Instance))).Methods(http.MethodGet)
+
 	// sync/protocol endpoints
 	if cfg.RemoteServer != nil && cfg.RemoteServer.Enabled {
 		log.Info("running in `remote` mode")
@@ -3,15 +3,19 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/qri/api/util"
+	"github.com/qri-io/qri/automation"
 	"github.com/qri-io/qri/base/archive"
 	"github.com/qri-io/qri/event"
 	"github.com/qri-io/qri/lib"
+	qhttp "github.com/qri-io/qri/lib/http"
 )
 
 const (
@@ -209,6 +213,74 @@ func SaveByUploadHandler(inst *lib.Instance, routePrefix string) http.HandlerFun
 	}
 }
 
+// RunEventsHandler streams the step events & script output of a run as
+// server-sent events, closing the stream once the run finishes. Clients resume
+// a stream by setting the Last-Event-ID header. Streams are dispatched as the
+// "automation.followrun" method, which checks the request access token &
+// access control policy before any event is sent
+// Examples:
+// curl -N http://localhost:2503/auto/run/RUN_ID/events
+func RunEventsHandler(inst *lib.Instance) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.NotFoundHandler(w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			util.WriteErrResponse(w, http.StatusInternalServerError, fmt.Errorf("response streaming is not supported"))
+			return
+		}
+
+		p := &lib.FollowRunParams{ID: r.FormValue("id")}
+		if lastID := r.Header.Get(qhttp.LastEventIDHeader); lastID != "" {
+			n, err := strconv.Atoi(lastID)
+			if err != nil {
+				util.WriteErrResponse(w, http.StatusBadRequest, fmt.Errorf("invalid %s header %q", qhttp.LastEventIDHeader, lastID))
+				return
+			}
+			p.LastEventID = n
+		}
+
+		// headers are written with the first event, so errors finding the run
+		// can still be reported with a status code
+		started := false
+		start := func() {
+			w.Header().Set("Content-Type", qhttp.EventStreamMimeType)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			flusher.Flush()
+			started = true
+		}
+		p.Events = func(e automation.RunEvent) error {
+			if !started {
+				start()
+			}
+			if err := qhttp.WriteRunEvent(w, e); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		err := inst.Automation().FollowRun(r.Context(), p)
+		if started {
+			if err != nil {
+				log.Debugw("streaming run events", "runID", p.ID, "err", err)
+			}
+			return
+		}
+		if errors.Is(err, automation.ErrRunEventsNotFound) {
+			util.WriteErrResponse(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			util.RespondWithError(w, err)
+			return
+		}
+		start()
+	}
+}
+
 func extensionToMimeType(ext string) string {
 	switch ext {
 	case ".csv":
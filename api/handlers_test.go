@@ -226,6 +226,37 @@ func datasetJSONResponse(t *testing.T, body string) *dataset.Dataset {
 	return res.Data
 }
 
+func TestRunEventsHandler(t *testing.T) {
+	run := NewAPITestRunner(t)
+	defer run.Delete()
+
+	res, err := run.Inst.Automation().Apply(run.Ctx, &lib.ApplyParams{
+		Wait: true,
+		Transform: &dataset.Transform{
+			Text: `
+print("hello")
+ds = dataset.latest()
+dataset.commit(ds)
+`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test instance uses a nil event bus, so the finished run has no
+	// events to stream
+	gotStatusCode, gotBody := APICall("/auto/run/"+res.RunID+"/events", RunEventsHandler(run.Inst), map[string]string{"id": res.RunID})
+	if gotStatusCode != http.StatusOK {
+		t.Errorf("expected status code 200, got %d: %s", gotStatusCode, gotBody)
+	}
+
+	gotStatusCode, _ = APICall("/auto/run/not_a_run/events", RunEventsHandler(run.Inst), map[string]string{"id": "not_a_run"})
+	if gotStatusCode != http.StatusNotFound {
+		t.Errorf("expected status code 404 for an unknown run, got %d", gotStatusCode)
+	}
+}
+
 func TestValidateCSVRequest(t *testing.T) {
 	var caseName string
 	var expectErr error
@@ -9,7 +9,9 @@ import (
 
 	golog "github.com/ipfs/go-log"
 	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/auth/token"
 	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/remote/access"
 	"github.com/qri-io/qri/repo"
 )
 
@@ -49,6 +51,14 @@ func RespondWithError(w http.ResponseWriter, err error) {
 		WriteErrResponse(w, http.StatusBadRequest, err)
 		return
 	}
+	if errors.Is(err, token.ErrInvalidToken) || errors.Is(err, token.ErrTokenRevoked) {
+		WriteErrResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	if errors.Is(err, access.ErrAccessDenied) {
+		WriteErrResponse(w, http.StatusForbidden, err)
+		return
+	}
 	var perr *dsref.ParseError
 	if errors.As(err, &perr) {
 		WriteErrResponse(w, http.StatusBadRequest, err)
@@ -0,0 +1,170 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/qri-io/qfs"
+)
+
+// IssuedTokens records the claims of access tokens an instance has issued,
+// keyed by token ID. Raw token strings are never stored, so the record can be
+// listed & revoked against without holding credentials
+//
+// implementations of IssuedTokens must conform to the assertion test defined
+// in the spec subpackage
+type IssuedTokens interface {
+	// PutClaims records the claims of an issued token. claims must have an ID
+	PutClaims(ctx context.Context, claims *Claims) error
+	// ListClaims lists recorded claims, sorted by token ID
+	ListClaims(ctx context.Context, offset, limit int) ([]*Claims, error)
+	// DeleteClaims drops the record of a token, returning ErrTokenNotFound if
+	// no token with the given ID was issued
+	DeleteClaims(ctx context.Context, tokenID string) error
+}
+
+type qfsIssuedTokens struct {
+	path string
+	fs   qfs.Filesystem
+
+	lk     sync.Mutex
+	claims map[string]*Claims
+}
+
+var _ IssuedTokens = (*qfsIssuedTokens)(nil)
+
+// legacyIssuedToken is the format of early issued token files, which stored
+// raw tokens
+type legacyIssuedToken struct {
+	Key string
+	Raw string
+}
+
+// NewIssuedTokens creates a record of issued tokens persisted to a
+// qfs.Filesystem. Files that hold raw tokens are rewritten to hold only claims
+func NewIssuedTokens(filepath string, fs qfs.Filesystem) (IssuedTokens, error) {
+	ctx := context.Background()
+	it := &qfsIssuedTokens{
+		path:   filepath,
+		fs:     fs,
+		claims: map[string]*Claims{},
+	}
+
+	f, err := fs.Get(ctx, filepath)
+	if errors.Is(err, qfs.ErrNotFound) {
+		return it, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error creating issued token record: %w", err)
+	}
+
+	entries := []json.RawMessage{}
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid issued token file: %w", err)
+	}
+	migrated := false
+	for _, data := range entries {
+		legacy := legacyIssuedToken{}
+		if err := json.Unmarshal(data, &legacy); err == nil && legacy.Raw != "" {
+			claims, err := ParseUnverifiedClaims(legacy.Raw)
+			if err != nil {
+				log.Debugw("dropping unreadable issued token", "id", legacy.Key, "err", err)
+				migrated = true
+				continue
+			}
+			if claims.StandardClaims == nil {
+				claims.StandardClaims = &jwt.StandardClaims{}
+			}
+			if claims.Id == "" {
+				claims.Id = legacy.Key
+			}
+			it.claims[claims.Id] = claims
+			migrated = true
+			continue
+		}
+
+		claims := &Claims{}
+		if err := json.Unmarshal(data, claims); err != nil {
+			return nil, fmt.Errorf("invalid issued token file: %w", err)
+		}
+		if claims.StandardClaims == nil || claims.Id == "" {
+			return nil, fmt.Errorf("invalid issued token file: token ID is required")
+		}
+		it.claims[claims.Id] = claims
+	}
+
+	if migrated {
+		if err := it.save(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+func (it *qfsIssuedTokens) PutClaims(ctx context.Context, claims *Claims) error {
+	if claims == nil || claims.StandardClaims == nil || claims.Id == "" {
+		return fmt.Errorf("token ID is required")
+	}
+
+	it.lk.Lock()
+	defer it.lk.Unlock()
+
+	it.claims[claims.Id] = claims
+	return it.save(ctx)
+}
+
+func (it *qfsIssuedTokens) ListClaims(ctx context.Context, offset, limit int) ([]*Claims, error) {
+	it.lk.Lock()
+	defer it.lk.Unlock()
+
+	all := it.sorted()
+	results := make([]*Claims, 0, len(all))
+	for _, c := range all {
+		if offset > 0 {
+			offset--
+			continue
+		}
+		results = append(results, c)
+		if limit > 0 && len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+func (it *qfsIssuedTokens) DeleteClaims(ctx context.Context, tokenID string) error {
+	it.lk.Lock()
+	defer it.lk.Unlock()
+
+	if _, ok := it.claims[tokenID]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(it.claims, tokenID)
+	return it.save(ctx)
+}
+
+func (it *qfsIssuedTokens) sorted() []*Claims {
+	list := make([]*Claims, 0, len(it.claims))
+	for _, c := range it.claims {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+func (it *qfsIssuedTokens) save(ctx context.Context) error {
+	data, err := json.MarshalIndent(it.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := it.fs.Put(ctx, qfs.NewMemfileBytes(it.path, data))
+	if err != nil {
+		return err
+	}
+	it.path = path
+	return nil
+}
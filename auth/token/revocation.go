@@ -0,0 +1,94 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qfs"
+)
+
+// RevocationList tracks the IDs of tokens that must no longer be accepted
+//
+// implementations of RevocationList must conform to the assertion test defined
+// in the spec subpackage
+type RevocationList interface {
+	// Revoke adds a token ID to the list
+	Revoke(ctx context.Context, tokenID string) error
+	// IsRevoked returns true if a token ID is in the list
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+type qfsRevocationList struct {
+	path string
+	fs   qfs.Filesystem
+
+	lk  sync.Mutex
+	ids map[string]struct{}
+}
+
+var _ RevocationList = (*qfsRevocationList)(nil)
+
+// NewRevocationList creates a revocation list persisted to a qfs.Filesystem
+func NewRevocationList(filepath string, fs qfs.Filesystem) (RevocationList, error) {
+	ids := map[string]struct{}{}
+	if f, err := fs.Get(context.Background(), filepath); err == nil {
+		list := []string{}
+		if err := json.NewDecoder(f).Decode(&list); err != nil {
+			return nil, fmt.Errorf("invalid token revocation list file: %w", err)
+		}
+		for _, id := range list {
+			ids[id] = struct{}{}
+		}
+	} else if !errors.Is(err, qfs.ErrNotFound) {
+		return nil, fmt.Errorf("error creating token revocation list: %w", err)
+	}
+
+	return &qfsRevocationList{
+		path: filepath,
+		fs:   fs,
+		ids:  ids,
+	}, nil
+}
+
+func (rl *qfsRevocationList) Revoke(ctx context.Context, tokenID string) error {
+	if tokenID == "" {
+		return fmt.Errorf("token ID is required")
+	}
+
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	rl.ids[tokenID] = struct{}{}
+	return rl.save(ctx)
+}
+
+func (rl *qfsRevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	_, ok := rl.ids[tokenID]
+	return ok, nil
+}
+
+func (rl *qfsRevocationList) save(ctx context.Context) error {
+	list := make([]string, 0, len(rl.ids))
+	for id := range rl.ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := rl.fs.Put(ctx, qfs.NewMemfileBytes(rl.path, data))
+	if err != nil {
+		return err
+	}
+	rl.path = path
+	return nil
+}
@@ -0,0 +1,82 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	testkeys "github.com/qri-io/qri/auth/key/test"
+	"github.com/qri-io/qri/auth/token"
+)
+
+// AssertIssuedTokensSpec ensures a token.IssuedTokens implementation behaves
+// as expected
+func AssertIssuedTokensSpec(t *testing.T, newIssuedTokens func(context.Context) token.IssuedTokens) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	issued := newIssuedTokens(ctx)
+
+	results, err := issued.ListClaims(ctx, 0, -1)
+	if err != nil {
+		t.Errorf("listing an empty record shouldn't error. got: %q", err)
+	}
+	if len(results) > 0 {
+		t.Errorf("new record should return no results. got: %d", len(results))
+	}
+	if err := issued.DeleteClaims(ctx, "not_issued"); !errors.Is(err, token.ErrTokenNotFound) {
+		t.Errorf("expected deleting an unknown token ID to return a wrap of token.ErrTokenNotFound. got: %v", err)
+	}
+	if err := issued.PutClaims(ctx, &token.Claims{}); err == nil {
+		t.Errorf("putting claims without a token ID should error. got nil")
+	}
+
+	kd := testkeys.GetKeyData(0)
+	ids := make([]string, 2)
+	for i := range ids {
+		id, raw, err := token.NewScopedPrivKeyAuthToken(kd.PrivKey, kd.EncodedPeerID, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := token.ParseUnverifiedClaims(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := issued.PutClaims(ctx, claims); err != nil {
+			t.Errorf("putting claims shouldn't error. got: %q", err)
+		}
+		ids[i] = id
+	}
+
+	results, err = issued.ListClaims(ctx, 0, -1)
+	if err != nil {
+		t.Errorf("listing claims shouldn't error. got: %q", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result length mismatch. expected 2, got: %d", len(results))
+	}
+	for _, c := range results {
+		if c.Subject != kd.EncodedPeerID {
+			t.Errorf("subject mismatch. want: %q got: %q", kd.EncodedPeerID, c.Subject)
+		}
+	}
+	if results[0].Id > results[1].Id {
+		t.Errorf("expected claims to be sorted by token ID")
+	}
+
+	results, err = issued.ListClaims(ctx, 1, 1)
+	if err != nil {
+		t.Errorf("listing claims with offset=1, limit=1 shouldn't error. got: %q", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("result length mismatch listing with offset=1, limit=1. expected 1, got: %d", len(results))
+	}
+
+	if err := issued.DeleteClaims(ctx, ids[0]); err != nil {
+		t.Errorf("deleting an issued token shouldn't error. got: %q", err)
+	}
+	results, _ = issued.ListClaims(ctx, 0, -1)
+	if len(results) != 1 || results[0].Id != ids[1] {
+		t.Errorf("expected only %q to remain after delete. got: %v", ids[1], results)
+	}
+}
@@ -0,0 +1,67 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qri-io/qri/auth/key"
+	testkeys "github.com/qri-io/qri/auth/key/test"
+	"github.com/qri-io/qri/auth/token"
+)
+
+// AssertRevocationListSpec ensures a token.RevocationList implementation
+// behaves as expected
+func AssertRevocationListSpec(t *testing.T, newRevocationList func(context.Context) token.RevocationList) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	revoked := newRevocationList(ctx)
+
+	if err := revoked.Revoke(ctx, ""); err == nil {
+		t.Errorf("revoking an empty token ID should error. got nil")
+	}
+
+	is, err := revoked.IsRevoked(ctx, "not_revoked")
+	if err != nil {
+		t.Errorf("checking a token ID shouldn't error. got: %q", err)
+	}
+	if is {
+		t.Errorf("new list shouldn't report any token as revoked")
+	}
+
+	if err := revoked.Revoke(ctx, "token_a"); err != nil {
+		t.Errorf("revoking a token ID shouldn't error. got: %q", err)
+	}
+	if is, _ := revoked.IsRevoked(ctx, "token_a"); !is {
+		t.Errorf("expected token_a to be revoked")
+	}
+	if is, _ := revoked.IsRevoked(ctx, "token_b"); is {
+		t.Errorf("expected token_b to not be revoked")
+	}
+	if err := revoked.Revoke(ctx, "token_a"); err != nil {
+		t.Errorf("revoking a token ID twice shouldn't error. got: %q", err)
+	}
+
+	kd := testkeys.GetKeyData(0)
+	ks, err := key.NewMemStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddPubKey(ctx, kd.KeyID, kd.PrivKey.GetPublic()); err != nil {
+		t.Fatal(err)
+	}
+	id, raw, err := token.NewScopedPrivKeyAuthToken(kd.PrivKey, kd.EncodedPeerID, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token.ParseAuthToken(ctx, raw, ks, revoked); err != nil {
+		t.Errorf("parsing a token before revocation shouldn't error. got: %q", err)
+	}
+	if err := revoked.Revoke(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token.ParseAuthToken(ctx, raw, ks, revoked); !errors.Is(err, token.ErrTokenRevoked) {
+		t.Errorf("expected parsing a revoked token to return token.ErrTokenRevoked. got: %v", err)
+	}
+}
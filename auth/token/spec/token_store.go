@@ -18,6 +18,9 @@ func AssertTokenStoreSpec(t *testing.T, newTokenStore func(context.Context) toke
 	prevTs := token.Timestamp
 	token.Timestamp = func() time.Time { return time.Time{} }
 	defer func() { token.Timestamp = prevTs }()
+	prevID := token.NewTokenID
+	token.NewTokenID = func() string { return "" }
+	defer func() { token.NewTokenID = prevID }()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
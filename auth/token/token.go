@@ -13,22 +13,30 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	golog "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/qri-io/qfs"
 	"github.com/qri-io/qri/auth/key"
 	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/remote/access"
 )
 
 var (
 	// Timestamp is a replacable function for getting the current time,
 	// can be overridden for tests
 	Timestamp = func() time.Time { return time.Now() }
+	// NewTokenID is a replacable function for generating unique token IDs,
+	// can be overridden for tests
+	NewTokenID = func() string { return uuid.New().String() }
 	// ErrTokenNotFound is returned by stores that cannot find an access token
 	// for a given key
 	ErrTokenNotFound = errors.New("access token not found")
 	// ErrInvalidToken indicates an access token is invalid
 	ErrInvalidToken = errors.New("invalid access token")
+	// ErrTokenRevoked indicates an access token has been added to a revocation
+	// list
+	ErrTokenRevoked = errors.New("access token has been revoked")
 	// DefaultTokenTTL is the default
 	DefaultTokenTTL = time.Hour * 24 * 14
 
@@ -46,6 +54,56 @@ type Token = jwt.Token
 type Claims struct {
 	*jwt.StandardClaims
 	ClientType ClientType `json:"clientType"`
+	// Scopes limit the token to a set of actions on a set of resources. A token
+	// without scopes is unrestricted
+	Scopes []Scope `json:"scopes,omitempty"`
+}
+
+// Allows returns true if the claims permit taking action on resource.
+// subjectUsername fills the "_subject" placeholder of scope resources
+func (c *Claims) Allows(resource access.Resource, action access.Action, subjectUsername string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s.Resources.Contains(resource, subjectUsername) && s.Actions.Contains(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope grants a token permission to take a set of actions on a set of
+// resources, using the resource & action grammar of the access package
+type Scope struct {
+	Resources access.Resources `json:"resources"`
+	Actions   access.Actions   `json:"actions"`
+}
+
+// ParseScope constructs a scope from lists of resource & action strings
+func ParseScope(resources, actions []string) (Scope, error) {
+	s := Scope{}
+	if len(resources) == 0 {
+		return s, fmt.Errorf("scope requires at least one resource")
+	}
+	if len(actions) == 0 {
+		return s, fmt.Errorf("scope requires at least one action")
+	}
+	for _, str := range resources {
+		rsc, err := access.ParseResource(str)
+		if err != nil {
+			return s, err
+		}
+		s.Resources = append(s.Resources, rsc)
+	}
+	for _, str := range actions {
+		act, err := access.ParseAction(str)
+		if err != nil {
+			return s, err
+		}
+		s.Actions = append(s.Actions, act)
+	}
+	return s, nil
 }
 
 // Parse will parse, validate and return a token
@@ -59,8 +117,21 @@ func ParseWithClaims(tokenString string, claims *Claims, tokens Source) (*Token,
 }
 
 // NewPrivKeyAuthToken creates a JWT token string suitable for making requests
-// authenticated as the given private key
+// authenticated as the given private key. Tokens are assigned a unique token
+// ID that can be used to revoke the token
 func NewPrivKeyAuthToken(pk crypto.PrivKey, profileID string, ttl time.Duration) (string, error) {
+	return newPrivKeyAuthToken(pk, profileID, ttl, NewTokenID(), nil)
+}
+
+// NewScopedPrivKeyAuthToken creates a JWT token string limited to the given
+// scopes, returning the token ID alongside the token
+func NewScopedPrivKeyAuthToken(pk crypto.PrivKey, profileID string, ttl time.Duration, scopes []Scope) (tokenID, raw string, err error) {
+	tokenID = NewTokenID()
+	raw, err = newPrivKeyAuthToken(pk, profileID, ttl, tokenID, scopes)
+	return tokenID, raw, err
+}
+
+func newPrivKeyAuthToken(pk crypto.PrivKey, profileID string, ttl time.Duration, tokenID string, scopes []Scope) (string, error) {
 	signingMethod, err := jwtSigningMethod(pk)
 	if err != nil {
 		return "", err
@@ -101,6 +172,7 @@ func NewPrivKeyAuthToken(pk crypto.PrivKey, profileID string, ttl time.Duration)
 	// set our claims
 	t.Claims = &Claims{
 		StandardClaims: &jwt.StandardClaims{
+			Id:      tokenID,
 			Issuer:  id,
 			Subject: profileID,
 			// set the expire time
@@ -108,15 +180,17 @@ func NewPrivKeyAuthToken(pk crypto.PrivKey, profileID string, ttl time.Duration)
 			ExpiresAt: exp,
 		},
 		ClientType: UserClient,
+		Scopes:     scopes,
 	}
 
 	return t.SignedString(signKey)
 }
 
-// ParseAuthToken will parse, validate and return a token
-func ParseAuthToken(ctx context.Context, tokenString string, keystore key.Store) (*Token, error) {
+// ParseAuthToken will parse, validate and return a token. Tokens with an ID
+// present in revoked are rejected with ErrTokenRevoked. revoked may be nil
+func ParseAuthToken(ctx context.Context, tokenString string, keystore key.Store, revoked RevocationList) (*Token, error) {
 	claims := &Claims{}
-	return jwt.ParseWithClaims(tokenString, claims, func(t *Token) (interface{}, error) {
+	tok, err := jwt.ParseWithClaims(tokenString, claims, func(t *Token) (interface{}, error) {
 		pid, err := key.DecodeID(claims.Issuer)
 		if err != nil {
 			return nil, err
@@ -148,6 +222,30 @@ func ParseAuthToken(ctx context.Context, tokenString string, keystore key.Store)
 			return nil, fmt.Errorf("unsupported key type: %q", pubKey.Type())
 		}
 	})
+	if err != nil {
+		return nil, err
+	}
+	if revoked != nil && claims.StandardClaims != nil && claims.Id != "" {
+		isRevoked, err := revoked.IsRevoked(ctx, claims.Id)
+		if err != nil {
+			return nil, err
+		}
+		if isRevoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return tok, nil
+}
+
+// ParseUnverifiedClaims reads the claims of a token string without checking
+// the token signature. Only use for tokens from a trusted source, like a Store
+func ParseUnverifiedClaims(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	p := &jwt.Parser{UseJSONNumber: true}
+	if _, _, err := p.ParseUnverified(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	return claims, nil
 }
 
 // Source creates tokens, and provides a verification key for all tokens
@@ -243,11 +341,18 @@ func (a *pkSource) CreateToken(pro *profile.Profile, ttl time.Duration) (string,
 	return a.CreateTokenWithClaims(claims, ttl)
 }
 
-// CreateToken returns a new JWT token from provided claims
+// CreateTokenWithClaims returns a new JWT token from provided claims. Claims
+// without a token ID are assigned one
 func (a *pkSource) CreateTokenWithClaims(claims *Claims, ttl time.Duration) (string, error) {
 	if claims == nil {
 		return "", fmt.Errorf("empty token claims")
 	}
+	if claims.StandardClaims == nil {
+		claims.StandardClaims = &jwt.StandardClaims{}
+	}
+	if claims.Id == "" {
+		claims.Id = NewTokenID()
+	}
 	// create a signer for rsa 256
 	t := jwt.New(a.signingMethod)
 
@@ -321,12 +426,8 @@ func NewStore(filepath string, fs qfs.Filesystem) (Store, error) {
 		for _, t := range rawToks {
 			toks[t.Key] = t.Raw
 		}
-	} else {
-		if err.Error() == "path not found" {
-			// TODO(arqu): handle Not Found
-		} else {
-			return nil, fmt.Errorf("error creating token store: %w", err)
-		}
+	} else if !errors.Is(err, qfs.ErrNotFound) {
+		return nil, fmt.Errorf("error creating token store: %w", err)
 	}
 
 	return &qfsStore{
@@ -117,13 +117,16 @@ func (ct ClientType) String() string {
 type LocalProvider struct {
 	profiles profile.Store
 	keys     key.Store
+	revoked  RevocationList
 }
 
-// NewProvider instantiates a new LocalProvider
-func NewProvider(p profile.Store, k key.Store) (*LocalProvider, error) {
+// NewProvider instantiates a new LocalProvider. Refresh tokens with an ID in
+// revoked are rejected. revoked may be nil
+func NewProvider(p profile.Store, k key.Store, revoked RevocationList) (*LocalProvider, error) {
 	return &LocalProvider{
 		profiles: p,
 		keys:     k,
+		revoked:  revoked,
 	}, nil
 }
 
@@ -175,7 +178,7 @@ func (p *LocalProvider) Token(ctx context.Context, req *Request) (*Response, err
 		if req.RefreshToken == "" {
 			return nil, ErrInvalidRequest
 		}
-		tok, err := ParseAuthToken(ctx, req.RefreshToken, p.keys)
+		tok, err := ParseAuthToken(ctx, req.RefreshToken, p.keys, p.revoked)
 		if err != nil {
 			log.Debugf("token.Provider error parsing refresh token: %q", err.Error())
 			return nil, ErrInvalidRequest
@@ -2,6 +2,9 @@ package token_test
 
 import (
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,12 +14,16 @@ import (
 	"github.com/qri-io/qri/auth/token"
 	token_spec "github.com/qri-io/qri/auth/token/spec"
 	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/remote/access"
 )
 
 func TestPrivKeyTokens(t *testing.T) {
 	prevTs := token.Timestamp
 	token.Timestamp = func() time.Time { return time.Time{} }
 	defer func() { token.Timestamp = prevTs }()
+	prevID := token.NewTokenID
+	token.NewTokenID = func() string { return "" }
+	defer func() { token.NewTokenID = prevID }()
 
 	kd := testkeys.GetKeyData(0)
 	tokens, err := token.NewPrivKeySource(kd.PrivKey)
@@ -84,8 +91,186 @@ func TestNewPrivKeyAuthToken(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = token.ParseAuthToken(ctx, str, ks)
+	tok, err := token.ParseAuthToken(ctx, str, ks, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if claims, ok := tok.Claims.(*token.Claims); !ok || claims.Id == "" {
+		t.Errorf("expected token to have an ID")
+	}
+}
+
+func TestRevocationList(t *testing.T) {
+	fs := qfs.NewMemFS()
+
+	token_spec.AssertRevocationListSpec(t, func(ctx context.Context) token.RevocationList {
+		rl, err := token.NewRevocationList("revoked_tokens.json", fs)
+		if err != nil {
+			panic(err)
+		}
+		return rl
+	})
+}
+
+func TestIssuedTokens(t *testing.T) {
+	token_spec.AssertIssuedTokensSpec(t, func(ctx context.Context) token.IssuedTokens {
+		it, err := token.NewIssuedTokens("tokens.json", qfs.NewMemFS())
+		if err != nil {
+			panic(err)
+		}
+		return it
+	})
+}
+
+func TestIssuedTokensDropsRawTokens(t *testing.T) {
+	ctx := context.Background()
+	fs := qfs.NewMemFS()
+	kd := testkeys.GetKeyData(0)
+
+	id, raw, err := token.NewScopedPrivKeyAuthToken(kd.PrivKey, kd.EncodedPeerID, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := json.Marshal([]map[string]string{{"Key": id, "Raw": raw}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := fs.Put(ctx, qfs.NewMemfileBytes("tokens.json", legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := token.NewIssuedTokens(path, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := it.ListClaims(ctx, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 1 || claims[0].Id != id {
+		t.Errorf("expected legacy token %q to be listed. got: %v", id, claims)
+	}
+
+	f, err := fs.Get(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), raw) {
+		t.Errorf("expected raw token to be removed from the issued token file")
+	}
+}
+
+func TestProviderRejectsRevokedRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	kd := testkeys.GetKeyData(0)
+	ks, err := key.NewMemStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pro, err := profile.NewSparsePKProfile("doug", kd.PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps, err := profile.NewMemStore(ctx, pro, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := token.NewRevocationList("revoked_tokens.json", qfs.NewMemFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := token.NewProvider(ps, ks, revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Token(ctx, &token.Request{GrantType: token.PasswordCredentials, Username: "doug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := &token.Request{GrantType: token.Refreshing, RefreshToken: res.RefreshToken}
+	if _, err := p.Token(ctx, refresh); err != nil {
+		t.Errorf("refreshing with a valid refresh token shouldn't error. got: %q", err)
+	}
+
+	claims, err := token.ParseUnverifiedClaims(res.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Id == "" {
+		t.Fatal("expected refresh token to have an ID")
+	}
+	if err := revoked.Revoke(ctx, claims.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Token(ctx, refresh); err == nil {
+		t.Errorf("expected refreshing with a revoked refresh token to error")
+	}
+}
+
+func TestScopedAuthToken(t *testing.T) {
+	ctx := context.Background()
+	kd := testkeys.GetKeyData(0)
+	ks, err := key.NewMemStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddPubKey(ctx, kd.KeyID, kd.PrivKey.GetPublic()); err != nil {
+		t.Fatal(err)
+	}
+
+	scope, err := token.ParseScope([]string{"dataset:_subject:*"}, []string{"dataset:get", "log:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, str, err := token.NewScopedPrivKeyAuthToken(kd.PrivKey, kd.EncodedPeerID, 0, []token.Scope{scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" {
+		t.Errorf("expected scoped token to have an ID")
+	}
+
+	tok, err := token.ParseAuthToken(ctx, str, ks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, ok := tok.Claims.(*token.Claims)
+	if !ok {
+		t.Fatalf("expected claims to be *token.Claims, got: %T", tok.Claims)
+	}
+	if claims.Id != id {
+		t.Errorf("token ID mismatch. want: %q got: %q", id, claims.Id)
+	}
+
+	cases := []struct {
+		resource, action string
+		expect           bool
+	}{
+		{"dataset:doug:world_bank", "dataset:get", true},
+		{"dataset:doug:world_bank", "log:history", true},
+		{"dataset:doug:world_bank", "dataset:save", false},
+		{"dataset:b5:world_bank", "dataset:get", false},
+		{"node", "dataset:get", false},
+	}
+	for _, c := range cases {
+		got := claims.Allows(access.MustParseResource(c.resource), access.MustParseAction(c.action), "doug")
+		if got != c.expect {
+			t.Errorf("claims.Allows(%q, %q) mismatch. want: %t got: %t", c.resource, c.action, c.expect, got)
+		}
+	}
+
+	unscoped := &token.Claims{}
+	if !unscoped.Allows(access.MustParseResource("node"), access.MustParseAction("config:set"), "doug") {
+		t.Errorf("expected claims without scopes to allow any action")
+	}
+
+	if _, err := token.ParseScope(nil, []string{"dataset:get"}); err == nil {
+		t.Errorf("expected parsing a scope without resources to error")
+	}
 }
@@ -4,12 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 
+	golog "github.com/ipfs/go-log"
 	"github.com/qri-io/qri/event"
 )
 
 var (
+	log = golog.Logger("hook")
+
 	// ErrUnexpectedType indicates the hook type is unexpected
 	ErrUnexpectedType = fmt.Errorf("unexpected hook type")
+	// ErrUnknownType indicates the hook type is not one this package can
+	// construct
+	ErrUnknownType = fmt.Errorf("unknown hook type")
 )
 
 // A Hook determines under what circumstances its `event.Type` should be
@@ -29,3 +35,31 @@ type Hook interface {
 	// the payload that should be emitted along with the event
 	Event() (event.Type, interface{})
 }
+
+// NewHook constructs a Hook from a map of options. The "type" field of the
+// map determines what kind of Hook is returned
+func NewHook(opts map[string]interface{}) (Hook, error) {
+	typ, ok := opts["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("hook options map must include a %q field with the hook type given as a string", "type")
+	}
+
+	var h Hook
+	switch typ {
+	case RuntimeType:
+		h = &RuntimeHook{}
+	case WebhookType:
+		h = &WebhookHook{}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, typ)
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
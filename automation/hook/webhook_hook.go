@@ -0,0 +1,265 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/event"
+)
+
+const (
+	// WebhookType denotes a `WebhookHook`
+	WebhookType = "Webhook Hook"
+	// ETWebhookHook denotes a `WebhookHook` event
+	// TODO (ramfox): this will probably move to the `event` package
+	ETWebhookHook = event.Type("workflow:webhookhook")
+	// SignatureHeader is the HTTP header a WebhookHook writes the payload
+	// signature to
+	SignatureHeader = "X-Qri-Signature"
+	// EventHeader is the HTTP header a WebhookHook writes the event type to
+	EventHeader = "X-Qri-Event"
+
+	// DefaultWebhookMaxAttempts is the number of times a WebhookHook will try
+	// to deliver a payload when MaxAttempts is not set
+	DefaultWebhookMaxAttempts = 5
+	// DefaultWebhookBackoff is the amount of time a WebhookHook waits before
+	// the first retry when Backoff is not set. The wait doubles with each
+	// following attempt
+	DefaultWebhookBackoff = time.Second
+)
+
+// NowFunc returns the current time. Can be overridden in tests to create
+// determinism
+var NowFunc = time.Now
+
+// WebhookPayload is the body a WebhookHook POSTs when a workflow run finishes
+type WebhookPayload struct {
+	RunID      string `json:"runID"`
+	WorkflowID string `json:"workflowID"`
+	InitID     string `json:"initID"`
+	Status     string `json:"status"`
+	Ref        string `json:"ref"`
+	CommitPath string `json:"commitPath"`
+}
+
+// A WebhookHook implements the Hook interface & POSTs a signed JSON payload to
+// a URL after a workflow run has stopped
+type WebhookHook struct {
+	enabled bool
+	// URL is the address the payload is POSTed to
+	URL string
+	// Secret is the key used to sign the payload. Receivers can confirm the
+	// request came from this node by computing the HMAC-SHA256 of the request
+	// body & comparing it to the `X-Qri-Signature` header. Secret is read when
+	// unmarshaling hook options, but never marshaled. The orchestrator moves it
+	// to the workflow's secret store & fills it in from SecretName before
+	// delivery
+	Secret string
+	// SecretName is the name of the workflow secret that holds Secret
+	SecretName string
+	// MaxAttempts is the number of times to try delivery before giving up
+	MaxAttempts int
+	// Backoff is the wait before the first retry. Each retry doubles the wait
+	Backoff time.Duration
+	payload *WebhookPayload
+}
+
+var _ Hook = (*WebhookHook)(nil)
+
+// NewWebhookHook returns an enabled `WebhookHook` that delivers to the given
+// URL, signing payloads with secret
+func NewWebhookHook(url, secret string) *WebhookHook {
+	return &WebhookHook{
+		enabled:     true,
+		URL:         url,
+		Secret:      secret,
+		MaxAttempts: DefaultWebhookMaxAttempts,
+		Backoff:     DefaultWebhookBackoff,
+	}
+}
+
+// Enabled returns the enabled status
+func (wh *WebhookHook) Enabled() bool {
+	return wh.enabled
+}
+
+// SetEnabled sets the enabled status
+func (wh *WebhookHook) SetEnabled(enabled bool) error {
+	wh.enabled = enabled
+	return nil
+}
+
+// Type returns the type of Hook
+func (wh *WebhookHook) Type() string {
+	return WebhookType
+}
+
+// Advance is a no-op, WebhookHooks keep no state between runs
+func (wh *WebhookHook) Advance() error {
+	return nil
+}
+
+// Event returns the event.Type ETWebhookHook as well as the associated payload
+func (wh *WebhookHook) Event() (event.Type, interface{}) {
+	return ETWebhookHook, wh.payload
+}
+
+// SetPayload sets the payload the WebhookHook will deliver
+func (wh *WebhookHook) SetPayload(p WebhookPayload) {
+	wh.payload = &p
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of body, keyed by the
+// hook secret
+func (wh *WebhookHook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(wh.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver POSTs the hook payload to the hook URL, retrying with exponential
+// backoff until a request gets a 2XX response or MaxAttempts is reached.
+// Every attempt is returned in order, and an error is returned if none of the
+// attempts succeeded
+func (wh *WebhookHook) Deliver(ctx context.Context, client *http.Client) ([]*run.HookDelivery, error) {
+	if wh.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if wh.payload == nil {
+		return nil, fmt.Errorf("webhook payload is required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(wh.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := wh.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	backoff := wh.Backoff
+	if backoff <= 0 {
+		backoff = DefaultWebhookBackoff
+	}
+
+	deliveries := make([]*run.HookDelivery, 0, maxAttempts)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		d := wh.post(ctx, client, body, attempt)
+		deliveries = append(deliveries, d)
+		if d.Succeeded() {
+			return deliveries, nil
+		}
+		log.Debugw("webhook delivery failed", "url", wh.URL, "attempt", attempt, "status", d.StatusCode, "error", d.Error)
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return deliveries, ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+	return deliveries, fmt.Errorf("webhook delivery to %q failed after %d attempts", wh.URL, maxAttempts)
+}
+
+func (wh *WebhookHook) post(ctx context.Context, client *http.Client, body []byte, attempt int) *run.HookDelivery {
+	start := NowFunc()
+	d := &run.HookDelivery{
+		HookType:  WebhookType,
+		URL:       wh.URL,
+		Attempt:   attempt,
+		Timestamp: &start,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(ETWebhookHook))
+	req.Header.Set(SignatureHeader, wh.Sign(body))
+
+	res, err := client.Do(req)
+	d.Duration = int64(NowFunc().Sub(start))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	defer res.Body.Close()
+	d.StatusCode = res.StatusCode
+	return d
+}
+
+type webhookHook struct {
+	Enabled     bool            `json:"enabled"`
+	Type        string          `json:"type"`
+	URL         string          `json:"url"`
+	Secret      string          `json:"secret,omitempty"`
+	SecretName  string          `json:"secretName,omitempty"`
+	MaxAttempts int             `json:"maxAttempts,omitempty"`
+	Backoff     string          `json:"backoff,omitempty"`
+	Payload     *WebhookPayload `json:"payload,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaller interface. The signing secret is
+// left out so it's never written to the workflow store
+func (wh *WebhookHook) MarshalJSON() ([]byte, error) {
+	if wh == nil {
+		wh = &WebhookHook{}
+	}
+	h := webhookHook{
+		Enabled:     wh.enabled,
+		Type:        wh.Type(),
+		URL:         wh.URL,
+		SecretName:  wh.SecretName,
+		MaxAttempts: wh.MaxAttempts,
+		Payload:     wh.payload,
+	}
+	if wh.Backoff != 0 {
+		h.Backoff = wh.Backoff.String()
+	}
+	return json.Marshal(h)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaller interface
+func (wh *WebhookHook) UnmarshalJSON(d []byte) error {
+	h := &webhookHook{}
+	err := json.Unmarshal(d, h)
+	if err != nil {
+		return err
+	}
+	if h.Type != WebhookType {
+		return fmt.Errorf("%w, got %q expected %q", ErrUnexpectedType, h.Type, WebhookType)
+	}
+	var backoff time.Duration
+	if h.Backoff != "" {
+		if backoff, err = time.ParseDuration(h.Backoff); err != nil {
+			return fmt.Errorf("parsing webhook backoff: %w", err)
+		}
+	}
+	if wh == nil {
+		wh = &WebhookHook{}
+	}
+	wh.enabled = h.Enabled
+	wh.URL = h.URL
+	wh.Secret = h.Secret
+	wh.SecretName = h.SecretName
+	wh.MaxAttempts = h.MaxAttempts
+	wh.Backoff = backoff
+	wh.payload = h.Payload
+	return nil
+}
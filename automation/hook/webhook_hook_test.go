@@ -0,0 +1,123 @@
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/automation/hook"
+	"github.com/qri-io/qri/automation/spec"
+)
+
+func TestWebhookHook(t *testing.T) {
+	wh := hook.NewWebhookHook("http://example.com/hook", "secret")
+	spec.AssertHook(t, wh)
+}
+
+func TestWebhookHookDeliver(t *testing.T) {
+	ctx := context.Background()
+	expect := hook.WebhookPayload{
+		RunID:      "run_id",
+		WorkflowID: "workflow_id",
+		InitID:     "init_id",
+		Status:     "succeeded",
+		Ref:        "peer/dataset",
+		CommitPath: "/mem/QmCommit",
+	}
+
+	wh := hook.NewWebhookHook("", "secret")
+	wh.MaxAttempts = 3
+	wh.Backoff = time.Millisecond
+	wh.SetPayload(expect)
+
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if got := r.Header.Get(hook.SignatureHeader); got != wh.Sign(body) {
+			t.Errorf("signature mismatch. expected %q, got %q", wh.Sign(body), got)
+		}
+		got := hook.WebhookPayload{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if diff := cmp.Diff(expect, got); diff != "" {
+			t.Errorf("payload mismatch (-want +got):\n%s", diff)
+		}
+		// fail the first request to exercise retries
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	wh.URL = s.URL
+
+	deliveries, err := wh.Deliver(ctx, s.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("expected 2 delivery attempts, got %d", len(deliveries))
+	}
+	if deliveries[0].Succeeded() {
+		t.Errorf("expected first delivery attempt to fail")
+	}
+	if !deliveries[1].Succeeded() {
+		t.Errorf("expected second delivery attempt to succeed, got status %d", deliveries[1].StatusCode)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	wh.URL = failing.URL
+
+	deliveries, err = wh.Deliver(ctx, failing.Client())
+	if err == nil {
+		t.Fatal("expected delivery that never succeeds to error")
+	}
+	if len(deliveries) != wh.MaxAttempts {
+		t.Errorf("expected %d delivery attempts, got %d", wh.MaxAttempts, len(deliveries))
+	}
+}
+
+func TestNewHook(t *testing.T) {
+	wh := hook.NewWebhookHook("http://example.com/hook", "secret")
+	data, err := json.Marshal(wh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string]interface{}{}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hook.NewHook(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, ok := h.(*hook.WebhookHook)
+	if !ok {
+		t.Fatalf("expected NewHook to return a *hook.WebhookHook, got %T", h)
+	}
+	if got.URL != wh.URL || got.Backoff != wh.Backoff || !got.Enabled() {
+		t.Errorf("constructed hook mismatch. expected %#v, got %#v", wh, got)
+	}
+
+	if _, err := hook.NewHook(map[string]interface{}{"type": "unknown"}); err == nil {
+		t.Error("expected unknown hook type to error")
+	}
+}
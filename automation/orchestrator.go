@@ -2,24 +2,48 @@ package automation
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	golog "github.com/ipfs/go-log"
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/automation/hook"
 	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/automation/secrets"
 	"github.com/qri-io/qri/automation/trigger"
 	"github.com/qri-io/qri/automation/workflow"
 	"github.com/qri-io/qri/base/dsfs"
 	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/transform/startf"
+	"github.com/qri-io/qri/transform/staticlark"
 )
 
 var (
 	log = golog.Logger("automation")
+
+	// ErrDatasetTriggerCycle indicates saving a workflow would create a cycle
+	// of workflows that trigger one another through dataset triggers
+	ErrDatasetTriggerCycle = fmt.Errorf("dataset trigger cycle")
+	// ErrRunTimedOut indicates a run was canceled for exceeding its workflow's
+	// timeout
+	ErrRunTimedOut = fmt.Errorf("run timed out")
+	// ErrTransformLeaksSecrets indicates static analysis found a workflow's
+	// transform may leak sensitive data
+	ErrTransformLeaksSecrets = fmt.Errorf("transform may leak secrets")
+	// ErrNoSecretStore indicates the orchestrator isn't configured to store
+	// workflow secrets
+	ErrNoSecretStore = fmt.Errorf("no secret store configured")
 )
 
 // NowFunc returns a pointer to the current time. Can be overridden in
@@ -34,6 +58,35 @@ type OrchestratorOptions struct {
 	WorkflowStore workflow.Store
 	Listeners     []trigger.Listener
 	RunStore      run.Store
+	// HookClient is the HTTP client hooks use to deliver payloads outside of
+	// the qri process. Defaults to http.DefaultClient
+	HookClient *http.Client
+	// RunQueue configures the queue runs & applies wait in before executing.
+	// When RunQueue.Path is set, queued runs are persisted to that file and
+	// recovered when the orchestrator is constructed. Defaults to an in-memory
+	// queue
+	RunQueue *RunQueueOptions
+	// RunRetention determines which runs & how much run output the RunStore
+	// keeps. The zero value keeps everything
+	RunRetention run.RetentionPolicy
+	// RunCompactInterval is how often the RunStore is compacted according to
+	// RunRetention. Zero disables compaction, and is only valid when
+	// RunRetention is the zero value
+	RunCompactInterval time.Duration
+	// TransformAnalyzer statically analyzes the transform a workflow runs.
+	// When set, SaveWorkflow refuses workflows with transforms that may leak
+	// secrets. Nil disables analysis
+	TransformAnalyzer TransformAnalyzer
+	// SecretStore holds the secrets injected into workflow runs. Nil disables
+	// stored secrets
+	SecretStore secrets.Store
+}
+
+// TransformAnalyzer performs static analysis on the transform script of the
+// dataset a workflow runs. only non-test implementation is in lib, but this
+// interface is used to avoid a direct dependency
+type TransformAnalyzer interface {
+	AnalyzeWorkflowTransform(ctx context.Context, wf *workflow.Workflow) ([]staticlark.Diagnostic, error)
 }
 
 // WorkflowRunner is for running workflows using some execution engine
@@ -49,6 +102,12 @@ type WorkflowRunParams struct {
 	Secrets      map[string]string
 	OutputWidth  int
 	OutputHeight int
+	// HTTPFixtures, when set, serves HTTP requests made by the transform from
+	// a previous run's recording instead of the network
+	HTTPFixtures *event.HTTPFixtures
+	// Record stores an applied run & the HTTP requests its transform makes,
+	// so the run can be replayed
+	Record bool
 }
 
 // Orchestrator manages automation in qri
@@ -62,6 +121,18 @@ type Orchestrator struct {
 	cancel    context.CancelFunc
 	doneCh    chan struct{}
 	running   bool
+
+	hookClient *http.Client
+	analyzer   TransformAnalyzer
+	secrets    secrets.Store
+	// commits tracks the dataset version committed by each in-flight run
+	commitsLk sync.Mutex
+	commits   map[string]*dsref.VersionInfo
+	// hooksLk serializes writing hook deliveries to run stores that can't add
+	// them atomically
+	hooksLk sync.Mutex
+	// streams records run events for followers
+	streams *runStreams
 }
 
 // NewOrchestrator constructs an orchestrator
@@ -74,17 +145,21 @@ func NewOrchestrator(ctx context.Context, bus event.Bus, runner WorkflowRunner,
 	if runner == nil {
 		return nil, fmt.Errorf("WorkflowRunner required")
 	}
+	if !opts.RunRetention.IsZero() && opts.RunCompactInterval <= 0 {
+		return nil, fmt.Errorf("RunCompactInterval required to apply RunRetention")
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
+	// the orchestrator can't be stopped until it's listening for the context
+	// to close, so a failed construction only cancels the context
 	ok := false
-	var o *Orchestrator
 	defer func() {
 		if !ok {
-			o.Stop()
+			cancel()
 		}
 	}()
 
-	o = &Orchestrator{
+	o := &Orchestrator{
 		cancel: cancel,
 		doneCh: make(chan struct{}),
 
@@ -92,7 +167,15 @@ func NewOrchestrator(ctx context.Context, bus event.Bus, runner WorkflowRunner,
 		runner:    runner,
 		workflows: opts.WorkflowStore,
 		runs:      opts.RunStore,
-		runQueue:  NewRunQueue(ctx, bus, 50*time.Millisecond, 1),
+
+		hookClient: opts.HookClient,
+		analyzer:   opts.TransformAnalyzer,
+		secrets:    opts.SecretStore,
+		commits:    map[string]*dsref.VersionInfo{},
+		streams:    newRunStreams(),
+	}
+	if o.hookClient == nil {
+		o.hookClient = http.DefaultClient
 	}
 
 	for _, l := range opts.Listeners {
@@ -128,31 +211,83 @@ func NewOrchestrator(ctx context.Context, bus event.Bus, runner WorkflowRunner,
 
 		o.listeners = map[string]trigger.Listener{}
 	}
-	// TODO (ramfox): once hooks/completors are implemented, start the completor system here
+
+	qOpts := DefaultRunQueueOptions()
+	if opts.RunQueue != nil {
+		qOpts = *opts.RunQueue
+	}
+	if qOpts.Path == "" {
+		o.runQueue = NewRunQueueOptions(ctx, bus, qOpts)
+	} else {
+		rq, err := NewFileRunQueue(ctx, bus, qOpts)
+		if err != nil {
+			return nil, err
+		}
+		o.runQueue = rq
+		o.recoverRuns(ctx, rq, qOpts.RequeueInFlight)
+	}
 	ok = true
 
 	go o.handleContextClose(ctx)
+	go run.RunCompactor(ctx, o.runs, opts.RunRetention, opts.RunCompactInterval)
 
 	return o, nil
 }
 
+const (
+	// StoreTypeFile stores workflows & runs in JSON files
+	StoreTypeFile = "file"
+	// StoreTypeSQLite stores workflows & runs in a SQLite database
+	StoreTypeSQLite = "sqlite"
+)
+
+// NewStores creates the workflow & run stores of the given type within the
+// repo. An empty storeType uses StoreTypeFile
+func NewStores(repoPath, storeType string) (workflow.Store, run.Store, error) {
+	switch storeType {
+	case "", StoreTypeFile:
+		wfs, err := workflow.NewFileStore(repoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		rs, err := run.NewFileStore(repoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wfs, rs, nil
+	case StoreTypeSQLite:
+		wfs, err := workflow.NewSQLiteStore(repoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		rs, err := run.NewSQLiteStore(repoPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return wfs, rs, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown automation store type %q", storeType)
+	}
+}
+
 // DefaultOrchestratorOptions is a temporary solution to supplying options to the orchestrator
 // TODO (ramfox): remove this in favor of using the automation configuration to
 // determing what the orchestrator should be configured as
-func DefaultOrchestratorOptions(bus event.Bus, repoPath string) (OrchestratorOptions, error) {
-	wfs, err := workflow.NewFileStore(repoPath)
-	if err != nil {
-		return OrchestratorOptions{}, err
-	}
-	rs, err := run.NewFileStore(repoPath)
+func DefaultOrchestratorOptions(bus event.Bus, repoPath, storeType string) (OrchestratorOptions, error) {
+	wfs, rs, err := NewStores(repoPath, storeType)
 	if err != nil {
 		return OrchestratorOptions{}, err
 	}
+	qOpts := DefaultRunQueueOptions()
+	qOpts.Path = filepath.Join(repoPath, "run_queue.json")
+	qOpts.MaxConcurrentPerWorkflow = 1
 	return OrchestratorOptions{
 		WorkflowStore: wfs,
 		RunStore:      rs,
+		RunQueue:      &qOpts,
 		Listeners: []trigger.Listener{
 			trigger.NewCronListener(bus),
+			trigger.NewDatasetListener(bus),
 		},
 	}, nil
 }
@@ -165,6 +300,7 @@ func DefaultMemOrchestratorOptions(ctx context.Context, bus event.Bus) Orchestra
 		RunStore:      run.NewMemStore(),
 		Listeners: []trigger.Listener{
 			trigger.NewRuntimeListener(ctx, bus),
+			trigger.NewDatasetListener(bus),
 		},
 	}
 }
@@ -174,6 +310,7 @@ func (o *Orchestrator) Start(ctx context.Context) error {
 	// TODO(ramfox): when hooks and completors are set up, start them here
 	o.running = true
 	o.bus.SubscribeTypes(o.handleTrigger, event.ETAutomationWorkflowTrigger)
+	o.bus.SubscribeTypes(o.handleCommit, event.ETLogbookWriteCommit)
 	return o.startListeners(ctx)
 }
 
@@ -271,6 +408,18 @@ func (o *Orchestrator) advanceTrigger(wf *workflow.Workflow, triggerID string) *
 	return wf
 }
 
+// saveAdvancedTrigger stores a workflow whose only change is an advanced
+// trigger. The workflow was checked when it was saved, so the checks
+// SaveWorkflow runs aren't repeated
+func (o *Orchestrator) saveAdvancedTrigger(ctx context.Context, wf *workflow.Workflow) (*workflow.Workflow, error) {
+	wf, err := o.workflows.Put(ctx, wf)
+	if err != nil {
+		return nil, err
+	}
+	go o.updateListeners(wf)
+	return wf, nil
+}
+
 // handleTrigger calls `RunWorkflow` when an `event.ETAutomationWorkflowTrigger` event is fired
 // it expects the payload for the `event.ETAutomationWorkflowTrigger` to be a workflow.ID
 // represented as a string
@@ -286,14 +435,14 @@ func (o *Orchestrator) handleTrigger(ctx context.Context, e event.Event) error {
 				log.Debugw("handleTrigger: error fetching workflow", "id", wtp.WorkflowID, "err", err)
 				return
 			}
-			wf = o.advanceTrigger(wf, wtp.TriggerID)
-			wf, err = o.SaveWorkflow(ctx, wf)
+			wf, err = o.saveAdvancedTrigger(ctx, o.advanceTrigger(wf, wtp.TriggerID))
 			if err != nil {
 				log.Debugw("handleTrigger: error saving workflow", "id", wtp.WorkflowID, "err", err)
+				return
 			}
 			runID := run.NewID()
 			runFunc := o.runWorkflowFactory(wf, runID)
-			if err := o.runQueue.Push(ctx, wf.OwnerID.Encode(), runID, "run", runFunc); err != nil {
+			if err := o.runQueue.Push(ctx, wf.OwnerID.Encode(), wf.ID.String(), runID, "run", runFunc); err != nil {
 
 				log.Debugw("handleTrigger: error queuing workflow", "err", err)
 			}
@@ -302,6 +451,63 @@ func (o *Orchestrator) handleTrigger(ctx context.Context, e event.Event) error {
 	return nil
 }
 
+// recoverRuns restores runs left in a persisted run queue by a previous
+// process. Queued runs are pushed back onto the queue. Runs that were executing
+// when the process stopped are marked as failed, and re-queued as fresh runs
+// when requeueInFlight is true. Applies write output to a caller that no longer
+// exists, so they are dropped
+func (o *Orchestrator) recoverRuns(ctx context.Context, rq RecoverableRunQueue, requeueInFlight bool) {
+	queued, inFlight := rq.Recover()
+	for _, e := range inFlight {
+		if e.Mode == "run" {
+			if err := o.failRun(ctx, workflow.ID(e.WorkflowID), e.RunID, "run interrupted by shutdown"); err != nil {
+				log.Debugw("recoverRuns: marking interrupted run as failed", "runID", e.RunID, "error", err)
+			}
+		}
+		if requeueInFlight {
+			e.RunID = run.NewID()
+			e.Running = false
+			queued = append(queued, e)
+		}
+	}
+	for _, e := range queued {
+		if e.Mode != "run" {
+			log.Debugw("recoverRuns: dropping queued entry that can't be recovered", "runID", e.RunID, "mode", e.Mode)
+			continue
+		}
+		wf, err := o.workflows.Get(ctx, workflow.ID(e.WorkflowID))
+		if err != nil {
+			log.Debugw("recoverRuns: fetching workflow", "workflowID", e.WorkflowID, "error", err)
+			continue
+		}
+		if err := rq.Push(ctx, e.OwnerID, e.WorkflowID, e.RunID, e.Mode, o.runWorkflowFactory(wf, e.RunID)); err != nil {
+			log.Debugw("recoverRuns: queuing recovered run", "runID", e.RunID, "error", err)
+		}
+	}
+	// recovered runs stay in the queue file until they've been re-queued
+	if err := rq.ClearRecovered(); err != nil {
+		log.Debugw("recoverRuns: clearing recovered runs", "error", err)
+	}
+}
+
+// failRun records a run as failed in the run store, creating the run if it
+// doesn't exist
+func (o *Orchestrator) failRun(ctx context.Context, wid workflow.ID, runID, message string) error {
+	r, err := o.runs.Get(ctx, runID)
+	if errors.Is(err, run.ErrNotFound) {
+		r, err = o.runs.Create(ctx, &run.State{ID: runID, WorkflowID: wid})
+	}
+	if err != nil {
+		return err
+	}
+	r = r.Copy()
+	r.Status = run.RSFailed
+	r.Message = message
+	r.StopTime = NowFunc()
+	_, err = o.runs.Put(ctx, r)
+	return err
+}
+
 func (o *Orchestrator) runWorkflowFactory(wf *workflow.Workflow, runID string) runQueueFunc {
 	return func(ctx context.Context) error {
 		return o.runWorkflow(ctx, wf, runID)
@@ -318,13 +524,22 @@ func (o *Orchestrator) RunWorkflow(ctx context.Context, wid workflow.ID, runID s
 		return "", err
 	}
 
+	// open the run event stream before queuing so the run can be followed
+	// while it waits to start
+	stream := o.streams.open(runID, wf.OwnerID)
 	runFunc := o.runWorkflowFactory(wf, runID)
-	return runID, o.runQueue.Push(ctx, wf.OwnerID.Encode(), runID, "run", runFunc)
+	if err := o.runQueue.Push(ctx, wf.OwnerID.Encode(), wf.ID.String(), runID, "run", runFunc); err != nil {
+		stream.finish()
+		return runID, err
+	}
+	return runID, nil
 }
 
 func (o *Orchestrator) runWorkflow(ctx context.Context, wf *workflow.Workflow, runID string) error {
 	wid := wf.ID
 	log.Debugw("runWorkflow, workflow", "id", wid)
+	stream := o.streams.open(runID, wf.OwnerID)
+	defer stream.finish()
 
 	go func(wf *workflow.Workflow) {
 		if err := o.bus.PublishID(ctx, event.ETAutomationWorkflowStarted, wf.ID.String(), event.WorkflowStartedEvent{
@@ -338,49 +553,236 @@ func (o *Orchestrator) runWorkflow(ctx context.Context, wf *workflow.Workflow, r
 	}(wf)
 
 	if o.runs != nil {
-		r := &run.State{ID: runID, WorkflowID: wid}
+		r := &run.State{ID: runID, WorkflowID: wid, OwnerID: wf.OwnerID}
 		if _, err := o.runs.Create(ctx, r); err != nil {
 			return err
 		}
+	}
 
-		handler := runEventsHandler(o.runs)
-		o.bus.SubscribeID(handler, runID)
-		// TODO (b5): event bus needs an unsubscribe mechanism
-		// defer o.bus.UnsubscribeID(runID)
+	params := WorkflowRunParams{}
+	if o.secrets != nil {
+		var err error
+		if params.Secrets, err = o.secrets.Secrets(ctx, wid); err != nil {
+			err = fmt.Errorf("loading workflow secrets: %w", err)
+			if o.runs != nil {
+				if ferr := o.failRun(ctx, wid, runID, err.Error()); ferr != nil {
+					log.Debugw("runWorkflow: recording failed run", "runID", runID, "error", ferr)
+				}
+			}
+			go o.publishWorkflowStopped(ctx, wf, runID, run.RSFailed)
+			return err
+		}
 	}
 
+	// secrets are redacted from events before they're written to the run
+	// store, so scripts that print secrets don't persist them
+	handler := runEventsHandler(o.runs, params.Secrets, stream)
+	o.bus.SubscribeID(handler, runID)
+	defer o.bus.UnsubscribeID(runID)
+
 	// need to replace w/ log collector
 	streams := ioes.NewDiscardIOStreams()
 
-	// TODO(dustmop): Retrieve params from enqueued run, pass them into RunAndCommit
-	err := o.runner.RunAndCommit(ctx, runID, wf, streams, WorkflowRunParams{})
+	o.trackCommit(runID)
+	err := o.runAndCommit(ctx, wf, runID, streams, params)
+	commit := o.popCommit(runID)
 	go func(wf *workflow.Workflow) {
-		runStatus := run.RSFailed
-		if err == nil {
-			runStatus = run.RSSucceeded
-		}
-		if errors.Is(err, dsfs.ErrNoChanges) {
-			runStatus = run.RSUnchanged
-		}
-		if err := o.bus.PublishID(ctx, event.ETAutomationWorkflowStopped, wf.ID.String(), event.WorkflowStoppedEvent{
-			InitID:     wf.InitID,
-			OwnerID:    wf.OwnerID,
-			WorkflowID: wf.WorkflowID(),
+		runStatus := runStatusFromError(err)
+		o.publishWorkflowStopped(ctx, wf, runID, runStatus)
+
+		p := hook.WebhookPayload{
 			RunID:      runID,
+			WorkflowID: wf.WorkflowID(),
+			InitID:     wf.InitID,
 			Status:     string(runStatus),
-		}); err != nil {
-			log.Debug(err)
 		}
+		if commit != nil {
+			p.Ref = commit.SimpleRef().Human()
+			p.CommitPath = commit.Path
+		}
+		o.runHooks(ctx, wf, params.Secrets, p)
 	}(wf)
 
-	// TODO (ramfox): when hooks/completors are added, this should wait for the err, iterate through the hooks
-	// for this workflow, and emit the events for hooks that this orchestrator understands
+	return err
+}
+
+// publishWorkflowStopped announces that a run of a workflow has stopped
+func (o *Orchestrator) publishWorkflowStopped(ctx context.Context, wf *workflow.Workflow, runID string, status run.Status) {
+	if err := o.bus.PublishID(ctx, event.ETAutomationWorkflowStopped, wf.ID.String(), event.WorkflowStoppedEvent{
+		InitID:     wf.InitID,
+		OwnerID:    wf.OwnerID,
+		WorkflowID: wf.WorkflowID(),
+		RunID:      runID,
+		Status:     string(status),
+	}); err != nil {
+		log.Debug(err)
+	}
+}
+
+// runAndCommit runs a workflow, retrying failed attempts according to the
+// workflow's RetryPolicy. Runs that exceed the workflow's Timeout are canceled
+func (o *Orchestrator) runAndCommit(ctx context.Context, wf *workflow.Workflow, runID string, streams ioes.IOStreams, params WorkflowRunParams) error {
+	timedOut := make(chan struct{})
+	if wf.Timeout > 0 {
+		timer := time.AfterFunc(wf.Timeout, func() {
+			log.Debugw("runAndCommit: run exceeded timeout", "runID", runID, "timeout", wf.Timeout)
+			close(timedOut)
+			o.CancelRun(ctx, runID)
+		})
+		defer timer.Stop()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = o.runner.RunAndCommit(ctx, runID, wf, streams, params)
+		status := runStatusFromError(err)
+		if ctx.Err() != nil || !wf.RetryPolicy.ShouldRetry(attempt, string(status)) {
+			break
+		}
+		backoff := wf.RetryPolicy.BackoffDuration(attempt)
+		log.Debugw("runAndCommit: retrying run", "runID", runID, "attempt", attempt, "status", status, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	select {
+	case <-timedOut:
+		err = fmt.Errorf("%w: exceeded %s", ErrRunTimedOut, wf.Timeout)
+		if o.runs != nil {
+			if ferr := o.failRun(context.Background(), wf.ID, runID, err.Error()); ferr != nil {
+				log.Debugw("runAndCommit: recording timed out run", "runID", runID, "error", ferr)
+			}
+		}
+	default:
+	}
+	return err
+}
+
+// runStatusFromError returns the status of a run that finished with the given
+// error
+func runStatusFromError(err error) run.Status {
+	if err == nil {
+		return run.RSSucceeded
+	}
+	if errors.Is(err, dsfs.ErrNoChanges) {
+		return run.RSUnchanged
+	}
+	if errors.Is(err, startf.ErrLimitExceeded) {
+		return run.RSLimitExceeded
+	}
+	return run.RSFailed
+}
+
+// trackCommit marks a run as in-flight, so the dataset version it commits can
+// be recorded
+func (o *Orchestrator) trackCommit(runID string) {
+	o.commitsLk.Lock()
+	defer o.commitsLk.Unlock()
+	o.commits[runID] = nil
+}
+
+// popCommit returns the dataset version committed by the given run, if any,
+// and stops tracking the run
+func (o *Orchestrator) popCommit(runID string) *dsref.VersionInfo {
+	o.commitsLk.Lock()
+	defer o.commitsLk.Unlock()
+	vi := o.commits[runID]
+	delete(o.commits, runID)
+	return vi
+}
+
+// handleCommit records the dataset version written by an in-flight run
+func (o *Orchestrator) handleCommit(ctx context.Context, e event.Event) error {
+	vi, ok := e.Payload.(dsref.VersionInfo)
+	if !ok || vi.RunID == "" {
+		return nil
+	}
+	o.commitsLk.Lock()
+	defer o.commitsLk.Unlock()
+	if _, ok := o.commits[vi.RunID]; ok {
+		o.commits[vi.RunID] = &vi
+	}
+	return nil
+}
+
+// runHooks delivers the given payload for each enabled hook in the workflow
+// that reaches outside of the qri process. Webhook signing secrets are read
+// from the workflow's secrets. Delivery attempts are recorded in the run store
+func (o *Orchestrator) runHooks(ctx context.Context, wf *workflow.Workflow, wfSecrets map[string]string, p hook.WebhookPayload) {
+	for _, opts := range wf.Hooks {
+		h, err := hook.NewHook(opts)
+		if err != nil {
+			log.Debugw("runHooks: error constructing hook", "workflowID", wf.ID, "error", err)
+			continue
+		}
+		wh, ok := h.(*hook.WebhookHook)
+		if !ok || !wh.Enabled() {
+			continue
+		}
+		if wh.SecretName != "" {
+			secret, ok := wfSecrets[wh.SecretName]
+			if !ok {
+				log.Debugw("runHooks: webhook secret not found", "runID", p.RunID, "url", wh.URL, "secretName", wh.SecretName)
+				failed := &run.HookDelivery{
+					HookType:  wh.Type(),
+					URL:       wh.URL,
+					Attempt:   1,
+					Timestamp: NowFunc(),
+					Error:     fmt.Sprintf("webhook secret %q not found", wh.SecretName),
+				}
+				if err := o.addHookDeliveries(ctx, p.RunID, []*run.HookDelivery{failed}); err != nil {
+					log.Debugw("runHooks: recording hook deliveries", "runID", p.RunID, "error", err)
+				}
+				continue
+			}
+			wh.Secret = secret
+		}
+		wh.SetPayload(p)
+		go func(wh *hook.WebhookHook) {
+			deliveries, err := wh.Deliver(ctx, o.hookClient)
+			if err != nil {
+				log.Debugw("runHooks: webhook delivery", "runID", p.RunID, "url", wh.URL, "error", err)
+			}
+			if err := o.addHookDeliveries(ctx, p.RunID, deliveries); err != nil {
+				log.Debugw("runHooks: recording hook deliveries", "runID", p.RunID, "error", err)
+			}
+		}(wh)
+	}
+}
+
+// addHookDeliveries writes hook delivery attempts to the run store. Stores
+// that can add deliveries atomically are used directly so deliveries don't
+// race with run events being written to the same run
+func (o *Orchestrator) addHookDeliveries(ctx context.Context, runID string, deliveries []*run.HookDelivery) error {
+	if o.runs == nil || len(deliveries) == 0 {
+		return nil
+	}
+	if adder, ok := o.runs.(run.HookDeliveryAdder); ok {
+		return adder.AddHookDeliveries(runID, deliveries...)
+	}
+	o.hooksLk.Lock()
+	defer o.hooksLk.Unlock()
+	r, err := o.runs.Get(ctx, runID)
+	if err != nil {
+		return err
+	}
+	r = r.Copy()
+	for _, d := range deliveries {
+		r.AddHookDelivery(d)
+	}
+	_, err = o.runs.Put(ctx, r)
 	return err
 }
 
 // ApplyWorkflow runs the given workflow, but does not record the output
 func (o *Orchestrator) ApplyWorkflow(ctx context.Context, wait bool, scriptOutput io.Writer, wf *workflow.Workflow, ds *dataset.Dataset, params WorkflowRunParams) (string, error) {
 	runID := run.NewID()
+	stream := o.streams.open(runID, wf.OwnerID)
 	if wait {
 		return runID, o.applyWorkflow(ctx, scriptOutput, wf, ds, runID, params)
 	}
@@ -389,11 +791,36 @@ func (o *Orchestrator) ApplyWorkflow(ctx context.Context, wait bool, scriptOutpu
 	runFunc := func(ctx context.Context) error {
 		return o.applyWorkflow(ctx, scriptOutput, wf, ds, runID, params)
 	}
-	return runID, o.runQueue.Push(ctx, wf.OwnerID.Encode(), runID, "apply", runFunc)
+	if err := o.runQueue.Push(ctx, wf.OwnerID.Encode(), wf.ID.String(), runID, "apply", runFunc); err != nil {
+		stream.finish()
+		return runID, err
+	}
+	return runID, nil
 }
 
 func (o *Orchestrator) applyWorkflow(ctx context.Context, scriptOutput io.Writer, wf *workflow.Workflow, ds *dataset.Dataset, runID string, params WorkflowRunParams) error {
 	log.Debugw("ApplyWorkflow", "workflow id", wf.ID, "run id", runID)
+	stream := o.streams.open(runID, wf.OwnerID)
+	defer stream.finish()
+
+	// applied runs are only stored when recording, otherwise they're only
+	// recorded for followers
+	var store run.Store
+	if params.Record && o.runs != nil {
+		r := &run.State{ID: runID, WorkflowID: wf.ID, OwnerID: wf.OwnerID, InitID: ds.ID}
+		if r.WorkflowID == "" {
+			// ephemeral workflows aren't stored & have no ID, give the run
+			// one so it can be stored
+			r.WorkflowID = workflow.NewID()
+		}
+		if _, err := o.runs.Create(ctx, r); err != nil {
+			return err
+		}
+		store = o.runs
+	}
+	o.bus.SubscribeID(runEventsHandler(store, params.Secrets, stream), runID)
+	defer o.bus.UnsubscribeID(runID)
+
 	if scriptOutput != nil {
 		o.bus.SubscribeID(func(ctx context.Context, e event.Event) error {
 			log.Debugw("apply transform event", "type", e.Type, "payload", e.Payload)
@@ -407,7 +834,6 @@ func (o *Orchestrator) applyWorkflow(ctx context.Context, scriptOutput io.Writer
 			}
 			return nil
 		}, runID)
-		// TODO (ramfox): defer unsubscribe to id
 	}
 
 	// TODO (ramfox): when we understand what it means to dryrun a hook, this should wait for the err, iterator thought the hooks
@@ -415,15 +841,89 @@ func (o *Orchestrator) applyWorkflow(ctx context.Context, scriptOutput io.Writer
 	return o.runner.RunEphemeral(ctx, runID, wf, ds, true, params)
 }
 
+// FollowRun calls fn with each followed event of a run owned by ownerID,
+// starting after the event numbered lastEventID. Events the run has already
+// emitted are sent first. FollowRun returns when the run finishes, fn returns
+// an error, or the context is done. Runs whose events are no longer kept in
+// memory are read from the run store
+func (o *Orchestrator) FollowRun(ctx context.Context, runID string, ownerID profile.ID, lastEventID int, fn func(RunEvent) error) error {
+	stream, ok := o.streams.get(runID)
+	if !ok {
+		return o.followStoredRun(ctx, runID, ownerID, lastEventID, fn)
+	}
+	if stream.ownerID != ownerID {
+		return ErrRunEventsNotFound
+	}
+	return stream.follow(ctx, lastEventID, fn)
+}
+
+// followStoredRun calls fn with the reconstructed events of a stored run
+func (o *Orchestrator) followStoredRun(ctx context.Context, runID string, ownerID profile.ID, lastEventID int, fn func(RunEvent) error) error {
+	if o.runs == nil {
+		return ErrRunEventsNotFound
+	}
+	r, err := o.runs.Get(ctx, runID)
+	if errors.Is(err, run.ErrNotFound) {
+		return ErrRunEventsNotFound
+	} else if err != nil {
+		return err
+	}
+
+	owner := r.OwnerID
+	if owner == "" {
+		// runs stored before owners were recorded are owned by their workflow
+		if wf, err := o.GetWorkflow(ctx, r.WorkflowID); err == nil {
+			owner = wf.OwnerID
+		}
+	}
+	if owner == "" || owner != ownerID {
+		return ErrRunEventsNotFound
+	}
+
+	for i, e := range storedRunEvents(r) {
+		if i < lastEventID {
+			continue
+		}
+		if err := fn(RunEvent{ID: i + 1, Event: e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CancelRun cancels the run of the given runID
 func (o *Orchestrator) CancelRun(ctx context.Context, runID string) {
 	log.Debugw("orchestrator.CancelRun", "runID", runID)
 	o.runQueue.Cancel(runID)
 }
 
+// checkTransformLeaks errors if static analysis finds the workflow's transform
+// may leak sensitive data
+func (o *Orchestrator) checkTransformLeaks(ctx context.Context, wf *workflow.Workflow) error {
+	if o.analyzer == nil {
+		return nil
+	}
+	diags, err := o.analyzer.AnalyzeWorkflowTransform(ctx, wf)
+	if err != nil {
+		return fmt.Errorf("analyzing transform: %w", err)
+	}
+	leaks := staticlark.Leaks(diags)
+	if len(leaks) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(leaks))
+	for i, d := range leaks {
+		msgs[i] = fmt.Sprintf("%s: %s", d.Pos, d.Message)
+	}
+	return fmt.Errorf("%w:\n%s", ErrTransformLeaksSecrets, strings.Join(msgs, "\n"))
+}
+
 // SaveWorkflow creates a new workflow if the workflow id is empty, or updates
 // an existing workflow in the workflow Store
 func (o *Orchestrator) SaveWorkflow(ctx context.Context, wf *workflow.Workflow) (*workflow.Workflow, error) {
+	// prevWF is the stored version of an existing workflow, restored if saving
+	// fails part way through
+	var prevWF *workflow.Workflow
 	if wf.ID != "" {
 		fetchedWF, err := o.workflows.Get(ctx, wf.ID)
 		if errors.Is(err, workflow.ErrNotFound) {
@@ -439,6 +939,7 @@ func (o *Orchestrator) SaveWorkflow(ctx context.Context, wf *workflow.Workflow)
 		if wf.Created == nil || !fetchedWF.Created.Equal(*wf.Created) {
 			return nil, fmt.Errorf("SaveWorkflow error: given workflow %q has a different Created time than the workflow on record", wf.ID)
 		}
+		prevWF = fetchedWF.Copy()
 	}
 	triggers := []map[string]interface{}{}
 	for _, opt := range wf.Triggers {
@@ -457,19 +958,31 @@ func (o *Orchestrator) SaveWorkflow(ctx context.Context, wf *workflow.Workflow)
 		triggers = append(triggers, t.ToMap())
 	}
 	wf.Triggers = triggers
-	// TODO (ramfox): when we add hooks in a follow up, this function should receive HookrOptions as a param
-
-	// it should iterate over the hooks this orchestrator understands, and err if the workflow references
-	// any that it doesn't know about
+	if err := o.checkDatasetTriggerCycle(ctx, wf); err != nil {
+		return nil, fmt.Errorf("SaveWorkflow error: %w", err)
+	}
+	hookSecrets, err := o.extractHookSecrets(wf)
+	if err != nil {
+		return nil, fmt.Errorf("SaveWorkflow error: %w", err)
+	}
+	if err := o.checkTransformLeaks(ctx, wf); err != nil {
+		return nil, fmt.Errorf("SaveWorkflow error: %w", err)
+	}
 
 	isNewWF := wf.ID == ""
 	if isNewWF {
 		wf.Created = NowFunc()
 	}
-	wf, err := o.workflows.Put(ctx, wf)
+	wf, err = o.workflows.Put(ctx, wf)
 	if err != nil {
 		return nil, err
 	}
+	for name, value := range hookSecrets {
+		if err := o.secrets.Set(ctx, wf.ID, name, value); err != nil {
+			o.rollbackSaveWorkflow(ctx, wf.ID, prevWF)
+			return nil, fmt.Errorf("SaveWorkflow error: storing hook secret: %w", err)
+		}
+	}
 	if isNewWF {
 		go func() {
 			if err := o.bus.Publish(ctx, event.ETAutomationWorkflowCreated, *wf); err != nil {
@@ -481,6 +994,113 @@ func (o *Orchestrator) SaveWorkflow(ctx context.Context, wf *workflow.Workflow)
 	return wf, err
 }
 
+// rollbackSaveWorkflow undoes writing a workflow to the store. New workflows
+// are removed along with any secrets stored for them, existing workflows are
+// restored to prev
+func (o *Orchestrator) rollbackSaveWorkflow(ctx context.Context, wid workflow.ID, prev *workflow.Workflow) {
+	if prev != nil {
+		if _, err := o.workflows.Put(ctx, prev); err != nil {
+			log.Errorw("restoring workflow", "workflowID", wid, "error", err)
+		}
+		return
+	}
+	if err := o.workflows.Remove(ctx, wid); err != nil {
+		log.Errorw("removing workflow", "workflowID", wid, "error", err)
+	}
+	if o.secrets != nil {
+		if err := o.secrets.RemoveAll(ctx, wid); err != nil {
+			log.Errorw("removing workflow secrets", "workflowID", wid, "error", err)
+		}
+	}
+}
+
+// extractHookSecrets constructs the hooks of a workflow, replacing webhook
+// signing secrets with the name of a workflow secret so they aren't written to
+// the workflow store. Extracted secret values are returned by name
+func (o *Orchestrator) extractHookSecrets(wf *workflow.Workflow) (map[string]string, error) {
+	extracted := map[string]string{}
+	hooks := make([]map[string]interface{}, 0, len(wf.Hooks))
+	for i, opt := range wf.Hooks {
+		h, err := hook.NewHook(opt)
+		if err != nil {
+			return nil, fmt.Errorf("constructing hook: %w", err)
+		}
+		wh, ok := h.(*hook.WebhookHook)
+		if !ok || wh.Secret == "" {
+			hooks = append(hooks, opt)
+			continue
+		}
+		if o.secrets == nil {
+			return nil, fmt.Errorf("storing webhook secret: %w", ErrNoSecretStore)
+		}
+		if wh.SecretName == "" {
+			wh.SecretName = fmt.Sprintf("webhook_%d_secret", i)
+		}
+		if err := secrets.ValidateName(wh.SecretName); err != nil {
+			return nil, err
+		}
+		extracted[wh.SecretName] = wh.Secret
+
+		data, err := json.Marshal(wh)
+		if err != nil {
+			return nil, err
+		}
+		opt = map[string]interface{}{}
+		if err := json.Unmarshal(data, &opt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, opt)
+	}
+	wf.Hooks = hooks
+	return extracted, nil
+}
+
+// checkDatasetTriggerCycle errors if saving the given workflow would create a
+// cycle in the graph of datasets whose new versions trigger each other's
+// workflows
+func (o *Orchestrator) checkDatasetTriggerCycle(ctx context.Context, wf *workflow.Workflow) error {
+	deps := trigger.DatasetTriggerDependencies(wf.Triggers)
+	if len(deps) == 0 {
+		return nil
+	}
+	wfs, err := o.workflows.List(ctx, "", params.ListAll)
+	if err != nil {
+		return err
+	}
+
+	// upstream maps a dataset InitID to the InitIDs of the datasets that
+	// trigger the dataset's workflow
+	upstream := map[string][]string{}
+	for _, w := range wfs {
+		if w.ID == wf.ID {
+			continue
+		}
+		upstream[w.InitID] = trigger.DatasetTriggerDependencies(w.Triggers)
+	}
+	upstream[wf.InitID] = deps
+
+	// walk upstream from the workflow's dataset. any path that leads back to
+	// the workflow's dataset is a cycle
+	visited := map[string]bool{}
+	var walk func(initID string, path []string) error
+	walk = func(initID string, path []string) error {
+		for _, dep := range upstream[initID] {
+			if dep == wf.InitID {
+				return fmt.Errorf("%w: %s", ErrDatasetTriggerCycle, strings.Join(append(path, dep), " <- "))
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if err := walk(dep, append(path, dep)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(wf.InitID, []string{wf.InitID})
+}
+
 // GetWorkflow fetches an existing workflow from the WorkflowStore
 func (o *Orchestrator) GetWorkflow(ctx context.Context, id workflow.ID) (*workflow.Workflow, error) {
 	return o.workflows.Get(ctx, id)
@@ -501,6 +1121,11 @@ func (o *Orchestrator) RemoveWorkflow(ctx context.Context, id workflow.ID) error
 	if err := o.workflows.Remove(ctx, id); err != nil {
 		return err
 	}
+	if o.secrets != nil {
+		if err := o.secrets.RemoveAll(ctx, id); err != nil {
+			log.Debugw("removing workflow secrets", "workflowID", id, "err", err)
+		}
+	}
 	go func() {
 		if err := o.bus.Publish(ctx, event.ETAutomationWorkflowRemoved, *wf); err != nil {
 			log.Debug(err)
@@ -515,9 +1140,66 @@ func (o *Orchestrator) RunInfo(ctx context.Context, id string) (*run.State, erro
 	return o.runs.Get(ctx, id)
 }
 
-// runEventsHandler returns a handler that writes run events to a run store
-func runEventsHandler(store run.Store) event.Handler {
+// SetRunHTTPFixtures stores the HTTP requests recorded by a run
+func (o *Orchestrator) SetRunHTTPFixtures(ctx context.Context, runID string, fixtures *event.HTTPFixtures) error {
+	if o.runs == nil {
+		return nil
+	}
+	r, err := o.runs.Get(ctx, runID)
+	if err != nil {
+		return err
+	}
+	r = r.Copy()
+	r.HTTPFixtures = fixtures
+	_, err = o.runs.Put(ctx, r)
+	return err
+}
+
+// SetWorkflowSecret stores a secret that is injected into runs of a workflow
+func (o *Orchestrator) SetWorkflowSecret(ctx context.Context, wid workflow.ID, name, value string) error {
+	if o.secrets == nil {
+		return ErrNoSecretStore
+	}
+	if _, err := o.workflows.Get(ctx, wid); err != nil {
+		return err
+	}
+	return o.secrets.Set(ctx, wid, name, value)
+}
+
+// WorkflowSecretNames lists the names of the secrets stored for a workflow
+func (o *Orchestrator) WorkflowSecretNames(ctx context.Context, wid workflow.ID) ([]string, error) {
+	if o.secrets == nil {
+		return nil, ErrNoSecretStore
+	}
+	return o.secrets.Names(ctx, wid)
+}
+
+// WorkflowSecrets returns the secrets stored for a workflow
+func (o *Orchestrator) WorkflowSecrets(ctx context.Context, wid workflow.ID) (map[string]string, error) {
+	if o.secrets == nil {
+		return map[string]string{}, nil
+	}
+	return o.secrets.Secrets(ctx, wid)
+}
+
+// RemoveWorkflowSecret deletes a secret stored for a workflow
+func (o *Orchestrator) RemoveWorkflowSecret(ctx context.Context, wid workflow.ID, name string) error {
+	if o.secrets == nil {
+		return ErrNoSecretStore
+	}
+	return o.secrets.Remove(ctx, wid, name)
+}
+
+// runEventsHandler returns a handler that writes run events to a run store &
+// records them for followers of the run, redacting secret values from script
+// output. store may be nil for runs that aren't stored
+func runEventsHandler(store run.Store, secrets map[string]string, stream *runStream) event.Handler {
 	return func(ctx context.Context, e event.Event) error {
+		e = redactSecrets(e, secrets)
+		stream.add(e)
+		if store == nil {
+			return nil
+		}
 		if adder, ok := store.(run.EventAdder); ok {
 			return adder.AddEvent(e.SessionID, e)
 		}
@@ -535,6 +1217,18 @@ func runEventsHandler(store run.Store) event.Handler {
 	}
 }
 
+// redactSecrets replaces secret values in transform messages. Transformers
+// redact messages before publishing them, this guards the run store against
+// runners that don't
+func redactSecrets(e event.Event, secrets map[string]string) event.Event {
+	msg, ok := e.Payload.(event.TransformMessage)
+	if !ok || len(secrets) == 0 {
+		return e
+	}
+	e.Payload = msg.RedactSecrets(secrets)
+	return e
+}
+
 func (o *Orchestrator) updateListeners(sources ...trigger.Source) {
 	if !o.running {
 		return
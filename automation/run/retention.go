@@ -0,0 +1,133 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// NowFunc returns a pointer to the current time. Can be overridden in
+// tests to create determinism
+var NowFunc = func() *time.Time {
+	now := time.Now()
+	return &now
+}
+
+// RetentionPolicy determines which runs a Store keeps, and how much output each
+// run step keeps. The zero value keeps everything
+type RetentionPolicy struct {
+	// MaxRunsPerWorkflow is the number of most recent runs kept for each
+	// workflow. Zero keeps all runs
+	MaxRunsPerWorkflow int
+	// MaxAge drops runs that stopped longer than MaxAge ago. Runs that haven't
+	// stopped are never dropped for age. Zero keeps runs of any age
+	MaxAge time.Duration
+	// MaxOutputSize is the maximum number of bytes of output log kept for each
+	// run step. Older output is dropped first. Zero keeps all output
+	MaxOutputSize int64
+}
+
+// IsZero returns true if the policy keeps everything
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxRunsPerWorkflow <= 0 && p.MaxAge <= 0 && p.MaxOutputSize <= 0
+}
+
+// RunCompactor calls Compact on the store every interval until the context is
+// canceled. Zero value policies are ignored
+func RunCompactor(ctx context.Context, s Store, p RetentionPolicy, interval time.Duration) {
+	if p.IsZero() || interval <= 0 {
+		return
+	}
+	for {
+		select {
+		case <-time.After(interval):
+			if err := s.Compact(ctx, p); err != nil {
+				log.Debugw("compacting run store", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// keep returns true if the policy retains a run that has newerRuns runs of the
+// same workflow created after it
+func (p RetentionPolicy) keep(r *State, newerRuns int, now time.Time) bool {
+	if newerRuns == 0 {
+		return true
+	}
+	if p.MaxRunsPerWorkflow > 0 && newerRuns >= p.MaxRunsPerWorkflow {
+		return false
+	}
+	if p.MaxAge > 0 && r.StopTime != nil && now.Sub(*r.StopTime) > p.MaxAge {
+		return false
+	}
+	return true
+}
+
+// truncateOutput returns a copy of the run with the output of each step
+// truncated to fit the policy, and true if any output was dropped
+func (p RetentionPolicy) truncateOutput(r *State) (*State, bool) {
+	if p.MaxOutputSize <= 0 {
+		return r, false
+	}
+	truncated := false
+	steps := func(steps []*StepState) []*StepState {
+		res := make([]*StepState, len(steps))
+		for i, s := range steps {
+			res[i] = s
+			if t, ok := p.truncateStepOutput(s); ok {
+				res[i] = t
+				truncated = true
+			}
+		}
+		return res
+	}
+
+	r = r.Copy()
+	r.Steps = steps(r.Steps)
+	attempts := make([]*AttemptState, len(r.Attempts))
+	for i, a := range r.Attempts {
+		cp := *a
+		cp.Steps = steps(a.Steps)
+		attempts[i] = &cp
+	}
+	if len(attempts) > 0 {
+		r.Attempts = attempts
+	}
+	return r, truncated
+}
+
+// truncateStepOutput drops the oldest output from a step until it fits within
+// MaxOutputSize
+func (p RetentionPolicy) truncateStepOutput(s *StepState) (*StepState, bool) {
+	var size int64
+	keepFrom := len(s.Output)
+	for i := len(s.Output) - 1; i >= 0; i-- {
+		size += outputSize(s.Output[i])
+		if size > p.MaxOutputSize {
+			break
+		}
+		keepFrom = i
+	}
+	if keepFrom == 0 {
+		return s, false
+	}
+
+	var dropped int64
+	for _, e := range s.Output[:keepFrom] {
+		dropped += outputSize(e)
+	}
+	cp := s.Copy()
+	cp.Output = s.Output[keepFrom:]
+	cp.TruncatedOutput += dropped
+	return cp, true
+}
+
+func outputSize(e interface{}) int64 {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/qri/automation/workflow"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
 )
 
 var (
@@ -61,6 +62,9 @@ const (
 	RSUnchanged = Status("unchanged")
 	// RSSkipped indicates a script/step was not executed
 	RSSkipped = Status("skipped")
+	// RSLimitExceeded indicates a script/step was halted for exceeding a
+	// resource limit, such as execution steps or wall clock time
+	RSLimitExceeded = Status("limit_exceeded")
 )
 
 // State is a passable, cachable data structure that describes the execution of
@@ -76,6 +80,21 @@ type State struct {
 	StopTime   *time.Time   `json:"stopTime"`
 	Duration   int64        `json:"duration"`
 	Steps      []*StepState `json:"steps"`
+	// HookDeliveries records every attempt to deliver a hook payload for this
+	// run, in the order attempts were made
+	HookDeliveries []*HookDelivery `json:"hookDeliveries,omitempty"`
+	// Attempts records previous attempts of a run that was retried, in the
+	// order they were made. The fields of State describe the latest attempt
+	Attempts []*AttemptState `json:"attempts,omitempty"`
+	// InitID is the dataset the run applied a transform to, set for runs that
+	// aren't associated with a stored workflow
+	InitID string `json:"initID,omitempty"`
+	// OwnerID is the profile that started the run
+	OwnerID profile.ID `json:"ownerID,omitempty"`
+	// HTTPFixtures records the HTTP requests the transform made & the
+	// responses it received, used to replay the run offline. Only set for
+	// runs that opted in to recording
+	HTTPFixtures *event.HTTPFixtures `json:"httpFixtures,omitempty"`
 }
 
 // NewState returns a new *State with the given runID
@@ -109,10 +128,33 @@ func (rs *State) Copy() *State {
 		StopTime:   rs.StopTime,
 		Duration:   rs.Duration,
 		Steps:      rs.Steps,
+
+		HookDeliveries: rs.HookDeliveries,
+		Attempts:       rs.Attempts,
+		InitID:         rs.InitID,
+		OwnerID:        rs.OwnerID,
+		HTTPFixtures:   rs.HTTPFixtures,
 	}
 	return run
 }
 
+// AddHookDelivery appends a hook delivery attempt to the run
+func (rs *State) AddHookDelivery(d *HookDelivery) {
+	rs.HookDeliveries = append(rs.HookDeliveries, d)
+}
+
+// FailedHookDeliveries returns all hook delivery attempts for this run that
+// did not succeed
+func (rs *State) FailedHookDeliveries() []*HookDelivery {
+	failed := []*HookDelivery{}
+	for _, d := range rs.HookDeliveries {
+		if !d.Succeeded() {
+			failed = append(failed, d)
+		}
+	}
+	return failed
+}
+
 // AddTransformEvent alters state based on a given event
 func (rs *State) AddTransformEvent(e event.Event) error {
 	if rs.ID != e.SessionID {
@@ -122,6 +164,11 @@ func (rs *State) AddTransformEvent(e event.Event) error {
 
 	switch e.Type {
 	case event.ETTransformStart:
+		if rs.StartTime != nil {
+			// a run that has already started is being retried, move the
+			// previous attempt into the attempt history
+			rs.archiveAttempt()
+		}
 		rs.Status = RSRunning
 		rs.StartTime = toTimePointer(e.Timestamp)
 		return nil
@@ -176,6 +223,23 @@ func (rs *State) AddTransformEvent(e event.Event) error {
 	return fmt.Errorf("unexpected event type: %q", e.Type)
 }
 
+func (rs *State) archiveAttempt() {
+	rs.Attempts = append(rs.Attempts, &AttemptState{
+		Number:    len(rs.Attempts) + 1,
+		Status:    rs.Status,
+		Message:   rs.Message,
+		StartTime: rs.StartTime,
+		StopTime:  rs.StopTime,
+		Duration:  rs.Duration,
+		Steps:     rs.Steps,
+	})
+	rs.Message = ""
+	rs.StopTime = nil
+	rs.Duration = 0
+	rs.Steps = nil
+	rs.HTTPFixtures = nil
+}
+
 func (rs *State) lastStep() (*StepState, error) {
 	if len(rs.Steps) > 0 {
 		return rs.Steps[len(rs.Steps)-1], nil
@@ -202,6 +266,9 @@ type StepState struct {
 	StopTime  *time.Time    `json:"stopTime"`
 	Duration  int64         `json:"duration"`
 	Output    []event.Event `json:"output"`
+	// TruncatedOutput is the number of bytes of output dropped from the start
+	// of Output by run store compaction
+	TruncatedOutput int64 `json:"truncatedOutput,omitempty"`
 }
 
 // Copy returns a shallow copy of the receiver
@@ -214,9 +281,39 @@ func (ss *StepState) Copy() *StepState {
 		StopTime:  ss.StopTime,
 		Duration:  ss.Duration,
 		Output:    ss.Output,
+
+		TruncatedOutput: ss.TruncatedOutput,
 	}
 }
 
+// AttemptState describes a single attempt of a run that was retried
+type AttemptState struct {
+	Number    int          `json:"number"`
+	Status    Status       `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	StartTime *time.Time   `json:"startTime"`
+	StopTime  *time.Time   `json:"stopTime"`
+	Duration  int64        `json:"duration"`
+	Steps     []*StepState `json:"steps"`
+}
+
+// HookDelivery describes a single attempt to deliver a hook payload to a
+// destination outside of the qri process
+type HookDelivery struct {
+	HookType   string     `json:"hookType"`
+	URL        string     `json:"url"`
+	Attempt    int        `json:"attempt"`
+	StatusCode int        `json:"statusCode"`
+	Error      string     `json:"error,omitempty"`
+	Timestamp  *time.Time `json:"timestamp"`
+	Duration   int64      `json:"duration"`
+}
+
+// Succeeded returns true if the delivery attempt got a 2XX response
+func (d *HookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
 // NewStepStateFromEvent constructs StepState from an event
 func NewStepStateFromEvent(e event.Event) (*StepState, error) {
 	if tsl, ok := e.Payload.(event.TransformStepLifecycle); ok {
@@ -237,6 +334,8 @@ type _stepState struct {
 	StopTime  *time.Time `json:"stopTime"`
 	Duration  int64      `json:"duration"`
 	Output    []_event   `json:"output"`
+
+	TruncatedOutput int64 `json:"truncatedOutput,omitempty"`
 }
 
 type _event struct {
@@ -259,6 +358,7 @@ func (ss *StepState) UnmarshalJSON(data []byte) error {
 	tmpSS.StartTime = rs.StartTime
 	tmpSS.StopTime = rs.StopTime
 	tmpSS.Duration = rs.Duration
+	tmpSS.TruncatedOutput = rs.TruncatedOutput
 	for _, re := range rs.Output {
 		e := event.Event{
 			Type:      re.Type,
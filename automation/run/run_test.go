@@ -43,6 +43,82 @@ func TestStateAddTransformEvent(t *testing.T) {
 	}
 }
 
+func TestStateAddTransformEventRetry(t *testing.T) {
+	runID := NewID()
+	events := []event.Event{
+		{Type: event.ETTransformStart, Timestamp: 100, SessionID: runID, Payload: event.TransformLifecycle{Status: "running"}},
+		{Type: event.ETTransformStepStart, Timestamp: 200, SessionID: runID, Payload: event.TransformStepLifecycle{Name: "download"}},
+		{Type: event.ETTransformStepStop, Timestamp: 300, SessionID: runID, Payload: event.TransformStepLifecycle{Name: "download", Status: "failed"}},
+		{Type: event.ETTransformStop, Timestamp: 400, SessionID: runID, Payload: event.TransformLifecycle{Status: "failed"}},
+		// second attempt
+		{Type: event.ETTransformStart, Timestamp: 1000, SessionID: runID, Payload: event.TransformLifecycle{Status: "running"}},
+		{Type: event.ETTransformStepStart, Timestamp: 1100, SessionID: runID, Payload: event.TransformStepLifecycle{Name: "download"}},
+		{Type: event.ETTransformStepStop, Timestamp: 1200, SessionID: runID, Payload: event.TransformStepLifecycle{Name: "download", Status: "succeeded"}},
+		{Type: event.ETTransformStop, Timestamp: 1300, SessionID: runID, Payload: event.TransformLifecycle{Status: "succeeded"}},
+	}
+
+	got := NewState(runID)
+	for _, e := range events {
+		if err := got.AddTransformEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect := &State{
+		ID:        runID,
+		Status:    RSSucceeded,
+		StartTime: toTimePointer(1000),
+		StopTime:  toTimePointer(1300),
+		Duration:  300,
+		Steps: []*StepState{
+			{Name: "download", StartTime: toTimePointer(1100), StopTime: toTimePointer(1200), Duration: 100, Status: RSSucceeded},
+		},
+		Attempts: []*AttemptState{
+			{
+				Number:    1,
+				Status:    RSFailed,
+				StartTime: toTimePointer(100),
+				StopTime:  toTimePointer(400),
+				Duration:  300,
+				Steps: []*StepState{
+					{Name: "download", StartTime: toTimePointer(200), StopTime: toTimePointer(300), Duration: 100, Status: RSFailed},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch. (-want +got):\n%s", diff)
+	}
+}
+
+func TestStateHTTPFixtures(t *testing.T) {
+	fixtures := &event.HTTPFixtures{
+		Exchanges: []event.HTTPExchange{
+			{RequestKey: "req", Method: "GET", URL: "https://example.com", StatusCode: 200, BodyHash: "body"},
+		},
+		Blobs: map[string][]byte{"body": []byte("hello")},
+	}
+
+	got := NewState(NewID())
+	got.InitID = "init_id"
+	got.HTTPFixtures = fixtures
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &State{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, decoded); diff != "" {
+		t.Errorf("decoded state mismatch. (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(got, got.Copy()); diff != "" {
+		t.Errorf("copied state mismatch. (-want +got):\n%s", diff)
+	}
+}
+
 func getStates(runID string) []struct {
 	e event.Event
 	r *State
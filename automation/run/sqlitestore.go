@@ -0,0 +1,391 @@
+package run
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/qri-io/qri/automation/workflow"
+	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
+)
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS runs (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		id          TEXT NOT NULL UNIQUE,
+		workflow_id TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		start_time  INTEGER,
+		stop_time   INTEGER,
+		data        BLOB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS runs_workflow_seq ON runs (workflow_id, seq DESC)`,
+	`CREATE INDEX IF NOT EXISTS runs_status_start_time ON runs (status, start_time DESC)`,
+	// run_workflows tracks the total number of runs & the latest run of each
+	// workflow. count is not reduced when runs are compacted
+	`CREATE TABLE IF NOT EXISTS run_workflows (
+		workflow_id   TEXT PRIMARY KEY,
+		count         INTEGER NOT NULL,
+		latest_run_id TEXT NOT NULL
+	)`,
+}
+
+// sqliteStore is a Store implementation backed by a SQLite database. Runs are
+// stored as JSON alongside indexed columns used for listing & compaction.
+// sqliteStore is safe for concurrent use
+type sqliteStore struct {
+	db *sql.DB
+}
+
+var (
+	_ Store             = (*sqliteStore)(nil)
+	_ EventAdder        = (*sqliteStore)(nil)
+	_ HookDeliveryAdder = (*sqliteStore)(nil)
+)
+
+// NewSQLiteStore creates a run store that persists to a SQLite database within
+// the repo
+func NewSQLiteStore(repoPath string) (Store, error) {
+	db, err := workflow.OpenSQLite(filepath.Join(repoPath, workflow.SQLiteFilename))
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating run tables: %w", err)
+		}
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// Create adds a new run State to the store
+func (s *sqliteStore) Create(ctx context.Context, r *State) (*State, error) {
+	if r == nil {
+		return nil, fmt.Errorf("run is nil")
+	}
+	run := r.Copy()
+	if run.ID == "" {
+		run.ID = NewID()
+	}
+	if _, err := s.Get(ctx, run.ID); !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("run with this ID already exists")
+	}
+	if err := run.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO runs (id, workflow_id, status, start_time, stop_time, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.WorkflowID.String(), string(run.Status), sqliteTime(run.StartTime), sqliteTime(run.StopTime), data,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO run_workflows (workflow_id, count, latest_run_id)
+		VALUES (?, 1, ?)
+		ON CONFLICT (workflow_id) DO UPDATE SET
+			count = count + 1,
+			latest_run_id = excluded.latest_run_id`,
+		run.WorkflowID.String(), run.ID,
+	); err != nil {
+		return nil, err
+	}
+	return run, tx.Commit()
+}
+
+// Put updates an existing run State in the store
+func (s *sqliteStore) Put(ctx context.Context, r *State) (*State, error) {
+	if r == nil {
+		return nil, fmt.Errorf("run is nil")
+	}
+	run := r.Copy()
+	if run.ID == "" {
+		return nil, fmt.Errorf("run has empty ID")
+	}
+	fetchedR, err := s.Get(ctx, run.ID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if fetchedR.WorkflowID != run.WorkflowID {
+		return nil, fmt.Errorf("run.State's WorkflowID does not match the WorkflowID of the associated run.State currently in the store")
+	}
+	if err := run.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.update(ctx, s.db, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *sqliteStore) update(ctx context.Context, db execer, run *State) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE runs SET status = ?, start_time = ?, stop_time = ?, data = ?
+		WHERE id = ?`,
+		string(run.Status), sqliteTime(run.StartTime), sqliteTime(run.StopTime), data, run.ID,
+	)
+	return err
+}
+
+// Get fetches a run State using the associated ID
+func (s *sqliteStore) Get(ctx context.Context, id string) (*State, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM runs WHERE id = ?`, id)
+	return scanRun(row)
+}
+
+// Count returns the number of runs for a given workflow.ID
+func (s *sqliteStore) Count(ctx context.Context, wid workflow.ID) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT count FROM run_workflows WHERE workflow_id = ?`, wid.String()).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w %q", ErrUnknownWorkflowID, wid)
+	}
+	return count, err
+}
+
+// List lists all the runs associated with the workflow.ID in reverse
+// chronological order
+func (s *sqliteStore) List(ctx context.Context, wid workflow.ID, lp params.List) ([]*State, error) {
+	if err := lp.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.Count(ctx, wid); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM runs
+		WHERE workflow_id = ?
+		ORDER BY seq DESC
+		LIMIT ? OFFSET ?`,
+		wid.String(), sqliteLimit(lp), lp.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+// GetLatest returns the most recent run associated with the workflow id
+func (s *sqliteStore) GetLatest(ctx context.Context, wid workflow.ID) (*State, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT r.data FROM run_workflows w
+		JOIN runs r ON r.id = w.latest_run_id
+		WHERE w.workflow_id = ?`,
+		wid.String(),
+	)
+	run, err := scanRun(row)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownWorkflowID, wid)
+	}
+	return run, err
+}
+
+// GetStatus returns the status of the latest run based on the
+// workflow.ID
+func (s *sqliteStore) GetStatus(ctx context.Context, wid workflow.ID) (Status, error) {
+	run, err := s.GetLatest(ctx, wid)
+	if err != nil {
+		return "", err
+	}
+	return run.Status, nil
+}
+
+// ListByStatus returns a list of run.State entries with a given status
+// looking only at the most recent run of each Workflow
+func (s *sqliteStore) ListByStatus(ctx context.Context, owner profile.ID, status Status, lp params.List) ([]*State, error) {
+	if err := lp.Validate(); err != nil {
+		return nil, err
+	}
+	// runs without a start time sort first, matching the order of a run.Set
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.data FROM runs r
+		JOIN run_workflows w ON w.latest_run_id = r.id
+		WHERE r.status = ?
+		ORDER BY r.start_time IS NULL DESC, r.start_time DESC
+		LIMIT ? OFFSET ?`,
+		string(status), sqliteLimit(lp), lp.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+// AddEvent writes an event to the store, attaching it to an existing stored
+// run state
+func (s *sqliteStore) AddEvent(id string, e event.Event) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	run, err := scanRun(tx.QueryRowContext(ctx, `SELECT data FROM runs WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	if err := run.AddTransformEvent(e); err != nil {
+		return fmt.Errorf("adding transform event to run: %w", err)
+	}
+	if err := s.update(ctx, tx, run); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddHookDeliveries appends hook delivery attempts to an existing stored run
+// state
+func (s *sqliteStore) AddHookDeliveries(id string, ds ...*HookDelivery) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	run, err := scanRun(tx.QueryRowContext(ctx, `SELECT data FROM runs WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	for _, d := range ds {
+		run.AddHookDelivery(d)
+	}
+	if err := s.update(ctx, tx, run); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Compact removes runs & truncates run output that falls outside the given
+// retention policy
+func (s *sqliteStore) Compact(ctx context.Context, p RetentionPolicy) error {
+	if p.IsZero() {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if p.MaxRunsPerWorkflow > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM runs WHERE seq IN (
+				SELECT seq FROM (
+					SELECT seq, ROW_NUMBER() OVER (PARTITION BY workflow_id ORDER BY seq DESC) AS n
+					FROM runs
+				) WHERE n > ?
+			)`, p.MaxRunsPerWorkflow); err != nil {
+			return err
+		}
+	}
+	if p.MaxAge > 0 {
+		before := NowFunc().Add(-p.MaxAge).UnixNano()
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM runs
+			WHERE stop_time IS NOT NULL AND stop_time < ?
+			AND id NOT IN (SELECT latest_run_id FROM run_workflows)`, before); err != nil {
+			return err
+		}
+	}
+	if p.MaxOutputSize > 0 {
+		// a run can only have a step with output over the limit if the whole
+		// run is larger than the limit
+		rows, err := tx.QueryContext(ctx, `SELECT data FROM runs WHERE length(data) > ?`, p.MaxOutputSize)
+		if err != nil {
+			return err
+		}
+		runs, err := scanRuns(rows)
+		if err != nil {
+			return err
+		}
+		for _, r := range runs {
+			if t, truncated := p.truncateOutput(r); truncated {
+				if err := s.update(ctx, tx, t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Shutdown closes the database
+func (s *sqliteStore) Shutdown() error {
+	return s.db.Close()
+}
+
+// sqliteLimit converts a list limit to a SQLite LIMIT value, where -1 fetches
+// all rows
+func sqliteLimit(lp params.List) int {
+	if lp.All() {
+		return -1
+	}
+	return lp.Limit
+}
+
+// sqliteTime stores times as unix nanoseconds, or NULL
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row scanner) (*State, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	run := &State{}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func scanRuns(rows *sql.Rows) ([]*State, error) {
+	defer rows.Close()
+	runs := []*State{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
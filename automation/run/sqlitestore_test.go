@@ -0,0 +1,65 @@
+package run_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/automation/spec"
+	"github.com/qri-io/qri/event"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	store, err := run.NewSQLiteStore(tmpdir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore unexpected error: %s", err)
+	}
+	spec.AssertRunStore(t, store)
+
+	adder, ok := store.(run.EventAdder)
+	if !ok {
+		t.Fatal("expected SQLite run store to be an EventAdder")
+	}
+	r, err := store.Create(ctx, &run.State{WorkflowID: "event_workflow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := event.Event{Type: event.ETTransformStart, Timestamp: 1, SessionID: r.ID, Payload: event.TransformLifecycle{Status: "running"}}
+	if err := adder.AddEvent(r.ID, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// runs should persist across store instances
+	store, err = run.NewSQLiteStore(tmpdir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore unexpected error: %s", err)
+	}
+	defer store.Shutdown()
+	got, err := store.Get(ctx, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := r.Copy()
+	if err := expect.AddTransformEvent(start); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("stored run mismatch (-want +got):\n%s", diff)
+	}
+	if status, err := store.GetStatus(ctx, "event_workflow"); err != nil || status != run.RSRunning {
+		t.Errorf("expected latest run status %q, got %q, error: %v", run.RSRunning, status, err)
+	}
+}
@@ -45,6 +45,10 @@ type Store interface {
 	// ListByStatus returns a list of run.State entries with a given status
 	// looking only at the most recent run of each Workflow
 	ListByStatus(ctx context.Context, owner profile.ID, s Status, lp params.List) ([]*State, error)
+	// Compact removes runs & truncates run output that falls outside the
+	// given retention policy. The most recent run of each workflow is always
+	// kept. Compacting doesn't change the run Count of a workflow
+	Compact(ctx context.Context, p RetentionPolicy) error
 	// Shutdown closes the store
 	Shutdown() error
 }
@@ -61,6 +65,19 @@ type EventAdder interface {
 	AddEvent(id string, e event.Event) error
 }
 
+// HookDeliveryAdder is an extension interface that writes hook delivery
+// attempts to a run without racing concurrent event writes. Result should
+// equal to calling:
+//   run := store.Get(id)
+//   run.AddHookDelivery(d) // for each delivery
+//   store.Put(run)
+type HookDeliveryAdder interface {
+	Store
+	// AddHookDeliveries appends hook delivery attempts to an existing stored
+	// run state
+	AddHookDeliveries(id string, ds ...*HookDelivery) error
+}
+
 // MemStore is an in memory representation of a Store
 type MemStore struct {
 	mu        sync.Mutex
@@ -81,8 +98,9 @@ func newWorkflowMeta() *workflowMeta {
 }
 
 var (
-	_ Store      = (*MemStore)(nil)
-	_ EventAdder = (*MemStore)(nil)
+	_ Store             = (*MemStore)(nil)
+	_ EventAdder        = (*MemStore)(nil)
+	_ HookDeliveryAdder = (*MemStore)(nil)
 )
 
 // NewMemStore returns a MemStore
@@ -270,6 +288,46 @@ func (s *MemStore) ListByStatus(ctx context.Context, owner profile.ID, status St
 	return set.Slice(start, end), nil
 }
 
+// Compact removes runs & truncates run output that falls outside the given
+// retention policy
+func (s *MemStore) Compact(ctx context.Context, p RetentionPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactNoLock(p)
+	return nil
+}
+
+// compactNoLock applies a retention policy, returning true if anything was
+// removed. Only use this when you have a surrounding lock
+func (s *MemStore) compactNoLock(p RetentionPolicy) bool {
+	if p.IsZero() {
+		return false
+	}
+	now := *NowFunc()
+	changed := false
+	for _, wfm := range s.workflows {
+		kept := make([]string, 0, len(wfm.RunIDs))
+		for i, id := range wfm.RunIDs {
+			r, ok := s.runs[id]
+			if !ok {
+				continue
+			}
+			if !p.keep(r, len(wfm.RunIDs)-1-i, now) {
+				delete(s.runs, id)
+				changed = true
+				continue
+			}
+			if t, truncated := p.truncateOutput(r); truncated {
+				s.runs[id] = t
+				changed = true
+			}
+			kept = append(kept, id)
+		}
+		wfm.RunIDs = kept
+	}
+	return changed
+}
+
 // Shutdown closes the store
 func (s *MemStore) Shutdown() error {
 	return nil
@@ -293,6 +351,24 @@ func (s *MemStore) AddEvent(id string, e event.Event) error {
 	return nil
 }
 
+// AddHookDeliveries appends hook delivery attempts to an existing stored run
+// state
+func (s *MemStore) AddHookDeliveries(id string, ds ...*HookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	run = run.Copy()
+	for _, d := range ds {
+		run.AddHookDelivery(d)
+	}
+	s.runs[id] = run
+	return nil
+}
+
 // MarshalJSON satisfies the json.Marshaller interface
 func (s *MemStore) MarshalJSON() ([]byte, error) {
 	if s == nil {
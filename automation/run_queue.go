@@ -2,7 +2,10 @@ package automation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"sync"
 	"time"
 
@@ -19,17 +22,68 @@ type runQueueFunc func(context.Context) error
 
 // RunQueue queues runs and apply transforms & allows you to cancel runs and apply transforms
 type RunQueue interface {
-	Push(ctx context.Context, ownerID string, runID string, mode string, f runQueueFunc) error
+	Push(ctx context.Context, ownerID, workflowID, runID, mode string, f runQueueFunc) error
 	Pop(ctx context.Context) (*runQueueInfo, error)
 	Cancel(runID string) error
 	Shutdown() error
 }
 
+// RecoverableRunQueue is a RunQueue that persists queued runs, and can report
+// the runs that were waiting or executing when the queue last stopped
+type RecoverableRunQueue interface {
+	RunQueue
+	// Recover returns runs that were waiting in the queue & runs that were
+	// executing when the queue last stopped. Recovered runs are not executed
+	// until they are pushed back onto the queue. Recover only returns runs
+	// the first time it's called
+	Recover() (queued, inFlight []RunQueueEntry)
+	// ClearRecovered removes recovered runs that weren't pushed back onto the
+	// queue from the persisted queue. Recovered runs stay persisted until
+	// ClearRecovered is called, so call it once they've been re-queued
+	ClearRecovered() error
+}
+
+// RunQueueOptions configures a RunQueue
+type RunQueueOptions struct {
+	// Interval is the amount of time to wait between checks for the next run
+	Interval time.Duration
+	// Workers is the number of runs that can execute at once
+	Workers int
+	// MaxConcurrentPerWorkflow limits the number of runs of a single workflow
+	// that can execute at once. Zero means no limit
+	MaxConcurrentPerWorkflow int
+	// Path is the file the queue persists to. Required by NewFileRunQueue
+	Path string
+	// RequeueInFlight determines how runs that were executing when the queue
+	// last stopped are recovered. Interrupted runs are always marked as failed,
+	// when RequeueInFlight is true a fresh run of the same workflow is queued
+	RequeueInFlight bool
+}
+
+// DefaultRunQueueOptions returns the options used by the orchestrator when
+// none are given
+func DefaultRunQueueOptions() RunQueueOptions {
+	return RunQueueOptions{
+		Interval: 50 * time.Millisecond,
+		Workers:  1,
+	}
+}
+
+// RunQueueEntry is the persisted description of a queued run
+type RunQueueEntry struct {
+	OwnerID    string `json:"ownerID"`
+	WorkflowID string `json:"workflowID"`
+	RunID      string `json:"runID"`
+	Mode       string `json:"mode"`
+	Running    bool   `json:"running"`
+}
+
 type runQueueInfo struct {
-	ownerID string
-	runID   string
-	mode    string
-	f       runQueueFunc
+	ownerID    string
+	workflowID string
+	runID      string
+	mode       string
+	f          runQueueFunc
 }
 
 type runQueue struct {
@@ -40,33 +94,88 @@ type runQueue struct {
 	clk        sync.Mutex
 	cancelCh   chan string
 	closeQueue context.CancelFunc
+	// done is closed when the queue shuts down
+	done <-chan struct{}
+
+	// maxPerWorkflow & running are guarded by qlk
+	maxPerWorkflow int
+	running        map[string]int
+
+	// persistence fields, also guarded by qlk. path is empty for in-memory
+	// queues. recovered entries are returned by Recover, & stay persisted
+	// until they're pushed back onto the queue or ClearRecovered is called
+	path        string
+	entries     []RunQueueEntry
+	recovered   []RunQueueEntry
+	unrecovered []RunQueueEntry
 }
 
-var _ RunQueue = (*runQueue)(nil)
+var (
+	_ RunQueue            = (*runQueue)(nil)
+	_ RecoverableRunQueue = (*runQueue)(nil)
+)
 
 // NewRunQueue returns a RunQueue, that polls every interval to run the next
 // run or apply in the queue
-// TODO (ramfox): when the run queue's responsibility expands, add an Options struct
 func NewRunQueue(ctx context.Context, pub event.Publisher, interval time.Duration, workers int) RunQueue {
-	ctx, cancel := context.WithCancel(ctx)
+	opts := DefaultRunQueueOptions()
+	opts.Interval = interval
+	opts.Workers = workers
+	return NewRunQueueOptions(ctx, pub, opts)
+}
+
+// NewRunQueueOptions returns an in-memory RunQueue configured by opts. The
+// Path option is ignored
+func NewRunQueueOptions(ctx context.Context, pub event.Publisher, opts RunQueueOptions) RunQueue {
+	opts.Path = ""
+	r := newRunQueue(pub, opts)
+	r.start(ctx, opts)
+	return r
+}
+
+// NewFileRunQueue returns a RunQueue that persists every change to the file at
+// opts.Path, so queued runs survive a process restart. Runs that were in the
+// file when the queue is created are available from Recover
+func NewFileRunQueue(ctx context.Context, pub event.Publisher, opts RunQueueOptions) (RecoverableRunQueue, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("file run queue requires a path")
+	}
+	r := newRunQueue(pub, opts)
+	if err := r.loadFromFile(); err != nil {
+		return nil, err
+	}
+	r.start(ctx, opts)
+	return r, nil
+}
 
-	r := &runQueue{
-		queue:      []*runQueueInfo{},
-		qlk:        sync.Mutex{},
-		pub:        pub,
-		cancels:    map[string]context.CancelFunc{},
-		clk:        sync.Mutex{},
-		cancelCh:   make(chan string),
-		closeQueue: cancel,
+func newRunQueue(pub event.Publisher, opts RunQueueOptions) *runQueue {
+	return &runQueue{
+		queue:          []*runQueueInfo{},
+		qlk:            sync.Mutex{},
+		pub:            pub,
+		cancels:        map[string]context.CancelFunc{},
+		clk:            sync.Mutex{},
+		cancelCh:       make(chan string),
+		maxPerWorkflow: opts.MaxConcurrentPerWorkflow,
+		running:        map[string]int{},
+		path:           opts.Path,
+		entries:        []RunQueueEntry{},
 	}
+}
+
+func (r *runQueue) start(ctx context.Context, opts RunQueueOptions) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.closeQueue = cancel
+	r.done = ctx.Done()
+
+	workers := opts.Workers
 	if workers == 0 {
 		workers = 1
 	}
 	for i := 0; i < workers; i++ {
-		go r.pollQueue(ctx, interval)
+		go r.pollQueue(ctx, opts.Interval)
 	}
 	go r.listenForCancelations(ctx)
-	return r
 }
 
 func (r *runQueue) listenForCancelations(ctx context.Context) {
@@ -75,10 +184,9 @@ func (r *runQueue) listenForCancelations(ctx context.Context) {
 		select {
 		case cancelRunID := <-r.cancelCh:
 			r.clk.Lock()
-			if _, ok := r.cancels[cancelRunID]; !ok {
-				continue
+			if cancel, ok := r.cancels[cancelRunID]; ok {
+				cancel()
 			}
-			r.cancels[cancelRunID]()
 			r.clk.Unlock()
 		case <-ctx.Done():
 			return
@@ -108,7 +216,9 @@ func (r *runQueue) pollQueue(ctx context.Context, interval time.Duration) {
 			}
 			runCtx, cancel := context.WithCancel(ctx)
 			r.addRunCancel(info.runID, cancel)
-			done := make(chan struct{})
+			// done is buffered so the run goroutine can exit if the run context is
+			// canceled before the run returns
+			done := make(chan struct{}, 1)
 			go func() {
 				if err := info.f(runCtx); err != nil {
 					log.Debugw("RunQueue", "runID", info.runID, "mode", info.mode, "error", err)
@@ -118,10 +228,17 @@ func (r *runQueue) pollQueue(ctx context.Context, interval time.Duration) {
 			select {
 			case <-done:
 				log.Debugw("RunQueue run finished", "runID", info.runID)
+				r.finish(info)
 			case <-runCtx.Done():
 				log.Debugw("RunQueue: context canceled before run finished", "runID", info.runID)
+				// a canceled run is finished, but a closing queue leaves the run in
+				// place to be recovered when the queue restarts
+				if ctx.Err() == nil {
+					r.finish(info)
+				}
 			}
 			r.removeRunCancel(info.runID)
+			cancel()
 		case <-ctx.Done():
 			log.Debug("finished polling run queue")
 			return
@@ -129,9 +246,33 @@ func (r *runQueue) pollQueue(ctx context.Context, interval time.Duration) {
 	}
 }
 
-func (r *runQueue) Push(ctx context.Context, ownerID string, runID string, mode string, f runQueueFunc) error {
+// Push persists a run, then adds it to the queue. A run that can't be
+// persisted isn't queued
+func (r *runQueue) Push(ctx context.Context, ownerID, workflowID, runID, mode string, f runQueueFunc) error {
 	r.qlk.Lock()
 	defer r.qlk.Unlock()
+
+	prevUnrecovered := append([]RunQueueEntry{}, r.unrecovered...)
+	r.dropUnrecoveredNoLock(runID)
+	r.entries = append(r.entries, RunQueueEntry{
+		OwnerID:    ownerID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+		Mode:       mode,
+	})
+	if err := r.writeToFileNoLock(); err != nil {
+		r.entries = r.entries[:len(r.entries)-1]
+		r.unrecovered = prevUnrecovered
+		return err
+	}
+
+	r.queue = append(r.queue, &runQueueInfo{
+		ownerID:    ownerID,
+		workflowID: workflowID,
+		runID:      runID,
+		mode:       mode,
+		f:          f,
+	})
 	scopedCtx := profile.AddIDToContext(ctx, ownerID)
 	go func() {
 		switch mode {
@@ -145,24 +286,41 @@ func (r *runQueue) Push(ctx context.Context, ownerID string, runID string, mode
 			}
 		}
 	}()
-	info := &runQueueInfo{
-		ownerID: ownerID,
-		runID:   runID,
-		mode:    mode,
-		f:       f,
-	}
-	r.queue = append(r.queue, info)
 	return nil
 }
 
+// Pop removes the first run in the queue whose workflow is below the
+// per-workflow concurrency limit
 func (r *runQueue) Pop(ctx context.Context) (*runQueueInfo, error) {
 	r.qlk.Lock()
 	defer r.qlk.Unlock()
 	if len(r.queue) == 0 {
 		return nil, ErrEmptyQueue
 	}
-	info := r.queue[0]
-	r.queue = r.queue[1:]
+	idx := -1
+	for i, qi := range r.queue {
+		if r.canStartNoLock(qi) {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return nil, ErrEmptyQueue
+	}
+	info := r.queue[idx]
+	r.queue = append(r.queue[:idx], r.queue[idx+1:]...)
+	if info.workflowID != "" {
+		r.running[info.workflowID]++
+	}
+	for i, e := range r.entries {
+		if e.RunID == info.runID {
+			r.entries[i].Running = true
+			break
+		}
+	}
+	if err := r.writeToFileNoLock(); err != nil {
+		log.Debugw("RunQueue: persisting popped run", "runID", info.runID, "error", err)
+	}
 	go func() {
 		switch info.mode {
 		case "run":
@@ -178,8 +336,129 @@ func (r *runQueue) Pop(ctx context.Context) (*runQueueInfo, error) {
 	return info, nil
 }
 
+// canStartNoLock returns true if the run's workflow is below the per-workflow
+// concurrency limit. Only use this when you have a surrounding lock
+func (r *runQueue) canStartNoLock(info *runQueueInfo) bool {
+	if r.maxPerWorkflow <= 0 || info.workflowID == "" {
+		return true
+	}
+	return r.running[info.workflowID] < r.maxPerWorkflow
+}
+
+// finish removes a completed run from the queue's bookkeeping
+func (r *runQueue) finish(info *runQueueInfo) {
+	r.qlk.Lock()
+	defer r.qlk.Unlock()
+	if info.workflowID != "" {
+		r.running[info.workflowID]--
+		if r.running[info.workflowID] <= 0 {
+			delete(r.running, info.workflowID)
+		}
+	}
+	for i, e := range r.entries {
+		if e.RunID == info.runID {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			break
+		}
+	}
+	if err := r.writeToFileNoLock(); err != nil {
+		log.Debugw("RunQueue: persisting finished run", "runID", info.runID, "error", err)
+	}
+}
+
+// Recover returns runs that were queued & running when the queue was last
+// stopped. Recovered runs stay in the queue file until they're pushed back onto
+// the queue or ClearRecovered is called, so a crash while recovering doesn't
+// lose them
+func (r *runQueue) Recover() (queued, inFlight []RunQueueEntry) {
+	r.qlk.Lock()
+	defer r.qlk.Unlock()
+	if r.recovered == nil {
+		return nil, nil
+	}
+	for _, e := range r.recovered {
+		if e.Running {
+			inFlight = append(inFlight, e)
+		} else {
+			queued = append(queued, e)
+		}
+	}
+	r.recovered = nil
+	return queued, inFlight
+}
+
+// ClearRecovered removes recovered runs that weren't pushed back onto the
+// queue from the queue file
+func (r *runQueue) ClearRecovered() error {
+	r.qlk.Lock()
+	defer r.qlk.Unlock()
+	if len(r.unrecovered) == 0 {
+		return nil
+	}
+	r.unrecovered = nil
+	return r.writeToFileNoLock()
+}
+
+// dropUnrecoveredNoLock stops persisting a recovered run that's being pushed
+// back onto the queue. Only use this when you have a surrounding lock
+func (r *runQueue) dropUnrecoveredNoLock(runID string) {
+	for i, e := range r.unrecovered {
+		if e.RunID == runID {
+			r.unrecovered = append(r.unrecovered[:i], r.unrecovered[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *runQueue) loadFromFile() error {
+	r.qlk.Lock()
+	defer r.qlk.Unlock()
+
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.Debugw("RunQueue loading queue from file", "error", err)
+		return err
+	}
+	entries := []RunQueueEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Debugw("RunQueue deserializing from JSON", "error", err)
+		return err
+	}
+	// recovered entries aren't part of the queue until they're pushed back,
+	// but stay in the file until they're re-queued or cleared
+	r.recovered = entries
+	r.unrecovered = append([]RunQueueEntry{}, entries...)
+	return nil
+}
+
+// writeToFileNoLock persists the queue. it writes to a temporary file & renames
+// the result into place so a crash mid-write never corrupts the queue file.
+// Only use this when you have a surrounding lock
+func (r *runQueue) writeToFileNoLock() error {
+	if r.path == "" {
+		return nil
+	}
+	entries := append(append([]RunQueueEntry{}, r.unrecovered...), r.entries...)
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
 func (r *runQueue) Cancel(runID string) error {
-	r.cancelCh <- runID
+	select {
+	case r.cancelCh <- runID:
+	case <-r.done:
+		log.Debugw("RunQueue: canceling run after shutdown", "runID", runID)
+	}
 	return nil
 }
 
@@ -2,6 +2,13 @@ package automation
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,6 +27,7 @@ func TestRunQueue(t *testing.T) {
 		"third message",
 	}
 	ownerID := "owner"
+	workflowID := "workflow"
 	runID := "run"
 	mode := "apply"
 	f := func(msg string) runQueueFunc {
@@ -29,7 +37,7 @@ func TestRunQueue(t *testing.T) {
 		}
 	}
 
-	if err := rq.Push(ctx, ownerID, runID, mode, f(expectMsgs[0])); err != nil {
+	if err := rq.Push(ctx, ownerID, workflowID, runID, mode, f(expectMsgs[0])); err != nil {
 		t.Fatal(err)
 	}
 	<-time.After(100 * time.Millisecond)
@@ -38,15 +46,15 @@ func TestRunQueue(t *testing.T) {
 		return
 	}
 
-	if err := rq.Push(ctx, ownerID, runID, mode, f(expectMsgs[1])); err != nil {
+	if err := rq.Push(ctx, ownerID, workflowID, runID, mode, f(expectMsgs[1])); err != nil {
 		t.Fatal(err)
 	}
-	if err := rq.Push(ctx, ownerID, runID, mode, f(expectMsgs[2])); err != nil {
+	if err := rq.Push(ctx, ownerID, workflowID, runID, mode, f(expectMsgs[2])); err != nil {
 		t.Fatal(err)
 	}
 	<-time.After(200 * time.Millisecond)
 	cancel()
-	if err := rq.Push(ctx, ownerID, runID, mode, f("bad message")); err != nil {
+	if err := rq.Push(ctx, ownerID, workflowID, runID, mode, f("bad message")); err != nil {
 		t.Fatal(err)
 	}
 	<-time.After(100 * time.Millisecond)
@@ -61,6 +69,7 @@ func TestRunQueueCancel(t *testing.T) {
 	defer cancel()
 	rq := NewRunQueue(ctx, event.NilBus, 50*time.Millisecond, 1)
 	ownerID := "owner"
+	workflowID := "workflow"
 	runID := "run"
 	mode := "apply"
 	msg := make(chan string)
@@ -77,7 +86,7 @@ func TestRunQueueCancel(t *testing.T) {
 		}
 	}
 
-	if err := rq.Push(ctx, ownerID, runID, mode, f); err != nil {
+	if err := rq.Push(ctx, ownerID, workflowID, runID, mode, f); err != nil {
 		t.Fatal(err)
 	}
 	<-runStarted
@@ -87,3 +96,200 @@ func TestRunQueueCancel(t *testing.T) {
 		t.Errorf(gotMsg)
 	}
 }
+
+func TestRunQueueCancelAfterShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rq := NewRunQueue(ctx, event.NilBus, 50*time.Millisecond, 1)
+	if err := rq.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled := make(chan struct{})
+	go func() {
+		rq.Cancel("run")
+		close(canceled)
+	}()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected Cancel not to block after the queue shuts down")
+	}
+}
+
+func TestRunQueueMaxConcurrentPerWorkflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rq := NewRunQueueOptions(ctx, event.NilBus, RunQueueOptions{
+		Interval:                 10 * time.Millisecond,
+		Workers:                  3,
+		MaxConcurrentPerWorkflow: 1,
+	})
+
+	lk := sync.Mutex{}
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	wg := sync.WaitGroup{}
+	f := func(workflowID string) runQueueFunc {
+		return func(ctx context.Context) error {
+			defer wg.Done()
+			lk.Lock()
+			running[workflowID]++
+			if running[workflowID] > maxRunning[workflowID] {
+				maxRunning[workflowID] = running[workflowID]
+			}
+			lk.Unlock()
+			<-time.After(50 * time.Millisecond)
+			lk.Lock()
+			running[workflowID]--
+			lk.Unlock()
+			return nil
+		}
+	}
+
+	for i, wid := range []string{"a", "a", "b", "a", "b"} {
+		wg.Add(1)
+		if err := rq.Push(ctx, "owner", wid, fmt.Sprintf("run_%d", i), "run", f(wid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	expect := map[string]int{"a": 1, "b": 1}
+	if diff := cmp.Diff(expect, maxRunning); diff != "" {
+		t.Errorf("max concurrent runs per workflow mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileRunQueueRecover(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, "run_queue.json")
+	opts := RunQueueOptions{
+		Interval: 10 * time.Millisecond,
+		Workers:  1,
+		Path:     path,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rq, err := NewFileRunQueue(ctx, event.NilBus, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued, inFlight := rq.Recover(); len(queued) != 0 || len(inFlight) != 0 {
+		t.Fatalf("expected a new queue to have nothing to recover, got %d queued & %d in flight", len(queued), len(inFlight))
+	}
+
+	runStarted := make(chan struct{})
+	blocking := func(ctx context.Context) error {
+		runStarted <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	noop := func(ctx context.Context) error { return nil }
+
+	if err := rq.Push(ctx, "owner", "workflow_a", "run_a", "run", blocking); err != nil {
+		t.Fatal(err)
+	}
+	<-runStarted
+	if err := rq.Push(ctx, "owner", "workflow_b", "run_b", "run", noop); err != nil {
+		t.Fatal(err)
+	}
+	// simulate the process stopping with one run executing & one run queued
+	cancel()
+	<-time.After(50 * time.Millisecond)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	opts.Interval = time.Hour
+	rq, err = NewFileRunQueue(ctx, event.NilBus, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, inFlight := rq.Recover()
+	expectQueued := []RunQueueEntry{{OwnerID: "owner", WorkflowID: "workflow_b", RunID: "run_b", Mode: "run"}}
+	if diff := cmp.Diff(expectQueued, queued); diff != "" {
+		t.Errorf("queued runs mismatch (-want +got):\n%s", diff)
+	}
+	expectInFlight := []RunQueueEntry{{OwnerID: "owner", WorkflowID: "workflow_a", RunID: "run_a", Mode: "run", Running: true}}
+	if diff := cmp.Diff(expectInFlight, inFlight); diff != "" {
+		t.Errorf("in-flight runs mismatch (-want +got):\n%s", diff)
+	}
+
+	queued, inFlight = rq.Recover()
+	if len(queued) != 0 || len(inFlight) != 0 {
+		t.Errorf("expected Recover to only return runs once, got %d queued & %d in flight", len(queued), len(inFlight))
+	}
+
+	// recovered runs stay in the queue file until they're re-queued or cleared
+	persisted := func() []RunQueueEntry {
+		t.Helper()
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := []RunQueueEntry{}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+	if err := rq.Push(ctx, "owner", "workflow_b", "run_b", "run", noop); err != nil {
+		t.Fatal(err)
+	}
+	expect := []RunQueueEntry{
+		{OwnerID: "owner", WorkflowID: "workflow_a", RunID: "run_a", Mode: "run", Running: true},
+		{OwnerID: "owner", WorkflowID: "workflow_b", RunID: "run_b", Mode: "run"},
+	}
+	if diff := cmp.Diff(expect, persisted()); diff != "" {
+		t.Errorf("persisted queue while recovering mismatch (-want +got):\n%s", diff)
+	}
+	if err := rq.ClearRecovered(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expect[1:], persisted()); diff != "" {
+		t.Errorf("persisted queue after clearing recovered runs mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewFileRunQueue(ctx, event.NilBus, RunQueueOptions{}); err == nil {
+		t.Error("expected NewFileRunQueue without a path to error")
+	}
+}
+
+func TestFileRunQueuePushPersistError(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	dir := filepath.Join(tmpdir, "queue")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rq, err := NewFileRunQueue(ctx, event.NilBus, RunQueueOptions{
+		Interval: time.Hour,
+		Workers:  1,
+		Path:     filepath.Join(dir, "run_queue.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// runs that can't be persisted aren't queued
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	noop := func(ctx context.Context) error { return nil }
+	if err := rq.Push(ctx, "owner", "workflow_a", "run_a", "run", noop); err == nil {
+		t.Fatal("expected push to error when the queue can't be persisted")
+	}
+	if _, err := rq.Pop(ctx); !errors.Is(err, ErrEmptyQueue) {
+		t.Errorf("expected failed push to leave the queue empty, got: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
+)
+
+// ErrRunEventsNotFound indicates the events of a run aren't available to
+// follow, either because the run doesn't exist or it finished long enough ago
+// that its events are no longer kept
+var ErrRunEventsNotFound = fmt.Errorf("run events not found")
+
+const (
+	// runStreamLimit is the number of run event streams kept in memory.
+	// Streams are dropped in the order runs started once the limit is exceeded
+	runStreamLimit = 100
+	// runStreamEventLimit is the number of events kept by a run stream. The
+	// oldest events are dropped once the limit is exceeded
+	runStreamEventLimit = 10000
+)
+
+// followedEventTypes are the run events sent to followers: run & step
+// lifecycle events and script output
+var followedEventTypes = map[event.Type]bool{
+	event.ETTransformStart:     true,
+	event.ETTransformStop:      true,
+	event.ETTransformStepStart: true,
+	event.ETTransformStepStop:  true,
+	event.ETTransformStepSkip:  true,
+	event.ETTransformPrint:     true,
+	event.ETTransformError:     true,
+	event.ETTransformCanceled:  true,
+}
+
+// RunEvent is an event emitted by a run, numbered by its position in the
+// run's event stream. IDs start at 1
+type RunEvent struct {
+	ID    int
+	Event event.Event
+}
+
+// runStream records the followed events of a single run
+type runStream struct {
+	// profile that owns the run. Only the owner may follow the stream
+	ownerID profile.ID
+
+	lk     sync.Mutex
+	events []event.Event
+	// number of events dropped from the start of events
+	dropped int
+	done    bool
+	// changed is closed & replaced each time the stream changes
+	changed chan struct{}
+}
+
+func newRunStream(ownerID profile.ID) *runStream {
+	return &runStream{ownerID: ownerID, changed: make(chan struct{})}
+}
+
+func (s *runStream) add(e event.Event) {
+	if !followedEventTypes[e.Type] {
+		return
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.done {
+		return
+	}
+	s.events = append(s.events, e)
+	if len(s.events) > runStreamEventLimit {
+		// drop a tenth of the limit at a time so events aren't copied on
+		// every add
+		n := len(s.events) - runStreamEventLimit + runStreamEventLimit/10
+		s.events = append([]event.Event(nil), s.events[n:]...)
+		s.dropped += n
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *runStream) finish() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.done {
+		return
+	}
+	s.done = true
+	close(s.changed)
+}
+
+// since returns events after the given event ID, whether the stream is
+// finished, and a channel that closes when the stream next changes. Events
+// that have been dropped are skipped
+func (s *runStream) since(lastEventID int) ([]RunEvent, bool, <-chan struct{}) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	var events []RunEvent
+	for i := lastEventID - s.dropped; i < len(s.events); i++ {
+		if i < 0 {
+			continue
+		}
+		events = append(events, RunEvent{ID: s.dropped + i + 1, Event: s.events[i]})
+	}
+	return events, s.done, s.changed
+}
+
+// follow calls fn with each event after lastEventID until the stream finishes,
+// fn returns an error, or the context is done
+func (s *runStream) follow(ctx context.Context, lastEventID int, fn func(RunEvent) error) error {
+	for {
+		events, done, changed := s.since(lastEventID)
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+			lastEventID = e.ID
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runStreams holds the event streams of in-progress & recently finished runs
+type runStreams struct {
+	lk      sync.Mutex
+	streams map[string]*runStream
+	// run IDs in the order streams were opened
+	order []string
+}
+
+func newRunStreams() *runStreams {
+	return &runStreams{streams: map[string]*runStream{}}
+}
+
+// open returns the stream for a run, creating it for the given owner if it
+// doesn't exist
+func (rs *runStreams) open(runID string, ownerID profile.ID) *runStream {
+	rs.lk.Lock()
+	defer rs.lk.Unlock()
+	if s, ok := rs.streams[runID]; ok {
+		return s
+	}
+
+	s := newRunStream(ownerID)
+	rs.streams[runID] = s
+	rs.order = append(rs.order, runID)
+	for len(rs.order) > runStreamLimit {
+		delete(rs.streams, rs.order[0])
+		rs.order = rs.order[1:]
+	}
+	return s
+}
+
+func (rs *runStreams) get(runID string) (*runStream, bool) {
+	rs.lk.Lock()
+	defer rs.lk.Unlock()
+	s, ok := rs.streams[runID]
+	return s, ok
+}
+
+// storedRunEvents reconstructs the followed events of the latest attempt of a
+// stored run, for runs whose stream is no longer kept
+func storedRunEvents(r *run.State) []event.Event {
+	var events []event.Event
+	add := func(typ event.Type, t *time.Time, payload interface{}) {
+		e := event.Event{Type: typ, SessionID: r.ID, Payload: payload}
+		if t != nil {
+			e.Timestamp = t.UnixNano()
+		}
+		events = append(events, e)
+	}
+
+	if r.StartTime != nil {
+		add(event.ETTransformStart, r.StartTime, event.TransformLifecycle{RunID: r.ID, StepCount: len(r.Steps), InitID: r.InitID})
+	}
+	for _, step := range r.Steps {
+		if step.Status == run.RSSkipped {
+			add(event.ETTransformStepSkip, step.StartTime, event.TransformStepLifecycle{Name: step.Name, Category: step.Category, Status: string(step.Status)})
+			continue
+		}
+		add(event.ETTransformStepStart, step.StartTime, event.TransformStepLifecycle{Name: step.Name, Category: step.Category})
+		for _, e := range step.Output {
+			if followedEventTypes[e.Type] {
+				events = append(events, e)
+			}
+		}
+		if step.StopTime != nil {
+			add(event.ETTransformStepStop, step.StopTime, event.TransformStepLifecycle{Name: step.Name, Category: step.Category, Status: string(step.Status)})
+		}
+	}
+	if r.StopTime != nil {
+		add(event.ETTransformStop, r.StopTime, event.TransformLifecycle{RunID: r.ID, StepCount: len(r.Steps), Status: string(r.Status), InitID: r.InitID})
+	}
+	return events
+}
@@ -0,0 +1,162 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/event"
+)
+
+func TestRunStreamFollow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	s := newRunStream("")
+	s.add(event.Event{Type: event.ETTransformStart})
+	s.add(event.Event{Type: event.ETTransformDatasetPreview})
+	s.add(event.Event{Type: event.ETTransformPrint, Payload: event.TransformMessage{Msg: "one"}})
+
+	got := []RunEvent{}
+	errCh := make(chan error)
+	go func() {
+		errCh <- s.follow(ctx, 0, func(e RunEvent) error {
+			got = append(got, e)
+			return nil
+		})
+	}()
+
+	s.add(event.Event{Type: event.ETTransformPrint, Payload: event.TransformMessage{Msg: "two"}})
+	s.add(event.Event{Type: event.ETTransformStop})
+	s.finish()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []event.Type{event.ETTransformStart, event.ETTransformPrint, event.ETTransformPrint, event.ETTransformStop}
+	if len(got) != len(expect) {
+		t.Fatalf("expected %d events, got %d: %v", len(expect), len(got), got)
+	}
+	for i, e := range got {
+		if e.ID != i+1 {
+			t.Errorf("event %d ID mismatch. want: %d, got: %d", i, i+1, e.ID)
+		}
+		if e.Event.Type != expect[i] {
+			t.Errorf("event %d type mismatch. want: %q, got: %q", i, expect[i], e.Event.Type)
+		}
+	}
+
+	// resuming after an event only sends later events
+	resumed := []RunEvent{}
+	if err := s.follow(ctx, 2, func(e RunEvent) error {
+		resumed = append(resumed, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed) != 2 || resumed[0].ID != 3 {
+		t.Errorf("expected events 3 & 4 when resuming after event 2. got: %v", resumed)
+	}
+
+	// following an unfinished stream stops when the context is done
+	unfinished := newRunStream("")
+	cctx, ccancel := context.WithCancel(ctx)
+	ccancel()
+	if err := unfinished.follow(cctx, 0, func(RunEvent) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled following a canceled context. got: %v", err)
+	}
+}
+
+func TestRunStreamsLimit(t *testing.T) {
+	rs := newRunStreams()
+	first := rs.open("run_0", "")
+	if rs.open("run_0", "") != first {
+		t.Errorf("expected opening an existing run stream to return the same stream")
+	}
+	for i := 1; i <= runStreamLimit; i++ {
+		rs.open(fmt.Sprintf("run_%d", i), "")
+	}
+	if _, ok := rs.get("run_0"); ok {
+		t.Errorf("expected the oldest stream to be dropped once the limit is exceeded")
+	}
+	if len(rs.streams) != runStreamLimit {
+		t.Errorf("expected %d streams. got: %d", runStreamLimit, len(rs.streams))
+	}
+}
+
+func TestRunStreamEventLimit(t *testing.T) {
+	s := newRunStream("")
+	total := runStreamEventLimit + 1
+	for i := 0; i < total; i++ {
+		s.add(event.Event{Type: event.ETTransformPrint})
+	}
+	s.finish()
+	if len(s.events) > runStreamEventLimit {
+		t.Errorf("expected at most %d events to be kept. got: %d", runStreamEventLimit, len(s.events))
+	}
+
+	events, done, _ := s.since(0)
+	if !done {
+		t.Errorf("expected stream to be done")
+	}
+	if len(events) != len(s.events) {
+		t.Fatalf("expected all kept events to be sent. got: %d", len(events))
+	}
+	if first := events[0].ID; first != s.dropped+1 {
+		t.Errorf("expected the first kept event to be numbered after dropped events. want: %d got: %d", s.dropped+1, first)
+	}
+	if last := events[len(events)-1].ID; last != total {
+		t.Errorf("expected event IDs to be stable after dropping events. want: %d got: %d", total, last)
+	}
+	if resumed, _, _ := s.since(total - 1); len(resumed) != 1 || resumed[0].ID != total {
+		t.Errorf("expected resuming to send the last event. got: %v", resumed)
+	}
+}
+
+func TestStoredRunEvents(t *testing.T) {
+	start := time.Unix(1234567890, 0)
+	stop := start.Add(time.Second)
+	r := &run.State{
+		ID:        "run_a",
+		Status:    run.RSSucceeded,
+		StartTime: &start,
+		StopTime:  &stop,
+		Steps: []*run.StepState{
+			{
+				Name:      "setup",
+				Status:    run.RSSucceeded,
+				StartTime: &start,
+				StopTime:  &stop,
+				Output: []event.Event{
+					{Type: event.ETTransformPrint, SessionID: "run_a", Payload: event.TransformMessage{Msg: "hello"}},
+					{Type: event.ETTransformDatasetPreview, SessionID: "run_a"},
+				},
+			},
+			{Name: "download", Status: run.RSSkipped},
+		},
+	}
+
+	expect := []event.Type{
+		event.ETTransformStart,
+		event.ETTransformStepStart,
+		event.ETTransformPrint,
+		event.ETTransformStepStop,
+		event.ETTransformStepSkip,
+		event.ETTransformStop,
+	}
+	got := storedRunEvents(r)
+	if len(got) != len(expect) {
+		t.Fatalf("expected %d events, got %d: %v", len(expect), len(got), got)
+	}
+	for i, e := range got {
+		if e.Type != expect[i] {
+			t.Errorf("event %d type mismatch. want: %q, got: %q", i, expect[i], e.Type)
+		}
+	}
+	if tl, ok := got[len(got)-1].Payload.(event.TransformLifecycle); !ok || tl.Status != string(run.RSSucceeded) {
+		t.Errorf("expected stop event to carry the run status. got: %#v", got[len(got)-1].Payload)
+	}
+}
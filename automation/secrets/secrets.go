@@ -0,0 +1,325 @@
+// Package secrets stores transform secrets for workflows. Secrets are
+// encrypted at rest with a symmetric key derived from the owner's private key,
+// and scoped to a single workflow
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	golog "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/qri-io/qri/automation/workflow"
+)
+
+var log = golog.Logger("secrets")
+
+var (
+	// ErrNotFound indicates a secret doesn't exist
+	ErrNotFound = fmt.Errorf("secret not found")
+	// ErrDecrypt indicates stored secrets couldn't be decrypted, usually
+	// because they were encrypted with a different key
+	ErrDecrypt = fmt.Errorf("cannot decrypt secrets")
+)
+
+// Filename is the name of the file secrets are stored in within a repo
+const Filename = "secrets.json"
+
+// validName matches secret names, which must be usable as map keys in
+// transform scripts & safe to print in listings
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-\.]*$`)
+
+// ValidateName returns an error if name isn't a valid secret name
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: names must start with a letter or underscore and contain only letters, numbers, underscores, dashes & dots", name)
+	}
+	return nil
+}
+
+// Store keeps secrets for workflows. Secret values are never listed, only
+// returned in full to run a workflow
+type Store interface {
+	// Set stores a secret for a workflow, replacing any existing value
+	Set(ctx context.Context, wid workflow.ID, name, value string) error
+	// Names lists the names of a workflow's secrets in sorted order
+	Names(ctx context.Context, wid workflow.ID) ([]string, error)
+	// Secrets returns all secrets stored for a workflow, decrypted
+	Secrets(ctx context.Context, wid workflow.ID) (map[string]string, error)
+	// Remove deletes a secret, returning ErrNotFound if it doesn't exist
+	Remove(ctx context.Context, wid workflow.ID, name string) error
+	// RemoveAll deletes all secrets for a workflow
+	RemoveAll(ctx context.Context, wid workflow.ID) error
+}
+
+// store implements Store, encrypting the secrets of each workflow as a single
+// sealed blob. When path is empty secrets are only kept in memory
+type store struct {
+	path string
+	aead cipher.AEAD
+
+	lock sync.Mutex
+	// sealed maps workflow IDs to encrypted secrets
+	sealed map[workflow.ID][]byte
+}
+
+var _ Store = (*store)(nil)
+
+// NewMemStore creates a secret store that keeps encrypted secrets in memory
+func NewMemStore(pk crypto.PrivKey) (Store, error) {
+	aead, err := newAEAD(pk)
+	if err != nil {
+		return nil, err
+	}
+	return &store{aead: aead, sealed: map[workflow.ID][]byte{}}, nil
+}
+
+// NewFileStore creates a secret store that persists encrypted secrets to a
+// file within the repo
+func NewFileStore(repoPath string, pk crypto.PrivKey) (Store, error) {
+	aead, err := newAEAD(pk)
+	if err != nil {
+		return nil, err
+	}
+	s := &store{
+		path:   filepath.Join(repoPath, Filename),
+		aead:   aead,
+		sealed: map[workflow.ID][]byte{},
+	}
+	return s, s.loadFromFile()
+}
+
+// newAEAD derives an AES-256-GCM cipher from a private key
+func newAEAD(pk crypto.PrivKey) (cipher.AEAD, error) {
+	if pk == nil {
+		return nil, fmt.Errorf("a private key is required to encrypt secrets")
+	}
+	raw, err := pk.Raw()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write([]byte("qri secrets v1\x00"))
+	h.Write(raw)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Set stores a secret for a workflow
+func (s *store) Set(ctx context.Context, wid workflow.ID, name, value string) error {
+	if wid == "" {
+		return fmt.Errorf("workflow ID is required")
+	}
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secrets, err := s.open(wid)
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	sealed, err := s.seal(wid, secrets)
+	if err != nil {
+		return err
+	}
+	return s.update(wid, sealed)
+}
+
+// Names lists the names of a workflow's secrets
+func (s *store) Names(ctx context.Context, wid workflow.ID) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secrets, err := s.open(wid)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Secrets returns all secrets for a workflow
+func (s *store) Secrets(ctx context.Context, wid workflow.ID) (map[string]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.open(wid)
+}
+
+// Remove deletes a secret
+func (s *store) Remove(ctx context.Context, wid workflow.ID, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secrets, err := s.open(wid)
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	delete(secrets, name)
+	if len(secrets) == 0 {
+		return s.update(wid, nil)
+	}
+	sealed, err := s.seal(wid, secrets)
+	if err != nil {
+		return err
+	}
+	return s.update(wid, sealed)
+}
+
+// RemoveAll deletes all secrets for a workflow
+func (s *store) RemoveAll(ctx context.Context, wid workflow.ID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.sealed[wid]; !ok {
+		return nil
+	}
+	return s.update(wid, nil)
+}
+
+// update replaces the sealed secrets of a workflow, removing them if sealed
+// is nil, and persists the store. The previous secrets are restored if
+// persisting fails, so memory never runs ahead of disk. callers must hold the
+// lock
+func (s *store) update(wid workflow.ID, sealed []byte) error {
+	prev, existed := s.sealed[wid]
+	if sealed == nil {
+		delete(s.sealed, wid)
+	} else {
+		s.sealed[wid] = sealed
+	}
+	if err := s.writeToFile(); err != nil {
+		if existed {
+			s.sealed[wid] = prev
+		} else {
+			delete(s.sealed, wid)
+		}
+		return err
+	}
+	return nil
+}
+
+// open decrypts the secrets of a workflow. the workflow ID is authenticated
+// along with the ciphertext, so sealed secrets can't be moved between
+// workflows. callers must hold the lock
+func (s *store) open(wid workflow.ID) (map[string]string, error) {
+	secrets := map[string]string{}
+	data, ok := s.sealed[wid]
+	if !ok {
+		return secrets, nil
+	}
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, ErrDecrypt
+	}
+	plain, err := s.aead.Open(nil, data[:size], data[size:], []byte(wid))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// seal encrypts the secrets of a workflow
+func (s *store) seal(wid workflow.ID, secrets map[string]string) ([]byte, error) {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, []byte(wid)), nil
+}
+
+// fileData is the on-disk format of the secret store
+type fileData struct {
+	Workflows map[workflow.ID][]byte `json:"workflows"`
+}
+
+func (s *store) loadFromFile() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	fd := &fileData{}
+	if err := json.Unmarshal(data, fd); err != nil {
+		return fmt.Errorf("reading secrets file: %w", err)
+	}
+	if fd.Workflows != nil {
+		s.sealed = fd.Workflows
+	}
+	return nil
+}
+
+// writeToFile persists sealed secrets. callers must hold the lock
+func (s *store) writeToFile() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(fileData{Workflows: s.sealed})
+	if err != nil {
+		return err
+	}
+	log.Debugw("writing secrets", "path", s.path)
+	// write to a temp file & rename it into place, so a failed write never
+	// leaves a truncated secrets file. temp files are created readable by the
+	// owner only: secrets are encrypted, but restrict access all the same
+	f, err := ioutil.TempFile(filepath.Dir(s.path), Filename+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
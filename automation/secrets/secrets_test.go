@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	testkeys "github.com/qri-io/qri/auth/key/test"
+	"github.com/qri-io/qri/automation/workflow"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pk := testkeys.GetKeyData(0).PrivKey
+	wid := workflow.ID("workflow_a")
+
+	s, err := NewFileStore(dir, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, wid, "api_key", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, wid, "TOKEN", "sekret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, workflow.ID("workflow_b"), "api_key", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, wid, "bad name!", "value"); err == nil {
+		t.Error("expected setting an invalid secret name to error")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"hunter2", "sekret", "api_key"} {
+		if strings.Contains(string(data), plain) {
+			t.Errorf("expected secrets file not to contain %q in plain text", plain)
+		}
+	}
+
+	// reopening the store with the same key decrypts secrets
+	s, err = NewFileStore(dir, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Secrets(ctx, wid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"api_key": "hunter2", "TOKEN": "sekret"}, got); diff != "" {
+		t.Errorf("secrets mismatch (-want +got):\n%s", diff)
+	}
+	names, err := s.Names(ctx, wid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"TOKEN", "api_key"}, names); diff != "" {
+		t.Errorf("names mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := s.Remove(ctx, wid, "TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(ctx, wid, "TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected removing a missing secret to return ErrNotFound, got: %v", err)
+	}
+	if err := s.RemoveAll(ctx, workflow.ID("workflow_b")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Secrets(ctx, workflow.ID("workflow_b")); len(got) != 0 {
+		t.Errorf("expected removed workflow to have no secrets, got: %v", got)
+	}
+
+	// a different key can't read secrets
+	other, err := NewFileStore(dir, testkeys.GetKeyData(1).PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Secrets(ctx, wid); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected reading secrets with the wrong key to return ErrDecrypt, got: %v", err)
+	}
+}
+
+func TestMemStoreScopesWorkflows(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewMemStore(testkeys.GetKeyData(0).PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, workflow.ID("a"), "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	// sealed secrets are bound to their workflow
+	ms := s.(*store)
+	ms.sealed[workflow.ID("b")] = ms.sealed[workflow.ID("a")]
+	if _, err := s.Secrets(ctx, workflow.ID("b")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected secrets copied to another workflow not to decrypt, got: %v", err)
+	}
+}
+
+func TestFileStoreRollsBackFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pk := testkeys.GetKeyData(0).PrivKey
+	wid := workflow.ID("workflow_a")
+
+	s, err := NewFileStore(dir, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, wid, "api_key", "secret_value"); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing the repo directory makes writes fail
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, wid, "api_key", "new_value"); err == nil {
+		t.Fatal("expected set to error when the secrets file can't be written")
+	}
+	if err := s.Set(ctx, wid, "other", "value"); err == nil {
+		t.Fatal("expected set to error when the secrets file can't be written")
+	}
+	if err := s.Remove(ctx, wid, "api_key"); err == nil {
+		t.Fatal("expected remove to error when the secrets file can't be written")
+	}
+	got, err := s.Secrets(ctx, wid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"api_key": "secret_value"}, got); diff != "" {
+		t.Errorf("expected failed writes to be rolled back (-want +got):\n%s", diff)
+	}
+}
@@ -2,6 +2,7 @@ package spec
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/qri-io/qri/automation/run"
 	"github.com/qri-io/qri/automation/workflow"
 	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/event"
 )
 
 // AssertRunStore confirms the expected behavior of a run.Store interface
@@ -104,6 +106,96 @@ func AssertRunStore(t *testing.T, store run.Store) {
 	if diff := cmp.Diff(expectedRuns[:1], gotRuns); diff != "" {
 		t.Errorf("store.ListByStatus mismatch (-want +got): \n%s", diff)
 	}
+
+	assertCompact(ctx, t, store)
+}
+
+// assertCompact confirms the expected behavior of the run.Store's Compact
+// method
+func assertCompact(ctx context.Context, t *testing.T, store run.Store) {
+	wid := workflow.ID("compact test id")
+	now := time.Now()
+	output := []event.Event{
+		{Type: event.ETTransformPrint, Timestamp: 1, SessionID: "compact", Payload: event.TransformMessage{Msg: "first"}},
+		{Type: event.ETTransformPrint, Timestamp: 2, SessionID: "compact", Payload: event.TransformMessage{Msg: "second"}},
+		{Type: event.ETTransformPrint, Timestamp: 3, SessionID: "compact", Payload: event.TransformMessage{Msg: "third"}},
+	}
+	stopTimes := []time.Time{
+		now.Add(-72 * time.Hour),
+		now.Add(-48 * time.Hour),
+		now.Add(-time.Hour),
+		now,
+	}
+	runs := make([]*run.State, len(stopTimes))
+	for i := range stopTimes {
+		r := &run.State{
+			WorkflowID: wid,
+			Status:     run.RSSucceeded,
+			StopTime:   &stopTimes[i],
+			Steps:      []*run.StepState{{Name: "transform", Output: output}},
+		}
+		created, err := store.Create(ctx, r)
+		if err != nil {
+			t.Fatalf("store.Create unexpected error: %s", err)
+		}
+		runs[i] = created
+	}
+
+	if err := store.Compact(ctx, run.RetentionPolicy{}); err != nil {
+		t.Fatalf("store.Compact unexpected error: %s", err)
+	}
+	if got, err := store.List(ctx, wid, params.ListAll); err != nil || len(got) != 4 {
+		t.Fatalf("store.Compact with an empty policy should keep all runs, got %d runs, error: %v", len(got), err)
+	}
+
+	if err := store.Compact(ctx, run.RetentionPolicy{MaxRunsPerWorkflow: 3}); err != nil {
+		t.Fatalf("store.Compact unexpected error: %s", err)
+	}
+	if _, err := store.Get(ctx, runs[0].ID); !errors.Is(err, run.ErrNotFound) {
+		t.Errorf("store.Compact should remove runs beyond MaxRunsPerWorkflow, expected run.ErrNotFound, got: %v", err)
+	}
+
+	if err := store.Compact(ctx, run.RetentionPolicy{MaxAge: 24 * time.Hour}); err != nil {
+		t.Fatalf("store.Compact unexpected error: %s", err)
+	}
+	if _, err := store.Get(ctx, runs[1].ID); !errors.Is(err, run.ErrNotFound) {
+		t.Errorf("store.Compact should remove runs older than MaxAge, expected run.ErrNotFound, got: %v", err)
+	}
+	got, err := store.List(ctx, wid, params.ListAll)
+	if err != nil {
+		t.Fatalf("store.List unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(runs[2:], []*run.State{got[1], got[0]}); diff != "" {
+		t.Errorf("store.Compact kept runs mismatch (-want +got):\n%s", diff)
+	}
+	if count, err := store.Count(ctx, wid); err != nil || count != 4 {
+		t.Errorf("store.Compact should not change the run count, expected 4, got %d, error: %v", count, err)
+	}
+
+	lastEvent, err := json.Marshal(output[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(ctx, run.RetentionPolicy{MaxOutputSize: int64(len(lastEvent))}); err != nil {
+		t.Fatalf("store.Compact unexpected error: %s", err)
+	}
+	truncated, err := store.Get(ctx, runs[2].ID)
+	if err != nil {
+		t.Fatalf("store.Get unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(output[2:], truncated.Steps[0].Output); diff != "" {
+		t.Errorf("store.Compact should keep the most recent output that fits in MaxOutputSize (-want +got):\n%s", diff)
+	}
+	if truncated.Steps[0].TruncatedOutput == 0 {
+		t.Errorf("store.Compact should record the amount of output truncated from a step")
+	}
+
+	if err := store.Compact(ctx, run.RetentionPolicy{MaxAge: time.Nanosecond}); err != nil {
+		t.Fatalf("store.Compact unexpected error: %s", err)
+	}
+	if _, err := store.GetLatest(ctx, wid); err != nil {
+		t.Errorf("store.Compact should always keep the most recent run of a workflow, got: %s", err)
+	}
 }
 
 // assertCreatePut confirms the expected behavior of the run.Store's Put method
@@ -174,6 +174,9 @@ func AssertWorkflowLister(t *testing.T, store workflow.Store) {
 		}
 		if i%2 == 0 {
 			wf.Active = true
+			if wf, err = store.Put(ctx, wf); err != nil {
+				t.Fatal(err)
+			}
 			expectedDeployedWorkflows[4-(i/2)] = wf
 		}
 		expectedAllWorkflows[9-i] = wf
@@ -0,0 +1,297 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+)
+
+// DatasetType denotes a `DatasetTrigger`
+const DatasetType = "dataset"
+
+// A DatasetTrigger implements the Trigger interface & fires when a new
+// version of an upstream dataset is saved or pulled. Chaining workflows
+// with DatasetTriggers builds a graph of transforms that re-run in
+// dependency order
+type DatasetTrigger struct {
+	id     string
+	active bool
+	// initID is the InitID of the upstream dataset
+	initID string
+	// ref is the human-readable reference to the upstream dataset, kept for
+	// display purposes
+	ref          string
+	AdvanceCount int
+}
+
+var _ Trigger = (*DatasetTrigger)(nil)
+
+// NewEmptyDatasetTrigger returns a DatasetTrigger that fires when the dataset
+// with the given InitID gets a new version
+func NewEmptyDatasetTrigger(initID string) *DatasetTrigger {
+	return &DatasetTrigger{
+		id:     NewID(),
+		active: false,
+		initID: initID,
+	}
+}
+
+// NewDatasetTrigger constructs a DatasetTrigger from a configuration object
+func NewDatasetTrigger(opt map[string]interface{}) (Trigger, error) {
+	t := opt["type"]
+	if t != DatasetType {
+		return nil, fmt.Errorf("%w, expected %q but got %q", ErrTypeMismatch, DatasetType, t)
+	}
+	if initID, _ := opt["initID"].(string); initID == "" {
+		return nil, fmt.Errorf("field %q required", "initID")
+	}
+
+	data, err := json.Marshal(opt)
+	if err != nil {
+		return nil, err
+	}
+	dt := &DatasetTrigger{}
+	err = dt.UnmarshalJSON(data)
+
+	if dt.id == "" {
+		dt.id = NewID()
+	}
+	return dt, err
+}
+
+// ID return the trigger.ID
+func (dt *DatasetTrigger) ID() string {
+	return dt.id
+}
+
+// Active returns if the DatasetTrigger is active
+func (dt *DatasetTrigger) Active() bool {
+	return dt.active
+}
+
+// SetActive sets the active status
+func (dt *DatasetTrigger) SetActive(active bool) error {
+	dt.active = active
+	return nil
+}
+
+// Type returns the DatasetType
+func (dt *DatasetTrigger) Type() string {
+	return DatasetType
+}
+
+// InitID returns the InitID of the upstream dataset
+func (dt *DatasetTrigger) InitID() string {
+	return dt.initID
+}
+
+// Advance increments the AdvanceCount
+func (dt *DatasetTrigger) Advance() error {
+	dt.AdvanceCount++
+	return nil
+}
+
+// ToMap returns the trigger as a map[string]interface{}
+func (dt *DatasetTrigger) ToMap() map[string]interface{} {
+	v := map[string]interface{}{
+		"id":           dt.id,
+		"active":       dt.active,
+		"type":         DatasetType,
+		"initID":       dt.initID,
+		"advanceCount": dt.AdvanceCount,
+	}
+	if dt.ref != "" {
+		v["ref"] = dt.ref
+	}
+	return v
+}
+
+type datasetTrigger struct {
+	ID           string `json:"id"`
+	Active       bool   `json:"active"`
+	Type         string `json:"type"`
+	InitID       string `json:"initID"`
+	Ref          string `json:"ref,omitempty"`
+	AdvanceCount int    `json:"advanceCount"`
+}
+
+// MarshalJSON implements the json.Marshaller interface
+func (dt *DatasetTrigger) MarshalJSON() ([]byte, error) {
+	if dt == nil {
+		dt = &DatasetTrigger{}
+	}
+	return json.Marshal(datasetTrigger{
+		ID:           dt.ID(),
+		Active:       dt.active,
+		Type:         dt.Type(),
+		InitID:       dt.initID,
+		Ref:          dt.ref,
+		AdvanceCount: dt.AdvanceCount,
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface
+func (dt *DatasetTrigger) UnmarshalJSON(d []byte) error {
+	t := &datasetTrigger{}
+	err := json.Unmarshal(d, t)
+	if err != nil {
+		return err
+	}
+	if t.Type != DatasetType {
+		return fmt.Errorf("%w, got %s, expected %s", ErrUnexpectedType, t.Type, DatasetType)
+	}
+	*dt = DatasetTrigger{
+		id:           t.ID,
+		active:       t.Active,
+		initID:       t.InitID,
+		ref:          t.Ref,
+		AdvanceCount: t.AdvanceCount,
+	}
+	return nil
+}
+
+// DatasetTriggerDependencies returns the InitIDs of all upstream datasets
+// referenced by DatasetTriggers in a list of trigger options. Misshaped
+// trigger options are ignored
+func DatasetTriggerDependencies(triggerOpts []map[string]interface{}) []string {
+	deps := []string{}
+	for _, opt := range triggerOpts {
+		if opt["type"] != DatasetType {
+			continue
+		}
+		if initID, ok := opt["initID"].(string); ok && initID != "" {
+			deps = append(deps, initID)
+		}
+	}
+	return deps
+}
+
+// DatasetListener listens for new versions of datasets referenced by
+// DatasetTriggers
+type DatasetListener struct {
+	bus       event.Bus
+	listening bool
+	triggers  *Set
+	// lastPaths tracks the most recent version path that fired triggers for
+	// each upstream InitID, so a version that is both saved & pulled only
+	// fires once
+	lastPathsLk sync.Mutex
+	lastPaths   map[string]string
+}
+
+var _ Listener = (*DatasetListener)(nil)
+
+// NewDatasetListener creates a DatasetListener that subscribes to dataset
+// save and pull events on the given bus. Any events published before the
+// DatasetListener has been started using `datasetListener.Start(ctx)` will
+// be ignored
+func NewDatasetListener(bus event.Bus) *DatasetListener {
+	l := &DatasetListener{
+		bus:       bus,
+		triggers:  NewSet(DatasetType, NewDatasetTrigger),
+		lastPaths: map[string]string{},
+	}
+	bus.SubscribeTypes(l.handleDatasetEvent,
+		event.ETDatasetSaveCompleted,
+		event.ETDatasetPulled,
+	)
+	return l
+}
+
+// ConstructTrigger binds NewDatasetTrigger to DatasetListener
+func (l *DatasetListener) ConstructTrigger(opt map[string]interface{}) (Trigger, error) {
+	return NewDatasetTrigger(opt)
+}
+
+// Listen takes a list of sources and adds or updates the Listener's
+// store to include all the active triggers of the correct type
+func (l *DatasetListener) Listen(sources ...Source) error {
+	return l.triggers.Add(sources...)
+}
+
+// Type returns the Type `DatasetType`
+func (l *DatasetListener) Type() string {
+	return DatasetType
+}
+
+// Start tells the DatasetListener to begin actively listening for new
+// dataset versions
+func (l *DatasetListener) Start(ctx context.Context) error {
+	l.listening = true
+	go func() {
+		<-ctx.Done()
+		l.Stop()
+	}()
+	return nil
+}
+
+// Stop tells the DatasetListener to stop actively listening for new dataset
+// versions
+func (l *DatasetListener) Stop() error {
+	l.listening = false
+	return nil
+}
+
+// TriggersExists returns true if triggers in the source match the triggers
+// stored in the dataset listener
+func (l *DatasetListener) TriggersExists(source Source) bool {
+	return l.triggers.Exists(source)
+}
+
+func (l *DatasetListener) handleDatasetEvent(ctx context.Context, e event.Event) error {
+	if !l.listening {
+		return nil
+	}
+
+	var initID, path string
+	switch p := e.Payload.(type) {
+	case event.DsSaveEvent:
+		if p.Error != nil {
+			return nil
+		}
+		initID, path = p.InitID, p.Path
+	case dsref.VersionInfo:
+		initID, path = p.InitID, p.Path
+	default:
+		return nil
+	}
+	if initID == "" || path == "" {
+		return nil
+	}
+
+	l.lastPathsLk.Lock()
+	if l.lastPaths[initID] == path {
+		l.lastPathsLk.Unlock()
+		return nil
+	}
+	l.lastPaths[initID] = path
+	l.lastPathsLk.Unlock()
+
+	l.triggers.activeLock.Lock()
+	wtes := []event.WorkflowTriggerEvent{}
+	for ownerID, wids := range l.triggers.active {
+		for workflowID, triggers := range wids {
+			for _, trig := range triggers {
+				if t, ok := trig.(*DatasetTrigger); ok && t.initID == initID {
+					wtes = append(wtes, event.WorkflowTriggerEvent{
+						WorkflowID: workflowID,
+						OwnerID:    ownerID,
+						TriggerID:  t.ID(),
+					})
+				}
+			}
+		}
+	}
+	l.triggers.activeLock.Unlock()
+
+	for _, wte := range wtes {
+		if err := l.bus.Publish(ctx, event.ETAutomationWorkflowTrigger, wte); err != nil {
+			log.Debugw("DatasetListener: publish ETAutomationWorkflowTrigger", "error", err, "WorkflowTriggerEvent", wte)
+		}
+	}
+	return nil
+}
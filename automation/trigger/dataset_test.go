@@ -0,0 +1,116 @@
+package trigger_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/qri-io/qri/automation/spec"
+	"github.com/qri-io/qri/automation/trigger"
+	"github.com/qri-io/qri/automation/workflow"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+)
+
+func TestDatasetTrigger(t *testing.T) {
+	dt := trigger.NewEmptyDatasetTrigger("upstream_init_id")
+	adv := dt.ToMap()
+	adv["advanceCount"] = 1
+	spec.AssertTrigger(t, dt, adv)
+
+	if _, err := trigger.NewDatasetTrigger(map[string]interface{}{"type": trigger.DatasetType}); err == nil {
+		t.Error("expected constructing a dataset trigger without an initID to error")
+	}
+}
+
+func TestDatasetListener(t *testing.T) {
+	wf := &workflow.Workflow{
+		ID:      workflow.ID("test workflow id"),
+		OwnerID: "test Owner id",
+		Active:  true,
+	}
+	upstreamInitID := "upstream_init_id"
+
+	listenerConstructor := func(ctx context.Context, bus event.Bus) (trigger.Listener, func(), func()) {
+		dl := trigger.NewDatasetListener(bus)
+		triggerOpts := map[string]interface{}{
+			"active": true,
+			"type":   trigger.DatasetType,
+			"initID": upstreamInitID,
+		}
+
+		trig, err := dl.ConstructTrigger(triggerOpts)
+		if err != nil {
+			t.Fatalf("DatasetListener.ConstructTrigger unexpected error: %s", err)
+		}
+		dt, ok := trig.(*trigger.DatasetTrigger)
+		if !ok {
+			t.Fatal("DatasetListener.ConstructTrigger did not return a DatasetTrigger")
+		}
+
+		versions := 0
+		activateTrigger := func() {
+			versions++
+			path := fmt.Sprintf("/mem/QmVersion%d", versions)
+			// alternate between saves & pulls, both should fire the trigger
+			if versions%2 == 0 {
+				bus.Publish(ctx, event.ETDatasetPulled, dsref.VersionInfo{
+					InitID: upstreamInitID,
+					Path:   path,
+				})
+				return
+			}
+			bus.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{
+				InitID: upstreamInitID,
+				Path:   path,
+			})
+		}
+		advanceTrigger := func() {}
+
+		wf.Triggers = []map[string]interface{}{dt.ToMap()}
+		if err := dl.Listen(wf); err != nil {
+			t.Fatalf("DatasetListener.Listen unexpected error: %s", err)
+		}
+		return dl, activateTrigger, advanceTrigger
+	}
+	spec.AssertListener(t, listenerConstructor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(ctx)
+	l, _, _ := listenerConstructor(ctx, bus)
+	dl, ok := l.(*trigger.DatasetListener)
+	if !ok {
+		t.Fatal("DatasetListener unexpected assertion error, listenerConstructor should return a DatasetListener")
+	}
+	if err := dl.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	triggered := 0
+	bus.SubscribeTypes(func(ctx context.Context, e event.Event) error {
+		triggered++
+		return nil
+	}, event.ETAutomationWorkflowTrigger)
+
+	saved := event.DsSaveEvent{InitID: upstreamInitID, Path: "/mem/QmSameVersion"}
+	bus.Publish(ctx, event.ETDatasetSaveCompleted, saved)
+	bus.Publish(ctx, event.ETDatasetPulled, dsref.VersionInfo{InitID: upstreamInitID, Path: saved.Path})
+	if triggered != 1 {
+		t.Errorf("expected saving & pulling the same version to trigger once, triggered %d times", triggered)
+	}
+
+	bus.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{InitID: "other_init_id", Path: "/mem/QmOther"})
+	bus.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{InitID: upstreamInitID, Error: fmt.Errorf("oh noes")})
+	if triggered != 1 {
+		t.Errorf("expected unrelated datasets & failed saves not to trigger, triggered %d times", triggered)
+	}
+
+	wf.Triggers = []map[string]interface{}{}
+	if err := dl.Listen(wf); err != nil {
+		t.Fatalf("DatasetListener.Listen unexpected error: %s", err)
+	}
+	if dl.TriggersExists(wf) {
+		t.Errorf("DatasetListener.Listen error: should remove triggers from its internal store when given an updated workflow with a no longer active trigger")
+	}
+}
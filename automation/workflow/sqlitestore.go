@@ -0,0 +1,240 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/profile"
+	// register the "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteFilename is the name of the database file SQLite-backed automation
+// stores create within a repo
+const SQLiteFilename = "automation.sqlite"
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS workflows (
+		id       TEXT PRIMARY KEY,
+		init_id  TEXT NOT NULL UNIQUE,
+		owner_id TEXT NOT NULL,
+		created  INTEGER NOT NULL,
+		active   INTEGER NOT NULL,
+		data     BLOB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS workflows_created ON workflows (created DESC)`,
+	`CREATE INDEX IF NOT EXISTS workflows_active_created ON workflows (active, created DESC)`,
+}
+
+// sqliteStore is a store implementation backed by a SQLite database. Workflows
+// are stored as JSON alongside indexed columns used for listing.
+// sqliteStore is safe for concurrent use
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// compile-time assertion that sqliteStore is a Store
+var _ Store = (*sqliteStore)(nil)
+
+// NewSQLiteStore creates a workflow store that persists to a SQLite database
+// within the repo
+func NewSQLiteStore(repoPath string) (Store, error) {
+	db, err := OpenSQLite(filepath.Join(repoPath, SQLiteFilename))
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating workflow tables: %w", err)
+		}
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// OpenSQLite opens a SQLite database at path, configured for use by automation
+// stores
+func OpenSQLite(path string) (*sql.DB, error) {
+	// pragmas in the connection string apply to every connection. WAL mode
+	// lets readers proceed while workflow & run stores sharing a database
+	// write, and the busy timeout waits out the other store's writes
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, serialize access within a store
+	// through one connection
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Put adds a Workflow to the store
+func (s *sqliteStore) Put(ctx context.Context, wf *Workflow) (*Workflow, error) {
+	if wf == nil {
+		return nil, ErrNilWorkflow
+	}
+	w := wf.Copy()
+	if w.ID == "" {
+		_, err := s.GetByInitID(ctx, w.InitID)
+		if err == nil {
+			return nil, ErrWorkflowForDatasetExists
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		w.ID = NewID()
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO workflows (id, init_id, owner_id, created, active, data)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			init_id = excluded.init_id,
+			owner_id = excluded.owner_id,
+			created = excluded.created,
+			active = excluded.active,
+			data = excluded.data`,
+		w.ID.String(), w.InitID, w.OwnerID.Encode(), w.Created.UnixNano(), w.Active, data)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get fetches a Workflow using the associated ID
+func (s *sqliteStore) Get(ctx context.Context, wid ID) (*Workflow, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM workflows WHERE id = ?`, wid.String())
+	return scanWorkflow(row)
+}
+
+// GetByInitID fetches a Workflow using the dataset ID
+func (s *sqliteStore) GetByInitID(ctx context.Context, initID string) (*Workflow, error) {
+	if initID == "" {
+		return nil, ErrNotFound
+	}
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM workflows WHERE init_id = ?`, initID)
+	return scanWorkflow(row)
+}
+
+// Remove removes a Workflow from the store
+func (s *sqliteStore) Remove(ctx context.Context, id ID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM workflows WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List lists all the workflows in the store, by decending order from time of
+// creation
+func (s *sqliteStore) List(ctx context.Context, pid profile.ID, lp params.List) ([]*Workflow, error) {
+	limit, offset, err := sqliteLimitOffset(lp)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return []*Workflow{}, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM workflows
+		ORDER BY created DESC
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanWorkflows(rows)
+}
+
+// ListDeployed lists all the workflows in the store that are deployed, by
+// decending order from time of creation
+func (s *sqliteStore) ListDeployed(ctx context.Context, pid profile.ID, lp params.List) ([]*Workflow, error) {
+	limit, offset, err := sqliteLimitOffset(lp)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return []*Workflow{}, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM workflows
+		WHERE active = 1
+		ORDER BY created DESC
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanWorkflows(rows)
+}
+
+// Shutdown closes the database
+func (s *sqliteStore) Shutdown(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// sqliteLimitOffset converts list params to SQLite LIMIT & OFFSET values,
+// where a limit of -1 fetches all rows
+func sqliteLimitOffset(lp params.List) (limit, offset int, err error) {
+	switch {
+	case lp.Limit == -1 && lp.Offset == 0:
+		return -1, 0, nil
+	case lp.Limit < 0:
+		return 0, 0, fmt.Errorf("limit of %d is out of bounds", lp.Limit)
+	case lp.Offset < 0:
+		return 0, 0, fmt.Errorf("offset of %d is out of bounds", lp.Offset)
+	}
+	return lp.Limit, lp.Offset, nil
+}
+
+func scanWorkflow(row *sql.Row) (*Workflow, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	wf := &Workflow{}
+	if err := json.Unmarshal(data, wf); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+func scanWorkflows(rows *sql.Rows) ([]*Workflow, error) {
+	defer rows.Close()
+	wfs := []*Workflow{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		wf := &Workflow{}
+		if err := json.Unmarshal(data, wf); err != nil {
+			return nil, err
+		}
+		wfs = append(wfs, wf)
+	}
+	return wfs, rows.Err()
+}
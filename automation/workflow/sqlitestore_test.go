@@ -0,0 +1,42 @@
+package workflow_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/qri-io/qri/automation/spec"
+	"github.com/qri-io/qri/automation/workflow"
+)
+
+func TestSQLiteStoreIntegration(t *testing.T) {
+	ctx := context.Background()
+	tmpdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	store, err := workflow.NewSQLiteStore(tmpdir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore unexpected error: %s", err)
+	}
+	spec.AssertWorkflowStore(t, store)
+	if err := store.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	listerDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(listerDir)
+
+	store, err = workflow.NewSQLiteStore(listerDir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore unexpected error: %s", err)
+	}
+	defer store.Shutdown(ctx)
+	spec.AssertWorkflowLister(t, store)
+}
@@ -24,6 +24,12 @@ var (
 	ErrNoOwnerID = fmt.Errorf("invalid workflow: empty OwnerID")
 	// ErrNilCreated indicates the workflow is invalid because the Created field is empty
 	ErrNilCreated = fmt.Errorf("invalid workflow: nil Created")
+	// ErrInvalidRetryPolicy indicates the workflow is invalid because the
+	// RetryPolicy has a negative MaxAttempts or Backoff
+	ErrInvalidRetryPolicy = fmt.Errorf("invalid workflow: retry policy attempts and backoff must not be negative")
+	// ErrNegativeTimeout indicates the workflow is invalid because the Timeout
+	// field is negative
+	ErrNegativeTimeout = fmt.Errorf("invalid workflow: negative Timeout")
 )
 
 // ID is a string identifier for a workflow
@@ -58,6 +64,164 @@ type Workflow struct {
 	Active   bool                     `json:"active"`
 	Triggers []map[string]interface{} `json:"triggers"`
 	Hooks    []map[string]interface{} `json:"hooks"`
+	// RetryPolicy determines if and how a failed run is attempted again. A nil
+	// RetryPolicy runs the workflow once
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// Timeout is the maximum duration of a run, including all retries. Runs
+	// that exceed the timeout are canceled. Zero means no timeout. Encoded as
+	// a duration string like "30m"
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaller interface, encoding Timeout as a
+// duration string
+func (w Workflow) MarshalJSON() ([]byte, error) {
+	type alias Workflow
+	return json.Marshal(struct {
+		alias
+		Timeout string `json:"timeout,omitempty"`
+	}{alias: alias(w), Timeout: durationString(w.Timeout)})
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaller interface
+func (w *Workflow) UnmarshalJSON(data []byte) error {
+	type alias Workflow
+	v := struct {
+		*alias
+		Timeout json.RawMessage `json:"timeout,omitempty"`
+	}{alias: (*alias)(w)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	timeout, err := parseDuration(v.Timeout)
+	if err != nil {
+		return fmt.Errorf("parsing workflow timeout: %w", err)
+	}
+	w.Timeout = timeout
+	return nil
+}
+
+// MaxRetryBackoff is the longest a RetryPolicy will wait between attempts
+const MaxRetryBackoff = time.Hour
+
+// DefaultRetryOn is the list of run statuses retried when a RetryPolicy
+// doesn't specify any
+var DefaultRetryOn = []string{"failed"}
+
+// RetryPolicy configures repeated attempts of a workflow run
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a run will be attempted,
+	// including the first attempt
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff is the duration to wait before the second attempt. The wait
+	// doubles with each subsequent attempt, up to MaxRetryBackoff. Encoded as
+	// a duration string like "30s"
+	Backoff time.Duration `json:"backoff"`
+	// RetryOn lists the run statuses that will be retried. Defaults to
+	// DefaultRetryOn
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaller interface, encoding Backoff as a
+// duration string
+func (rp RetryPolicy) MarshalJSON() ([]byte, error) {
+	type alias RetryPolicy
+	return json.Marshal(struct {
+		alias
+		Backoff string `json:"backoff,omitempty"`
+	}{alias: alias(rp), Backoff: durationString(rp.Backoff)})
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaller interface
+func (rp *RetryPolicy) UnmarshalJSON(data []byte) error {
+	type alias RetryPolicy
+	v := struct {
+		*alias
+		Backoff json.RawMessage `json:"backoff,omitempty"`
+	}{alias: (*alias)(rp)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	backoff, err := parseDuration(v.Backoff)
+	if err != nil {
+		return fmt.Errorf("parsing retry policy backoff: %w", err)
+	}
+	rp.Backoff = backoff
+	return nil
+}
+
+// durationString encodes a duration as a string like "1m30s", or an empty
+// string for zero durations
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// parseDuration decodes a JSON duration string like "1m30s". Durations
+// written as integer nanoseconds by earlier versions are also accepted
+func parseDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		if str == "" {
+			return 0, nil
+		}
+		return time.ParseDuration(str)
+	}
+	var nanos int64
+	if err := json.Unmarshal(data, &nanos); err != nil {
+		return 0, fmt.Errorf("invalid duration %s", data)
+	}
+	return time.Duration(nanos), nil
+}
+
+// Validate errors if the retry policy is not valid
+func (rp *RetryPolicy) Validate() error {
+	if rp == nil {
+		return nil
+	}
+	if rp.MaxAttempts < 0 || rp.Backoff < 0 {
+		return ErrInvalidRetryPolicy
+	}
+	return nil
+}
+
+// ShouldRetry returns true if a run that finished the given attempt with the
+// given status should be attempted again. Attempts are numbered from one
+func (rp *RetryPolicy) ShouldRetry(attempt int, status string) bool {
+	if rp == nil || attempt >= rp.MaxAttempts {
+		return false
+	}
+	retryOn := rp.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = DefaultRetryOn
+	}
+	for _, s := range retryOn {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffDuration returns the duration to wait after the given attempt before
+// trying again, capped at MaxRetryBackoff
+func (rp *RetryPolicy) BackoffDuration(attempt int) time.Duration {
+	if rp == nil || attempt < 1 || rp.Backoff <= 0 {
+		return 0
+	}
+	d := rp.Backoff
+	for i := 1; i < attempt && d < MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > MaxRetryBackoff {
+		d = MaxRetryBackoff
+	}
+	return d
 }
 
 // Validate errors if the workflow is not valid
@@ -77,7 +241,10 @@ func (w *Workflow) Validate() error {
 	if w.Created == nil {
 		return ErrNilCreated
 	}
-	return nil
+	if w.Timeout < 0 {
+		return ErrNegativeTimeout
+	}
+	return w.RetryPolicy.Validate()
 }
 
 // Copy returns a shallow copy of the receiver
@@ -93,6 +260,9 @@ func (w *Workflow) Copy() *Workflow {
 		Active:   w.Active,
 		Triggers: w.Triggers,
 		Hooks:    w.Hooks,
+
+		RetryPolicy: w.RetryPolicy,
+		Timeout:     w.Timeout,
 	}
 	return workflow
 }
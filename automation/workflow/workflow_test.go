@@ -1,10 +1,13 @@
 package workflow
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/qri-io/qri/profile"
 )
 
@@ -21,6 +24,8 @@ func TestWorkflowValidate(t *testing.T) {
 		{"no dataset id", &Workflow{ID: "test_id"}, ErrNoInitID},
 		{"no owner id", &Workflow{ID: "test_id", InitID: "dataset_id"}, ErrNoOwnerID},
 		{"no created time", &Workflow{ID: "test_id", InitID: "dataset_id", OwnerID: ownerID}, ErrNilCreated},
+		{"negative timeout", &Workflow{ID: "test_id", InitID: "dataset_id", OwnerID: ownerID, Created: &now, Timeout: -time.Second}, ErrNegativeTimeout},
+		{"negative retry attempts", &Workflow{ID: "test_id", InitID: "dataset_id", OwnerID: ownerID, Created: &now, RetryPolicy: &RetryPolicy{MaxAttempts: -1}}, ErrInvalidRetryPolicy},
 		{"no error", &Workflow{ID: "test_id", InitID: "dataset_id", OwnerID: ownerID, Created: &now}, nil},
 	}
 	for _, c := range cases {
@@ -30,3 +35,78 @@ func TestWorkflowValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestRetryPolicy(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	if nilPolicy.ShouldRetry(1, "failed") {
+		t.Error("expected a nil retry policy to never retry")
+	}
+
+	rp := &RetryPolicy{MaxAttempts: 3, Backoff: time.Second}
+	cases := []struct {
+		attempt int
+		status  string
+		expect  bool
+	}{
+		{1, "failed", true},
+		{2, "failed", true},
+		{3, "failed", false},
+		{1, "succeeded", false},
+		{1, "unchanged", false},
+	}
+	for _, c := range cases {
+		if got := rp.ShouldRetry(c.attempt, c.status); got != c.expect {
+			t.Errorf("ShouldRetry(%d, %q): expected %t, got %t", c.attempt, c.status, c.expect, got)
+		}
+	}
+
+	rp.RetryOn = []string{"unchanged"}
+	if rp.ShouldRetry(1, "failed") || !rp.ShouldRetry(1, "unchanged") {
+		t.Error("expected RetryOn to determine which statuses are retried")
+	}
+
+	for attempt, expect := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 64: MaxRetryBackoff, 1000: MaxRetryBackoff} {
+		if got := rp.BackoffDuration(attempt); got != expect {
+			t.Errorf("BackoffDuration(%d): expected %s, got %s", attempt, expect, got)
+		}
+	}
+}
+
+func TestWorkflowDurationJSON(t *testing.T) {
+	now := time.Unix(0, 0).UTC()
+	wf := &Workflow{
+		ID:          "test_id",
+		InitID:      "dataset_id",
+		OwnerID:     "owner_id",
+		Created:     &now,
+		RetryPolicy: &RetryPolicy{MaxAttempts: 3, Backoff: 30 * time.Second},
+		Timeout:     90 * time.Minute,
+	}
+	data, err := json.Marshal(wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{`"timeout":"1h30m0s"`, `"backoff":"30s"`} {
+		if !strings.Contains(string(data), expect) {
+			t.Errorf("expected encoded workflow to contain %s, got: %s", expect, data)
+		}
+	}
+
+	got := &Workflow{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wf, got); diff != "" {
+		t.Errorf("workflow round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	// durations written as integer nanoseconds are still read
+	legacy := `{"id":"test_id","timeout":5000000000,"retryPolicy":{"maxAttempts":2,"backoff":1000000000}}`
+	got = &Workflow{}
+	if err := json.Unmarshal([]byte(legacy), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Timeout != 5*time.Second || got.RetryPolicy.Backoff != time.Second {
+		t.Errorf("expected legacy durations to be read, got timeout %s & backoff %s", got.Timeout, got.RetryPolicy.Backoff)
+	}
+}
@@ -186,6 +186,12 @@ func Export(ctx context.Context, fs qfs.Filesystem, ds *dataset.Dataset, refStr,
 		}
 		return fileWritten, w.Close()
 
+	case base.ParquetDataFormat:
+		if err := base.WriteParquetBody(reader, writer); err != nil {
+			return "", err
+		}
+		return fileWritten, nil
+
 	case "zip":
 		ref, err := dsref.Parse(refStr)
 		if err != nil {
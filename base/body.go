@@ -14,7 +14,8 @@ import (
 // ErrNoBodyToInline is an error returned when a dataset has no body for inlining
 var ErrNoBodyToInline = fmt.Errorf("no body to inline")
 
-// ReadBodyBytes grabs some or all of a dataset's body, writing an output in the desired format
+// ReadBodyBytes grabs some or all of a dataset's body, writing an output in the desired format.
+// Pass ParquetFormat to write a parquet body
 func ReadBodyBytes(ds *dataset.Dataset, format dataset.DataFormat, fcfg dataset.FormatConfig, limit, offset int, all bool) (data []byte, err error) {
 	if ds == nil {
 		return nil, fmt.Errorf("can't load body from a nil dataset")
@@ -26,6 +27,10 @@ func ReadBodyBytes(ds *dataset.Dataset, format dataset.DataFormat, fcfg dataset.
 		return
 	}
 
+	if format == ParquetFormat {
+		return readParquetBodyBytes(ds, file, limit, offset, all)
+	}
+
 	st := &dataset.Structure{}
 	assign := &dataset.Structure{
 		Format: format.String(),
@@ -45,6 +50,26 @@ func ReadBodyBytes(ds *dataset.Dataset, format dataset.DataFormat, fcfg dataset.
 	return data, nil
 }
 
+func readParquetBodyBytes(ds *dataset.Dataset, file qfs.File, limit, offset int, all bool) ([]byte, error) {
+	rr, err := dsio.NewEntryReader(ds.Structure, file)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating data reader: %s", err)
+	}
+	if !all {
+		rr = &dsio.PagedReader{
+			Reader: rr,
+			Limit:  limit,
+			Offset: offset,
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteParquetBody(rr, buf); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // GetBody takes returns the Body as a go-native structure,
 // using limit, offset, and all parameters to determine what part of the Body to return
 func GetBody(ds *dataset.Dataset, limit, offset int, all bool) (interface{}, error) {
@@ -137,16 +162,32 @@ func InlineJSONBody(ds *dataset.Dataset) error {
 }
 
 // ConvertBodyFormat rewrites a body from a source format to a destination format.
+// Either format may be ParquetDataFormat
 // TODO (b5): Combine this with ConvertBodyFile, update callers.
 func ConvertBodyFormat(bodyFile qfs.File, fromSt, toSt *dataset.Structure) (qfs.File, error) {
 	// Reader for entries of the source body.
-	r, err := dsio.NewEntryReader(fromSt, bodyFile)
+	var (
+		r   dsio.EntryReader
+		err error
+	)
+	if IsParquetFormat(fromSt.Format) {
+		r, err = NewParquetEntryReader(bodyFile)
+	} else {
+		r, err = dsio.NewEntryReader(fromSt, bodyFile)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Writes entries to a new body.
 	buffer := &bytes.Buffer{}
+	if IsParquetFormat(toSt.Format) {
+		if err := WriteParquetBody(r, buffer); err != nil {
+			return nil, err
+		}
+		return qfs.NewMemfileReader(fmt.Sprintf("body.%s", ParquetDataFormat), buffer), nil
+	}
+
 	w, err := dsio.NewEntryWriter(toSt, buffer)
 	if err != nil {
 		return nil, err
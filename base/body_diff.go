@@ -0,0 +1,652 @@
+package base
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/tabular"
+)
+
+const (
+	// RowAdded is a row that only exists in the right body of a diff
+	RowAdded = "add"
+	// RowRemoved is a row that only exists in the left body of a diff
+	RowRemoved = "remove"
+	// RowModified is a row with the same key in both bodies, but different
+	// values
+	RowModified = "modify"
+)
+
+// RowDiffChunkSize is the number of rows a keyed body diff sorts in memory
+// before spilling sorted rows to a temp file. Can be overridden in tests
+var RowDiffChunkSize = 100000
+
+// RowDelta is a single row that differs between two dataset bodies
+type RowDelta struct {
+	Type  string        `json:"type"`
+	Key   []interface{} `json:"key"`
+	Left  []interface{} `json:"left,omitempty"`
+	Right []interface{} `json:"right,omitempty"`
+}
+
+// PrimaryKey returns the primary key columns declared by a structure's schema
+// with the "primaryKey" keyword. primaryKey is either a single column title or
+// a list of titles
+func PrimaryKey(st *dataset.Structure) ([]string, error) {
+	if st == nil || st.Schema == nil {
+		return nil, nil
+	}
+	switch pk := st.Schema["primaryKey"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{pk}, nil
+	case []string:
+		return pk, nil
+	case []interface{}:
+		key := make([]string, len(pk))
+		for i, col := range pk {
+			title, ok := col.(string)
+			if !ok {
+				return nil, fmt.Errorf("primaryKey must be a list of column titles")
+			}
+			key[i] = title
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("primaryKey must be a column title or list of column titles")
+}
+
+// DiffBodiesByKey compares the bodies of two tabular datasets row by row,
+// matching rows that share the same values in the key columns. Bodies are
+// streamed & sorted in chunks so memory use is bounded by RowDiffChunkSize,
+// not body size. Differing rows are returned in key order, skipping the first
+// offset differences & returning at most limit. A limit of -1 returns all
+// differences
+func DiffBodiesByKey(ctx context.Context, left, right *dataset.Dataset, key []string, offset, limit int) ([]*RowDelta, error) {
+	d, err := NewRowDiff(ctx, left, right, key)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	if err := d.Skip(ctx, offset); err != nil {
+		return nil, err
+	}
+	return d.Next(ctx, limit)
+}
+
+// RowDiff is a keyed comparison of two dataset bodies that can be read a page
+// at a time. Both bodies are sorted once when the RowDiff is created, so
+// reading later pages continues from the last returned difference instead of
+// re-reading the bodies. A RowDiff must be closed to remove any temp files
+type RowDiff struct {
+	lrows, rrows rowIterator
+	l, r         *keyedRow
+	pos          int
+	done         bool
+}
+
+// NewRowDiff sorts the bodies of two tabular datasets by key & returns a
+// RowDiff positioned at the first difference
+func NewRowDiff(ctx context.Context, left, right *dataset.Dataset, key []string) (*RowDiff, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("keyed diffs require at least one key column")
+	}
+	lrows, err := sortedBodyRows(ctx, left, key)
+	if err != nil {
+		return nil, fmt.Errorf("left body: %w", err)
+	}
+	rrows, err := sortedBodyRows(ctx, right, key)
+	if err != nil {
+		lrows.Close()
+		return nil, fmt.Errorf("right body: %w", err)
+	}
+
+	d := &RowDiff{lrows: lrows, rrows: rrows}
+	if d.l, err = lrows.Next(); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("left body: %w", err)
+	}
+	if d.r, err = rrows.Next(); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("right body: %w", err)
+	}
+	return d, nil
+}
+
+// Position returns the number of differences read so far
+func (d *RowDiff) Position() int {
+	return d.pos
+}
+
+// Done returns true when every difference has been read
+func (d *RowDiff) Done() bool {
+	return d.done
+}
+
+// Skip reads past the next n differences
+func (d *RowDiff) Skip(ctx context.Context, n int) error {
+	for i := 0; i < n; i++ {
+		delta, err := d.next(ctx)
+		if err != nil {
+			return err
+		}
+		if delta == nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Next returns up to limit differences in key order, continuing from the last
+// call. A limit of -1 returns all remaining differences
+func (d *RowDiff) Next(ctx context.Context, limit int) ([]*RowDelta, error) {
+	res := []*RowDelta{}
+	for limit < 0 || len(res) < limit {
+		delta, err := d.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			break
+		}
+		res = append(res, delta)
+	}
+	return res, nil
+}
+
+// Close releases the sorted bodies
+func (d *RowDiff) Close() error {
+	lerr := d.lrows.Close()
+	if err := d.rrows.Close(); err != nil {
+		return err
+	}
+	return lerr
+}
+
+// next returns the next difference, or nil when the bodies are exhausted
+func (d *RowDiff) next(ctx context.Context) (delta *RowDelta, err error) {
+	for d.l != nil || d.r != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		cmp := 0
+		if d.l == nil {
+			cmp = 1
+		} else if d.r == nil {
+			cmp = -1
+		} else {
+			cmp = compareKeys(d.l.Key, d.r.Key)
+		}
+
+		switch {
+		case cmp < 0:
+			delta = &RowDelta{Type: RowRemoved, Key: d.l.Key, Left: d.l.Row}
+			if d.l, err = d.lrows.Next(); err != nil {
+				return nil, fmt.Errorf("left body: %w", err)
+			}
+		case cmp > 0:
+			delta = &RowDelta{Type: RowAdded, Key: d.r.Key, Right: d.r.Row}
+			if d.r, err = d.rrows.Next(); err != nil {
+				return nil, fmt.Errorf("right body: %w", err)
+			}
+		default:
+			if !jsonEqual(d.l.Row, d.r.Row) {
+				delta = &RowDelta{Type: RowModified, Key: d.l.Key, Left: d.l.Row, Right: d.r.Row}
+			}
+			if d.l, err = d.lrows.Next(); err != nil {
+				return nil, fmt.Errorf("left body: %w", err)
+			}
+			if d.r, err = d.rrows.Next(); err != nil {
+				return nil, fmt.Errorf("right body: %w", err)
+			}
+		}
+		if delta != nil {
+			d.pos++
+			return delta, nil
+		}
+	}
+	d.done = true
+	return nil, nil
+}
+
+// keyedRow is a body row & the values of it's key columns
+type keyedRow struct {
+	Key []interface{} `json:"key"`
+	Row []interface{} `json:"row"`
+}
+
+// rowIterator iterates rows in key order. Next returns a nil row when the
+// iterator is exhausted
+type rowIterator interface {
+	Next() (*keyedRow, error)
+	Close() error
+}
+
+// sortedBodyRows reads a dataset body & returns an iterator of its rows sorted
+// by key. Rows are sorted in chunks of RowDiffChunkSize, spilling each chunk to
+// a temp file when the body doesn't fit in a single chunk
+func sortedBodyRows(ctx context.Context, ds *dataset.Dataset, key []string) (rowIterator, error) {
+	if ds == nil || ds.Structure == nil {
+		return nil, fmt.Errorf("dataset has no structure")
+	}
+	file := ds.BodyFile()
+	if file == nil {
+		return nil, fmt.Errorf("no body file to read")
+	}
+	cols, _, err := tabular.ColumnsFromJSONSchema(ds.Structure.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("keyed diffs require a tabular body: %w", err)
+	}
+	keyIdx, err := keyIndexes(cols.Titles(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := dsio.NewEntryReader(ds.Structure, file)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating data reader: %w", err)
+	}
+	defer rr.Close()
+
+	chunks := []*chunkFile{}
+	closeChunks := func() {
+		for _, c := range chunks {
+			c.Close()
+		}
+	}
+
+	var chunk []*keyedRow
+	for {
+		if err := ctx.Err(); err != nil {
+			closeChunks()
+			return nil, err
+		}
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			closeChunks()
+			return nil, err
+		}
+		row, ok := ent.Value.([]interface{})
+		if !ok {
+			closeChunks()
+			return nil, fmt.Errorf("keyed diffs require rows to be arrays, entry %d is %T", ent.Index, ent.Value)
+		}
+		kr := &keyedRow{Key: make([]interface{}, len(keyIdx)), Row: row}
+		for i, idx := range keyIdx {
+			if idx < len(row) {
+				kr.Key[i] = row[idx]
+			}
+		}
+		chunk = append(chunk, kr)
+
+		if len(chunk) >= RowDiffChunkSize {
+			cf, err := spillChunk(chunk)
+			if err != nil {
+				closeChunks()
+				return nil, err
+			}
+			chunks = append(chunks, cf)
+			chunk = nil
+		}
+	}
+
+	sortRows(chunk)
+	if len(chunks) == 0 {
+		return &uniqueRows{rows: &sliceRows{rows: chunk}}, nil
+	}
+	if len(chunk) > 0 {
+		cf, err := spillChunk(chunk)
+		if err != nil {
+			closeChunks()
+			return nil, err
+		}
+		chunks = append(chunks, cf)
+	}
+	mr, err := newMergeRows(chunks)
+	if err != nil {
+		closeChunks()
+		return nil, err
+	}
+	return &uniqueRows{rows: mr}, nil
+}
+
+func keyIndexes(titles, key []string) ([]int, error) {
+	idx := make([]int, len(key))
+	for i, k := range key {
+		idx[i] = -1
+		for j, t := range titles {
+			if t == k {
+				idx[i] = j
+				break
+			}
+		}
+		if idx[i] == -1 {
+			return nil, fmt.Errorf("key column %q not found", k)
+		}
+	}
+	return idx, nil
+}
+
+func sortRows(rows []*keyedRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareKeys(rows[i].Key, rows[j].Key) < 0
+	})
+}
+
+// sliceRows iterates a sorted slice of rows held in memory
+type sliceRows struct {
+	rows []*keyedRow
+}
+
+func (s *sliceRows) Next() (*keyedRow, error) {
+	if len(s.rows) == 0 {
+		return nil, nil
+	}
+	r := s.rows[0]
+	s.rows = s.rows[1:]
+	return r, nil
+}
+
+func (s *sliceRows) Close() error { return nil }
+
+// uniqueRows errors if an iterator returns two rows with the same key
+type uniqueRows struct {
+	rows rowIterator
+	prev *keyedRow
+}
+
+func (u *uniqueRows) Next() (*keyedRow, error) {
+	r, err := u.rows.Next()
+	if err != nil || r == nil {
+		return r, err
+	}
+	if u.prev != nil && compareKeys(u.prev.Key, r.Key) == 0 {
+		return nil, fmt.Errorf("duplicate key %v", r.Key)
+	}
+	u.prev = r
+	return r, nil
+}
+
+func (u *uniqueRows) Close() error { return u.rows.Close() }
+
+// chunkFile is a temp file of sorted, newline-delimited JSON rows
+type chunkFile struct {
+	f    *os.File
+	dec  *json.Decoder
+	head *keyedRow
+}
+
+func spillChunk(rows []*keyedRow) (*chunkFile, error) {
+	sortRows(rows)
+	f, err := ioutil.TempFile("", "qri_row_diff")
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	dec.UseNumber()
+	return &chunkFile{f: f, dec: dec}, nil
+}
+
+// advance reads the next row of the chunk into head, setting head to nil when
+// the chunk is exhausted
+func (c *chunkFile) advance() error {
+	r := &keyedRow{}
+	if err := c.dec.Decode(r); err != nil {
+		if err == io.EOF {
+			c.head = nil
+			return nil
+		}
+		return err
+	}
+	c.head = r
+	return nil
+}
+
+func (c *chunkFile) Close() error {
+	err := c.f.Close()
+	os.Remove(c.f.Name())
+	return err
+}
+
+// mergeRows performs a k-way merge of sorted chunk files
+type mergeRows struct {
+	chunks []*chunkFile
+	h      chunkHeap
+}
+
+func newMergeRows(chunks []*chunkFile) (*mergeRows, error) {
+	m := &mergeRows{chunks: chunks}
+	for _, c := range chunks {
+		if err := c.advance(); err != nil {
+			return nil, err
+		}
+		if c.head != nil {
+			m.h = append(m.h, c)
+		}
+	}
+	heap.Init(&m.h)
+	return m, nil
+}
+
+func (m *mergeRows) Next() (*keyedRow, error) {
+	if len(m.h) == 0 {
+		return nil, nil
+	}
+	c := m.h[0]
+	r := c.head
+	if err := c.advance(); err != nil {
+		return nil, err
+	}
+	if c.head == nil {
+		heap.Pop(&m.h)
+	} else {
+		heap.Fix(&m.h, 0)
+	}
+	return r, nil
+}
+
+func (m *mergeRows) Close() error {
+	var err error
+	for _, c := range m.chunks {
+		if cerr := c.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// chunkHeap orders chunk files by the key of their next row
+type chunkHeap []*chunkFile
+
+func (h chunkHeap) Len() int            { return len(h) }
+func (h chunkHeap) Less(i, j int) bool  { return compareKeys(h[i].head.Key, h[j].head.Key) < 0 }
+func (h chunkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *chunkHeap) Push(x interface{}) { *h = append(*h, x.(*chunkFile)) }
+func (h *chunkHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	*h = old[:n-1]
+	return c
+}
+
+// compareKeys orders keys column by column
+func compareKeys(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareValues orders values by type: null, boolean, number, string, then any
+// other value by it's JSON encoding
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch ra {
+	case 0:
+		return 0
+	case 1:
+		ab, bb := a.(bool), b.(bool)
+		if ab == bb {
+			return 0
+		} else if !ab {
+			return -1
+		}
+		return 1
+	case 2:
+		return compareNumbers(a, b)
+	case 3:
+		as, bs := a.(string), b.(string)
+		if as < bs {
+			return -1
+		} else if as > bs {
+			return 1
+		}
+		return 0
+	}
+	ad, _ := json.Marshal(a)
+	bd, _ := json.Marshal(b)
+	return bytes.Compare(ad, bd)
+}
+
+func valueRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int, int64, float64, json.Number:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+func toFloat64(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+// compareNumbers orders two numeric values. integers are compared as int64s &
+// mixed values as arbitrary precision floats, so large integer keys that share
+// a float64 representation don't collide
+func compareNumbers(a, b interface{}) int {
+	if ai, ok := toInt64(a); ok {
+		if bi, ok := toInt64(b); ok {
+			switch {
+			case ai < bi:
+				return -1
+			case ai > bi:
+				return 1
+			}
+			return 0
+		}
+	}
+	if af, ok := a.(float64); ok {
+		if bf, ok := b.(float64); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	return toBigFloat(a).Cmp(toBigFloat(b))
+}
+
+// toInt64 returns the value of an integer number
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toBigFloat(v interface{}) *big.Float {
+	f := new(big.Float)
+	switch n := v.(type) {
+	case int:
+		f.SetInt64(int64(n))
+	case int64:
+		f.SetInt64(n)
+	case float64:
+		f.SetFloat64(n)
+	case json.Number:
+		if _, ok := f.SetString(string(n)); !ok {
+			f.SetInt64(0)
+		}
+	}
+	return f
+}
+
+// jsonEqual compares rows by their JSON encoding, so rows read from memory &
+// rows decoded from a temp file compare equal
+func jsonEqual(a, b []interface{}) bool {
+	ad, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bd, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ad, bd)
+}
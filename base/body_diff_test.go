@@ -0,0 +1,181 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
+)
+
+func newRowDiffDataset(body string) *dataset.Dataset {
+	ds := &dataset.Dataset{
+		Structure: &dataset.Structure{
+			Format:       "csv",
+			FormatConfig: map[string]interface{}{"headerRow": true},
+			Schema: map[string]interface{}{
+				"type":       "array",
+				"primaryKey": []interface{}{"id"},
+				"items": map[string]interface{}{
+					"type": "array",
+					"items": []interface{}{
+						map[string]interface{}{"title": "id", "type": "integer"},
+						map[string]interface{}{"title": "region", "type": "string"},
+						map[string]interface{}{"title": "pop", "type": "integer"},
+					},
+				},
+			},
+		},
+	}
+	ds.SetBodyFile(qfs.NewMemfileBytes("body.csv", []byte(body)))
+	return ds
+}
+
+func TestDiffBodiesByKey(t *testing.T) {
+	ctx := context.Background()
+	left := "id,region,pop\n3,c,300\n1,a,100\n2,b,200\n5,e,500\n"
+	right := "id,region,pop\n4,d,400\n1,a,100\n5,e,550\n2,b,200\n"
+
+	expect := []*RowDelta{
+		{Type: RowRemoved, Key: []interface{}{3}, Left: []interface{}{3, "c", 300}},
+		{Type: RowAdded, Key: []interface{}{4}, Right: []interface{}{4, "d", 400}},
+		{Type: RowModified, Key: []interface{}{5}, Left: []interface{}{5, "e", 500}, Right: []interface{}{5, "e", 550}},
+	}
+
+	// compare results by JSON encoding, rows that spill to disk decode numbers
+	// as json.Number
+	check := func(t *testing.T, expect, got []*RowDelta) {
+		t.Helper()
+		if diff := cmp.Diff(rowDiffJSONString(t, expect), rowDiffJSONString(t, got)); diff != "" {
+			t.Errorf("result mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	got, err := DiffBodiesByKey(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"id"}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, expect, got)
+
+	t.Run("spill to disk", func(t *testing.T) {
+		prev := RowDiffChunkSize
+		RowDiffChunkSize = 2
+		defer func() { RowDiffChunkSize = prev }()
+
+		got, err := DiffBodiesByKey(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"id"}, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, expect, got)
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		got, err := DiffBodiesByKey(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"id"}, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, expect[1:2], got)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		d, err := NewRowDiff(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		for i := range expect {
+			got, err := d.Next(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, expect[i:i+1], got)
+			if d.Position() != i+1 {
+				t.Errorf("expected position %d, got %d", i+1, d.Position())
+			}
+		}
+		got, err := d.Next(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 || !d.Done() {
+			t.Errorf("expected exhausted diff to be done, got %d deltas", len(got))
+		}
+	})
+
+	t.Run("composite key", func(t *testing.T) {
+		got, err := DiffBodiesByKey(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"region", "pop"}, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 4 {
+			t.Errorf("expected a modified row to be a remove & add when the key changes, got %d deltas", len(got))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dupes := "id,region,pop\n1,a,100\n1,b,200\n"
+		_, err := DiffBodiesByKey(ctx, newRowDiffDataset(dupes), newRowDiffDataset(right), []string{"id"}, 0, -1)
+		if err == nil || err.Error() != "left body: duplicate key [1]" {
+			t.Errorf("expected duplicate key error, got: %v", err)
+		}
+		_, err = DiffBodiesByKey(ctx, newRowDiffDataset(left), newRowDiffDataset(right), []string{"missing"}, 0, -1)
+		if err == nil || err.Error() != `left body: key column "missing" not found` {
+			t.Errorf("expected missing column error, got: %v", err)
+		}
+	})
+}
+
+func TestCompareValuesLargeIntegers(t *testing.T) {
+	// 2^53 + 1 & 2^53 share a float64 representation
+	a, b := int64(9007199254740993), int64(9007199254740992)
+	cases := []struct {
+		a, b   interface{}
+		expect int
+	}{
+		{a, b, 1},
+		{b, a, -1},
+		{json.Number("9007199254740993"), b, 1},
+		{a, json.Number("9007199254740992"), 1},
+		{json.Number("9007199254740993"), json.Number("9007199254740993"), 0},
+		{a, float64(9007199254740992), 1},
+		{1.5, int64(1), 1},
+		{json.Number("1.5"), int64(2), -1},
+	}
+	for i, c := range cases {
+		if got := compareValues(c.a, c.b); got != c.expect {
+			t.Errorf("case %d: compareValues(%v, %v) expected %d, got %d", i, c.a, c.b, c.expect, got)
+		}
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	ds := newRowDiffDataset("")
+	got, err := PrimaryKey(ds.Structure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"id"}, got); diff != "" {
+		t.Errorf("primary key mismatch (-want +got):\n%s", diff)
+	}
+
+	ds.Structure.Schema["primaryKey"] = "region"
+	if got, _ = PrimaryKey(ds.Structure); !cmp.Equal([]string{"region"}, got) {
+		t.Errorf("expected single column primary key, got: %v", got)
+	}
+
+	ds.Structure.Schema["primaryKey"] = 5
+	if _, err = PrimaryKey(ds.Structure); err == nil {
+		t.Error("expected invalid primaryKey to error")
+	}
+}
+
+func rowDiffJSONString(t *testing.T, deltas []*RowDelta) string {
+	t.Helper()
+	data, err := json.Marshal(deltas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,426 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/tabular"
+	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/dsref"
+)
+
+// Constraints are table-level rules a tabular body must satisfy, declared in
+// the structure schema alongside the column definitions:
+//
+//	{
+//	  "type": "array",
+//	  "items": { ... },
+//	  "primaryKey": ["id"],
+//	  "unique": ["email", ["first_name", "last_name"]],
+//	  "notNull": ["country_code"],
+//	  "foreignKeys": [
+//	    { "columns": ["country_code"], "dataset": "me/countries", "refColumns": ["code"] }
+//	  ]
+//	}
+//
+// Empty strings are treated as null. JSON schema validates each row on it's own, constraints validate rows
+// against each other & against other datasets
+type Constraints struct {
+	// PrimaryKey columns must be unique & not null
+	PrimaryKey []string
+	// Unique lists sets of columns that must have unique values
+	Unique [][]string
+	// NotNull columns must have a value in every row
+	NotNull []string
+	// ForeignKeys require values to exist in another dataset
+	ForeignKeys []*ForeignKey
+}
+
+// ForeignKey requires every value of a set of columns to exist in columns of
+// another dataset. Rows with a null value in any foreign key column are not
+// checked
+type ForeignKey struct {
+	Columns []string `json:"columns"`
+	// Dataset is a reference to the dataset values must exist in
+	Dataset string `json:"dataset"`
+	// RefColumns are the columns of the referenced dataset to match, defaults
+	// to Columns
+	RefColumns []string `json:"refColumns,omitempty"`
+}
+
+// IsEmpty returns true if there are no constraints to check
+func (c *Constraints) IsEmpty() bool {
+	return c == nil || (len(c.PrimaryKey) == 0 && len(c.Unique) == 0 && len(c.NotNull) == 0 && len(c.ForeignKeys) == 0)
+}
+
+// ParseConstraints reads table constraints from a structure's schema
+func ParseConstraints(st *dataset.Structure) (*Constraints, error) {
+	c := &Constraints{}
+	if st == nil || st.Schema == nil {
+		return c, nil
+	}
+
+	var err error
+	if c.PrimaryKey, err = PrimaryKey(st); err != nil {
+		return nil, err
+	}
+
+	if unique, ok := st.Schema["unique"]; ok {
+		list, ok := unique.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unique must be a list of columns")
+		}
+		for _, u := range list {
+			cols, err := columnList(u)
+			if err != nil {
+				return nil, fmt.Errorf("unique: %w", err)
+			}
+			c.Unique = append(c.Unique, cols)
+		}
+	}
+
+	if notNull, ok := st.Schema["notNull"]; ok {
+		if c.NotNull, err = columnList(notNull); err != nil {
+			return nil, fmt.Errorf("notNull: %w", err)
+		}
+	}
+
+	if fks, ok := st.Schema["foreignKeys"]; ok {
+		data, err := json.Marshal(fks)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c.ForeignKeys); err != nil {
+			return nil, fmt.Errorf("foreignKeys must be a list of foreign key objects")
+		}
+		for _, fk := range c.ForeignKeys {
+			if len(fk.Columns) == 0 || fk.Dataset == "" {
+				return nil, fmt.Errorf("foreign keys require columns and a dataset")
+			}
+			if len(fk.RefColumns) == 0 {
+				fk.RefColumns = fk.Columns
+			}
+			if len(fk.RefColumns) != len(fk.Columns) {
+				return nil, fmt.Errorf("foreign key to %s must have the same number of columns and refColumns", fk.Dataset)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// columnList accepts a single column title or a list of titles
+func columnList(v interface{}) ([]string, error) {
+	switch cols := v.(type) {
+	case string:
+		return []string{cols}, nil
+	case []string:
+		return cols, nil
+	case []interface{}:
+		res := make([]string, len(cols))
+		for i, col := range cols {
+			title, ok := col.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of column titles")
+			}
+			res[i] = title
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("expected a column title or list of column titles")
+}
+
+// ValidateConstraints checks a tabular body against the table constraints
+// declared in the structure schema, returning an error for each violation.
+// Datasets referenced by foreign keys are loaded with loader
+func ValidateConstraints(ctx context.Context, loader dsref.Loader, body qfs.File, st *dataset.Structure) ([]jsonschema.KeyError, error) {
+	chk, err := newConstraintChecker(ctx, loader, st)
+	if err != nil || chk == nil {
+		return nil, err
+	}
+	if body == nil {
+		return nil, fmt.Errorf("body passed to ValidateConstraints must not be nil")
+	}
+
+	rr, err := dsio.NewEntryReader(st, body)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating data reader: %w", err)
+	}
+	defer rr.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		chk.CheckEntry(ent)
+	}
+	if chk.err != nil {
+		return nil, chk.err
+	}
+	return chk.errs, nil
+}
+
+// constraintChecker accumulates table constraint violations one body entry at
+// a time, so a body can be checked as it streams past
+type constraintChecker struct {
+	titles     []string
+	uniques    []*uniqueCheck
+	notNull    []string
+	notNullIdx []int
+	foreigns   []*foreignCheck
+
+	errs []jsonschema.KeyError
+	err  error
+}
+
+// assert at compile time that constraintChecker can check entries while dsfs
+// writes a body
+var _ dsfs.EntryChecker = (*constraintChecker)(nil)
+
+type uniqueCheck struct {
+	name string
+	idx  []int
+	seen map[string]int
+}
+
+type foreignCheck struct {
+	fk     *ForeignKey
+	idx    []int
+	values map[string]bool
+}
+
+// newConstraintChecker prepares to check entries against the constraints
+// declared in st, loading foreign key datasets with loader. It returns nil if
+// st declares no constraints
+func newConstraintChecker(ctx context.Context, loader dsref.Loader, st *dataset.Structure) (*constraintChecker, error) {
+	c, err := ParseConstraints(st)
+	if err != nil {
+		return nil, err
+	}
+	if c.IsEmpty() {
+		return nil, nil
+	}
+
+	cols, _, err := tabular.ColumnsFromJSONSchema(st.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("table constraints require a tabular schema: %w", err)
+	}
+	chk := &constraintChecker{
+		titles: cols.Titles(),
+		errs:   []jsonschema.KeyError{},
+	}
+
+	addUnique := func(key []string, name string) error {
+		idx, err := keyIndexes(chk.titles, key)
+		if err != nil {
+			return err
+		}
+		chk.uniques = append(chk.uniques, &uniqueCheck{name: name, idx: idx, seen: map[string]int{}})
+		return nil
+	}
+	if len(c.PrimaryKey) > 0 {
+		if err := addUnique(c.PrimaryKey, "primary key"); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range c.Unique {
+		if err := addUnique(u, "unique"); err != nil {
+			return nil, err
+		}
+	}
+
+	// primary key columns must also be non-null
+	chk.notNull = append(append([]string{}, c.PrimaryKey...), c.NotNull...)
+	if chk.notNullIdx, err = keyIndexes(chk.titles, chk.notNull); err != nil {
+		return nil, err
+	}
+
+	for _, fk := range c.ForeignKeys {
+		idx, err := keyIndexes(chk.titles, fk.Columns)
+		if err != nil {
+			return nil, err
+		}
+		values, err := foreignKeyValues(ctx, loader, fk)
+		if err != nil {
+			return nil, err
+		}
+		chk.foreigns = append(chk.foreigns, &foreignCheck{fk: fk, idx: idx, values: values})
+	}
+	return chk, nil
+}
+
+// CheckEntry records any constraint violations in a single body entry. Once
+// an entry can't be checked at all, later entries are ignored
+func (chk *constraintChecker) CheckEntry(ent dsio.Entry) {
+	if chk.err != nil {
+		return
+	}
+	row, ok := ent.Value.([]interface{})
+	if !ok {
+		chk.err = fmt.Errorf("table constraints require rows to be arrays, entry %d is %T", ent.Index, ent.Value)
+		return
+	}
+
+	reportedNull := map[int]bool{}
+	for i, idx := range chk.notNullIdx {
+		if reportedNull[idx] || !isNull(rowValue(row, idx)) {
+			continue
+		}
+		reportedNull[idx] = true
+		chk.errs = append(chk.errs, jsonschema.KeyError{
+			PropertyPath: fmt.Sprintf("/%d/%d", ent.Index, idx),
+			Message:      fmt.Sprintf("column %q must not be null", chk.notNull[i]),
+		})
+	}
+
+	for _, u := range chk.uniques {
+		key, values := rowKey(row, u.idx)
+		if key == "" {
+			// nulls are never equal to one another
+			continue
+		}
+		if first, ok := u.seen[key]; ok {
+			chk.errs = append(chk.errs, jsonschema.KeyError{
+				PropertyPath: fmt.Sprintf("/%d", ent.Index),
+				InvalidValue: values,
+				Message:      fmt.Sprintf("duplicate %s %s, first used in row %d", u.name, columnNames(chk.titles, u.idx), first),
+			})
+			continue
+		}
+		u.seen[key] = ent.Index
+	}
+
+	for _, f := range chk.foreigns {
+		key, values := rowKey(row, f.idx)
+		if key == "" || f.values[key] {
+			continue
+		}
+		chk.errs = append(chk.errs, jsonschema.KeyError{
+			PropertyPath: fmt.Sprintf("/%d", ent.Index),
+			InvalidValue: values,
+			Message:      fmt.Sprintf("%s not found in %s %s", columnNames(chk.titles, f.idx), f.fk.Dataset, strings.Join(f.fk.RefColumns, ", ")),
+		})
+	}
+}
+
+// Close returns an ErrConstraintViolation error summarizing the violations
+// found in all checked entries
+func (chk *constraintChecker) Close() error {
+	if chk.err != nil {
+		return chk.err
+	}
+	return constraintViolationError(chk.errs)
+}
+
+// constraintViolationError wraps ErrConstraintViolation with the first of
+// errs, returning nil if errs is empty
+func constraintViolationError(errs []jsonschema.KeyError) error {
+	if len(errs) == 1 {
+		return fmt.Errorf("%w: %s at %s", ErrConstraintViolation, errs[0].Message, errs[0].PropertyPath)
+	} else if len(errs) > 1 {
+		return fmt.Errorf("%w: %s at %s, and %d more violations", ErrConstraintViolation, errs[0].Message, errs[0].PropertyPath, len(errs)-1)
+	}
+	return nil
+}
+
+// foreignKeyValues loads the set of keys present in the dataset a foreign key
+// references
+func foreignKeyValues(ctx context.Context, loader dsref.Loader, fk *ForeignKey) (map[string]bool, error) {
+	if loader == nil {
+		return nil, fmt.Errorf("cannot check foreign key to %s without a dataset loader", fk.Dataset)
+	}
+	ds, err := loader.LoadDataset(ctx, fk.Dataset)
+	if err != nil {
+		return nil, fmt.Errorf("loading foreign key dataset %s: %w", fk.Dataset, err)
+	}
+	if ds.Structure == nil || ds.BodyFile() == nil {
+		return nil, fmt.Errorf("foreign key dataset %s has no body", fk.Dataset)
+	}
+	cols, _, err := tabular.ColumnsFromJSONSchema(ds.Structure.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("foreign key dataset %s: %w", fk.Dataset, err)
+	}
+	idx, err := keyIndexes(cols.Titles(), fk.RefColumns)
+	if err != nil {
+		return nil, fmt.Errorf("foreign key dataset %s: %w", fk.Dataset, err)
+	}
+
+	rr, err := dsio.NewEntryReader(ds.Structure, ds.BodyFile())
+	if err != nil {
+		return nil, err
+	}
+	defer rr.Close()
+
+	values := map[string]bool{}
+	for {
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		row, ok := ent.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("foreign key dataset %s rows must be arrays", fk.Dataset)
+		}
+		if key, _ := rowKey(row, idx); key != "" {
+			values[key] = true
+		}
+	}
+	return values, nil
+}
+
+func rowValue(row []interface{}, idx int) interface{} {
+	if idx < len(row) {
+		return row[idx]
+	}
+	return nil
+}
+
+// isNull treats empty strings as null, which is how tabular formats like CSV
+// represent missing values
+func isNull(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// rowKey encodes the values of columns at idx as a string that is equal for
+// equal values. rowKey returns an empty string if any value is null
+func rowKey(row []interface{}, idx []int) (string, []interface{}) {
+	values := make([]interface{}, len(idx))
+	for i, j := range idx {
+		values[i] = rowValue(row, j)
+		if isNull(values[i]) {
+			return "", values
+		}
+		// compare numbers by value, regardless of how they were decoded
+		if valueRank(values[i]) == 2 {
+			values[i] = toFloat64(values[i])
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", values
+	}
+	return string(data), values
+}
+
+func columnNames(titles []string, idx []int) string {
+	names := make([]string, len(idx))
+	for i, j := range idx {
+		names[i] = titles[j]
+	}
+	return strings.Join(names, ", ")
+}
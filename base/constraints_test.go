@@ -0,0 +1,119 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qfs"
+)
+
+type constraintTestLoader map[string]*dataset.Dataset
+
+func (l constraintTestLoader) LoadDataset(ctx context.Context, refstr string) (*dataset.Dataset, error) {
+	ds, ok := l[refstr]
+	if !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	return ds, nil
+}
+
+func TestValidateConstraints(t *testing.T) {
+	ctx := context.Background()
+	body := "id,region,pop\n1,a,100\n2,b,200\n2,c,300\n4,,400\n5,zz,500\n"
+
+	ds := newRowDiffDataset(body)
+	ds.Structure.Schema["unique"] = []interface{}{"region", []interface{}{"region", "pop"}}
+	ds.Structure.Schema["notNull"] = "region"
+	ds.Structure.Schema["foreignKeys"] = []interface{}{
+		map[string]interface{}{"columns": []interface{}{"region"}, "dataset": "peer/regions", "refColumns": []interface{}{"code"}},
+	}
+
+	regions := &dataset.Dataset{
+		Structure: &dataset.Structure{
+			Format:       "csv",
+			FormatConfig: map[string]interface{}{"headerRow": true},
+			Schema: map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":  "array",
+					"items": []interface{}{map[string]interface{}{"title": "code", "type": "string"}},
+				},
+			},
+		},
+	}
+	regions.SetBodyFile(qfs.NewMemfileBytes("body.csv", []byte("code\na\nb\nc\nd\n")))
+	loader := constraintTestLoader{"peer/regions": regions}
+
+	got, err := ValidateConstraints(ctx, loader, ds.BodyFile(), ds.Structure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []jsonschema.KeyError{
+		{PropertyPath: "/2", InvalidValue: []interface{}{float64(2)}, Message: "duplicate primary key id, first used in row 1"},
+		{PropertyPath: "/3/1", Message: `column "region" must not be null`},
+		{PropertyPath: "/4", InvalidValue: []interface{}{"zz"}, Message: "region not found in peer/regions code"},
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	ds = newRowDiffDataset(body)
+	delete(ds.Structure.Schema, "primaryKey")
+	got, err = ValidateConstraints(ctx, nil, ds.BodyFile(), ds.Structure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected a schema without constraints to skip validation, got: %v", got)
+	}
+
+	ds = newRowDiffDataset(body)
+	ds.Structure.Schema["foreignKeys"] = []interface{}{map[string]interface{}{"columns": []interface{}{"region"}, "dataset": "peer/regions"}}
+	if _, err = ValidateConstraints(ctx, nil, ds.BodyFile(), ds.Structure); err == nil {
+		t.Error("expected foreign keys without a loader to error")
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	bad := []struct {
+		keyword string
+		value   interface{}
+	}{
+		{"unique", "id"},
+		{"unique", []interface{}{5}},
+		{"notNull", 5},
+		{"foreignKeys", "peer/regions"},
+		{"foreignKeys", []interface{}{map[string]interface{}{"columns": []interface{}{"id"}}}},
+		{"foreignKeys", []interface{}{map[string]interface{}{"columns": []interface{}{"id"}, "dataset": "a/b", "refColumns": []interface{}{"x", "y"}}}},
+	}
+	for _, c := range bad {
+		ds := newRowDiffDataset("")
+		ds.Structure.Schema[c.keyword] = c.value
+		if _, err := ParseConstraints(ds.Structure); err == nil {
+			t.Errorf("expected %s: %v to error", c.keyword, c.value)
+		}
+	}
+}
+
+func TestSaveEnforceConstraints(t *testing.T) {
+	run := newTestRunner(t)
+	defer run.Delete()
+
+	ds := newRowDiffDataset("id,region,pop\n1,a,100\n1,b,200\n")
+	ds.Name = "constrained"
+	_, err := run.saveDataset(ds, SaveSwitches{EnforceConstraints: true})
+	if !errors.Is(err, ErrConstraintViolation) {
+		t.Fatalf("expected constraint violation error, got: %v", err)
+	}
+
+	ds = newRowDiffDataset("id,region,pop\n1,a,100\n2,b,200\n")
+	ds.Name = "constrained"
+	if _, err := run.saveDataset(ds, SaveSwitches{EnforceConstraints: true}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -85,6 +85,30 @@ func commitFileAddFunc(ctx context.Context, privKey crypto.PrivKey, publisher ev
 	}
 }
 
+// VerifyCommit checks the commit signature of a dataset version against the
+// public key of the version's author. The dataset must have component paths
+// populated, as they are when loaded with LoadDataset
+func VerifyCommit(ds *dataset.Dataset, pub crypto.PubKey) error {
+	if ds == nil || ds.Commit == nil || ds.Commit.Signature == "" {
+		return ErrUnsignedCommit
+	}
+	if pub == nil {
+		return fmt.Errorf("public key is required to verify a commit")
+	}
+	sig, err := base64.StdEncoding.DecodeString(ds.Commit.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: decoding signature: %s", ErrInvalidSignature, err)
+	}
+	ok, err := pub.Verify(ds.SigningBytes(), sig)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
 // confirmByteChangesExist returns an early error if no components paths
 // differ from the previous flag & we're not forcing a commit.
 // if we are forcing a commit, set commit title and message values, which
@@ -242,6 +242,9 @@ func (cff *computeFieldsFile) handleRows(ctx context.Context) {
 			if err := cff.acc.WriteEntry(ent); err != nil {
 				return err
 			}
+			if cff.sw.EntryChecker != nil {
+				cff.sw.EntryChecker.CheckEntry(ent)
+			}
 
 			if i%batchSize == 0 && i != 0 {
 				numValErrs, flushErr := cff.flushBatch(ctx, batchBuf, st, jsch)
@@ -292,6 +295,14 @@ func (cff *computeFieldsFile) handleRows(ctx context.Context) {
 		}
 		valErrorCount += numValErrs
 
+		if cff.sw.EntryChecker != nil {
+			if err := cff.sw.EntryChecker.Close(); err != nil {
+				log.Debugf("checking body entries: %s", err)
+				cff.done <- err
+				return
+			}
+		}
+
 		cff.Lock()
 		defer cff.Unlock()
 		log.Debugw("determined structure values", "errCount", valErrorCount, "entries", entries, "depth", depth, "bytecount", cff.teeReader.BytesRead())
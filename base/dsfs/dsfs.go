@@ -24,4 +24,9 @@ var (
 	// ErrStrictMode indicates a dataset failed validation when it is required to
 	// pass (Structure.Strict == true)
 	ErrStrictMode = fmt.Errorf("dataset body did not validate against schema in strict-mode")
+	// ErrUnsignedCommit indicates a dataset version has no commit signature
+	ErrUnsignedCommit = fmt.Errorf("commit is not signed")
+	// ErrInvalidSignature indicates a commit signature doesn't match the
+	// dataset version & public key it's checked against
+	ErrInvalidSignature = fmt.Errorf("invalid commit signature")
 )
@@ -11,6 +11,7 @@ import (
 	cid "github.com/ipfs/go-cid"
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/dataset/dsviz"
 	"github.com/qri-io/dataset/validate"
 	"github.com/qri-io/qfs"
@@ -58,6 +59,15 @@ type SaveSwitches struct {
 	FileHint string
 	// Drop is a string of components to remove before saving
 	Drop string
+	// EnforceConstraints refuses to save a body that violates the table
+	// constraints declared in the structure schema
+	EnforceConstraints bool
+	// ConstraintLoader loads datasets referenced by foreign key constraints
+	// when EnforceConstraints is set
+	ConstraintLoader dsref.Loader
+	// EntryChecker, if set, is handed each body entry as the body is written.
+	// A non-nil error from it's Close method aborts the write
+	EntryChecker EntryChecker
 	// parsed drop string into list of components
 	dropRevs []*dsref.Rev
 
@@ -67,6 +77,15 @@ type SaveSwitches struct {
 	bodyAct BodyAction
 }
 
+// EntryChecker inspects body entries as they stream into the store
+type EntryChecker interface {
+	// CheckEntry is called with each body entry in order
+	CheckEntry(ent dsio.Entry)
+	// Close is called once all entries are checked, returning an error if the
+	// body must not be saved
+	Close() error
+}
+
 // CreateDataset writes a dataset to a provided store.
 // Store is where we're going to store the data
 // Dataset to be saved
@@ -112,11 +131,13 @@ func CreateDataset(
 
 	peername := ds.Peername
 	name := ds.Name
+	initID := ds.ID
 
 	go func() {
 		evtErr := pub.Publish(ctx, event.ETDatasetSaveStarted, event.DsSaveEvent{
 			Username:   peername,
 			Name:       name,
+			InitID:     initID,
 			Message:    "save started",
 			Completion: 0,
 		})
@@ -131,6 +152,7 @@ func CreateDataset(
 		if evtErr := pub.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{
 			Username:   peername,
 			Name:       name,
+			InitID:     initID,
 			Error:      err,
 			Completion: 1.0,
 		}); evtErr != nil {
@@ -147,6 +169,7 @@ func CreateDataset(
 		if evtErr := pub.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{
 			Username:   peername,
 			Name:       name,
+			InitID:     initID,
 			Error:      err,
 			Completion: 1.0,
 		}); evtErr != nil {
@@ -158,6 +181,7 @@ func CreateDataset(
 	return path, pub.Publish(ctx, event.ETDatasetSaveCompleted, event.DsSaveEvent{
 		Username:   peername,
 		Name:       name,
+		InitID:     initID,
 		Message:    "dataset saved",
 		Path:       path,
 		Completion: 1.0,
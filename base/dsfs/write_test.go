@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -235,6 +236,44 @@ func TestDatasetSaveCustomTimestamp(t *testing.T) {
 	}
 }
 
+func TestVerifyCommit(t *testing.T) {
+	ctx := context.Background()
+	fs := qfs.NewMemFS()
+	privKey := testkeys.GetKeyData(10).PrivKey
+
+	ds := &dataset.Dataset{
+		Commit:    &dataset.Commit{},
+		Structure: &dataset.Structure{Format: "json", Schema: dataset.BaseSchemaArray},
+	}
+	ds.SetBodyFile(qfs.NewMemfileBytes("/body.json", []byte(`[1,2,3]`)))
+
+	path, err := CreateDataset(ctx, fs, fs, event.NilBus, ds, nil, privKey, SaveSwitches{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadDataset(ctx, fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyCommit(got, privKey.GetPublic()); err != nil {
+		t.Errorf("expected commit to verify with the author's key, got: %s", err)
+	}
+	if err := VerifyCommit(got, testkeys.GetKeyData(9).PrivKey.GetPublic()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected verifying with another key to fail with ErrInvalidSignature, got: %v", err)
+	}
+
+	got.Commit.Timestamp = got.Commit.Timestamp.Add(time.Second)
+	if err := VerifyCommit(got, privKey.GetPublic()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected tampered commit to fail with ErrInvalidSignature, got: %v", err)
+	}
+
+	got.Commit.Signature = ""
+	if err := VerifyCommit(got, privKey.GetPublic()); !errors.Is(err, ErrUnsignedCommit) {
+		t.Errorf("expected unsigned commit to fail with ErrUnsignedCommit, got: %v", err)
+	}
+}
+
 func TestDatasetSaveEvents(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
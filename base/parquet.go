@@ -0,0 +1,350 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/tabular"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/base/toqtype"
+	"github.com/segmentio/parquet-go"
+)
+
+// ParquetDataFormat is the format name for Apache Parquet bodies. Parquet isn't
+// one of the formats dsio reads & writes, so qri stores parquet bodies as CSV,
+// converting at the edges: when saving a parquet file & when a parquet body is
+// requested
+const ParquetDataFormat = "parquet"
+
+// ParquetFormat is the dataset.DataFormat that stands in for parquet where
+// qri accepts a typed format, like ReadBodyBytes. The dataset package doesn't
+// enumerate parquet, so this is a value it will never assign
+const ParquetFormat = dataset.DataFormat(-1)
+
+// parquetSchemaMetadataKey stores the json schema of a body in the key/value
+// metadata of parquet files qri writes. Parquet orders columns by name, the
+// stored schema restores the original column order when the file is read
+const parquetSchemaMetadataKey = "qri.schema"
+
+// parquetRowBufferSize is the number of rows read or written at a time
+const parquetRowBufferSize = 512
+
+// IsParquetFormat returns true if a format name or filename refers to parquet
+func IsParquetFormat(s string) bool {
+	if ext := filepath.Ext(s); ext != "" {
+		s = ext
+	}
+	return strings.ToLower(strings.TrimPrefix(s, ".")) == ParquetDataFormat
+}
+
+// ReadParquetBody converts a parquet file to a CSV body, returning the CSV
+// file and a structure that describes it
+func ReadParquetBody(file qfs.File) (qfs.File, *dataset.Structure, error) {
+	r, err := NewParquetEntryReader(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	st := &dataset.Structure{
+		Format:       dataset.CSVDataFormat.String(),
+		FormatConfig: map[string]interface{}{"headerRow": true},
+		Schema:       r.Structure().Schema,
+	}
+	buf := &bytes.Buffer{}
+	w, err := dsio.NewEntryWriter(st, buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dsio.Copy(r, w); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return qfs.NewMemfileReader(st.BodyFilename(), buf), st, nil
+}
+
+// ParquetEntryReader reads the rows of a parquet file as entries
+type ParquetEntryReader struct {
+	st        *dataset.Structure
+	r         *parquet.Reader
+	positions map[int]int
+	width     int
+	rows      []parquet.Row
+	buffered  []parquet.Row
+	index     int
+	done      bool
+}
+
+var _ dsio.EntryReader = (*ParquetEntryReader)(nil)
+
+// NewParquetEntryReader reads a parquet file. Parquet files need random access,
+// so the file is read into memory
+func NewParquetEntryReader(file qfs.File) (*ParquetEntryReader, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading parquet file: %w", err)
+	}
+
+	sch, err := parquetJSONSchema(f)
+	if err != nil {
+		return nil, err
+	}
+	cols, _, err := tabular.ColumnsFromJSONSchema(sch)
+	if err != nil {
+		return nil, err
+	}
+	// map parquet leaf columns to positions in an entry
+	positions := map[int]int{}
+	for i, col := range cols {
+		leaf, ok := f.Schema().Lookup(col.Title)
+		if !ok {
+			return nil, fmt.Errorf("parquet file has no column %q", col.Title)
+		}
+		positions[leaf.ColumnIndex] = i
+	}
+
+	return &ParquetEntryReader{
+		st:        &dataset.Structure{Format: ParquetDataFormat, Schema: sch},
+		r:         parquet.NewReader(bytes.NewReader(data)),
+		positions: positions,
+		width:     len(cols),
+		rows:      make([]parquet.Row, parquetRowBufferSize),
+	}, nil
+}
+
+// Structure gives the structure being read
+func (r *ParquetEntryReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one row of the parquet file
+func (r *ParquetEntryReader) ReadEntry() (dsio.Entry, error) {
+	if len(r.buffered) == 0 {
+		if r.done {
+			return dsio.Entry{}, io.EOF
+		}
+		n, err := r.r.ReadRows(r.rows)
+		if err == io.EOF {
+			r.done = true
+		} else if err != nil {
+			return dsio.Entry{}, fmt.Errorf("reading parquet rows: %w", err)
+		}
+		if n == 0 {
+			r.done = true
+			return dsio.Entry{}, io.EOF
+		}
+		r.buffered = r.rows[:n]
+	}
+
+	row := r.buffered[0]
+	r.buffered = r.buffered[1:]
+	vals := make([]interface{}, r.width)
+	for _, v := range row {
+		if pos, ok := r.positions[v.Column()]; ok {
+			vals[pos] = parquetValue(v)
+		}
+	}
+	ent := dsio.Entry{Index: r.index, Value: vals}
+	r.index++
+	return ent, nil
+}
+
+// Close finalizes the reader
+func (r *ParquetEntryReader) Close() error {
+	return r.r.Close()
+}
+
+// parquetJSONSchema returns the json schema stored in a parquet file written
+// by qri, falling back to a schema derived from parquet column types
+func parquetJSONSchema(f *parquet.File) (map[string]interface{}, error) {
+	if str, ok := f.Lookup(parquetSchemaMetadataKey); ok {
+		sch := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(str), &sch); err == nil {
+			return sch, nil
+		}
+		log.Debugf("ignoring invalid %s parquet metadata", parquetSchemaMetadataKey)
+	}
+	return toqtype.JSONSchemaFromParquet(f.Root())
+}
+
+func parquetValue(v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return int64(v.Int32())
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray())
+	default:
+		return v.String()
+	}
+}
+
+// WriteParquetBody copies entries from a tabular body to w as a parquet file
+func WriteParquetBody(r dsio.EntryReader, w io.Writer) error {
+	st := r.Structure()
+	cols, _, err := tabular.ColumnsFromJSONSchema(st.Schema)
+	if err != nil {
+		return fmt.Errorf("parquet bodies must be tabular: %w", err)
+	}
+	schema, err := toqtype.ParquetSchemaFromJSONSchema("body", st.Schema)
+	if err != nil {
+		return err
+	}
+	schemaData, err := json.Marshal(st.Schema)
+	if err != nil {
+		return err
+	}
+
+	// parquet leaf column index & type for each column of the body
+	type parquetCol struct {
+		index int
+		kind  parquet.Kind
+	}
+	pcols := make([]parquetCol, len(cols))
+	for i, col := range cols {
+		leaf, ok := schema.Lookup(col.Title)
+		if !ok {
+			return fmt.Errorf("missing parquet column %q", col.Title)
+		}
+		pcols[i] = parquetCol{index: leaf.ColumnIndex, kind: leaf.Node.Type().Kind()}
+	}
+
+	pw := parquet.NewWriter(w, schema, parquet.KeyValueMetadata(parquetSchemaMetadataKey, string(schemaData)))
+	rows := make([]parquet.Row, 0, parquetRowBufferSize)
+	flush := func() error {
+		if _, err := pw.WriteRows(rows); err != nil {
+			return err
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	for {
+		ent, err := r.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		vals, ok := ent.Value.([]interface{})
+		if !ok {
+			return fmt.Errorf("parquet bodies must be tabular, entry %d is %T", ent.Index, ent.Value)
+		}
+
+		row := make(parquet.Row, len(pcols))
+		for i, pc := range pcols {
+			v, err := toParquetValue(rowValue(vals, i), pc.kind)
+			if err != nil {
+				return fmt.Errorf("entry %d column %q: %w", ent.Index, cols[i].Title, err)
+			}
+			def := 1
+			if v.IsNull() {
+				def = 0
+			}
+			row[pc.index] = v.Level(0, def, pc.index)
+		}
+		if rows = append(rows, row); len(rows) == parquetRowBufferSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+func toParquetValue(v interface{}, kind parquet.Kind) (parquet.Value, error) {
+	if v == nil {
+		return parquet.Value{}, nil
+	}
+	switch kind {
+	case parquet.Boolean:
+		b, ok := v.(bool)
+		if !ok {
+			return parquet.Value{}, fmt.Errorf("expected a boolean, got %T", v)
+		}
+		return parquet.BooleanValue(b), nil
+	case parquet.Int64:
+		switch x := v.(type) {
+		case int:
+			return parquet.Int64Value(int64(x)), nil
+		case int64:
+			return parquet.Int64Value(x), nil
+		case json.Number:
+			i, err := x.Int64()
+			return parquet.Int64Value(i), err
+		}
+		if valueRank(v) != 2 {
+			return parquet.Value{}, fmt.Errorf("expected an integer, got %T", v)
+		}
+		return parquet.Int64Value(int64(toFloat64(v))), nil
+	case parquet.Double:
+		if valueRank(v) != 2 {
+			return parquet.Value{}, fmt.Errorf("expected a number, got %T", v)
+		}
+		return parquet.DoubleValue(toFloat64(v)), nil
+	default:
+		if s, ok := v.(string); ok {
+			return parquet.ByteArrayValue([]byte(s)), nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ByteArrayValue(data), nil
+	}
+}
+
+// convertParquetBody replaces a parquet body file with it's CSV equivalent,
+// filling in structure fields that describe the CSV body
+func convertParquetBody(ds *dataset.Dataset) error {
+	f := ds.BodyFile()
+	if f == nil {
+		return nil
+	}
+	if !IsParquetFormat(f.FileName()) && (ds.Structure == nil || !IsParquetFormat(ds.Structure.Format)) {
+		return nil
+	}
+
+	body, st, err := ReadParquetBody(f)
+	if err != nil {
+		return err
+	}
+	ds.SetBodyFile(body)
+	if ds.Structure == nil {
+		ds.Structure = st
+		return nil
+	}
+	ds.Structure.Format = st.Format
+	ds.Structure.FormatConfig = st.FormatConfig
+	if ds.Structure.Schema == nil {
+		ds.Structure.Schema = st.Schema
+	}
+	return nil
+}
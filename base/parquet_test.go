@@ -0,0 +1,95 @@
+package base
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
+)
+
+var parquetTestSchema = map[string]interface{}{
+	"type": "array",
+	"items": map[string]interface{}{
+		"type": "array",
+		"items": []interface{}{
+			map[string]interface{}{"title": "name", "type": "string"},
+			map[string]interface{}{"title": "count", "type": "integer"},
+			map[string]interface{}{"title": "avg", "type": "number"},
+			map[string]interface{}{"title": "active", "type": "boolean"},
+		},
+	},
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	jsonSt := &dataset.Structure{Format: "json", Schema: parquetTestSchema}
+	parquetSt := &dataset.Structure{Format: ParquetDataFormat, Schema: parquetTestSchema}
+	body := `[["a",1,1.5,true],["b",null,2.5,false],[null,3,null,null]]`
+
+	pq, err := ConvertBodyFormat(qfs.NewMemfileBytes("body.json", []byte(body)), jsonSt, parquetSt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsParquetFormat(pq.FileName()) {
+		t.Errorf("expected parquet filename, got %q", pq.FileName())
+	}
+
+	got, err := ConvertBodyFormat(pq, parquetSt, jsonSt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(body, string(data)); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConvertParquetBody(t *testing.T) {
+	jsonSt := &dataset.Structure{Format: "json", Schema: parquetTestSchema}
+	pq, err := ConvertBodyFormat(qfs.NewMemfileBytes("body.json", []byte(`[["a",1,1.5,true]]`)), jsonSt, &dataset.Structure{Format: ParquetDataFormat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(pq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := &dataset.Dataset{}
+	ds.SetBodyFile(qfs.NewMemfileBytes("data.parquet", data))
+	if err := convertParquetBody(ds); err != nil {
+		t.Fatal(err)
+	}
+	if ds.Structure == nil || ds.Structure.Format != "csv" {
+		t.Fatalf("expected parquet body to be converted to csv, got structure: %v", ds.Structure)
+	}
+	if diff := cmp.Diff(parquetTestSchema, ds.Structure.Schema); diff != "" {
+		t.Errorf("schema mismatch (-want +got):\n%s", diff)
+	}
+	csv, err := ioutil.ReadAll(ds.BodyFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("name,count,avg,active\na,1,1.5,true\n", string(csv)); diff != "" {
+		t.Errorf("body mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIsParquetFormat(t *testing.T) {
+	cases := map[string]bool{
+		"parquet":            true,
+		"/data/body.PARQUET": true,
+		".parquet":           true,
+		"csv":                false,
+		"body.csv":           false,
+	}
+	for in, expect := range cases {
+		if got := IsParquetFormat(in); got != expect {
+			t.Errorf("IsParquetFormat(%q): want %t, got %t", in, expect, got)
+		}
+	}
+}
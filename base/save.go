@@ -67,6 +67,11 @@ func SaveDataset(
 		mutable.Commit = nil
 	}
 
+	// parquet bodies are stored as CSV
+	if err = convertParquetBody(changes); err != nil {
+		return nil, err
+	}
+
 	// Handle a change in structure format.
 	if changes.BodyFile() != nil && prev.Structure != nil && changes.Structure != nil && prev.Structure.Format != changes.Structure.Format {
 		log.Debugf("body formats differ. prev=%q new=%q", prev.Structure.Format, changes.Structure.Format)
@@ -97,8 +102,17 @@ func SaveDataset(
 		return
 	}
 
+	if sw.EnforceConstraints {
+		if err = enforceConstraints(ctx, fs, changes, sw.ConstraintLoader, &sw); err != nil {
+			return nil, err
+		}
+	}
+
 	// let's make history, if it exists
 	changes.PreviousPath = prevPath
+	// set the initID before writing so save events carry it, including the
+	// events for the first version of a dataset
+	changes.ID = initID
 
 	// Write the dataset to storage and get back the new path
 	ds, err = CreateDataset(ctx, r, writeDest, author, changes, prev, sw)
@@ -115,6 +129,42 @@ func SaveDataset(
 	return ds, nil
 }
 
+// ErrConstraintViolation indicates a dataset body breaks the table constraints
+// declared in it's structure schema
+var ErrConstraintViolation = errors.New("body violates table constraints")
+
+// enforceConstraints errors if the body of ds violates the table constraints
+// declared in it's structure. A body file being saved is checked as dsfs
+// streams it into the store by setting the entry checker on sw
+func enforceConstraints(ctx context.Context, fs qfs.Filesystem, ds *dataset.Dataset, loader dsref.Loader, sw *SaveSwitches) error {
+	if ds.BodyFile() != nil {
+		chk, err := newConstraintChecker(ctx, loader, ds.Structure)
+		if err != nil {
+			return err
+		}
+		if chk != nil {
+			sw.EntryChecker = chk
+		}
+		return nil
+	}
+
+	if ds.BodyPath == "" {
+		return nil
+	}
+	if c, err := ParseConstraints(ds.Structure); err != nil || c.IsEmpty() {
+		return err
+	}
+	body, err := dsfs.LoadBody(ctx, fs, ds)
+	if err != nil {
+		return err
+	}
+	errs, err := ValidateConstraints(ctx, loader, body, ds.Structure)
+	if err != nil {
+		return err
+	}
+	return constraintViolationError(errs)
+}
+
 // CreateDataset uses dsfs to add a dataset to a repo's store, updating the refstore
 func CreateDataset(ctx context.Context, r repo.Repo, writeDest qfs.Filesystem, author *profile.Profile, ds, dsPrev *dataset.Dataset, sw SaveSwitches) (res *dataset.Dataset, err error) {
 	log.Debugw("CreateDataset", "ds.ID", ds.ID)
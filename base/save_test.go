@@ -84,6 +84,48 @@ func TestSaveDatasetReplace(t *testing.T) {
 	}
 }
 
+func TestSaveDatasetFirstSaveEventInitID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mux, err := muxfs.New(ctx, []qfs.Config{{Type: "mem"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := event.NewBus(ctx)
+	r, err := repo.NewMemRepoWithProfile(ctx, testPeerProfile, mux, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initIDs := make(chan string, 1)
+	bus.SubscribeTypes(func(ctx context.Context, e event.Event) error {
+		if p, ok := e.Payload.(event.DsSaveEvent); ok && p.Error == nil {
+			initIDs <- p.InitID
+		}
+		return nil
+	}, event.ETDatasetSaveCompleted)
+
+	run := &TestRunner{Context: ctx, Repo: r}
+	ds := run.BuildDataset("first_save", "json")
+	ds.SetBodyFile(qfs.NewMemfileBytes("body.json", []byte("[]")))
+	if _, err := run.SaveDataset(ds); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := dsref.Ref{Username: r.Logbook().Owner().Peername, Name: "first_save"}
+	if _, err := r.Logbook().ResolveRef(ctx, &ref); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-initIDs:
+		if got != ref.InitID {
+			t.Errorf("expected first save completed event to have InitID %q, got %q", ref.InitID, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for save completed event")
+	}
+}
+
 func TestCreateDataset(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
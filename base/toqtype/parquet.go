@@ -0,0 +1,93 @@
+package toqtype
+
+import (
+	"fmt"
+
+	"github.com/qri-io/dataset/tabular"
+	"github.com/segmentio/parquet-go"
+)
+
+// JSONSchemaFromParquet creates a tabular json schema from the columns of a
+// parquet file. Parquet files must be flat tables, nested columns aren't
+// supported
+func JSONSchemaFromParquet(root *parquet.Column) (map[string]interface{}, error) {
+	cols := root.Columns()
+	items := make([]interface{}, 0, len(cols))
+	for _, col := range cols {
+		if !col.Leaf() || col.Repeated() {
+			return nil, fmt.Errorf("parquet column %q: nested & repeated columns are not supported", col.Name())
+		}
+		items = append(items, map[string]interface{}{
+			"title": col.Name(),
+			"type":  ParquetKindToQType(col.Type().Kind()),
+		})
+	}
+
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		},
+	}, nil
+}
+
+// ParquetKindToQType maps a parquet physical type to a json schema type
+func ParquetKindToQType(k parquet.Kind) string {
+	switch k {
+	case parquet.Boolean:
+		return "boolean"
+	case parquet.Int32, parquet.Int64:
+		return "integer"
+	case parquet.Float, parquet.Double:
+		return "number"
+	default:
+		// byte arrays & 96 bit timestamps are represented as strings
+		return "string"
+	}
+}
+
+// ParquetSchemaFromJSONSchema creates a parquet schema from a tabular json
+// schema. All parquet columns are optional so null values can be written.
+// Columns that allow more than one type are written as strings
+func ParquetSchemaFromJSONSchema(name string, sch map[string]interface{}) (*parquet.Schema, error) {
+	cols, _, err := tabular.ColumnsFromJSONSchema(sch)
+	if err != nil {
+		return nil, err
+	}
+
+	group := parquet.Group{}
+	for _, col := range cols {
+		if _, exists := group[col.Title]; exists {
+			return nil, fmt.Errorf("duplicate column title %q", col.Title)
+		}
+		group[col.Title] = parquet.Optional(QTypeToParquetNode(col.Type))
+	}
+	return parquet.NewSchema(name, group), nil
+}
+
+// QTypeToParquetNode maps a column type to a parquet node, ignoring "null"
+func QTypeToParquetNode(t *tabular.ColType) parquet.Node {
+	if t == nil {
+		return parquet.String()
+	}
+	types := make([]string, 0, len(*t))
+	for _, s := range *t {
+		if s != "null" {
+			types = append(types, s)
+		}
+	}
+	if len(types) != 1 {
+		return parquet.String()
+	}
+	switch types[0] {
+	case "boolean":
+		return parquet.Leaf(parquet.BooleanType)
+	case "integer":
+		return parquet.Int(64)
+	case "number":
+		return parquet.Leaf(parquet.DoubleType)
+	default:
+		return parquet.String()
+	}
+}
@@ -0,0 +1,69 @@
+package toqtype
+
+import (
+	"testing"
+
+	"github.com/segmentio/parquet-go"
+)
+
+func TestParquetSchemaFromJSONSchema(t *testing.T) {
+	sch := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "array",
+			"items": []interface{}{
+				map[string]interface{}{"title": "name", "type": "string"},
+				map[string]interface{}{"title": "count", "type": []interface{}{"integer", "null"}},
+				map[string]interface{}{"title": "avg", "type": "number"},
+				map[string]interface{}{"title": "active", "type": "boolean"},
+				map[string]interface{}{"title": "mixed", "type": []interface{}{"integer", "string"}},
+			},
+		},
+	}
+
+	ps, err := ParquetSchemaFromJSONSchema("body", sch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]parquet.Kind{
+		"name":   parquet.ByteArray,
+		"count":  parquet.Int64,
+		"avg":    parquet.Double,
+		"active": parquet.Boolean,
+		"mixed":  parquet.ByteArray,
+	}
+	for title, kind := range expect {
+		leaf, ok := ps.Lookup(title)
+		if !ok {
+			t.Errorf("missing column %q", title)
+			continue
+		}
+		if got := leaf.Node.Type().Kind(); got != kind {
+			t.Errorf("column %q kind mismatch. want %s, got %s", title, kind, got)
+		}
+		if !leaf.Node.Optional() {
+			t.Errorf("expected column %q to be optional", title)
+		}
+	}
+
+	if _, err := ParquetSchemaFromJSONSchema("body", map[string]interface{}{"type": "object"}); err == nil {
+		t.Error("expected non-tabular schema to error")
+	}
+}
+
+func TestParquetKindToQType(t *testing.T) {
+	cases := map[parquet.Kind]string{
+		parquet.Boolean:   "boolean",
+		parquet.Int32:     "integer",
+		parquet.Int64:     "integer",
+		parquet.Float:     "number",
+		parquet.Double:    "number",
+		parquet.ByteArray: "string",
+		parquet.Int96:     "string",
+	}
+	for kind, expect := range cases {
+		if got := ParquetKindToQType(kind); got != expect {
+			t.Errorf("kind %s: want %q, got %q", kind, expect, got)
+		}
+	}
+}
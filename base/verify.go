@@ -0,0 +1,129 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/qri-io/qri/auth/key"
+	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/logbook"
+	"github.com/qri-io/qri/logbook/oplog"
+	"github.com/qri-io/qri/repo"
+)
+
+// ErrVerificationFailed indicates a dataset history doesn't match the public
+// key of its author
+var ErrVerificationFailed = fmt.Errorf("dataset failed verification")
+
+// VersionVerification is the result of verifying a single dataset version
+type VersionVerification struct {
+	Path        string    `json:"path"`
+	CommitTitle string    `json:"commitTitle,omitempty"`
+	CommitTime  time.Time `json:"commitTime,omitempty"`
+	// Foreign versions aren't stored locally, and can't be checked
+	Foreign bool `json:"foreign,omitempty"`
+	// Error describes why the version failed verification, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// Verification is the result of verifying the history of a dataset
+type Verification struct {
+	Ref dsref.Ref `json:"ref"`
+	// KeyID identifies the public key the dataset was checked against
+	KeyID string `json:"keyID"`
+	// LogError describes why the dataset log failed verification, if it did
+	LogError string                `json:"logError,omitempty"`
+	Versions []VersionVerification `json:"versions"`
+}
+
+// Err returns a wrap of ErrVerificationFailed describing the first problem
+// found, or nil if the log & all local versions passed verification
+func (v *Verification) Err() error {
+	if v.LogError != "" {
+		return fmt.Errorf("%w: %s", ErrVerificationFailed, v.LogError)
+	}
+	for _, ver := range v.Versions {
+		if ver.Error != "" {
+			return fmt.Errorf("%w: version %s: %s", ErrVerificationFailed, ver.Path, ver.Error)
+		}
+	}
+	return nil
+}
+
+// VerifyDataset checks the logbook of a dataset and the commit signature of
+// every locally stored version against the public key of the dataset author.
+// Versions that aren't stored locally are marked foreign & skipped. The
+// returned Verification records problems, callers should check Err
+func VerifyDataset(ctx context.Context, r repo.Repo, ref dsref.Ref, pub crypto.PubKey) (*Verification, error) {
+	keyID, err := key.IDFromPubKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verification{Ref: ref, KeyID: keyID}
+
+	if book := r.Logbook(); book != nil && ref.InitID != "" {
+		if err := book.VerifyDatasetLog(ctx, ref.InitID, pub); err != nil {
+			if errors.Is(err, logbook.ErrInvalidLog) {
+				v.LogError = err.Error()
+			} else if !errors.Is(err, oplog.ErrNotFound) {
+				return nil, err
+			}
+		}
+	}
+
+	items, err := DatasetLog(ctx, r, ref, -1, 0, "history", false)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := r.Filesystem()
+	for i, item := range items {
+		ver := VersionVerification{
+			Path:        item.Path,
+			CommitTitle: item.CommitTitle,
+			CommitTime:  item.CommitTime,
+			Foreign:     item.Foreign,
+		}
+		if !item.Foreign {
+			if ds, err := dsfs.LoadDataset(ctx, fs, item.Path); err != nil {
+				ver.Error = fmt.Sprintf("loading version: %s", err)
+			} else if err := dsfs.VerifyCommit(ds, pub); err != nil {
+				ver.Error = err.Error()
+			} else if i+1 < len(items) && ds.PreviousPath != items[i+1].Path {
+				// versions are ordered newest to oldest, each commit must point to the
+				// version before it in the log
+				ver.Error = fmt.Sprintf("previous path %q doesn't match log version %q", ds.PreviousPath, items[i+1].Path)
+			}
+		}
+		v.Versions = append(v.Versions, ver)
+	}
+
+	return v, nil
+}
+
+// VerifyVersion checks the logbook of a dataset & the commit signature of a
+// single locally stored version against the public key of the dataset author.
+// Like VerifyDataset, a dataset with no log only has its commit checked
+func VerifyVersion(ctx context.Context, r repo.Repo, ref dsref.Ref, pub crypto.PubKey) error {
+	if book := r.Logbook(); book != nil && ref.InitID != "" {
+		if err := book.VerifyDatasetLog(ctx, ref.InitID, pub); err != nil {
+			if errors.Is(err, logbook.ErrInvalidLog) {
+				return fmt.Errorf("%w: %s", ErrVerificationFailed, err)
+			} else if !errors.Is(err, oplog.ErrNotFound) {
+				return err
+			}
+		}
+	}
+	ds, err := dsfs.LoadDataset(ctx, r.Filesystem(), ref.Path)
+	if err != nil {
+		return err
+	}
+	if err := dsfs.VerifyCommit(ds, pub); err != nil {
+		return fmt.Errorf("%w: version %s: %s", ErrVerificationFailed, ref.Path, err)
+	}
+	return nil
+}
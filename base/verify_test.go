@@ -0,0 +1,52 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	testkeys "github.com/qri-io/qri/auth/key/test"
+)
+
+func TestVerifyDataset(t *testing.T) {
+	ctx := context.Background()
+	mr := newTestRepo(t)
+	addCitiesDataset(t, mr)
+	cities := updateCitiesDataset(t, mr, "")
+	pub := testPeerProfile.PrivKey.GetPublic()
+
+	v, err := VerifyDataset(ctx, mr, cities, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Err(); err != nil {
+		t.Errorf("expected dataset to verify against the author's key, got: %s", err)
+	}
+	if len(v.Versions) != 2 {
+		t.Errorf("expected 2 verified versions, got: %d", len(v.Versions))
+	}
+	if err := VerifyVersion(ctx, mr, cities, pub); err != nil {
+		t.Errorf("expected head version to verify against the author's key, got: %s", err)
+	}
+
+	other := testkeys.GetKeyData(9).PrivKey.GetPublic()
+	if v, err = VerifyDataset(ctx, mr, cities, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Err(); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("expected verifying against another key to fail with ErrVerificationFailed, got: %v", err)
+	}
+	if err := VerifyVersion(ctx, mr, cities, other); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("expected verifying head against another key to fail with ErrVerificationFailed, got: %v", err)
+	}
+
+	// versions of datasets with no log only have their commit checked
+	noLog := cities
+	noLog.InitID = "no_such_init_id"
+	if err := VerifyVersion(ctx, mr, noLog, pub); err != nil {
+		t.Errorf("expected a version with no log to verify by commit, got: %s", err)
+	}
+	if err := VerifyVersion(ctx, mr, noLog, other); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("expected a version with no log to fail commit verification, got: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/base/component"
+	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/dsref"
+)
+
+// WorkingDirectoryComponentNames are the components a working directory holds
+// as files. The commit is created when saving, and viz is deprecated
+func WorkingDirectoryComponentNames() []string {
+	return []string{"meta", "structure", "readme", "transform", "body"}
+}
+
+// WriteWorkingDirectory writes the components of the dataset version at path
+// to files in dir
+func WriteWorkingDirectory(ctx context.Context, fs qfs.Filesystem, path, dir string) error {
+	comp, err := versionComponents(ctx, fs, path)
+	if err != nil {
+		return err
+	}
+	for _, compName := range WorkingDirectoryComponentNames() {
+		if sub := comp.Base().GetSubcomponent(compName); sub != nil {
+			if _, err := sub.WriteTo(dir); err != nil {
+				return fmt.Errorf("writing %s: %w", compName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadWorkingDirectory reads the component files in dir into a dataset
+func ReadWorkingDirectory(ctx context.Context, fs qfs.Filesystem, dir string) (*dataset.Dataset, error) {
+	comp, err := component.ListDirectoryComponents(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := component.ExpandListedComponents(comp, fs); err != nil {
+		return nil, err
+	}
+	for _, compName := range append([]string{"dataset"}, component.AllSubcomponentNames()...) {
+		if sub := comp.Base().GetSubcomponent(compName); sub != nil && sub.Base().ProblemKind != "" {
+			return nil, fmt.Errorf("%s %s: %s", compName, sub.Base().ProblemKind, sub.Base().ProblemMessage)
+		}
+	}
+	return component.ToDataset(comp)
+}
+
+// RestoreComponent overwrites the file of a single component in a working
+// directory with its value at the dataset version at path. If the version
+// doesn't have the component, its file is removed
+func RestoreComponent(ctx context.Context, fs qfs.Filesystem, path, dir, compName string) error {
+	known, ok := component.GetKnownFilenames()[compName]
+	if !ok || compName == "dataset" || compName == "commit" || compName == "viz" {
+		return fmt.Errorf("cannot restore unknown component %q", compName)
+	}
+	comp, err := versionComponents(ctx, fs, path)
+	if err != nil {
+		return err
+	}
+
+	// remove every file for the component first, the restored component may
+	// use a different format than the working one
+	for _, ext := range known {
+		if err := os.Remove(filepath.Join(dir, compName+ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if sub := comp.Base().GetSubcomponent(compName); sub != nil {
+		if _, err := sub.WriteTo(dir); err != nil {
+			return fmt.Errorf("writing %s: %w", compName, err)
+		}
+	}
+	return nil
+}
+
+// WorkingDirectoryStatus compares the component files in a working directory
+// with the dataset version at ref.Path they were checked out from
+func (cs *ComponentStatus) WorkingDirectoryStatus(ctx context.Context, ref dsref.Ref, dir string) ([]StatusItem, error) {
+	if ref.Path == "" {
+		return nil, fmt.Errorf("path is required to determine working directory status")
+	}
+	prev, err := versionComponents(ctx, cs.fs, ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	next, err := workingDirectoryComponents(dir, cs.fs)
+	if err != nil {
+		return nil, err
+	}
+	return cs.calculateStateTransition(ctx, prev, next)
+}
+
+// versionComponents loads the dataset version at path as components, without
+// the values a working directory doesn't hold
+func versionComponents(ctx context.Context, fs qfs.Filesystem, path string) (component.Component, error) {
+	ds, err := dsfs.LoadDataset(ctx, fs, path)
+	if err != nil {
+		return nil, err
+	}
+	comp := component.ConvertDatasetToComponents(ds, fs)
+	comp.Base().RemoveSubcomponent("commit")
+	comp.Base().RemoveSubcomponent("viz")
+	for _, compName := range WorkingDirectoryComponentNames() {
+		if sub := comp.Base().GetSubcomponent(compName); sub != nil {
+			if err := sub.LoadAndFill(nil); err != nil {
+				return nil, fmt.Errorf("loading %s: %w", compName, err)
+			}
+		}
+	}
+	comp.DropDerivedValues()
+	return comp, nil
+}
+
+// workingDirectoryComponents lists and reads the component files in dir.
+// Files that can't be read are recorded as component problems instead of
+// returning an error
+func workingDirectoryComponents(dir string, fs qfs.Filesystem) (component.Component, error) {
+	comp, err := component.ListDirectoryComponents(dir)
+	if errors.Is(err, component.ErrNoDatasetFiles) {
+		comp = &component.FilesysComponent{}
+	} else if err != nil {
+		return nil, err
+	}
+	if err := component.ExpandListedComponents(comp, fs); err != nil {
+		return nil, err
+	}
+	comp.Base().RemoveSubcomponent("commit")
+	comp.Base().RemoveSubcomponent("viz")
+	for _, compName := range WorkingDirectoryComponentNames() {
+		sub := comp.Base().GetSubcomponent(compName)
+		if sub == nil || sub.Base().ProblemKind != "" {
+			continue
+		}
+		if err := sub.LoadAndFill(nil); err != nil {
+			if sub.Base().ProblemKind == "" {
+				sub.Base().SetErrorAsProblem(STParseError, err)
+			}
+			continue
+		}
+		sub.DropDerivedValues()
+	}
+	return comp, nil
+}
@@ -0,0 +1,77 @@
+package base
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkingDirectory(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+	fs := r.Filesystem()
+
+	dir, err := ioutil.TempDir("", "working_directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := WriteWorkingDirectory(ctx, fs, ref.Path, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := NewComponentStatus(ctx, fs)
+	expectStatus := func(expect map[string]string) {
+		t.Helper()
+		items, err := cs.WorkingDirectoryStatus(ctx, ref, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) == 0 {
+			t.Fatal("expected status items, got none")
+		}
+		for _, si := range items {
+			typ, ok := expect[si.Component]
+			if !ok {
+				typ = STUnmodified
+			}
+			if si.Type != typ {
+				t.Errorf("component %q status mismatch. want: %q, got: %q", si.Component, typ, si.Type)
+			}
+		}
+	}
+
+	// a fresh checkout matches the version it was written from
+	expectStatus(nil)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "meta.json"), []byte(`{"title":"edited title"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "body.csv")); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(map[string]string{"meta": STChange, "body": STRemoved})
+
+	ds, err := ReadWorkingDirectory(ctx, fs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Meta == nil || ds.Meta.Title != "edited title" {
+		t.Errorf("expected working directory dataset to have the edited meta title. got: %v", ds.Meta)
+	}
+
+	for _, compName := range []string{"meta", "body"} {
+		if err := RestoreComponent(ctx, fs, ref.Path, dir, compName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectStatus(nil)
+
+	if err := RestoreComponent(ctx, fs, ref.Path, dir, "commit"); err == nil {
+		t.Error("expected restoring the commit component to fail")
+	}
+}
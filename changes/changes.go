@@ -48,6 +48,9 @@ type ChangeReportDeltaComponent struct {
 	ChangeReportComponent
 	Title string      `json:"title,omitempty"`
 	Delta interface{} `json:"delta"`
+	// Distribution is the change in a column's histogram or value frequencies.
+	// Only populated by StatsDiff
+	Distribution *DistributionDelta `json:"distribution,omitempty"`
 }
 
 // StatsChangeComponent represents the stats change report
@@ -82,6 +85,8 @@ type EmptyObject map[string]interface{}
 // Service generates a change report between two datasets
 type Service interface {
 	Report(ctx context.Context, leftRef, rightRef string) (*ChangeReportResponse, error)
+	// StatsDiff compares the stats of two datasets column-by-column
+	StatsDiff(ctx context.Context, leftDs, rightDs *dataset.Dataset) (*StatsChangeComponent, error)
 }
 
 // Service can generate a change report between two datasets
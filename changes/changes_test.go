@@ -778,3 +778,56 @@ raleigh,25000,5000.65,false`
 
 	return run.updateDataset(t, ds, alteredBodyData)
 }
+
+func TestDistributionDelta(t *testing.T) {
+	cols := getBaseCols()
+
+	// avg_age histograms have different bins, so only left & right are set
+	got := distributionDelta(cols[0].Left, cols[0].Right)
+	if got == nil || got.Histogram == nil {
+		t.Fatalf("expected numeric column to compare histograms, got: %#v", got)
+	}
+	if got.Histogram.Delta != nil {
+		t.Errorf("expected histograms with different bins to have no delta, got: %v", got.Histogram.Delta)
+	}
+
+	hist := map[string]interface{}{
+		"bins":        []interface{}{float64(0), float64(10), float64(20)},
+		"frequencies": []interface{}{float64(3), float64(1)},
+	}
+	moreHist := map[string]interface{}{
+		"bins":        []interface{}{float64(0), float64(10), float64(20)},
+		"frequencies": []interface{}{float64(2), float64(4)},
+	}
+	got = distributionDelta(EmptyObject{"histogram": hist}, EmptyObject{"histogram": moreHist})
+	if diff := cmp.Diff([]float64{-1, 3}, got.Histogram.Delta); diff != "" {
+		t.Errorf("histogram delta mismatch (-want +got):\n%s", diff)
+	}
+
+	// city frequencies are unchanged
+	got = distributionDelta(cols[1].Left, cols[1].Right)
+	expect := &DistributionDelta{Categories: []*CategoryDelta{}}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("unchanged frequencies mismatch (-want +got):\n%s", diff)
+	}
+
+	left := EmptyObject{"frequencies": map[string]interface{}{"a": float64(2), "b": float64(1), "c": float64(1)}}
+	right := EmptyObject{"frequencies": map[string]interface{}{"a": float64(5), "b": float64(1), "d": float64(1)}}
+	got = distributionDelta(left, right)
+	expect = &DistributionDelta{
+		Categories: []*CategoryDelta{
+			{Value: "a", Left: 2, Right: 5, Delta: 3},
+			{Value: "c", Left: 1, Right: 0, Delta: -1},
+			{Value: "d", Left: 0, Right: 1, Delta: 1},
+		},
+		AddedCategories:   1,
+		RemovedCategories: 1,
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("frequency delta mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := distributionDelta(EmptyObject{"trueCount": float64(1)}, EmptyObject{}); got != nil {
+		t.Errorf("expected columns without a distribution to return nil, got: %#v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package changes
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/qri-io/dataset"
+)
+
+// DistributionDelta describes how the distribution of values in a column
+// changed between two versions. Numeric columns compare histograms, string
+// columns compare category frequencies
+type DistributionDelta struct {
+	Histogram *HistogramDelta `json:"histogram,omitempty"`
+	// Categories lists string values whose frequency changed, ordered by the
+	// size of the change
+	Categories        []*CategoryDelta `json:"categories,omitempty"`
+	AddedCategories   int              `json:"addedCategories,omitempty"`
+	RemovedCategories int              `json:"removedCategories,omitempty"`
+}
+
+// Histogram is a set of bins & the number of values that fall within each bin.
+// Bins are bin edges, so there is one more bin than there are frequencies
+type Histogram struct {
+	Bins        []float64 `json:"bins"`
+	Frequencies []float64 `json:"frequencies"`
+}
+
+// HistogramDelta compares the histograms of a numeric column
+type HistogramDelta struct {
+	Left  *Histogram `json:"left,omitempty"`
+	Right *Histogram `json:"right,omitempty"`
+	// Delta is the change in frequency of each bin. Delta is only set when both
+	// histograms have the same bins
+	Delta []float64 `json:"delta,omitempty"`
+}
+
+// CategoryDelta is the change in frequency of a single string value
+type CategoryDelta struct {
+	Value string  `json:"value"`
+	Left  float64 `json:"left"`
+	Right float64 `json:"right"`
+	Delta float64 `json:"delta"`
+}
+
+// StatsDiff computes the change in stats between two datasets, including the
+// change in the distribution of values in each column. Columns are sorted by
+// title
+func (svc *service) StatsDiff(ctx context.Context, leftDs, rightDs *dataset.Dataset) (*StatsChangeComponent, error) {
+	res, err := svc.statsDiff(ctx, leftDs, rightDs)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(res.Columns, func(i, j int) bool {
+		return res.Columns[i].Title < res.Columns[j].Title
+	})
+	for _, col := range res.Columns {
+		col.Distribution = distributionDelta(col.Left, col.Right)
+	}
+	return res, nil
+}
+
+// distributionDelta compares the histograms or frequencies of the left & right
+// stats of a column, returning nil if neither side has a distribution
+func distributionDelta(left, right interface{}) *DistributionDelta {
+	l, r := statsObject(left), statsObject(right)
+
+	lh, rh := parseHistogram(l["histogram"]), parseHistogram(r["histogram"])
+	if lh != nil || rh != nil {
+		hd := &HistogramDelta{Left: lh, Right: rh}
+		if lh != nil && rh != nil && equalFloats(lh.Bins, rh.Bins) && len(lh.Frequencies) == len(rh.Frequencies) {
+			hd.Delta = make([]float64, len(lh.Frequencies))
+			for i := range lh.Frequencies {
+				hd.Delta[i] = rh.Frequencies[i] - lh.Frequencies[i]
+			}
+		}
+		return &DistributionDelta{Histogram: hd}
+	}
+
+	lf, lok := l["frequencies"].(map[string]interface{})
+	rf, rok := r["frequencies"].(map[string]interface{})
+	if !lok && !rok {
+		return nil
+	}
+
+	dd := &DistributionDelta{Categories: []*CategoryDelta{}}
+	for value, lv := range lf {
+		c := &CategoryDelta{Value: value, Left: toFloat(lv)}
+		if rv, ok := rf[value]; ok {
+			c.Right = toFloat(rv)
+		} else {
+			dd.RemovedCategories++
+		}
+		c.Delta = c.Right - c.Left
+		if c.Delta != 0 {
+			dd.Categories = append(dd.Categories, c)
+		}
+	}
+	for value, rv := range rf {
+		if _, ok := lf[value]; ok {
+			continue
+		}
+		dd.AddedCategories++
+		c := &CategoryDelta{Value: value, Right: toFloat(rv)}
+		c.Delta = c.Right
+		dd.Categories = append(dd.Categories, c)
+	}
+	sort.Slice(dd.Categories, func(i, j int) bool {
+		a, b := math.Abs(dd.Categories[i].Delta), math.Abs(dd.Categories[j].Delta)
+		if a == b {
+			return dd.Categories[i].Value < dd.Categories[j].Value
+		}
+		return a > b
+	})
+	return dd
+}
+
+func statsObject(v interface{}) map[string]interface{} {
+	switch o := v.(type) {
+	case EmptyObject:
+		return o
+	case map[string]interface{}:
+		return o
+	}
+	return map[string]interface{}{}
+}
+
+func parseHistogram(v interface{}) *Histogram {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	bins, _ := m["bins"].([]interface{})
+	freqs, _ := m["frequencies"].([]interface{})
+	h := &Histogram{
+		Bins:        make([]float64, len(bins)),
+		Frequencies: make([]float64, len(freqs)),
+	}
+	for i, b := range bins {
+		h.Bins[i] = toFloat(b)
+	}
+	for i, f := range freqs {
+		h.Frequencies[i] = toFloat(f)
+	}
+	return h
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
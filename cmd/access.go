@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/qri/lib"
@@ -30,10 +33,28 @@ token creates a JSON Web Token (JWT) that authenticates the given user.
 Constructing an access token requires a private key that backs the given user.
 
 In the course of normal operation you shouldn't need this command, It's mainly
-here for crafting API requests in external progrmas`[1:],
+here for crafting API requests in external progrmas
+
+Tokens can be limited to a set of resources & actions. Actions name a method
+as "read:group:method" for methods that only read, and "write:group:method" for
+all others, eg: "read:dataset:get", or "read:*" for every read-only method.
+Subscribing to events over a websocket is the action "read:event:subscribe".
+Resources name a dataset as "dataset:username:name", or "node" for methods that
+don't act on a single dataset. "*" matches anything that follows it, and
+"_subject" matches the username of the token holder.
+
+Tokens can be listed with "qri access token list", and revoked by ID with
+"qri access token revoke".`[1:],
 		Example: `
   # create an access token to authenticate yourself else where:
   $ qri access token --for me
+
+  # create a token that can only read your datasets:
+  $ qri access token --for me --resource "dataset:_subject:*" --action "read:*"
+
+  # list tokens, then revoke one:
+  $ qri access token list
+  $ qri access token revoke 2b5dd2fe-8a4d-4d7a-9a8c-3b3a07e5f6c4
 `[1:],
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := o.Complete(f, args); err != nil {
@@ -45,7 +66,35 @@ here for crafting API requests in external progrmas`[1:],
 	}
 	tokenCmd.Flags().StringVar(&o.GranteeUsername, "for", "", "user to create access token for")
 	tokenCmd.MarkFlagRequired("for")
+	tokenCmd.Flags().StringSliceVar(&o.Resources, "resource", nil, "resource to limit the token to, can be repeated")
+	tokenCmd.Flags().StringSliceVar(&o.Actions, "action", nil, "action to limit the token to, can be repeated")
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list access tokens created by this node",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.ListAccessTokens(context.TODO())
+		},
+	}
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke TOKEN_ID",
+		Short: "revoke an access token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.RevokeAccessToken(context.TODO(), args[0])
+		},
+	}
 
+	tokenCmd.AddCommand(listCmd, revokeCmd)
 	cmd.AddCommand(tokenCmd)
 	return cmd
 }
@@ -56,6 +105,8 @@ type AccessOptions struct {
 	Instance *lib.Instance
 
 	GranteeUsername string
+	Resources       []string
+	Actions         []string
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
@@ -69,6 +120,8 @@ func (o *AccessOptions) Complete(f Factory, args []string) (err error) {
 func (o *AccessOptions) CreateAccessToken(ctx context.Context) error {
 	p := &lib.CreateAuthTokenParams{
 		GranteeUsername: o.GranteeUsername,
+		Resources:       o.Resources,
+		Actions:         o.Actions,
 	}
 	token, err := o.Instance.Access().CreateAuthToken(ctx, p)
 	if err != nil {
@@ -78,3 +131,53 @@ func (o *AccessOptions) CreateAccessToken(ctx context.Context) error {
 	printInfo(o.Out, token)
 	return nil
 }
+
+// ListAccessTokens prints access tokens created by this node
+func (o *AccessOptions) ListAccessTokens(ctx context.Context) error {
+	toks, err := o.Instance.Access().ListAuthTokens(ctx, &lib.ListAuthTokensParams{Limit: -1})
+	if err != nil {
+		return err
+	}
+	if len(toks) == 0 {
+		printInfo(o.ErrOut, "no access tokens")
+		return nil
+	}
+
+	items := make([]string, len(toks))
+	for i, t := range toks {
+		items[i] = accessTokenString(t)
+	}
+	return printlnStringItems(o.Out, items)
+}
+
+// RevokeAccessToken revokes an access token by ID
+func (o *AccessOptions) RevokeAccessToken(ctx context.Context, id string) error {
+	if err := o.Instance.Access().RevokeAuthToken(ctx, &lib.RevokeAuthTokenParams{ID: id}); err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "revoked access token %s", id)
+	return nil
+}
+
+func accessTokenString(t lib.AuthToken) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s\n  subject: %s", t.ID, t.Subject)
+	if t.ExpiresAt != nil {
+		fmt.Fprintf(b, "\n  expires: %s", t.ExpiresAt.Format(time.RFC3339))
+	}
+	if len(t.Scopes) == 0 {
+		b.WriteString("\n  scopes:  unrestricted")
+	}
+	for _, s := range t.Scopes {
+		rscs := make([]string, len(s.Resources))
+		for i, r := range s.Resources {
+			rscs[i] = strings.Join(r, ":")
+		}
+		acts := make([]string, len(s.Actions))
+		for i, a := range s.Actions {
+			acts[i] = strings.Join(a, ":")
+		}
+		fmt.Fprintf(b, "\n  scope:   %s on %s", strings.Join(acts, ", "), strings.Join(rscs, ", "))
+	}
+	return b.String()
+}
@@ -10,4 +10,6 @@ func TestAccessCreateToken(t *testing.T) {
 
 	run.MustExec(t, "qri access token --for me")
 	run.MustExec(t, "qri access token --for peer")
+	run.MustExec(t, "qri access token --for me --resource dataset:_subject:* --action read:*")
+	run.MustExec(t, "qri access token list")
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/transform/staticlark"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +18,27 @@ func NewAnalyzeTransformCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Comm
 	cmd := &cobra.Command{
 		Use:   "analyze_transform",
 		Short: "analyze a transform script",
+		Long: `
+Analyze a transform script for problems without running it. Analysis reports
+functions that are never called, and traces sensitive data through the script
+to find secrets that may leak.
+
+Leaks are found using axioms: declarations of "sources", functions that
+return sensitive data like load_dataset & ctx.get_secret, and "sinks",
+functions like http.post & print that may leak data passed to them. Provide
+a JSON axioms file with --axioms to replace the default axioms:
+
+  {
+    "sources": [{ "name": "ctx.get_secret" }],
+    "sinks": [{ "name": "http.post", "params": ["url", "params", "headers", "body"] }]
+  }
+
+A sink may list "dangerous" params. If omitted every param is dangerous.`,
+		Example: `  # analyze a transform script:
+  $ qri analyze_transform --file transform.star
+
+  # analyze a transform script with custom axioms:
+  $ qri analyze_transform --file transform.star --axioms axioms.json`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -30,6 +52,7 @@ func NewAnalyzeTransformCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Comm
 
 	cmd.Flags().StringVar(&o.FilePath, "file", "", "path of transform script file")
 	cmd.MarkFlagRequired("file")
+	cmd.Flags().StringVar(&o.AxiomsFile, "axioms", "", "path to a JSON file of sources & sinks for leak analysis")
 
 	return cmd
 }
@@ -37,8 +60,9 @@ func NewAnalyzeTransformCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Comm
 // AnalyzeTransformOptions encapsulates state for the analyze-transform command
 type AnalyzeTransformOptions struct {
 	ioes.IOStreams
-	Instance *lib.Instance
-	FilePath string
+	Instance   *lib.Instance
+	FilePath   string
+	AxiomsFile string
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
@@ -46,7 +70,12 @@ func (o *AnalyzeTransformOptions) Complete(f Factory, args []string) (err error)
 	if o.Instance, err = f.Instance(); err != nil {
 		return err
 	}
-	o.FilePath, err = filepath.Abs(o.FilePath)
+	if o.FilePath, err = filepath.Abs(o.FilePath); err != nil {
+		return err
+	}
+	if o.AxiomsFile != "" {
+		o.AxiomsFile, err = filepath.Abs(o.AxiomsFile)
+	}
 	return err
 }
 
@@ -61,6 +90,7 @@ func (o *AnalyzeTransformOptions) Run() (err error) {
 
 	params := lib.AnalyzeTransformParams{
 		ScriptFileName: o.FilePath,
+		AxiomsFile:     o.AxiomsFile,
 	}
 
 	res, err := inst.Automation().AnalyzeTransform(ctx, &params)
@@ -69,9 +99,12 @@ func (o *AnalyzeTransformOptions) Run() (err error) {
 	}
 
 	for _, msg := range res.Diagnostics {
-		if msg.Category == "unused" {
+		switch msg.Category {
+		case staticlark.CategoryUnused:
 			printWarning(o.Out, "Function unused: %s", msg.Message)
-		} else {
+		case staticlark.CategoryLeak:
+			printWarning(o.Out, "%s: %s", msg.Pos, msg.Message)
+		default:
 			printWarning(o.Out, "Unknown warning: %s", msg.Message)
 		}
 	}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/automation"
+	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/event"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/repo"
 	"github.com/spf13/cobra"
@@ -24,12 +28,32 @@ func NewApplyCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
 the command completes.
 
 The apply command itself does not commit results to the repository. Use
-the --apply flag on the save command to commit results from transforms.`,
+the --apply flag on the save command to commit results from transforms.
+
+Use --record to store the run along with the HTTP requests the transform makes
+& the responses it receives. Recordings leave out URL query strings &
+credential headers, and stop once response bodies reach 10MB. Use --replay
+with the ID of a recorded run to serve HTTP requests from that recording
+instead of the network, reproducing the run offline. Without --file, replay
+uses the latest transform of the run's dataset.
+
+Use --follow with the ID of a run on a connected qri node to print the run's
+step events & script output as they happen. follow exits once the run
+finishes, with an error if the run failed.`,
 		Example: ` # Apply a transform and display the output:
  $ qri apply --file transform.star
 
  # Apply a transform using an existing dataset version:
- $ qri apply --file transform.star me/my_dataset`,
+ $ qri apply --file transform.star me/my_dataset
+
+ # Record the HTTP requests a transform makes:
+ $ qri apply --record --file transform.star me/my_dataset
+
+ # Reproduce a previous run using the HTTP responses it recorded:
+ $ qri apply --replay 7b1fae6d-a03f-4a84-bb1f-3e4e19bc2c93
+
+ # Print the output of a run as it happens:
+ $ qri apply --follow 7b1fae6d-a03f-4a84-bb1f-3e4e19bc2c93`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -42,7 +66,9 @@ the --apply flag on the save command to commit results from transforms.`,
 	}
 
 	cmd.Flags().StringVar(&o.FilePath, "file", "", "path of transform script file")
-	cmd.MarkFlagRequired("file")
+	cmd.Flags().BoolVar(&o.Record, "record", false, "store the run & the HTTP responses it receives so it can be replayed")
+	cmd.Flags().StringVar(&o.Replay, "replay", "", "ID of a previous recorded run to replay HTTP responses from")
+	cmd.Flags().StringVar(&o.Follow, "follow", "", "ID of a run to print events from as they happen")
 	cmd.Flags().StringSliceVar(&o.Secrets, "secrets", nil, "transform secrets as comma separated key,value,key,value,... sequence")
 	cmd.Flags().BoolVar(&o.Quiet, "quiet", false, "whether to suppress output from the application")
 
@@ -59,6 +85,11 @@ type ApplyOptions struct {
 	FilePath string
 	Quiet    bool
 	Secrets  []string
+	Record   bool
+	Replay   string
+	Follow   string
+
+	UsingRPC bool
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
@@ -66,6 +97,7 @@ func (o *ApplyOptions) Complete(f Factory, args []string) (err error) {
 	if o.Instance, err = f.Instance(); err != nil {
 		return err
 	}
+	o.UsingRPC = f.HTTPClient() != nil
 	if o.Refs, err = GetCurrentRefSelect(f, args, -1); err != nil {
 		// This error will be handled during validation
 		if err != repo.ErrEmptyRef {
@@ -73,39 +105,55 @@ func (o *ApplyOptions) Complete(f Factory, args []string) (err error) {
 		}
 		err = nil
 	}
-	o.FilePath, err = filepath.Abs(o.FilePath)
-	if err != nil {
-		return err
+	if o.FilePath != "" {
+		o.FilePath, err = filepath.Abs(o.FilePath)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 // Run executes the apply command
 func (o *ApplyOptions) Run() (err error) {
+	if o.Follow != "" {
+		if o.FilePath != "" || o.Record || o.Replay != "" {
+			return errors.New("--follow can't be combined with --file, --record or --replay")
+		}
+		return o.followRun(context.TODO())
+	}
 
-	if !strings.HasSuffix(o.FilePath, ".star") {
+	if o.Record && o.Replay != "" {
+		return errors.New("--record can't be combined with --replay")
+	}
+	if o.FilePath == "" && o.Replay == "" {
+		return errors.New("--file is required")
+	}
+	if o.FilePath != "" && !strings.HasSuffix(o.FilePath, ".star") {
 		return errors.New("only transform scripts are supported by --file")
 	}
 
 	ctx := context.TODO()
 	inst := o.Instance
 
-	tf := dataset.Transform{
-		ScriptPath: o.FilePath,
+	params := lib.ApplyParams{
+		Ref:          o.Refs.Ref(),
+		ScriptOutput: o.Out,
+		Wait:         true,
+		Record:       o.Record,
+		Replay:       o.Replay,
 	}
 
-	if len(o.Secrets) > 0 {
-		tf.Secrets, err = parseSecrets(o.Secrets...)
-		if err != nil {
-			return err
+	if o.FilePath != "" {
+		params.Transform = &dataset.Transform{
+			ScriptPath: o.FilePath,
 		}
 	}
 
-	params := lib.ApplyParams{
-		Ref:          o.Refs.Ref(),
-		Transform:    &tf,
-		ScriptOutput: o.Out,
-		Wait:         true,
+	if len(o.Secrets) > 0 {
+		if params.Secrets, err = parseSecrets(o.Secrets...); err != nil {
+			return err
+		}
 	}
 
 	terminalWidth, terminalHeight := sizeOfTerminal()
@@ -128,3 +176,63 @@ func (o *ApplyOptions) Run() (err error) {
 	}
 	return nil
 }
+
+// followRun prints the step events & script output of a run as they happen,
+// returning once the run finishes
+func (o *ApplyOptions) followRun(ctx context.Context) error {
+	var status, step string
+	p := &lib.FollowRunParams{
+		ID: o.Follow,
+		Events: func(e automation.RunEvent) error {
+			switch e.Event.Type {
+			case event.ETTransformStart:
+				printInfo(o.ErrOut, "run %s started", o.Follow)
+			case event.ETTransformStepStart:
+				if tsl, ok := e.Event.Payload.(event.TransformStepLifecycle); ok {
+					step = tsl.Name
+				}
+				printInfo(o.ErrOut, "step %s: running", step)
+			case event.ETTransformStepStop:
+				if tsl, ok := e.Event.Payload.(event.TransformStepLifecycle); ok {
+					printInfo(o.ErrOut, "step %s: %s", step, tsl.Status)
+				}
+			case event.ETTransformStepSkip:
+				if tsl, ok := e.Event.Payload.(event.TransformStepLifecycle); ok {
+					printInfo(o.ErrOut, "step %s: skipped", tsl.Name)
+				}
+			case event.ETTransformPrint:
+				if msg, ok := e.Event.Payload.(event.TransformMessage); ok && !o.Quiet {
+					fmt.Fprintln(o.Out, msg.Msg)
+				}
+			case event.ETTransformError:
+				if msg, ok := e.Event.Payload.(event.TransformMessage); ok {
+					printWarning(o.ErrOut, "%s", msg.Msg)
+				}
+			case event.ETTransformCanceled:
+				status = "canceled"
+			case event.ETTransformStop:
+				if tl, ok := e.Event.Payload.(event.TransformLifecycle); ok {
+					status = tl.Status
+				}
+			}
+			return nil
+		},
+	}
+
+	err := o.Instance.Automation().FollowRun(ctx, p)
+	if errors.Is(err, automation.ErrRunEventsNotFound) && !o.UsingRPC {
+		return fmt.Errorf("run %q not found. following a run requires a running qri node, start one with `qri connect`", o.Follow)
+	} else if err != nil {
+		return err
+	}
+
+	switch run.Status(status) {
+	case run.RSFailed, run.RSLimitExceeded, "canceled":
+		return fmt.Errorf("run %s %s", o.Follow, status)
+	case "":
+		printInfo(o.ErrOut, "run %s finished", o.Follow)
+	default:
+		printSuccess(o.ErrOut, "run %s %s", o.Follow, status)
+	}
+	return nil
+}
@@ -86,3 +86,21 @@ func TestApplyModifyBody(t *testing.T) {
 		t.Errorf("contents mismatch, want: %s, got: %s", expectContains, output)
 	}
 }
+
+func TestApplyFollowNotConnected(t *testing.T) {
+	run := NewTestRunner(t, "test_peer_apply_follow", "qri_test_apply_follow")
+	defer run.Delete()
+
+	err := run.ExecCommand("qri apply --follow not_a_run")
+	if err == nil {
+		t.Fatal("expected following a run without a connected node to error")
+	}
+	expectErr := "run \"not_a_run\" not found. following a run requires a running qri node, start one with `qri connect`"
+	if diff := cmp.Diff(expectErr, err.Error()); diff != "" {
+		t.Errorf("result mismatch (-want +got):%s\n", diff)
+	}
+
+	if err := run.ExecCommand("qri apply --follow not_a_run --file testdata/movies/tf.star"); err == nil {
+		t.Errorf("expected combining --follow & --file to error")
+	}
+}
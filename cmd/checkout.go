@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/dsref"
+	qerr "github.com/qri-io/qri/errors"
+	"github.com/qri-io/qri/lib"
+	"github.com/spf13/cobra"
+)
+
+// NewCheckoutCommand creates a new `qri checkout` cobra command for writing a
+// dataset to a linked working directory
+func NewCheckoutCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &CheckoutOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "checkout DATASET [DIRECTORY]",
+		Short: "write a dataset to a working directory",
+		Annotations: map[string]string{
+			"group": "dataset",
+		},
+		Long: `Checkout writes the latest version of a dataset to files in a directory, and
+links the directory to the dataset. Each component gets its own file, like
+meta.json, structure.json and body.csv, so you can edit them with any tool.
+
+Inside a linked directory ` + "`qri status`" + ` shows which files changed,
+` + "`qri restore`" + ` discards changes to a component, and ` + "`qri save`" + ` commits
+the files as a new version. The directory defaults to the dataset name.`,
+		Example: `  # check out a dataset, then edit & save it:
+  $ qri checkout me/annual_pop
+  $ cd annual_pop
+  $ qri status
+  $ qri save --title "fix population counts"`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// CheckoutOptions encapsulates state for the checkout command
+type CheckoutOptions struct {
+	ioes.IOStreams
+
+	Refs *RefSelect
+	Dir  string
+
+	inst *lib.Instance
+}
+
+// Complete adds any missing configuration that can only be added just before calling Run
+func (o *CheckoutOptions) Complete(f Factory, args []string) (err error) {
+	if o.inst, err = f.Instance(); err != nil {
+		return err
+	}
+	if o.Refs, err = GetCurrentRefSelect(f, args[:1], 1); err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		o.Dir = args[1]
+	} else {
+		ref, err := dsref.Parse(o.Refs.Ref())
+		if err != nil {
+			return err
+		}
+		o.Dir = ref.Name
+	}
+	o.Dir, err = filepath.Abs(o.Dir)
+	return err
+}
+
+// Run executes the checkout command
+func (o *CheckoutOptions) Run() error {
+	ctx := context.TODO()
+	link, err := o.inst.FSI().Checkout(ctx, &lib.CheckoutParams{Ref: o.Refs.Ref(), Dir: o.Dir})
+	if err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "checked out %s to %s", link.Human(), o.Dir)
+	return nil
+}
+
+// linkedDirectory returns the current directory, or an error if it isn't
+// linked to a dataset
+func linkedDirectory() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if _, err := lib.ReadLink(dir); err != nil {
+		if errors.Is(err, lib.ErrNotLinked) {
+			return "", qerr.New(err, "this directory is not linked to a dataset, use `qri checkout` to create a working directory")
+		}
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckoutStatusRestoreSave(t *testing.T) {
+	run := NewTestRunner(t, "test_peer_checkout", "qri_test_checkout")
+	defer run.Delete()
+
+	tmpDir := run.MakeTmpDir(t, "checkout")
+	bodyPath := filepath.Join(tmpDir, "cities.csv")
+	run.MustWriteFile(t, bodyPath, "city,pop\ntoronto,40000000\nnew york,8500000\n")
+	run.MustExec(t, "qri save --body "+bodyPath+" me/cities")
+
+	workDir := filepath.Join(tmpDir, "work")
+	run.MustExec(t, "qri checkout me/cities "+workDir)
+	for _, filename := range []string{"structure.json", "body.csv"} {
+		if _, err := os.Stat(filepath.Join(workDir, filename)); err != nil {
+			t.Errorf("expected checkout to write %s: %s", filename, err)
+		}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+
+	output := run.MustExec(t, "qri status")
+	if !strings.Contains(output, "working directory clean") {
+		t.Errorf("expected a clean working directory after checkout. got:\n%s", output)
+	}
+
+	run.MustWriteFile(t, filepath.Join(workDir, "meta.json"), `{"title":"city populations"}`)
+	run.MustWriteFile(t, filepath.Join(workDir, "body.csv"), "city,pop\ntoronto,40000000\n")
+	output = run.MustExec(t, "qri status")
+	for _, expect := range []string{"add: meta", "modified: body", "unmodified: structure"} {
+		if !strings.Contains(output, expect) {
+			t.Errorf("expected status to contain %q. got:\n%s", expect, output)
+		}
+	}
+
+	run.MustExec(t, "qri restore body")
+	output = run.MustExec(t, "qri status")
+	if !strings.Contains(output, "unmodified: body") {
+		t.Errorf("expected restored body to be unmodified. got:\n%s", output)
+	}
+
+	run.MustExec(t, "qri save")
+	ds := run.MustLoadDataset(t, "me/cities")
+	if ds.Meta == nil || ds.Meta.Title != "city populations" {
+		t.Errorf("expected saved meta title from meta.json. got: %v", ds.Meta)
+	}
+	output = run.MustExec(t, "qri status")
+	if !strings.Contains(output, "working directory clean") {
+		t.Errorf("expected a clean working directory after saving. got:\n%s", output)
+	}
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.ExecCommand("qri status"); err == nil || !strings.Contains(err.Error(), "not linked to a dataset") {
+		t.Errorf("expected status outside a linked directory to fail. got: %v", err)
+	}
+}
@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/base/component"
+	"github.com/qri-io/qri/changes"
 	"github.com/qri-io/qri/lib"
 	"github.com/spf13/cobra"
 )
@@ -48,7 +56,14 @@ Each change has a path that locates it within the document`,
   $ qri diff a.json b.json
 
   # Diff a json & csv file:
-  $ qri diff some_table.csv b.json`,
+  $ qri diff some_table.csv b.json
+
+  # Compare column statistics of a dataset against its last version:
+  $ qri diff --stats me/annual_pop
+
+  # Compare rows of two large tabular versions, matching rows on the "id"
+  # column instead of the schema's primary key:
+  $ qri diff --rows --key id me/annual_pop`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -62,6 +77,11 @@ Each change has a path that locates it within the document`,
 
 	cmd.Flags().StringVarP(&o.Format, "format", "f", "pretty", "output format. one of [json,pretty]")
 	cmd.Flags().BoolVar(&o.Summary, "summary", false, "just output the summary")
+	cmd.Flags().BoolVar(&o.Stats, "stats", false, "compare column statistics instead of dataset contents")
+	cmd.Flags().BoolVar(&o.Rows, "rows", false, "stream a row-by-row body diff, matching rows on the schema primary key")
+	cmd.Flags().StringSliceVar(&o.KeyColumns, "key", nil, "columns to match rows on when diffing --rows")
+	cmd.Flags().IntVar(&o.Offset, "offset", 0, "for --rows, skip this number of changed rows, default 0")
+	cmd.Flags().IntVar(&o.Limit, "limit", 25, "for --rows, number of changed rows to show, default 25")
 
 	return cmd
 }
@@ -74,6 +94,12 @@ type DiffOptions struct {
 	Selector string
 	Format   string
 	Summary  bool
+	Stats    bool
+
+	Rows       bool
+	KeyColumns []string
+	Offset     int
+	Limit      int
 
 	inst *lib.Instance
 }
@@ -106,8 +132,13 @@ func (o *DiffOptions) Complete(f Factory, args []string) (err error) {
 
 // Run executes the diff command
 func (o *DiffOptions) Run() (err error) {
+	if o.Rows || len(o.KeyColumns) > 0 {
+		return o.runRows()
+	}
+
 	p := &lib.DiffParams{
 		Selector: o.Selector,
+		Stats:    o.Stats,
 	}
 
 	if len(o.Refs.RefList()) == 1 {
@@ -137,5 +168,210 @@ func (o *DiffOptions) Run() (err error) {
 		return
 	}
 
+	if o.Stats {
+		return printStatsDiff(o.Out, res.Stats, o.Summary)
+	}
 	return printDiff(o.Out, res, o.Summary)
 }
+
+func (o *DiffOptions) runRows() error {
+	if o.Selector != "" && o.Selector != "body" {
+		return fmt.Errorf("row diffs can only compare bodies")
+	}
+	p := &lib.RowDiffParams{
+		KeyColumns: o.KeyColumns,
+	}
+	p.Offset = o.Offset
+	p.Limit = o.Limit
+
+	refs := o.Refs.RefList()
+	if len(refs) == 1 {
+		p.LeftSide = o.Refs.Ref()
+		p.UseLeftPrevVersion = true
+	} else if len(refs) == 2 {
+		p.LeftSide = refs[0]
+		p.RightSide = refs[1]
+	}
+
+	ctx := context.TODO()
+	res, _, err := o.inst.Diff().Rows(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	if o.Format == "json" {
+		return json.NewEncoder(o.Out).Encode(res)
+	}
+	return printRowDiff(o.Out, res)
+}
+
+// printRowDiff writes one line per changed row, prefixed with "+" for added,
+// "-" for removed & "~" for modified rows
+func printRowDiff(w io.Writer, deltas []*lib.RowDelta) error {
+	buf := &bytes.Buffer{}
+	if len(deltas) == 0 {
+		fmt.Fprintln(buf, "no changed rows")
+	}
+	for _, d := range deltas {
+		key := rowDiffJSON(d.Key)
+		switch d.Type {
+		case base.RowAdded:
+			fmt.Fprintln(buf, color.New(color.FgGreen).Sprintf("+ %s %s", key, rowDiffJSON(d.Right)))
+		case base.RowRemoved:
+			fmt.Fprintln(buf, color.New(color.FgRed).Sprintf("- %s %s", key, rowDiffJSON(d.Left)))
+		case base.RowModified:
+			fmt.Fprintln(buf, color.New(color.FgYellow).Sprintf("~ %s %s → %s", key, rowDiffJSON(d.Left), rowDiffJSON(d.Right)))
+		}
+	}
+	printToPager(w, buf)
+	return nil
+}
+
+func rowDiffJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// statsDiffFields orders the column statistics printed by printStatsDiff
+var statsDiffFields = []string{"count", "min", "max", "mean", "median", "minLength", "maxLength", "unique", "trueCount", "falseCount"}
+
+// printStatsDiff writes a human-readable description of the change in column
+// statistics between two dataset versions
+func printStatsDiff(w io.Writer, res *lib.StatsChange, summaryOnly bool) error {
+	if res == nil || res.Summary == nil {
+		return fmt.Errorf("no stats to compare")
+	}
+	buf := &bytes.Buffer{}
+
+	left, right := statsDiffObject(res.Summary.Left), statsDiffObject(res.Summary.Right)
+	for _, field := range []string{"entries", "columns", "totalSize"} {
+		fmt.Fprintf(buf, "%-10s %s\n", field+":", formatStatChange(left[field], right[field]))
+	}
+
+	for _, col := range res.Columns {
+		status, _ := col.About["status"].(string)
+		fmt.Fprintf(buf, "\n%s: %s\n", color.New(color.Bold).Sprint(col.Title), status)
+		if summaryOnly {
+			continue
+		}
+
+		left, right := statsDiffObject(col.Left), statsDiffObject(col.Right)
+		delta := statsDiffObject(col.Delta)
+		for _, field := range statsDiffFields {
+			if _, ok := delta[field]; !ok {
+				continue
+			}
+			fmt.Fprintf(buf, "  %-10s %s\n", field, formatStatChange(left[field], right[field]))
+		}
+
+		if col.Distribution != nil {
+			printDistributionDelta(buf, col.Distribution)
+		}
+	}
+
+	printToPager(w, buf)
+	return nil
+}
+
+func printDistributionDelta(w io.Writer, d *changes.DistributionDelta) {
+	if h := d.Histogram; h != nil {
+		if h.Delta != nil {
+			fmt.Fprintln(w, "  histogram:")
+			for i, diff := range h.Delta {
+				fmt.Fprintf(w, "    %-24s %s\n", histogramBinLabel(h.Right.Bins, i), formatDelta(h.Left.Frequencies[i], h.Right.Frequencies[i], diff))
+			}
+			return
+		}
+		printHistogram(w, "histogram before:", h.Left)
+		printHistogram(w, "histogram after:", h.Right)
+		return
+	}
+
+	if d.AddedCategories > 0 || d.RemovedCategories > 0 {
+		fmt.Fprintf(w, "  %d new categories, %d removed categories\n", d.AddedCategories, d.RemovedCategories)
+	}
+	for _, c := range d.Categories {
+		fmt.Fprintf(w, "    %-24s %s\n", c.Value, formatDelta(c.Left, c.Right, c.Delta))
+	}
+}
+
+// histogramBarWidth is the number of characters used to draw the largest bar
+// of a histogram. other bars are scaled relative to it
+const histogramBarWidth = 40
+
+func printHistogram(w io.Writer, title string, h *changes.Histogram) {
+	if h == nil {
+		return
+	}
+	fmt.Fprintf(w, "  %s\n", title)
+	max := 0.0
+	for _, freq := range h.Frequencies {
+		if freq > max {
+			max = freq
+		}
+	}
+	for i, freq := range h.Frequencies {
+		fmt.Fprintf(w, "    %-24s %s %s\n", histogramBinLabel(h.Bins, i), strings.Repeat("▇", histogramBarLength(freq, max)), formatStatValue(freq))
+	}
+}
+
+// histogramBarLength scales a frequency to a bar no wider than
+// histogramBarWidth. non-zero frequencies always get at least one character
+func histogramBarLength(freq, max float64) int {
+	if freq <= 0 || max <= 0 {
+		return 0
+	}
+	n := int(freq / max * histogramBarWidth)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func histogramBinLabel(bins []float64, i int) string {
+	if i+1 >= len(bins) {
+		return fmt.Sprintf("[%s, ∞)", formatStatValue(bins[i]))
+	}
+	return fmt.Sprintf("[%s, %s)", formatStatValue(bins[i]), formatStatValue(bins[i+1]))
+}
+
+// statsDiffObject normalizes a stats value to a map. values are round-tripped
+// through JSON because responses may have crossed an RPC boundary
+func statsDiffObject(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	if data, err := json.Marshal(v); err == nil {
+		json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+func formatStatChange(left, right interface{}) string {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	switch {
+	case lok && rok:
+		return formatDelta(l, r, r-l)
+	case rok:
+		return fmt.Sprintf("(none) → %s", formatStatValue(r))
+	case lok:
+		return fmt.Sprintf("%s → (none)", formatStatValue(l))
+	}
+	return "(none)"
+}
+
+func formatDelta(left, right, delta float64) string {
+	s := fmt.Sprintf("%s → %s", formatStatValue(left), formatStatValue(right))
+	if delta > 0 {
+		s += color.New(color.FgGreen).Sprintf(" (+%s)", formatStatValue(delta))
+	} else if delta < 0 {
+		s += color.New(color.FgRed).Sprintf(" (%s)", formatStatValue(delta))
+	}
+	return s
+}
+
+func formatStatValue(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10000)/10000, 'f', -1, 64)
+}
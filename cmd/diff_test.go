@@ -256,3 +256,21 @@ func TestDiffKnownFilenameComponent(t *testing.T) {
 		t.Errorf("output mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestHistogramBarLength(t *testing.T) {
+	cases := []struct {
+		freq, max float64
+		expect    int
+	}{
+		{0, 10, 0},
+		{10, 10, histogramBarWidth},
+		{5, 10, histogramBarWidth / 2},
+		{1, 100000, 1},
+		{250000, 250000, histogramBarWidth},
+	}
+	for _, c := range cases {
+		if got := histogramBarLength(c.freq, c.max); got != c.expect {
+			t.Errorf("histogramBarLength(%v, %v): expected %d, got %d", c.freq, c.max, c.expect, got)
+		}
+	}
+}
@@ -35,7 +35,10 @@ dataset and its fields.`,
   $ qri get meta me/annual_pop
 
   # Print the dataset body size to the console:
-  $ qri get structure.length me/annual_pop`,
+  $ qri get structure.length me/annual_pop
+
+  # Write the dataset body to a parquet file:
+  $ qri get body --format parquet -o annual_pop.parquet me/annual_pop`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -48,7 +51,7 @@ dataset and its fields.`,
 		},
 	}
 
-	cmd.Flags().StringVarP(&o.Format, "format", "f", "", "set output format [json, yaml, csv, zip]. If format is set to 'zip' it will save the entire dataset as a zip archive.")
+	cmd.Flags().StringVarP(&o.Format, "format", "f", "", "set output format [json, yaml, csv, parquet, zip]. If format is set to 'zip' it will save the entire dataset as a zip archive.")
 	cmd.Flags().BoolVar(&o.Pretty, "pretty", false, "whether to print output with indentation, only for json format")
 	cmd.Flags().IntVar(&o.Limit, "limit", -1, "for body, limit how many entries to get per request")
 	cmd.Flags().IntVar(&o.Offset, "offset", -1, "for body, offset amount at which to get entries")
@@ -106,8 +109,8 @@ func (o *GetOptions) Complete(f Factory, args []string) (err error) {
 			o.All = false
 		}
 	} else {
-		if o.Format == "csv" {
-			return fmt.Errorf("can only use --format=csv when getting body")
+		if o.Format == "csv" || o.Format == "parquet" {
+			return fmt.Errorf("can only use --format=%s when getting body", o.Format)
 		}
 		if o.Limit != -1 {
 			return fmt.Errorf("can only use --limit flag when getting body")
@@ -163,6 +166,14 @@ func (o *GetOptions) Run() (err error) {
 		if err != nil {
 			return err
 		}
+	case o.Format == "parquet":
+		if o.Outfile == "" {
+			return fmt.Errorf("parquet is a binary format, use --outfile to write parquet to a file")
+		}
+		outBytes, err = o.inst.Dataset().GetParquet(ctx, p)
+		if err != nil {
+			return err
+		}
 	default:
 		res, err := o.inst.WithSource(o.Remote).Dataset().Get(ctx, p)
 		if err != nil {
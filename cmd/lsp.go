@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/transform/lsp"
+	"github.com/spf13/cobra"
+)
+
+// NewLSPCommand creates a new `qri lsp` cobra command that runs a language
+// server for transform scripts
+func NewLSPCommand(_ Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &LSPOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "run a language server for transform scripts",
+		Long: `
+Lsp runs a Language Server Protocol server for starlark transform scripts,
+communicating over stdin & stdout. Editors that support LSP can use it to
+show analysis warnings as you type, like secrets that may leak & unused
+functions, and to complete, document & jump to the definition of functions.
+
+Configure your editor to run "qri lsp" for files ending in .star. In VS Code,
+use an extension that runs a generic language server for a file type.`,
+		Annotations: map[string]string{
+			"group": "other",
+		},
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// LSPOptions encapsulates state for the lsp command
+type LSPOptions struct {
+	ioes.IOStreams
+}
+
+// Run executes the lsp command
+func (o *LSPOptions) Run() error {
+	return lsp.NewServer(o.In, o.Out).Serve(context.Background())
+}
@@ -19,6 +19,11 @@ func NewPullCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
 		Short:   "fetch & store datasets from other peers",
 		Long: `Pull downloads datasets and stores them locally, fetching the dataset log and
 dataset version(s). By default pull fetches the latest version of a dataset.
+
+Pulled versions are checked against the public key of the dataset's author.
+Pull fails & discards the pulled version if the commit signature or dataset
+log don't match, or if the author's public key is unknown. Use --insecure to
+skip verification.
 `,
 		Example: `  # download a dataset log and latest version
   $ qri pull b5/world_bank_population
@@ -40,6 +45,7 @@ dataset version(s). By default pull fetches the latest version of a dataset.
 	cmd.Flags().StringVar(&o.Source, "source", "", "location to pull from")
 	cmd.MarkFlagFilename("link")
 	cmd.Flags().BoolVar(&o.LogsOnly, "logs-only", false, "only fetch logs, skipping HEAD data")
+	cmd.Flags().BoolVar(&o.Insecure, "insecure", false, "skip verifying pulled versions against the author's public key")
 
 	return cmd
 }
@@ -50,6 +56,7 @@ type PullOptions struct {
 	LinkDir  string
 	Source   string
 	LogsOnly bool
+	Insecure bool
 
 	inst *lib.Instance
 }
@@ -76,6 +83,7 @@ func (o *PullOptions) Run(args []string) error {
 		p := &lib.PullParams{
 			Ref:      arg,
 			LogsOnly: o.LogsOnly,
+			Insecure: o.Insecure,
 		}
 
 		res, err := o.inst.WithSource(o.Source).Dataset().Pull(ctx, p)
@@ -53,6 +53,7 @@ https://github.com/qri-io/qri/issues`,
 		NewAnalyzeTransformCommand(opt, ioStreams),
 		NewApplyCommand(opt, ioStreams),
 		NewAutocompleteCommand(opt, ioStreams),
+		NewCheckoutCommand(opt, ioStreams),
 		NewConfigCommand(opt, ioStreams),
 		NewConnectCommand(opt, ioStreams),
 		NewDAGCommand(opt, ioStreams),
@@ -61,6 +62,7 @@ https://github.com/qri-io/qri/issues`,
 		NewListCommand(opt, ioStreams),
 		NewLogCommand(opt, ioStreams),
 		NewLogbookCommand(opt, ioStreams),
+		NewLSPCommand(opt, ioStreams),
 		NewPushCommand(opt, ioStreams),
 		NewPullCommand(opt, ioStreams),
 		NewPeersCommand(opt, ioStreams),
@@ -69,10 +71,14 @@ https://github.com/qri-io/qri/issues`,
 		NewRemoveCommand(opt, ioStreams),
 		NewRenameCommand(opt, ioStreams),
 		NewRenderCommand(opt, ioStreams),
+		NewRestoreCommand(opt, ioStreams),
 		NewSaveCommand(opt, ioStreams),
 		NewSearchCommand(opt, ioStreams),
+		NewSecretsCommand(opt, ioStreams),
 		NewSetupCommand(opt, ioStreams),
+		NewStatusCommand(opt, ioStreams),
 		NewValidateCommand(opt, ioStreams),
+		NewVerifyCommand(opt, ioStreams),
 		NewVersionCommand(opt, ioStreams),
 		NewWhatChangedCommand(opt, ioStreams),
 	)
@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/repo"
 )
 
 // RefSelect represents zero or more references
 type RefSelect struct {
 	refs []string
+	// dir is the linked working directory the reference was read from
+	dir string
 }
 
 // NewEmptyRefSelect returns an empty reference selection
@@ -27,6 +31,26 @@ func NewListOfRefSelects(refs []string) *RefSelect {
 	return &RefSelect{refs: refs}
 }
 
+// NewLinkedDirectoryRefSelect returns the reference a working directory is
+// linked to
+func NewLinkedDirectoryRefSelect(ref dsref.Ref, dir string) *RefSelect {
+	return &RefSelect{refs: []string{ref.Human()}, dir: dir}
+}
+
+// IsLinked returns whether the reference was read from a linked working
+// directory
+func (r *RefSelect) IsLinked() bool {
+	return r != nil && r.dir != ""
+}
+
+// Dir returns the linked working directory, if there is one
+func (r *RefSelect) Dir() string {
+	if r == nil {
+		return ""
+	}
+	return r.dir
+}
+
 // Ref returns the reference as a string
 func (r *RefSelect) Ref() string {
 	if r == nil || len(r.refs) == 0 {
@@ -56,8 +80,6 @@ const (
 // as command-line arguments, or could be determined by being in a linked directory, or could be
 // selected by the `use` command. This order is also the precedence, from most important to least.
 // This is the recommended method for command-line commands to get references.
-// If an Ensurer is passed in, it is used to ensure that the ref in the .qri-ref linkfile matches
-// what is in the repo.
 func GetCurrentRefSelect(f Factory, args []string, allowed int) (*RefSelect, error) {
 	// If reference is specified by the user provide command-line arguments, use that reference.
 	if len(args) > 0 {
@@ -91,6 +113,13 @@ func GetCurrentRefSelect(f Factory, args []string, allowed int) (*RefSelect, err
 		return NewListOfRefSelects(args), nil
 	}
 
+	// If the current directory is a linked working directory, use the reference it's linked to.
+	if dir, err := os.Getwd(); err == nil {
+		if ref, err := lib.ReadLink(dir); err == nil {
+			return NewLinkedDirectoryRefSelect(ref, dir), nil
+		}
+	}
+
 	// Empty refselect
 	return NewEmptyRefSelect(), repo.ErrEmptyRef
 }
@@ -7,6 +7,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/qri-io/qri/base/linkfile"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/repo"
 )
 
 func TestBasicRefSelect(t *testing.T) {
@@ -77,6 +81,26 @@ func TestGetCurrentRefSelect(t *testing.T) {
 	if refs.Ref() != "me/explicit_ds" {
 		t.Errorf("error: ref_select, actual: %s, expect: %s", refs.Ref(), "me/explicit_ds")
 	}
+
+	// No argument in an unlinked directory
+	if _, err = GetCurrentRefSelect(f, nil, 1); err != repo.ErrEmptyRef {
+		t.Errorf("error: expected repo.ErrEmptyRef, got: %v", err)
+	}
+
+	// No argument in a linked directory
+	if _, err := linkfile.WriteHiddenInDir(workPath, dsref.Ref{Username: "peer", Name: "linked_ds", Path: "/mem/QmTestPath"}); err != nil {
+		t.Fatal(err)
+	}
+	refs, err = GetCurrentRefSelect(f, nil, 1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !refs.IsLinked() {
+		t.Errorf("error: expected ref_select to be linked")
+	}
+	if refs.Ref() != "peer/linked_ds" {
+		t.Errorf("error: ref_select, actual: %s, expect: %s", refs.Ref(), "peer/linked_ds")
+	}
 }
 
 func TestGetCurrentRefSelectUsingTwoArgs(t *testing.T) {
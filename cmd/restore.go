@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/lib"
+	"github.com/spf13/cobra"
+)
+
+// NewRestoreCommand creates a new `qri restore` cobra command for discarding
+// changes to a component in a linked working directory
+func NewRestoreCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &RestoreOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "restore COMPONENT",
+		Short: "discard changes to a component in a working directory",
+		Annotations: map[string]string{
+			"group": "dataset",
+		},
+		Long: `Restore rewrites the file of a component in a linked working directory from
+the version the directory was checked out from, discarding any changes. If that
+version doesn't have the component, its file is removed. Components are meta,
+structure, readme, transform & body.`,
+		Example: `  # discard edits to meta.json:
+  $ qri restore meta`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// RestoreOptions encapsulates state for the restore command
+type RestoreOptions struct {
+	ioes.IOStreams
+
+	Dir       string
+	Component string
+
+	inst *lib.Instance
+}
+
+// Complete adds any missing configuration that can only be added just before calling Run
+func (o *RestoreOptions) Complete(f Factory, args []string) (err error) {
+	if o.inst, err = f.Instance(); err != nil {
+		return err
+	}
+	o.Component = args[0]
+	o.Dir, err = linkedDirectory()
+	return err
+}
+
+// Run executes the restore command
+func (o *RestoreOptions) Run() error {
+	ctx := context.TODO()
+	link, err := lib.ReadLink(o.Dir)
+	if err != nil {
+		return err
+	}
+	p := &lib.RestoreParams{Ref: link.Alias(), Dir: o.Dir, Component: o.Component}
+	if err := o.inst.FSI().Restore(ctx, p); err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "restored %s", o.Component)
+	return nil
+}
@@ -43,8 +43,14 @@ different peer, the dataset gets renamed from ` + "`peers_name/dataset_name`" +
 		Example: `  # Save updated data to dataset annual_pop:
   $ qri save --body /path/to/data.csv me/annual_pop
 
+  # Save a parquet file, parquet bodies are stored as csv:
+  $ qri save --body /path/to/data.parquet me/annual_pop
+
   # Save updated dataset (no data) to annual_pop:
   $ qri save --file /path/to/dataset.yaml me/annual_pop
+
+  # Save the edited files of a working directory created by qri checkout:
+  $ cd annual_pop && qri save
   
   # Re-execute the latest transform from history:
   $ qri save --apply me/tf_dataset`,
@@ -80,6 +86,7 @@ different peer, the dataset gets renamed from ` + "`peers_name/dataset_name`" +
 	cmd.Flags().BoolVar(&o.NoRender, "no-render", false, "don't store a rendered version of the the visualization")
 	cmd.Flags().BoolVarP(&o.NewName, "new", "n", false, "save a new dataset only, using an available name")
 	cmd.Flags().StringVar(&o.Drop, "drop", "", "comma-separated list of components to remove")
+	cmd.Flags().BoolVar(&o.EnforceConstraints, "enforce-constraints", false, "refuse to save if the body violates table constraints in the schema")
 
 	return cmd
 }
@@ -109,6 +116,8 @@ type SaveOptions struct {
 	NewName        bool
 	UseDscache     bool
 
+	EnforceConstraints bool
+
 	inst *lib.Instance
 }
 
@@ -156,6 +165,14 @@ func (o *SaveOptions) Run() (err error) {
 
 		ShouldRender: !o.NoRender,
 		NewName:      o.NewName,
+
+		EnforceConstraints: o.EnforceConstraints,
+	}
+
+	// Saving in a linked working directory without body or component files
+	// commits the working directory files
+	if o.Refs.IsLinked() && o.BodyPath == "" && len(o.FilePaths) == 0 {
+		p.WorkingDir = o.Refs.Dir()
 	}
 
 	// Check if file ends in '.star'. If so, either Apply or NoApply is required.
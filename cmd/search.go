@@ -22,9 +22,16 @@ func NewSearchCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
 		Short: "search the registry for datasets",
 		Long: `Search datasets & peers that match your query. Search pings the qri registry. 
 
-Any dataset that has been pushed to the registry is available for search.`,
+Any dataset that has been pushed to the registry is available for search.
+
+Use --local to search datasets in your own repo instead, which works offline.
+Local search matches dataset names, meta titles, descriptions & keywords, 
+column names, readmes and commit messages.`,
 		Example: `  # Search for datasets featuring "annual population":
-  $ qri search "annual population"`,
+  $ qri search "annual population"
+
+  # Search your own datasets for ones with a "country" column:
+  $ qri search --local columns:country`,
 		Annotations: map[string]string{
 			"group": "network",
 		},
@@ -43,6 +50,7 @@ Any dataset that has been pushed to the registry is available for search.`,
 	cmd.Flags().StringVarP(&o.Format, "format", "f", "", "set output format [json|simple]")
 	cmd.Flags().IntVar(&o.Offset, "offset", 0, "number of records to skip from results, default 0")
 	cmd.Flags().IntVar(&o.Limit, "limit", 25, "size of results, default 25")
+	cmd.Flags().BoolVar(&o.Local, "local", false, "search datasets in your repo instead of the registry")
 
 	return cmd
 }
@@ -55,6 +63,7 @@ type SearchOptions struct {
 	Format string
 	Offset int
 	Limit  int
+	Local  bool
 	// Reindex bool
 
 	Instance *lib.Instance
@@ -96,6 +105,9 @@ func (o *SearchOptions) Run() (err error) {
 			Limit:  o.Limit,
 		},
 	}
+	if o.Local {
+		p.Source = "local"
+	}
 
 	results, err := inst.Search().Search(ctx, p)
 	if err != nil {
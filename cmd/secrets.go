@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/lib"
+	"github.com/spf13/cobra"
+)
+
+// NewSecretsCommand creates a new `qri secrets` cobra command for managing
+// the secrets workflows pass to transforms
+func NewSecretsCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &SecretsOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "manage secrets for dataset transforms",
+		Long: `
+Secrets are values like API keys that transform scripts need but shouldn't be
+written into the script itself. Secrets are stored encrypted within your repo,
+scoped to the workflow of a single dataset, and passed to the transform each
+time the workflow runs. Scripts read them with the 'secrets' global:
+
+  key = secrets.get("API_KEY")
+
+Secret values are never shown once set, and are redacted from stored run
+output.`[1:],
+		Example: `
+  # set a secret, reading the value from stdin:
+  $ qri secrets set me/dataset API_KEY
+
+  # list the names of a dataset's secrets:
+  $ qri secrets list me/dataset
+
+  # remove a secret:
+  $ qri secrets rm me/dataset API_KEY`[1:],
+		Annotations: map[string]string{
+			"group": "automation",
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set DATASET NAME [VALUE]",
+		Short: "store a secret for a dataset's workflow",
+		Long: `
+set stores a secret for the workflow of a dataset, replacing any existing value.
+When VALUE isn't given it's read from stdin, which keeps the secret out of your
+shell history.`[1:],
+		Args: cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Set(context.TODO())
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:     "list DATASET",
+		Aliases: []string{"ls"},
+		Short:   "list the names of a dataset's secrets",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.List(context.TODO())
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:     "rm DATASET NAME",
+		Aliases: []string{"remove"},
+		Short:   "remove a secret",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Remove(context.TODO())
+		},
+	}
+
+	cmd.AddCommand(setCmd, listCmd, rmCmd)
+	return cmd
+}
+
+// SecretsOptions encapsulates state for the secrets command
+type SecretsOptions struct {
+	ioes.IOStreams
+	Instance *lib.Instance
+
+	Ref   string
+	Name  string
+	Value string
+}
+
+// Complete adds any missing configuration that can only be added just before
+// calling Run
+func (o *SecretsOptions) Complete(f Factory, args []string) (err error) {
+	if o.Instance, err = f.Instance(); err != nil {
+		return err
+	}
+	o.Ref = args[0]
+	if len(args) > 1 {
+		o.Name = args[1]
+	}
+	if len(args) > 2 {
+		o.Value = args[2]
+	}
+	return nil
+}
+
+// Set stores a secret
+func (o *SecretsOptions) Set(ctx context.Context) error {
+	if o.Value == "" {
+		printInfoNoEndline(o.ErrOut, "value for %s: ", o.Name)
+		line, err := bufio.NewReader(o.In).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("reading secret value: %w", err)
+		}
+		o.Value = strings.TrimRight(line, "\r\n")
+	}
+	p := &lib.SecretParams{Ref: o.Ref, Name: o.Name, Value: o.Value}
+	if err := o.Instance.Automation().SetSecret(ctx, p); err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "set secret %s for %s", o.Name, o.Ref)
+	return nil
+}
+
+// List prints the names of a dataset's secrets
+func (o *SecretsOptions) List(ctx context.Context) error {
+	names, err := o.Instance.Automation().Secrets(ctx, &lib.SecretParams{Ref: o.Ref})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		printInfo(o.ErrOut, "%s has no secrets", o.Ref)
+		return nil
+	}
+	return printlnStringItems(o.Out, names)
+}
+
+// Remove deletes a secret
+func (o *SecretsOptions) Remove(ctx context.Context) error {
+	p := &lib.SecretParams{Ref: o.Ref, Name: o.Name}
+	if err := o.Instance.Automation().RemoveSecret(ctx, p); err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "removed secret %s from %s", o.Name, o.Ref)
+	return nil
+}
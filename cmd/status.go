@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/lib"
+	"github.com/spf13/cobra"
+)
+
+// NewStatusCommand creates a new `qri status` cobra command for showing the
+// changes in a linked working directory
+func NewStatusCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &StatusOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show changes in a working directory",
+		Annotations: map[string]string{
+			"group": "dataset",
+		},
+		Long: `Status compares the component files in a linked working directory with the
+version they were checked out from, listing which components were modified,
+added or removed. Run it inside a directory created by ` + "`qri checkout`" + `.`,
+		Example: `  # show what changed in the current working directory:
+  $ qri status`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+// StatusOptions encapsulates state for the status command
+type StatusOptions struct {
+	ioes.IOStreams
+
+	Dir string
+
+	inst *lib.Instance
+}
+
+// Complete adds any missing configuration that can only be added just before calling Run
+func (o *StatusOptions) Complete(f Factory, args []string) (err error) {
+	if o.inst, err = f.Instance(); err != nil {
+		return err
+	}
+	o.Dir, err = linkedDirectory()
+	return err
+}
+
+// Run executes the status command
+func (o *StatusOptions) Run() error {
+	ctx := context.TODO()
+	link, err := lib.ReadLink(o.Dir)
+	if err != nil {
+		return err
+	}
+	items, err := o.inst.Dataset().WhatChanged(ctx, &lib.WhatChangedParams{WorkingDir: o.Dir})
+	if err != nil {
+		return err
+	}
+
+	printInfo(o.Out, "for linked dataset [%s]\n", link.Human())
+	clean := true
+	for _, si := range items {
+		switch si.Type {
+		case base.STUnmodified:
+			printInfo(o.Out, "  unmodified: %s", si.Component)
+		case base.STChange:
+			printSuccess(o.Out, "  modified: %s", si.Component)
+		case base.STAdd:
+			printSuccess(o.Out, "  add: %s", si.Component)
+		case base.STRemoved:
+			printSuccess(o.Out, "  removed: %s", si.Component)
+		default:
+			printWarning(o.Out, "  %s: %s", si.Type, si.Component)
+		}
+		if si.Type != base.STUnmodified {
+			clean = false
+		}
+	}
+	if clean {
+		printInfo(o.Out, "\nworking directory clean")
+	} else {
+		printInfo(o.Out, "\nrun `qri save` to commit these changes, or `qri restore COMPONENT` to discard them")
+	}
+	return nil
+}
@@ -54,6 +54,12 @@ schema for dataset "me/foo"
 Using validate this way is a great way to see how changes to data or schema
 will affect a dataset before saving changes to a dataset.
 
+Tabular schemas can also declare table constraints that compare rows against
+each other and against other datasets with the "primaryKey", "unique",
+"notNull" and "foreignKeys" keywords. Validate reports constraint violations
+alongside schema errors. Use ` + "`qri save --enforce-constraints`" + ` to refuse
+saves that violate them.
+
 You can get the current schema of a dataset by running the ` + "`qri get structure.schema`" + `
 command.
 
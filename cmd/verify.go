@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/lib"
+	"github.com/spf13/cobra"
+)
+
+// NewVerifyCommand creates a new `qri verify` cobra command for checking a
+// dataset's history against the public key of its author
+func NewVerifyCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &VerifyOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "verify [DATASET]",
+		Short: "check dataset history was signed by its author",
+		Annotations: map[string]string{
+			"group": "dataset",
+		},
+		Long: `Verify checks every version of a dataset was written by the dataset's author.
+Each commit is signed with the author's private key when it's saved. Verify
+checks the signature of every version stored in your repo against the author's
+public key, and checks the dataset log is rooted in the author's profile.
+
+Versions that aren't stored locally are listed as foreign, and can't be checked.
+Pull checks the versions it fetches the same way. Use ` + "`qri pull --insecure`" + ` to
+skip verification.`,
+		Example: `  # verify the history of a dataset:
+  $ qri verify b5/world_bank_population`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Format, "format", "text", "output format. One of: [text|json]")
+
+	return cmd
+}
+
+// VerifyOptions encapsulates state for the verify command
+type VerifyOptions struct {
+	ioes.IOStreams
+
+	Refs   *RefSelect
+	Format string
+
+	inst *lib.Instance
+}
+
+// Complete adds any configuration that can only be added just before calling Run
+func (o *VerifyOptions) Complete(f Factory, args []string) (err error) {
+	if o.inst, err = f.Instance(); err != nil {
+		return
+	}
+	if o.Format != "text" && o.Format != "json" {
+		return fmt.Errorf(`%q is not a valid output format. Please use one of: "text", "json"`, o.Format)
+	}
+	o.Refs, err = GetCurrentRefSelect(f, args, 1)
+	return
+}
+
+// Run executes the verify command
+func (o *VerifyOptions) Run() error {
+	o.StartSpinner()
+	defer o.StopSpinner()
+
+	ctx := context.TODO()
+	res, err := o.inst.Dataset().Verify(ctx, &lib.VerifyParams{Ref: o.Refs.Ref()})
+	if err != nil {
+		return err
+	}
+	o.StopSpinner()
+
+	if o.Format == "json" {
+		if err := json.NewEncoder(o.Out).Encode(res); err != nil {
+			return err
+		}
+		return res.Err()
+	}
+
+	printVerification(o.Out, res)
+	if err := res.Err(); err != nil {
+		return err
+	}
+	printSuccess(o.ErrOut, "✔ %s verified with key %s", res.Ref.Human(), res.KeyID)
+	return nil
+}
+
+func printVerification(w io.Writer, v *base.Verification) {
+	if v.LogError != "" {
+		printWarning(w, "log: %s", v.LogError)
+	}
+	for _, ver := range v.Versions {
+		switch {
+		case ver.Foreign:
+			printInfo(w, "- %s (foreign, not checked)", ver.Path)
+		case ver.Error != "":
+			printWarning(w, "✖ %s: %s", ver.Path, ver.Error)
+		default:
+			printSuccess(w, "✔ %s", ver.Path)
+		}
+	}
+}
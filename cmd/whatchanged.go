@@ -55,6 +55,10 @@ func (o *WhatChangedOptions) Run() (err error) {
 	inst := o.Instance
 
 	params := lib.WhatChangedParams{Ref: o.Refs.Ref()}
+	if o.Refs.IsLinked() {
+		// in a linked working directory, show changes to the working files
+		params.WorkingDir = o.Refs.Dir()
+	}
 	res, err := inst.Dataset().WhatChanged(ctx, &params)
 	if err != nil {
 		printErr(o.ErrOut, err)
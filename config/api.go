@@ -26,6 +26,9 @@ type API struct {
 	ServeRemoteTraffic bool `json:"serveremotetraffic"`
 	// should the api provide the /webui endpoint? default is true
 	Webui bool `json:"webui"`
+	// path to a JSON access control policy file for API calls. relative paths
+	// are resolved against the repo path. When empty API calls are unrestricted
+	AccessControlPolicy string `json:"accesscontrolpolicy,omitempty"`
 }
 
 // SetArbitrary is an interface implementation of base/fill/struct in order to
@@ -60,6 +63,10 @@ func (a API) Validate() error {
         "description": "whether to allow requests from addresses other than localhost",
         "type": "boolean"
       },
+      "accesscontrolpolicy": {
+        "description": "path to a JSON access control policy file for API calls",
+        "type": "string"
+      },
       "allowedorigins": {
         "description": "Support CORS signing from a list of origins",
         "type": "array",
@@ -87,10 +94,11 @@ func DefaultAPI() *API {
 // Copy returns a deep copy of an API struct
 func (a *API) Copy() *API {
 	res := &API{
-		Enabled:            a.Enabled,
-		Address:            a.Address,
-		ServeRemoteTraffic: a.ServeRemoteTraffic,
-		Webui:              a.Webui,
+		Enabled:             a.Enabled,
+		Address:             a.Address,
+		ServeRemoteTraffic:  a.ServeRemoteTraffic,
+		Webui:               a.Webui,
+		AccessControlPolicy: a.AccessControlPolicy,
 	}
 	if a.AllowedOrigins != nil {
 		res.AllowedOrigins = make([]string, len(a.AllowedOrigins))
@@ -21,6 +21,7 @@ func TestAPICopy(t *testing.T) {
 	a.Webui = !a.Webui
 	a.ServeRemoteTraffic = !a.ServeRemoteTraffic
 	a.AllowedOrigins = []string{"bar"}
+	a.AccessControlPolicy = "policy.json"
 
 	if a.Enabled == b.Enabled {
 		t.Errorf("Enabled fields should not match")
@@ -34,6 +35,9 @@ func TestAPICopy(t *testing.T) {
 	if a.ServeRemoteTraffic == b.ServeRemoteTraffic {
 		t.Errorf("ServeRemoteTraffic fields should not match")
 	}
+	if a.AccessControlPolicy == b.AccessControlPolicy {
+		t.Errorf("AccessControlPolicy fields should not match")
+	}
 	if reflect.DeepEqual(a.AllowedOrigins, b.AllowedOrigins) {
 		t.Errorf("AllowedOrigins fields should not match")
 	}
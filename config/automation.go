@@ -2,21 +2,65 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dustin/go-humanize"
 )
 
 // Automation encapsulates configuration for the automation subsystem
 type Automation struct {
-	Enabled         bool
+	Enabled bool
+	// StoreType determines how workflows & runs are stored. "file" keeps them
+	// in JSON files, "sqlite" keeps them in a SQLite database. Both live in the
+	// repo directory. Empty uses "file"
+	StoreType       string
 	RunStoreMaxSize string
+	// RunStoreMaxRuns is the number of most recent runs kept for each workflow.
+	// Zero keeps all runs
+	RunStoreMaxRuns int
+	// RunStoreMaxAge drops runs that stopped longer ago than this duration,
+	// eg: "720h". "unlimited" or empty keeps runs of any age
+	RunStoreMaxAge string
+	// RunOutputMaxSize is the maximum size of output logs kept for each run
+	// step, eg: "1Mb". "unlimited" or empty keeps all output
+	RunOutputMaxSize string
+	// RunStoreCompactInterval is how often the run store is compacted, eg: "1h".
+	// "never" or empty disables compaction. Retention limits are applied when
+	// the store is compacted, so an interval is required when any are set
+	RunStoreCompactInterval string
+	// RefuseLeakyTransforms refuses to deploy workflows when static analysis
+	// finds their transform may leak secrets
+	RefuseLeakyTransforms bool
+	// TransformAxiomsFile is the path to a JSON file of staticlark axioms used
+	// to check transforms for leaks. Empty uses the default axioms
+	TransformAxiomsFile string
+	// TransformMaxSteps is the maximum number of starlark execution steps a
+	// transform run may take. Zero is unlimited
+	TransformMaxSteps uint64
+	// TransformMaxMemory is the approximate maximum number of bytes a
+	// transform run may allocate, eg: "2Gb". "unlimited" or empty is unlimited
+	TransformMaxMemory string
+	// TransformTimeout is the maximum wall clock time of a transform run,
+	// eg: "30m". "unlimited" or empty is unlimited
+	TransformTimeout string
+	// TransformMaxNetwork is the maximum number of bytes a transform run may
+	// send & receive over HTTP, eg: "500Mb". "unlimited" or empty is unlimited
+	TransformMaxNetwork string
 }
 
-// DefaultAutomation constructs an automation configuration with standard values
+// DefaultAutomation constructs an automation configuration with standard values.
+// Run retention is opt-in, by default every run & all run output is kept
 func DefaultAutomation() *Automation {
 	return &Automation{
-		Enabled:         true,
-		RunStoreMaxSize: "100Mb",
+		Enabled:                 true,
+		StoreType:               "file",
+		RunStoreMaxSize:         "100Mb",
+		RunStoreMaxAge:          "unlimited",
+		RunOutputMaxSize:        "unlimited",
+		RunStoreCompactInterval: "never",
+		TransformMaxMemory:      "unlimited",
+		TransformTimeout:        "unlimited",
+		TransformMaxNetwork:     "unlimited",
 	}
 }
 
@@ -29,6 +73,11 @@ func (a *Automation) SetArbitrary(key string, val interface{}) error {
 
 // Validate ensures a correct Automation configuration
 func (a *Automation) Validate() error {
+	switch a.StoreType {
+	case "", "file", "sqlite":
+	default:
+		return fmt.Errorf("invalid StoreType value: %q. must be one of \"file\" or \"sqlite\"", a.StoreType)
+	}
 	if a.RunStoreMaxSize != "unlimited" && a.RunStoreMaxSize != "" {
 		if _, err := humanize.ParseBytes(a.RunStoreMaxSize); err != nil {
 			return fmt.Errorf("invalid RunStoreMaxSize: %w", err)
@@ -36,14 +85,135 @@ func (a *Automation) Validate() error {
 	} else if a.RunStoreMaxSize != "unlimited" {
 		return fmt.Errorf("invalid RunStoreMaxSize value: %s", a.RunStoreMaxSize)
 	}
+	if a.RunStoreMaxRuns < 0 {
+		return fmt.Errorf("invalid RunStoreMaxRuns value: %d", a.RunStoreMaxRuns)
+	}
+	maxAge, err := a.RunStoreMaxAgeDuration()
+	if err != nil {
+		return err
+	}
+	maxOutput, err := a.RunOutputMaxSizeBytes()
+	if err != nil {
+		return err
+	}
+	interval, err := a.RunStoreCompactIntervalDuration()
+	if err != nil {
+		return err
+	}
+	// retention limits are only applied when the run store is compacted
+	if interval == 0 && (a.RunStoreMaxRuns > 0 || maxAge > 0 || maxOutput > 0) {
+		return fmt.Errorf("RunStoreCompactInterval is required when RunStoreMaxRuns, RunStoreMaxAge or RunOutputMaxSize are set")
+	}
+	if _, err := a.TransformMaxMemoryBytes(); err != nil {
+		return err
+	}
+	if _, err := a.TransformTimeoutDuration(); err != nil {
+		return err
+	}
+	if _, err := a.TransformMaxNetworkBytes(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// RunStoreMaxAgeDuration parses RunStoreMaxAge. Zero means unlimited
+func (a *Automation) RunStoreMaxAgeDuration() (time.Duration, error) {
+	if a.RunStoreMaxAge == "unlimited" || a.RunStoreMaxAge == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(a.RunStoreMaxAge)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RunStoreMaxAge: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid RunStoreMaxAge value: %s", a.RunStoreMaxAge)
+	}
+	return d, nil
+}
+
+// RunOutputMaxSizeBytes parses RunOutputMaxSize. Zero means unlimited
+func (a *Automation) RunOutputMaxSizeBytes() (int64, error) {
+	if a.RunOutputMaxSize == "unlimited" || a.RunOutputMaxSize == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(a.RunOutputMaxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RunOutputMaxSize: %w", err)
+	}
+	return int64(size), nil
+}
+
+// RunStoreCompactIntervalDuration parses RunStoreCompactInterval. Zero means
+// the run store is never compacted
+func (a *Automation) RunStoreCompactIntervalDuration() (time.Duration, error) {
+	if a.RunStoreCompactInterval == "never" || a.RunStoreCompactInterval == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(a.RunStoreCompactInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RunStoreCompactInterval: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid RunStoreCompactInterval value: %s", a.RunStoreCompactInterval)
+	}
+	return d, nil
+}
+
+// TransformMaxMemoryBytes parses TransformMaxMemory. Zero means unlimited
+func (a *Automation) TransformMaxMemoryBytes() (uint64, error) {
+	if a.TransformMaxMemory == "unlimited" || a.TransformMaxMemory == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(a.TransformMaxMemory)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TransformMaxMemory: %w", err)
+	}
+	return size, nil
+}
+
+// TransformTimeoutDuration parses TransformTimeout. Zero means unlimited
+func (a *Automation) TransformTimeoutDuration() (time.Duration, error) {
+	if a.TransformTimeout == "unlimited" || a.TransformTimeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(a.TransformTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TransformTimeout: %w", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid TransformTimeout value: %s", a.TransformTimeout)
+	}
+	return d, nil
+}
+
+// TransformMaxNetworkBytes parses TransformMaxNetwork. Zero means unlimited
+func (a *Automation) TransformMaxNetworkBytes() (int64, error) {
+	if a.TransformMaxNetwork == "unlimited" || a.TransformMaxNetwork == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(a.TransformMaxNetwork)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TransformMaxNetwork: %w", err)
+	}
+	return int64(size), nil
+}
+
 // Copy creates a shallow copy of Automation
 func (a *Automation) Copy() *Automation {
 	return &Automation{
-		Enabled:         a.Enabled,
-		RunStoreMaxSize: a.RunStoreMaxSize,
+		Enabled:                 a.Enabled,
+		StoreType:               a.StoreType,
+		RunStoreMaxSize:         a.RunStoreMaxSize,
+		RunStoreMaxRuns:         a.RunStoreMaxRuns,
+		RunStoreMaxAge:          a.RunStoreMaxAge,
+		RunOutputMaxSize:        a.RunOutputMaxSize,
+		RunStoreCompactInterval: a.RunStoreCompactInterval,
+		RefuseLeakyTransforms:   a.RefuseLeakyTransforms,
+		TransformAxiomsFile:     a.TransformAxiomsFile,
+		TransformMaxSteps:       a.TransformMaxSteps,
+		TransformMaxMemory:      a.TransformMaxMemory,
+		TransformTimeout:        a.TransformTimeout,
+		TransformMaxNetwork:     a.TransformMaxNetwork,
 	}
 }
@@ -9,6 +9,38 @@ func TestAutomationValidate(t *testing.T) {
 	if err != nil {
 		t.Errorf("error validating default api: %s", err)
 	}
+
+	bad := []func(a *Automation){
+		func(a *Automation) { a.StoreType = "postgres" },
+		func(a *Automation) { a.RunStoreMaxRuns = -1 },
+		func(a *Automation) { a.RunStoreMaxAge = "forever" },
+		func(a *Automation) { a.RunStoreMaxAge = "-1h" },
+		func(a *Automation) { a.RunOutputMaxSize = "lots" },
+		func(a *Automation) { a.RunStoreCompactInterval = "sometimes" },
+		// retention limits need a compaction interval to take effect
+		func(a *Automation) { a.RunStoreMaxRuns = 10 },
+		func(a *Automation) { a.RunStoreMaxAge = "720h" },
+		func(a *Automation) { a.RunOutputMaxSize = "1Mb" },
+		func(a *Automation) { a.TransformMaxMemory = "lots" },
+		func(a *Automation) { a.TransformTimeout = "-1m" },
+		func(a *Automation) { a.TransformMaxNetwork = "plenty" },
+	}
+	for i, modify := range bad {
+		a := DefaultAutomation()
+		modify(a)
+		if err := a.Validate(); err == nil {
+			t.Errorf("case %d: expected invalid automation config to error", i)
+		}
+	}
+
+	a := DefaultAutomation()
+	a.RunStoreMaxRuns = 10
+	a.RunStoreMaxAge = "720h"
+	a.RunOutputMaxSize = "1Mb"
+	a.RunStoreCompactInterval = "1h"
+	if err := a.Validate(); err != nil {
+		t.Errorf("expected retention limits with a compaction interval to be valid, got: %s", err)
+	}
 }
 
 func TestAutomationCopy(t *testing.T) {
@@ -16,12 +48,72 @@ func TestAutomationCopy(t *testing.T) {
 	b := a.Copy()
 
 	a.Enabled = !a.Enabled
+	a.StoreType = "sqlite"
 	a.RunStoreMaxSize = "foo"
+	a.RunStoreMaxRuns = 7
+	a.RunStoreMaxAge = "1h"
+	a.RunOutputMaxSize = "1Kb"
+	a.RunStoreCompactInterval = "1m"
+	a.RefuseLeakyTransforms = !a.RefuseLeakyTransforms
+	a.TransformAxiomsFile = "axioms.json"
+	a.TransformMaxSteps = 1000
+	a.TransformMaxMemory = "1Gb"
+	a.TransformTimeout = "5m"
+	a.TransformMaxNetwork = "10Mb"
 
 	if a.Enabled == b.Enabled {
 		t.Errorf("Enabled fields should not match")
 	}
+	if a.StoreType == b.StoreType {
+		t.Errorf("StoreType fields should not match")
+	}
 	if a.RunStoreMaxSize == b.RunStoreMaxSize {
 		t.Errorf("RunStoreMaxSize fields should not match")
 	}
+	if a.RunStoreMaxRuns == b.RunStoreMaxRuns {
+		t.Errorf("RunStoreMaxRuns fields should not match")
+	}
+	if a.RunStoreMaxAge == b.RunStoreMaxAge {
+		t.Errorf("RunStoreMaxAge fields should not match")
+	}
+	if a.RunOutputMaxSize == b.RunOutputMaxSize {
+		t.Errorf("RunOutputMaxSize fields should not match")
+	}
+	if a.RunStoreCompactInterval == b.RunStoreCompactInterval {
+		t.Errorf("RunStoreCompactInterval fields should not match")
+	}
+	if a.RefuseLeakyTransforms == b.RefuseLeakyTransforms {
+		t.Errorf("RefuseLeakyTransforms fields should not match")
+	}
+	if a.TransformAxiomsFile == b.TransformAxiomsFile {
+		t.Errorf("TransformAxiomsFile fields should not match")
+	}
+	if a.TransformMaxSteps == b.TransformMaxSteps {
+		t.Errorf("TransformMaxSteps fields should not match")
+	}
+	if a.TransformMaxMemory == b.TransformMaxMemory {
+		t.Errorf("TransformMaxMemory fields should not match")
+	}
+	if a.TransformTimeout == b.TransformTimeout {
+		t.Errorf("TransformTimeout fields should not match")
+	}
+	if a.TransformMaxNetwork == b.TransformMaxNetwork {
+		t.Errorf("TransformMaxNetwork fields should not match")
+	}
+}
+
+func TestDefaultAutomationKeepsRuns(t *testing.T) {
+	a := DefaultAutomation()
+	if a.RunStoreMaxRuns != 0 {
+		t.Errorf("expected default config to keep all runs, got RunStoreMaxRuns %d", a.RunStoreMaxRuns)
+	}
+	if d, err := a.RunStoreMaxAgeDuration(); err != nil || d != 0 {
+		t.Errorf("expected default config to keep runs of any age, got %s, err: %v", d, err)
+	}
+	if size, err := a.RunOutputMaxSizeBytes(); err != nil || size != 0 {
+		t.Errorf("expected default config to keep all run output, got %d, err: %v", size, err)
+	}
+	if d, err := a.RunStoreCompactIntervalDuration(); err != nil || d != 0 {
+		t.Errorf("expected default config to never compact the run store, got %s, err: %v", d, err)
+	}
 }
@@ -41,6 +41,9 @@ type DsRename struct {
 type DsSaveEvent struct {
 	Username string `json:"username"`
 	Name     string `json:"name"`
+	// InitID of the dataset being saved. empty when saving a new dataset
+	// that hasn't been initialized
+	InitID string `json:"initID,omitempty"`
 	// either message or error will be populated. message should be human-centric
 	// description of progress
 	Message string `json:"message"`
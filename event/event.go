@@ -70,6 +70,9 @@ type Bus interface {
 	SubscribeTypes(handler Handler, eventTypes ...Type)
 	// SubscribeID subscribes to only events that have a matching session id
 	SubscribeID(handler Handler, sessionID string)
+	// UnsubscribeID removes all handlers subscribed to a session id. Only the
+	// owner of a session should unsubscribe, once the session is over
+	UnsubscribeID(sessionID string)
 	// SubscribeAll subscribes to all events
 	SubscribeAll(handler Handler)
 	// NumSubscriptions returns the number of subscribers to the bus's events
@@ -100,6 +103,8 @@ func (nilBus) SubscribeTypes(handler Handler, eventTypes ...Type) {}
 
 func (nilBus) SubscribeID(handler Handler, id string) {}
 
+func (nilBus) UnsubscribeID(id string) {}
+
 func (nilBus) SubscribeAll(handler Handler) {}
 
 func (nilBus) NumSubscribers() int {
@@ -214,6 +219,14 @@ func (b *bus) SubscribeID(handler Handler, sessionID string) {
 	b.idSubs[sessionID] = append(b.idSubs[sessionID], handler)
 }
 
+// UnsubscribeID drops all handlers for the given sessionID
+func (b *bus) UnsubscribeID(sessionID string) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	log.Debugf("Unsubscribe from ID: %v", sessionID)
+	delete(b.idSubs, sessionID)
+}
+
 // SubscribeAll requests all events from the bus
 func (b *bus) SubscribeAll(handler Handler) {
 	b.lk.Lock()
@@ -134,6 +134,15 @@ func TestEventSubscribeID(t *testing.T) {
 	if diff := cmp.Diff(expectPayload, gotPayload); diff != "" {
 		t.Errorf("payload (-want +got):\n%s", diff)
 	}
+
+	bus.UnsubscribeID("789")
+	bus.PublishID(ctx, ETMainSaidHello, "789", "hi5")
+	if diff := cmp.Diff(expectNum, gotNumEvents); diff != "" {
+		t.Errorf("num events after unsubscribing (-want +got):\n%s", diff)
+	}
+	if bus.NumSubscribers() != 0 {
+		t.Errorf("expected no subscribers after unsubscribing. got: %d", bus.NumSubscribers())
+	}
 }
 
 func TestEventSubscribeAll(t *testing.T) {
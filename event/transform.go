@@ -1,5 +1,13 @@
 package event
 
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
 const (
 	// ETTransformStart signals the start a transform execution
 	// Payload will be a TransformLifecycle
@@ -72,9 +80,108 @@ const (
 	TransformMsgLvlError = TransformMsgLvl("error")
 )
 
+// TransformErrCategoryLimit categorizes ETTransformError messages sent when a
+// transform exceeds a resource limit
+const TransformErrCategoryLimit = "limit"
+
 // TransformMessage is the payload for print and error events
 type TransformMessage struct {
 	Lvl  TransformMsgLvl `json:"lvl"`
 	Msg  string          `json:"msg"`
 	Mode string          `json:"mode,omitempty"`
+	// Category distinguishes kinds of error messages, eg: TransformErrCategoryLimit
+	Category string `json:"category,omitempty"`
+}
+
+// RedactedSecret replaces secret values redacted from transform output
+const RedactedSecret = "[REDACTED]"
+
+// RedactSecrets returns a copy of the message with secret values replaced by
+// RedactedSecret
+func (m TransformMessage) RedactSecrets(secrets map[string]string) TransformMessage {
+	m.Msg = redactString(m.Msg, secrets)
+	return m
+}
+
+// HTTPFixtures is a content-addressed bundle of HTTP exchanges recorded during
+// a transform run. Response bodies are stored once in Blobs, keyed by the hex
+// encoded sha256 hash of the body. Request headers, URL queries &
+// credential-carrying response headers are not recorded
+type HTTPFixtures struct {
+	Exchanges []HTTPExchange    `json:"exchanges"`
+	Blobs     map[string][]byte `json:"blobs"`
+	// Truncated is true if the recording stopped early because it reached its
+	// size cap. Requests made after that point weren't recorded
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// HTTPExchange is a single recorded request & response
+type HTTPExchange struct {
+	// RequestKey is the hex encoded sha256 hash of the request method, URL &
+	// body, used to match requests when replaying
+	RequestKey string `json:"requestKey"`
+	Method     string `json:"method"`
+	// URL is the request URL without its query string
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	// BodyHash is the key of the response body in HTTPFixtures.Blobs
+	BodyHash string `json:"bodyHash"`
+}
+
+// RedactSecrets returns a copy of the fixtures with secret values replaced by
+// RedactedSecret in URLs, response headers & response bodies. Redacted bodies
+// are keyed by their new hash. Request keys are hashes & left as is
+func (f *HTTPFixtures) RedactSecrets(secrets map[string]string) *HTTPFixtures {
+	if f == nil {
+		return nil
+	}
+	res := &HTTPFixtures{
+		Exchanges: make([]HTTPExchange, len(f.Exchanges)),
+		Blobs:     make(map[string][]byte, len(f.Blobs)),
+		Truncated: f.Truncated,
+	}
+	rekeyed := make(map[string]string, len(f.Blobs))
+	for hash, blob := range f.Blobs {
+		redacted := redactBytes(blob, secrets)
+		sum := sha256.Sum256(redacted)
+		key := hex.EncodeToString(sum[:])
+		rekeyed[hash] = key
+		res.Blobs[key] = redacted
+	}
+	for i, ex := range f.Exchanges {
+		ex.URL = redactString(ex.URL, secrets)
+		if ex.Header != nil {
+			h := make(http.Header, len(ex.Header))
+			for key, vals := range ex.Header {
+				for _, val := range vals {
+					h[key] = append(h[key], redactString(val, secrets))
+				}
+			}
+			ex.Header = h
+		}
+		if key, ok := rekeyed[ex.BodyHash]; ok {
+			ex.BodyHash = key
+		}
+		res.Exchanges[i] = ex
+	}
+	return res
+}
+
+func redactString(s string, secrets map[string]string) string {
+	for _, val := range secrets {
+		if val != "" {
+			s = strings.ReplaceAll(s, val, RedactedSecret)
+		}
+	}
+	return s
+}
+
+func redactBytes(b []byte, secrets map[string]string) []byte {
+	for _, val := range secrets {
+		if val != "" {
+			b = bytes.ReplaceAll(b, []byte(val), []byte(RedactedSecret))
+		}
+	}
+	return b
 }
@@ -0,0 +1,49 @@
+package event
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPFixturesRedactSecrets(t *testing.T) {
+	f := &HTTPFixtures{
+		Exchanges: []HTTPExchange{
+			{
+				RequestKey: "req",
+				Method:     http.MethodGet,
+				URL:        "https://example.com/hunter2/data",
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Echo": []string{"token hunter2"}},
+				BodyHash:   "body",
+			},
+		},
+		Blobs: map[string][]byte{"body": []byte(`{"token":"hunter2"}`)},
+	}
+
+	got := f.RedactSecrets(map[string]string{"api_key": "hunter2"})
+	if len(got.Exchanges) != 1 || len(got.Blobs) != 1 {
+		t.Fatalf("expected one exchange & blob, got: %#v", got)
+	}
+	ex := got.Exchanges[0]
+	if ex.URL != "https://example.com/"+RedactedSecret+"/data" {
+		t.Errorf("expected URL to be redacted, got: %q", ex.URL)
+	}
+	if diff := cmp.Diff([]string{"token " + RedactedSecret}, ex.Header["X-Echo"]); diff != "" {
+		t.Errorf("header mismatch (-want +got):\n%s", diff)
+	}
+	body, ok := got.Blobs[ex.BodyHash]
+	if !ok {
+		t.Fatalf("expected redacted body to be keyed by exchange body hash %q", ex.BodyHash)
+	}
+	if strings.Contains(string(body), "hunter2") {
+		t.Errorf("expected body to be redacted, got: %s", body)
+	}
+
+	// the original is left untouched
+	if string(f.Blobs["body"]) != `{"token":"hunter2"}` || f.Exchanges[0].URL != "https://example.com/hunter2/data" {
+		t.Errorf("expected redacting to copy fixtures, original changed: %#v", f)
+	}
+}
@@ -2,12 +2,21 @@ package lib
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/qri-io/qri/auth/token"
+	"github.com/qri-io/qri/dsref"
 	qhttp "github.com/qri-io/qri/lib/http"
 	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/remote/access"
 )
 
 // AccessMethods is a group of methods for access control & user authentication
@@ -24,6 +33,8 @@ func (m AccessMethods) Name() string {
 func (m AccessMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
 		"createauthtoken": {Endpoint: qhttp.AECreateAuthToken, HTTPVerb: "POST", DefaultSource: "local"},
+		"listauthtokens":  {Endpoint: qhttp.AEListAuthTokens, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"revokeauthtoken": {Endpoint: qhttp.AERevokeAuthToken, HTTPVerb: "POST", DefaultSource: "local"},
 	}
 }
 
@@ -35,6 +46,10 @@ type CreateAuthTokenParams struct {
 	GranteeProfileID string `json:"granteeProfileID"`
 	// lifespan of token in nanoseconds; e.g. 2000000000000
 	TTL time.Duration `json:"ttl"`
+	// resources the token is limited to; e.g. "dataset:_subject:*"
+	Resources []string `json:"resources,omitempty"`
+	// actions the token is limited to; e.g. "read:dataset:get"
+	Actions []string `json:"actions,omitempty"`
 }
 
 // SetNonZeroDefaults uses default token time-to-live if one isn't set
@@ -49,13 +64,19 @@ func (p *CreateAuthTokenParams) Validate() error {
 	if p.GranteeUsername == "" && p.GranteeProfileID == "" {
 		return fmt.Errorf("either grantee username or profile is required")
 	}
+	if len(p.Resources) > 0 || len(p.Actions) > 0 {
+		if _, err := token.ParseScope(p.Resources, p.Actions); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // CreateAuthToken constructs a JWT string token suitable for making OAuth
 // requests as the grantee user. Creating an access token requires a stored
 // private key for the grantee.
-// Callers can provide either granteeUsername OR granteeProfileID
+// Callers can provide either granteeUsername OR granteeProfileID. Tokens are
+// limited to the given resources & actions if any are provided
 func (m AccessMethods) CreateAuthToken(ctx context.Context, p *CreateAuthTokenParams) (string, error) {
 	res, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "createauthtoken"), p)
 	if s, ok := res.(string); ok {
@@ -64,6 +85,51 @@ func (m AccessMethods) CreateAuthToken(ctx context.Context, p *CreateAuthTokenPa
 	return "", err
 }
 
+// AuthToken describes an access token issued by this instance
+type AuthToken struct {
+	ID        string        `json:"id"`
+	Subject   string        `json:"subject"`
+	Scopes    []token.Scope `json:"scopes,omitempty"`
+	ExpiresAt *time.Time    `json:"expiresAt,omitempty"`
+}
+
+// ListAuthTokensParams are input parameters for Access().ListAuthTokens
+type ListAuthTokensParams struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// ListAuthTokens lists access tokens created by this instance that haven't
+// been revoked. Raw token strings are not included
+func (m AccessMethods) ListAuthTokens(ctx context.Context, p *ListAuthTokensParams) ([]AuthToken, error) {
+	res, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "listauthtokens"), p)
+	if toks, ok := res.([]AuthToken); ok {
+		return toks, err
+	}
+	return nil, dispatchReturnError(res, err)
+}
+
+// RevokeAuthTokenParams are input parameters for Access().RevokeAuthToken
+type RevokeAuthTokenParams struct {
+	// ID of the token to revoke
+	ID string `json:"id"`
+}
+
+// Validate returns an error if input params are invalid
+func (p *RevokeAuthTokenParams) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("token ID is required")
+	}
+	return nil
+}
+
+// RevokeAuthToken adds a token to the revocation list, after which requests
+// made with the token are rejected
+func (m AccessMethods) RevokeAuthToken(ctx context.Context, p *RevokeAuthTokenParams) error {
+	_, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "revokeauthtoken"), p)
+	return err
+}
+
 // accessImpl is the backing implementation for AccessMethods
 type accessImpl struct{}
 
@@ -94,5 +160,207 @@ func (accessImpl) CreateAuthToken(scp scope, p *CreateAuthTokenParams) (string,
 		return "", fmt.Errorf("cannot create token for %q (id: %s), private key is required", grantee.Peername, grantee.ID.Encode())
 	}
 
-	return token.NewPrivKeyAuthToken(pk, grantee.ID.Encode(), p.TTL)
+	var scopes []token.Scope
+	if len(p.Resources) > 0 || len(p.Actions) > 0 {
+		scope, err := token.ParseScope(p.Resources, p.Actions)
+		if err != nil {
+			return "", err
+		}
+		scopes = []token.Scope{scope}
+	}
+
+	_, raw, err := token.NewScopedPrivKeyAuthToken(pk, grantee.ID.Encode(), p.TTL, scopes)
+	if err != nil {
+		return "", err
+	}
+	claims, err := token.ParseUnverifiedClaims(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := scp.Tokens().PutClaims(scp.Context(), claims); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (accessImpl) ListAuthTokens(scp scope, p *ListAuthTokensParams) ([]AuthToken, error) {
+	issued, err := scp.Tokens().ListClaims(scp.Context(), p.Offset, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	toks := make([]AuthToken, 0, len(issued))
+	for _, claims := range issued {
+		t := AuthToken{
+			ID:      claims.Id,
+			Subject: claims.Subject,
+			Scopes:  claims.Scopes,
+		}
+		if claims.ExpiresAt != 0 {
+			exp := time.Unix(claims.ExpiresAt, 0).In(time.UTC)
+			t.ExpiresAt = &exp
+		}
+		toks = append(toks, t)
+	}
+	return toks, nil
+}
+
+func (accessImpl) RevokeAuthToken(scp scope, p *RevokeAuthTokenParams) error {
+	if err := scp.RevokedTokens().Revoke(scp.Context(), p.ID); err != nil {
+		return err
+	}
+	// tokens may have been issued elsewhere, revoking a token this instance
+	// didn't create is fine
+	if err := scp.Tokens().DeleteClaims(scp.Context(), p.ID); err != nil && !errors.Is(err, token.ErrTokenNotFound) {
+		return err
+	}
+	return nil
+}
+
+// loadAccessControlPolicy reads a JSON policy file. Relative paths are
+// resolved against repoPath
+func loadAccessControlPolicy(path, repoPath string) (*access.Policy, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoPath, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pol := &access.Policy{}
+	if err := json.Unmarshal(data, pol); err != nil {
+		return nil, fmt.Errorf("invalid policy file %q: %w", path, err)
+	}
+	return pol, nil
+}
+
+// nodeResource is the access resource for method calls that don't act on a
+// single dataset
+const nodeResource = "node"
+
+// authorizeMethodCall checks a method call is permitted by the scopes of the
+// access token it was made with, and by the instance access control policy.
+// Calls are checked as the method action against the dataset named by a "Ref"
+// param field, or against the "node" resource when params don't reference a
+// dataset. Calls without a token, or with an unscoped token pass token checks.
+// The policy only applies to calls made through the JSON API, which must carry
+// an access token. The node owner is always permitted by the policy
+func (inst *Instance) authorizeMethodCall(scp scope, act access.Action, param interface{}) error {
+	scoped := scp.claims != nil && len(scp.claims.Scopes) > 0
+	enforcePolicy := inst.policy != nil && isAPICall(scp.Context())
+	if !scoped && !enforcePolicy {
+		return nil
+	}
+
+	pro := scp.ActiveProfile()
+	rsc, err := methodResource(pro, param)
+	if err != nil {
+		return err
+	}
+	if scoped && !claimsAllow(scp.claims, rsc, act, pro.Peername) {
+		return fmt.Errorf("%w: token doesn't permit %q on %q", access.ErrAccessDenied, strings.Join(act, ":"), strings.Join(rsc, ":"))
+	}
+
+	if enforcePolicy {
+		if scp.claims == nil {
+			return fmt.Errorf("%w: access token required", access.ErrAccessDenied)
+		}
+		if owner := inst.profiles.Owner(scp.Context()); owner != nil && owner.ID == pro.ID {
+			return nil
+		}
+		if err := inst.policy.Enforce(pro, strings.Join(rsc, ":"), strings.Join(act, ":")); err != nil {
+			return fmt.Errorf("%w: policy doesn't permit %s %q on %q", err, pro.Peername, strings.Join(act, ":"), strings.Join(rsc, ":"))
+		}
+	}
+	return nil
+}
+
+// claimsAllow checks token claims permit an action on a resource, accepting
+// scopes that name methods in the "group:method" form scoped tokens were
+// issued with before actions were prefixed with "read" or "write"
+func claimsAllow(claims *token.Claims, rsc access.Resource, act access.Action, subjectUsername string) bool {
+	if claims.Allows(rsc, act, subjectUsername) {
+		return true
+	}
+	if len(act) == 3 && (act[0] == "read" || act[0] == "write") {
+		return claims.Allows(rsc, act[1:], subjectUsername)
+	}
+	return false
+}
+
+// eventSubscriptionAction is the action event stream subscriptions are checked
+// as
+var eventSubscriptionAction = access.Action{"read", "event", "subscribe"}
+
+// AuthorizeEventSubscription checks the holder of an access token may
+// subscribe to their event stream over the API. Subscriptions are checked
+// against token scopes & the access control policy as the
+// "read:event:subscribe" action on the "node" resource.
+// Subscriptions made with scoped tokens only receive events about datasets
+// the scopes permit the same action on. The returned function reports if an
+// event about a dataset init ID may be sent, and is nil when all events may
+func (inst *Instance) AuthorizeEventSubscription(ctx context.Context, tokenString string) (func(initID string) bool, error) {
+	ctx = context.WithValue(token.AddToContext(ctx, tokenString), apiCallCtxKey, true)
+	scp, err := newScope(ctx, inst, "event.subscribe", "")
+	if err != nil {
+		return nil, err
+	}
+	if err := inst.authorizeMethodCall(scp, eventSubscriptionAction, nil); err != nil {
+		return nil, err
+	}
+	if scp.claims == nil || len(scp.claims.Scopes) == 0 {
+		return nil, nil
+	}
+
+	var (
+		claims   = scp.claims
+		username = scp.ActiveProfile().Peername
+		lk       sync.Mutex
+		// datasets are looked up once per subscription
+		permitted = map[string]bool{}
+	)
+	return func(initID string) bool {
+		// events that can't be tied to a dataset may be about one outside of the
+		// token's scopes
+		if initID == "" {
+			return false
+		}
+		lk.Lock()
+		defer lk.Unlock()
+		if ok, checked := permitted[initID]; checked {
+			return ok
+		}
+		ok := false
+		if ref, err := inst.logbook.Ref(ctx, initID); err == nil {
+			if rsc, err := access.ParseResource(access.ResourceStrFromRef(ref)); err == nil {
+				ok = claimsAllow(claims, rsc, eventSubscriptionAction, username)
+			}
+		}
+		permitted[initID] = ok
+		return ok
+	}, nil
+}
+
+// methodResource determines the access resource a method call acts on
+func methodResource(pro *profile.Profile, param interface{}) (access.Resource, error) {
+	v := reflect.Indirect(reflect.ValueOf(param))
+	if v.Kind() != reflect.Struct {
+		return access.Resource{nodeResource}, nil
+	}
+	f := v.FieldByName("Ref")
+	if !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return access.Resource{nodeResource}, nil
+	}
+
+	ref, err := dsref.Parse(f.String())
+	if err != nil && err != dsref.ErrBadCaseName {
+		return nil, err
+	}
+	if ref.Username == "" || ref.Name == "" {
+		return nil, fmt.Errorf("%w: access control requires a username/name dataset reference", access.ErrAccessDenied)
+	}
+	if ref.Username == "me" {
+		ref.Username = pro.Peername
+	}
+	return access.ParseResource(access.ResourceStrFromRef(ref))
 }
@@ -2,9 +2,15 @@ package lib
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	testkeys "github.com/qri-io/qri/auth/key/test"
 	"github.com/qri-io/qri/auth/token"
+	"github.com/qri-io/qri/automation"
+	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/remote/access"
 )
 
 func TestAccessCreateAuthToken(t *testing.T) {
@@ -23,7 +29,7 @@ func TestAccessCreateAuthToken(t *testing.T) {
 	}
 
 	// prove we can parse & validate that token
-	_, err = token.ParseAuthToken(ctx, s, inst.keystore)
+	_, err = token.ParseAuthToken(ctx, s, inst.keystore, inst.revokedTokens)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,3 +51,253 @@ func TestAccessValidationFailure(t *testing.T) {
 		t.Errorf("error mismatch, expect: %s, got: %s", expectErr, err)
 	}
 }
+
+func TestAccessScopedAuthTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inst, cleanup := NewMemTestInstance(ctx, t)
+	defer cleanup()
+
+	s, err := inst.Access().CreateAuthToken(ctx, &CreateAuthTokenParams{
+		GranteeUsername: "me",
+		Resources:       []string{"node"},
+		Actions:         []string{"read:access:listauthtokens"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopedCtx := token.AddToContext(ctx, s)
+
+	toks, err := inst.Access().ListAuthTokens(scopedCtx, &ListAuthTokensParams{})
+	if err != nil {
+		t.Fatalf("listing tokens with a token scoped to list tokens shouldn't error. got: %s", err)
+	}
+	if len(toks) != 1 {
+		t.Fatalf("expected 1 token, got: %d", len(toks))
+	}
+	if len(toks[0].Scopes) != 1 {
+		t.Errorf("expected listed token to have 1 scope, got: %d", len(toks[0].Scopes))
+	}
+
+	err = inst.Access().RevokeAuthToken(scopedCtx, &RevokeAuthTokenParams{ID: toks[0].ID})
+	if !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected revoking with a token scoped to list tokens to be denied. got: %v", err)
+	}
+
+	if err := inst.Access().RevokeAuthToken(ctx, &RevokeAuthTokenParams{ID: toks[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = inst.Access().ListAuthTokens(scopedCtx, &ListAuthTokensParams{}); !errors.Is(err, token.ErrTokenRevoked) {
+		t.Errorf("expected using a revoked token to fail with token.ErrTokenRevoked. got: %v", err)
+	}
+	if toks, err = inst.Access().ListAuthTokens(ctx, &ListAuthTokensParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 0 {
+		t.Errorf("expected revoked token to be removed from the list, got: %d tokens", len(toks))
+	}
+}
+
+func TestAccessLegacyScopedAuthTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inst, cleanup := NewMemTestInstance(ctx, t)
+	defer cleanup()
+
+	// tokens issued before actions were prefixed with "read" or "write" name
+	// methods as "group:method"
+	s, err := inst.Access().CreateAuthToken(ctx, &CreateAuthTokenParams{
+		GranteeUsername: "me",
+		Resources:       []string{"node"},
+		Actions:         []string{"access:listauthtokens"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopedCtx := token.AddToContext(ctx, s)
+
+	if _, err := inst.Access().ListAuthTokens(scopedCtx, &ListAuthTokensParams{}); err != nil {
+		t.Errorf("expected a \"group:method\" scoped token to be permitted. got: %s", err)
+	}
+	_, err = inst.Access().CreateAuthToken(scopedCtx, &CreateAuthTokenParams{GranteeUsername: "me"})
+	if !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected a \"group:method\" scoped token to be denied other methods. got: %v", err)
+	}
+}
+
+func TestAuthorizeScopedEventSubscription(t *testing.T) {
+	tr := newTestRunner(t)
+	defer tr.Delete()
+	inst := tr.Instance
+
+	cities, err := tr.SaveWithParams(&SaveParams{Ref: "me/cities", BodyPath: "testdata/cities_2/body.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tr.SaveWithParams(&SaveParams{Ref: "me/other", BodyPath: "testdata/cities_2/body.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unscoped, err := inst.Access().CreateAuthToken(tr.Ctx, &CreateAuthTokenParams{GranteeUsername: "me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	permit, err := inst.AuthorizeEventSubscription(tr.Ctx, unscoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permit != nil {
+		t.Errorf("expected an unscoped token to receive all events")
+	}
+
+	scoped, err := inst.Access().CreateAuthToken(tr.Ctx, &CreateAuthTokenParams{
+		GranteeUsername: "me",
+		Resources:       []string{"node", "dataset:_subject:cities"},
+		Actions:         []string{"read:event:subscribe"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permit, err = inst.AuthorizeEventSubscription(tr.Ctx, scoped); err != nil {
+		t.Fatal(err)
+	}
+	if permit == nil {
+		t.Fatal("expected a scoped token to be limited to permitted datasets")
+	}
+	if permit("") {
+		t.Errorf("expected events that can't be tied to a dataset to be denied")
+	}
+	if !permit(cities.InitID) {
+		t.Errorf("expected events about a dataset in scope to be permitted")
+	}
+	if permit(other.InitID) {
+		t.Errorf("expected events about a dataset out of scope to be denied")
+	}
+
+	listOnly, err := inst.Access().CreateAuthToken(tr.Ctx, &CreateAuthTokenParams{
+		GranteeUsername: "me",
+		Resources:       []string{"node"},
+		Actions:         []string{"read:access:listauthtokens"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.AuthorizeEventSubscription(tr.Ctx, listOnly); !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected a token without event scopes to be denied. got: %v", err)
+	}
+}
+
+func TestMethodResource(t *testing.T) {
+	pro := &profile.Profile{Peername: "doug"}
+	cases := []struct {
+		param  interface{}
+		expect string
+	}{
+		{&GetParams{Ref: "b5/world_bank"}, "dataset:b5:world_bank"},
+		{&GetParams{Ref: "me/world_bank"}, "dataset:doug:world_bank"},
+		{&GetParams{}, "node"},
+		{&ListAuthTokensParams{}, "node"},
+	}
+	for _, c := range cases {
+		rsc, err := methodResource(pro, c.param)
+		if err != nil {
+			t.Errorf("%#v: unexpected error: %s", c.param, err)
+			continue
+		}
+		if got := strings.Join(rsc, ":"); got != c.expect {
+			t.Errorf("%#v: resource mismatch. want: %q got: %q", c.param, c.expect, got)
+		}
+	}
+
+	if _, err := methodResource(pro, &GetParams{Ref: "@/ipfs/QmFoo"}); err == nil {
+		t.Errorf("expected a reference without a dataset name to error")
+	}
+}
+
+func TestAccessControlPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inst, cleanup := NewMemTestInstance(ctx, t)
+	defer cleanup()
+
+	inst.policy = &access.Policy{
+		{
+			Title:     "analysts can read",
+			Subject:   "*",
+			Resources: access.Resources{access.MustParseResource("*")},
+			Actions:   access.Actions{access.MustParseAction("read:*")},
+			Effect:    access.EffectAllow,
+		},
+	}
+
+	kd := testkeys.GetKeyData(5)
+	analyst := &profile.Profile{
+		ID:       profile.IDFromPeerID(kd.PeerID),
+		Peername: "analyst",
+		PubKey:   kd.PrivKey.GetPublic(),
+	}
+	if err := inst.profiles.PutProfile(ctx, analyst); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.keystore.AddPubKey(ctx, kd.KeyID, kd.PrivKey.GetPublic()); err != nil {
+		t.Fatal(err)
+	}
+	analystToken, err := token.NewPrivKeyAuthToken(kd.PrivKey, analyst.ID.Encode(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiCtx := context.WithValue(ctx, apiCallCtxKey, true)
+	analystCtx := token.AddToContext(apiCtx, analystToken)
+
+	if _, err := inst.Access().ListAuthTokens(analystCtx, &ListAuthTokensParams{}); err != nil {
+		t.Errorf("expected policy to permit a read-only method. got: %s", err)
+	}
+	_, err = inst.Access().CreateAuthToken(analystCtx, &CreateAuthTokenParams{GranteeUsername: "me"})
+	if !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected policy to deny a write method. got: %v", err)
+	}
+	if _, err := inst.Access().ListAuthTokens(apiCtx, &ListAuthTokensParams{}); !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected an API call without a token to be denied. got: %v", err)
+	}
+	if _, err := inst.AuthorizeEventSubscription(ctx, analystToken); err != nil {
+		t.Errorf("expected policy to permit subscribing to events. got: %s", err)
+	}
+	if _, err := inst.AuthorizeEventSubscription(ctx, ""); !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected an event subscription without a token to be denied. got: %v", err)
+	}
+
+	owner := inst.profiles.Owner(ctx)
+	ownerToken, err := token.NewPrivKeyAuthToken(owner.PrivKey, owner.ID.Encode(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Access().CreateAuthToken(token.AddToContext(apiCtx, ownerToken), &CreateAuthTokenParams{GranteeUsername: "me"}); err != nil {
+		t.Errorf("expected the node owner to be permitted. got: %s", err)
+	}
+	if _, err := inst.Access().CreateAuthToken(ctx, &CreateAuthTokenParams{GranteeUsername: "me"}); err != nil {
+		t.Errorf("expected calls that don't come from the API to be permitted. got: %s", err)
+	}
+
+	// event streams are checked against the policy like any other API call
+	inst.policy = &access.Policy{
+		{
+			Title:     "analysts can read datasets",
+			Subject:   "*",
+			Resources: access.Resources{access.MustParseResource("*")},
+			Actions:   access.Actions{access.MustParseAction("read:dataset:*")},
+			Effect:    access.EffectAllow,
+		},
+	}
+	if _, err := inst.AuthorizeEventSubscription(ctx, analystToken); !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected policy to deny subscribing to events. got: %v", err)
+	}
+	err = inst.Automation().FollowRun(analystCtx, &FollowRunParams{
+		ID:     "run_id",
+		Events: func(automation.RunEvent) error { return nil },
+	})
+	if !errors.Is(err, access.ErrAccessDenied) {
+		t.Errorf("expected policy to deny following a run. got: %v", err)
+	}
+}
@@ -1,22 +1,29 @@
 package lib
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/preview"
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/qri/automation"
 	"github.com/qri-io/qri/automation/run"
+	"github.com/qri-io/qri/automation/secrets"
+	"github.com/qri-io/qri/automation/trigger"
 	"github.com/qri-io/qri/automation/workflow"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
 	qhttp "github.com/qri-io/qri/lib/http"
 	"github.com/qri-io/qri/transform"
+	"github.com/qri-io/qri/transform/startf"
 	"github.com/qri-io/qri/transform/staticlark"
 )
 
@@ -36,13 +43,20 @@ func (m AutomationMethods) Attributes() map[string]AttributeSet {
 		"apply":    {Endpoint: qhttp.AEApply, HTTPVerb: "POST"},
 		"deploy":   {Endpoint: qhttp.AEDeploy, HTTPVerb: "POST", DefaultSource: "local"},
 		"run":      {Endpoint: qhttp.AERun, HTTPVerb: "POST"},
-		"runinfo":  {Endpoint: qhttp.AERunInfo, HTTPVerb: "POST"},
-		"workflow": {Endpoint: qhttp.AEWorkflow, HTTPVerb: "POST"},
+		"runinfo":  {Endpoint: qhttp.AERunInfo, HTTPVerb: "POST", ReadOnly: true},
+		"workflow": {Endpoint: qhttp.AEWorkflow, HTTPVerb: "POST", ReadOnly: true},
 		"remove":   {Endpoint: qhttp.AERemoveWorkflow, HTTPVerb: "POST"},
 		"cancel":   {Endpoint: qhttp.AECancel, HTTPVerb: "POST"},
+		// followrun streams events & is handled in a separate `RunEventsHandler`
+		// function, consumed over RPC with qhttp.Client.FollowRun
+		"followrun": {Endpoint: qhttp.DenyHTTP, DenyRPC: true, ReadOnly: true},
+
+		"setsecret":    {Endpoint: qhttp.AESetSecret, HTTPVerb: "POST", DefaultSource: "local"},
+		"secrets":      {Endpoint: qhttp.AESecrets, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"removesecret": {Endpoint: qhttp.AERemoveSecret, HTTPVerb: "POST", DefaultSource: "local"},
 
 		// NOTE: Temporary undocumented command for using the static analyzer
-		"analyzetransform": {Endpoint: qhttp.DenyHTTP},
+		"analyzetransform": {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
 	}
 }
 
@@ -58,13 +72,28 @@ type ApplyParams struct {
 	// size of the output area that the results will display on
 	OutputWidth  int `json:"outputWidth"`
 	OutputHeight int `json:"outputHeight"`
+	// Record stores the run & the HTTP requests made by the transform, so the
+	// run can be replayed later. Recordings are stored once the run completes,
+	// so Record requires Wait
+	Record bool `json:"record"`
+	// Replay is the ID of a previous recorded run. HTTP requests made by the
+	// transform are served from responses recorded during that run instead of
+	// the network. When Ref & Transform are empty the run's dataset & latest
+	// transform are used
+	Replay string `json:"replay"`
 }
 
 // Validate returns an error if ApplyParams fields are in an invalid state
 func (p *ApplyParams) Validate() error {
-	if p.Ref == "" && p.Transform == nil {
+	if p.Ref == "" && p.Transform == nil && p.Replay == "" {
 		return fmt.Errorf("one or both of Reference, Transform are required")
 	}
+	if p.Record && p.Replay != "" {
+		return fmt.Errorf("can't record a replayed run")
+	}
+	if p.Record && !p.Wait {
+		return fmt.Errorf("recording a run requires waiting for it to finish")
+	}
 	return nil
 }
 
@@ -151,6 +180,46 @@ func (m AutomationMethods) RunInfo(ctx context.Context, p *RunInfoParams) (*run.
 	return nil, dispatchReturnError(got, err)
 }
 
+// FollowRunParams are parameters for following the events of a run
+type FollowRunParams struct {
+	ID string `json:"id"`
+	// LastEventID is the ID of the last event the caller received, only
+	// events after it are sent
+	LastEventID int `json:"lastEventID"`
+	// Events is called with each run event as it occurs
+	Events func(automation.RunEvent) error `json:"-"`
+}
+
+// Validate returns an error if FollowRunParams fields are in an invalid state
+func (p *FollowRunParams) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("follow run params: run id required")
+	}
+	if p.Events == nil {
+		return fmt.Errorf("follow run params: events function required")
+	}
+	return nil
+}
+
+// FollowRun sends the step lifecycle events & script output of a run to
+// p.Events, returning once the run finishes
+func (m AutomationMethods) FollowRun(ctx context.Context, p *FollowRunParams) error {
+	// run events are streamed as server-sent events, which the JSON RPC layer
+	// can't carry. Use the http client directly when connected to a node
+	if inst, ok := m.d.(*Instance); ok && inst.http != nil {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		ctx, err := inst.rpcContext(ctx)
+		if err != nil {
+			return err
+		}
+		return inst.http.FollowRun(ctx, p.ID, p.LastEventID, p.Events)
+	}
+	_, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "followrun"), p)
+	return dispatchReturnError(nil, err)
+}
+
 // CancelParams are parameters for the cancel command
 type CancelParams struct {
 	RunID string `json:"runID"`
@@ -170,6 +239,49 @@ func (m AutomationMethods) Cancel(ctx context.Context, p *CancelParams) error {
 	return dispatchReturnError(nil, err)
 }
 
+// SecretParams are parameters for managing the secrets of a workflow.
+// Secrets are injected into the transform each time the workflow runs
+type SecretParams struct {
+	// Ref is the dataset the workflow runs, eg: "me/dataset"
+	Ref        string `json:"ref"`
+	WorkflowID string `json:"workflowID"`
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+}
+
+// Validate returns an error if SecretParams fields are in an invalid state
+func (p *SecretParams) Validate() error {
+	if p.Ref == "" && p.WorkflowID == "" {
+		return fmt.Errorf("secret params: ref or workflow id required")
+	}
+	if p.Ref != "" && p.WorkflowID != "" {
+		return fmt.Errorf("secret params: only one of ref or workflow id needed")
+	}
+	return nil
+}
+
+// SetSecret stores a secret for a workflow, replacing any existing value
+func (m AutomationMethods) SetSecret(ctx context.Context, p *SecretParams) error {
+	_, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "setsecret"), p)
+	return dispatchReturnError(nil, err)
+}
+
+// Secrets lists the names of the secrets stored for a workflow. Secret values
+// are never returned
+func (m AutomationMethods) Secrets(ctx context.Context, p *SecretParams) ([]string, error) {
+	got, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "secrets"), p)
+	if res, ok := got.([]string); ok {
+		return res, err
+	}
+	return nil, dispatchReturnError(got, err)
+}
+
+// RemoveSecret deletes a secret stored for a workflow
+func (m AutomationMethods) RemoveSecret(ctx context.Context, p *SecretParams) error {
+	_, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "removesecret"), p)
+	return dispatchReturnError(nil, err)
+}
+
 // WorkflowParams are parameters for the Workflow command
 type WorkflowParams struct {
 	WorkflowID string `json:"workflowID"`
@@ -207,6 +319,10 @@ func (m AutomationMethods) Remove(ctx context.Context, p *WorkflowParams) error
 // AnalyzeTransformParams are parameters for the analyzetransform command
 type AnalyzeTransformParams struct {
 	ScriptFileName string `json:"scriptFileName"`
+	// AxiomsFile is the path to a JSON file of staticlark axioms that declare
+	// sources of sensitive data & sinks that may leak it. Empty uses the
+	// default axioms
+	AxiomsFile string `json:"axiomsFile"`
 }
 
 // Validate ...
@@ -246,6 +362,28 @@ func (automationImpl) Apply(scope scope, p *ApplyParams) (*ApplyResult, error) {
 			return nil, err
 		}
 	}
+
+	var fixtures *event.HTTPFixtures
+	if p.Replay != "" {
+		rs, err := scope.AutomationOrchestrator().RunInfo(scope.Context(), p.Replay)
+		if err != nil {
+			return nil, fmt.Errorf("loading run %q to replay: %w", p.Replay, err)
+		}
+		if rs.HTTPFixtures == nil {
+			return nil, fmt.Errorf("run %q has no recorded HTTP requests to replay", p.Replay)
+		}
+		fixtures = rs.HTTPFixtures
+
+		if ref.IsEmpty() && p.Transform == nil {
+			if rs.InitID == "" {
+				return nil, fmt.Errorf("run %q has no dataset to replay, a reference or transform is required", p.Replay)
+			}
+			ref = dsref.Ref{InitID: rs.InitID}
+			if _, err := scope.ResolveReference(scope.Context(), &ref); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if err := scope.Logbook().ProfileCanWrite(scope.Context(), ref.InitID, scope.ActiveProfile()); err != nil {
 		return nil, fmt.Errorf("profile %s can not write to dataset %s", scope.ActiveProfile().ID.Encode(), ref.InitID)
 	}
@@ -259,6 +397,17 @@ func (automationImpl) Apply(scope scope, p *ApplyParams) (*ApplyResult, error) {
 	if p.Transform != nil {
 		ds.Transform = p.Transform
 		ds.Transform.OpenScriptFile(scope.Context(), scope.Filesystem())
+	} else if p.Replay != "" {
+		// replay the transform of the latest version
+		prev, err := base.LoadRevs(scope.Context(), scope.Filesystem(), ref, []*dsref.Rev{{Field: "tf", Gen: 1}})
+		if err != nil {
+			return nil, fmt.Errorf("loading transform component from history: %w", err)
+		}
+		if prev.Transform == nil {
+			return nil, fmt.Errorf("dataset %s has no transform to replay", ref.Human())
+		}
+		ds.Transform = prev.Transform
+		ds.Transform.OpenScriptFile(scope.Context(), scope.Filesystem())
 	}
 
 	wf := &workflow.Workflow{
@@ -273,10 +422,17 @@ func (automationImpl) Apply(scope scope, p *ApplyParams) (*ApplyResult, error) {
 		ctx = scope.AppContext()
 	}
 
+	secrets, err := applySecrets(scope, ref, p.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
 	params := automation.WorkflowRunParams{
-		Secrets:      p.Secrets,
+		Secrets:      secrets,
 		OutputWidth:  p.OutputWidth,
 		OutputHeight: p.OutputHeight,
+		HTTPFixtures: fixtures,
+		Record:       p.Record,
 	}
 
 	runID, err := scope.AutomationOrchestrator().ApplyWorkflow(ctx, p.Wait, p.ScriptOutput, wf, ds, params)
@@ -397,6 +553,13 @@ func deploy(scope scope, p *DeployParams) {
 
 	go scope.sendEvent(event.ETAutomationDeploySaveWorkflowStart, ref, deployPayload)
 
+	if wf.Triggers, err = resolveDatasetTriggers(scope, wf.Triggers); err != nil {
+		log.Debugw("deploy resolve dataset triggers", "error", err)
+		deployPayload.Error = err.Error()
+		scope.sendEvent(event.ETAutomationDeployEnd, ref, deployPayload)
+		return
+	}
+
 	wf, err = scope.AutomationOrchestrator().SaveWorkflow(scope.Context(), wf)
 	if err != nil {
 		log.Debugw("deploy save workflow", "error", err)
@@ -428,6 +591,56 @@ func deploy(scope scope, p *DeployParams) {
 	rollback = false
 }
 
+// resolveDatasetTriggers fills in the upstream InitID of any dataset triggers
+// that only specify a dataset reference
+func resolveDatasetTriggers(scope scope, triggers []map[string]interface{}) ([]map[string]interface{}, error) {
+	resolved := make([]map[string]interface{}, 0, len(triggers))
+	for _, opt := range triggers {
+		refStr, hasRef := opt["ref"].(string)
+		initID, _ := opt["initID"].(string)
+		if opt["type"] != trigger.DatasetType || !hasRef || initID != "" {
+			resolved = append(resolved, opt)
+			continue
+		}
+		ref, _, err := scope.ParseAndResolveRef(scope.Context(), refStr)
+		if err != nil {
+			return nil, fmt.Errorf("resolving dataset trigger %q: %w", refStr, err)
+		}
+		t := map[string]interface{}{}
+		for k, v := range opt {
+			t[k] = v
+		}
+		t["initID"] = ref.InitID
+		resolved = append(resolved, t)
+	}
+	return resolved, nil
+}
+
+// applySecrets merges the stored secrets of the workflow for a dataset with
+// secrets passed inline, inline secrets taking precedence
+func applySecrets(scope scope, ref dsref.Ref, inline map[string]string) (map[string]string, error) {
+	if ref.InitID == "" {
+		return inline, nil
+	}
+	wf, err := scope.AutomationOrchestrator().GetWorkflowByInitID(scope.Context(), ref.InitID)
+	if errors.Is(err, workflow.ErrNotFound) {
+		return inline, nil
+	} else if err != nil {
+		return nil, err
+	}
+	secrets, err := scope.AutomationOrchestrator().WorkflowSecrets(scope.Context(), wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return inline, nil
+	}
+	for key, val := range inline {
+		secrets[key] = val
+	}
+	return secrets, nil
+}
+
 // Run manually runs a workflow
 func (automationImpl) Run(scope scope, p *RunParams) (string, error) {
 	if p.WorkflowID == "" {
@@ -463,6 +676,11 @@ func (automationImpl) RunInfo(scope scope, p *RunInfoParams) (*run.State, error)
 	return scope.AutomationOrchestrator().RunInfo(scope.Context(), p.ID)
 }
 
+// FollowRun sends the events of a run as they occur
+func (automationImpl) FollowRun(scope scope, p *FollowRunParams) error {
+	return scope.AutomationOrchestrator().FollowRun(scope.Context(), p.ID, scope.ActiveProfile().ID, p.LastEventID, p.Events)
+}
+
 // Cancel cancels a run
 func (automationImpl) Cancel(scope scope, p *CancelParams) error {
 	scope.AutomationOrchestrator().CancelRun(scope.Context(), p.RunID)
@@ -513,6 +731,71 @@ func (automationImpl) Remove(scope scope, p *WorkflowParams) error {
 	return scope.AutomationOrchestrator().RemoveWorkflow(scope.Context(), workflow.ID(p.WorkflowID))
 }
 
+// SetSecret stores a secret for a workflow
+func (automationImpl) SetSecret(scope scope, p *SecretParams) error {
+	if p.Name == "" {
+		return fmt.Errorf("secret name is required")
+	}
+	if p.Value == "" {
+		return fmt.Errorf("secret value is required")
+	}
+	wf, err := secretsWorkflow(scope, p)
+	if err != nil {
+		return err
+	}
+	return scope.AutomationOrchestrator().SetWorkflowSecret(scope.Context(), wf.ID, p.Name, p.Value)
+}
+
+// Secrets lists the names of the secrets stored for a workflow
+func (automationImpl) Secrets(scope scope, p *SecretParams) ([]string, error) {
+	wf, err := secretsWorkflow(scope, p)
+	if err != nil {
+		return nil, err
+	}
+	return scope.AutomationOrchestrator().WorkflowSecretNames(scope.Context(), wf.ID)
+}
+
+// RemoveSecret deletes a secret stored for a workflow
+func (automationImpl) RemoveSecret(scope scope, p *SecretParams) error {
+	if p.Name == "" {
+		return fmt.Errorf("secret name is required")
+	}
+	wf, err := secretsWorkflow(scope, p)
+	if err != nil {
+		return err
+	}
+	return scope.AutomationOrchestrator().RemoveWorkflowSecret(scope.Context(), wf.ID, p.Name)
+}
+
+// secretsWorkflow fetches the workflow SecretParams refer to, erroring if the
+// active profile can't write to the workflow's dataset
+func secretsWorkflow(scope scope, p *SecretParams) (*workflow.Workflow, error) {
+	ctx := scope.Context()
+	var (
+		wf  *workflow.Workflow
+		err error
+	)
+	if p.WorkflowID != "" {
+		wf, err = scope.AutomationOrchestrator().GetWorkflow(ctx, workflow.ID(p.WorkflowID))
+	} else {
+		ref, perr := dsref.Parse(p.Ref)
+		if perr != nil {
+			return nil, perr
+		}
+		if _, err := scope.ResolveReference(ctx, &ref); err != nil {
+			return nil, err
+		}
+		wf, err = scope.AutomationOrchestrator().GetWorkflowByInitID(ctx, ref.InitID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.Logbook().ProfileCanWrite(ctx, wf.InitID, scope.ActiveProfile()); err != nil {
+		return nil, fmt.Errorf("profile %s can not write to dataset %s", scope.ActiveProfile().ID.Encode(), wf.InitID)
+	}
+	return wf, nil
+}
+
 func (inst *Instance) run(ctx context.Context, streams ioes.IOStreams, w *workflow.Workflow, runID string, params automation.WorkflowRunParams) error {
 	scope, err := newScopeFromWorkflow(ctx, inst, w)
 	if err != nil {
@@ -531,7 +814,8 @@ func (inst *Instance) run(ctx context.Context, streams ioes.IOStreams, w *workfl
 				RunID: runID,
 			},
 		},
-		Apply: true,
+		Apply:   true,
+		Secrets: params.Secrets,
 	}
 	dImpl := &datasetImpl{}
 	_, err = dImpl.Save(scope, p)
@@ -549,17 +833,40 @@ func (inst *Instance) apply(ctx context.Context, wait bool, runID string, wf *wo
 		OutputHeight: params.OutputHeight,
 	}
 
-	transformer := transform.NewTransformer(ctx, scope.Filesystem(), scope.Loader(), scope.Bus(), sizeInfo)
-	return transformer.Apply(scope.Context(), ds, runID, wait, params.Secrets)
+	limits, err := transformLimits(scope.Config())
+	if err != nil {
+		return err
+	}
+
+	transformer := transform.NewTransformer(ctx, scope.Filesystem(), scope.Loader(), scope.Logbook(), scope.Bus(), sizeInfo)
+	transformer.SetLimits(limits)
+	if params.HTTPFixtures != nil {
+		transformer.ReplayHTTP(params.HTTPFixtures)
+	} else if params.Record {
+		transformer.RecordHTTP()
+	}
+	err = transformer.Apply(scope.Context(), ds, runID, wait, params.Secrets)
+	// store recordings of failed runs too, they're often the ones worth replaying
+	if fixtures := transformer.HTTPFixtures(); fixtures != nil && wait {
+		if ferr := inst.automation.SetRunHTTPFixtures(ctx, runID, fixtures); ferr != nil {
+			log.Debugw("storing run HTTP fixtures", "runID", runID, "err", ferr)
+		}
+	}
+	return err
 }
 
 // AnalyzeTransform runs analysis on a transform script
 func (automationImpl) AnalyzeTransform(scope scope, p *AnalyzeTransformParams) (*AnalyzeTransformResult, error) {
-	ctx := scope.Context()
-	_ = ctx
+	var axioms *staticlark.Axioms
+	if p.AxiomsFile != "" {
+		var err error
+		if axioms, err = staticlark.LoadAxioms(p.AxiomsFile); err != nil {
+			return nil, err
+		}
+	}
 
 	// Perform static analysis and show the results
-	diagnostics, err := staticlark.AnalyzeFile(p.ScriptFileName)
+	diagnostics, err := staticlark.AnalyzeFile(p.ScriptFileName, axioms)
 	if err != nil {
 		return nil, err
 	}
@@ -585,3 +892,161 @@ func (r *runner) RunEphemeral(ctx context.Context, runID string, wf *workflow.Wo
 func (r *runner) RunAndCommit(ctx context.Context, runID string, wf *workflow.Workflow, streams ioes.IOStreams, params automation.WorkflowRunParams) error {
 	return r.owner.run(ctx, streams, wf, runID, params)
 }
+
+// transformAnalyzer checks the transform of a workflow's dataset for leaks,
+// used by the automation orchestrator via dependency injection
+type transformAnalyzer struct {
+	owner  *Instance
+	axioms *staticlark.Axioms
+}
+
+var _ automation.TransformAnalyzer = (*transformAnalyzer)(nil)
+
+// AnalyzeWorkflowTransform runs static analysis on the transform script of
+// the latest version of a workflow's dataset. The dataset is resolved & read
+// from the local repo only, analysis never pulls from the network
+func (a *transformAnalyzer) AnalyzeWorkflowTransform(ctx context.Context, wf *workflow.Workflow) ([]staticlark.Diagnostic, error) {
+	if wf.InitID == "" {
+		return nil, nil
+	}
+	scope, err := newScopeFromWorkflow(ctx, a.owner, wf)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := scope.LocalResolver()
+	if err != nil {
+		return nil, err
+	}
+	ref := &dsref.Ref{InitID: wf.InitID}
+	if _, err := resolver.ResolveRef(ctx, ref); err != nil {
+		return nil, err
+	}
+	if ref.Path == "" {
+		return nil, nil
+	}
+	ds, err := dsfs.LoadDataset(ctx, scope.Repo().Filesystem(), ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Transform == nil {
+		return nil, nil
+	}
+	if len(ds.Transform.Steps) == 0 && len(ds.Transform.ScriptBytes) == 0 {
+		if err := ds.Transform.OpenScriptFile(ctx, scope.Repo().Filesystem()); err != nil {
+			return nil, err
+		}
+	}
+	script, err := transformScript(ds.Transform)
+	if err != nil {
+		return nil, err
+	}
+	return staticlark.AnalyzeScript("transform.star", script, a.axioms)
+}
+
+// transformScript returns the starlark source of a transform, joining the
+// scripts of all starlark steps
+func transformScript(t *dataset.Transform) ([]byte, error) {
+	if len(t.Steps) == 0 {
+		if len(t.ScriptBytes) > 0 {
+			return t.ScriptBytes, nil
+		}
+		if t.ScriptFile() == nil {
+			return nil, nil
+		}
+		return ioutil.ReadAll(t.ScriptFile())
+	}
+	buf := &bytes.Buffer{}
+	for _, step := range t.Steps {
+		if step.Syntax != transform.SyntaxStarlark {
+			continue
+		}
+		if script, ok := step.Script.(string); ok {
+			buf.WriteString(script)
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// setTransformAnalyzerOptions configures the orchestrator to refuse workflows
+// with transforms that may leak secrets
+func setTransformAnalyzerOptions(opts *automation.OrchestratorOptions, cfg *config.Automation, inst *Instance) error {
+	if !cfg.RefuseLeakyTransforms {
+		return nil
+	}
+	var axioms *staticlark.Axioms
+	if cfg.TransformAxiomsFile != "" {
+		var err error
+		if axioms, err = staticlark.LoadAxioms(cfg.TransformAxiomsFile); err != nil {
+			return err
+		}
+	}
+	opts.TransformAnalyzer = &transformAnalyzer{owner: inst, axioms: axioms}
+	return nil
+}
+
+// transformLimits reads transform resource limits from configuration. A nil
+// configuration has no limits
+func transformLimits(cfg *config.Config) (startf.Limits, error) {
+	if cfg == nil || cfg.Automation == nil {
+		return startf.Limits{}, nil
+	}
+	a := cfg.Automation
+	maxMemory, err := a.TransformMaxMemoryBytes()
+	if err != nil {
+		return startf.Limits{}, err
+	}
+	timeout, err := a.TransformTimeoutDuration()
+	if err != nil {
+		return startf.Limits{}, err
+	}
+	maxNetwork, err := a.TransformMaxNetworkBytes()
+	if err != nil {
+		return startf.Limits{}, err
+	}
+	return startf.Limits{
+		MaxSteps:        a.TransformMaxSteps,
+		MaxAllocBytes:   maxMemory,
+		MaxDuration:     timeout,
+		MaxNetworkBytes: maxNetwork,
+	}, nil
+}
+
+// newSecretStore creates the store for workflow secrets, encrypted with the
+// private key of the repo owner. Repos without a path keep secrets in memory
+func newSecretStore(ctx context.Context, inst *Instance) (secrets.Store, error) {
+	pro := inst.profiles.Owner(ctx)
+	pk := inst.keystore.PrivKey(ctx, pro.GetKeyID())
+	if pk == nil {
+		log.Debugw("no private key for repo owner, workflow secrets are disabled", "profileID", pro.ID.Encode())
+		return nil, nil
+	}
+	if inst.repoPath == "" {
+		return secrets.NewMemStore(pk)
+	}
+	return secrets.NewFileStore(inst.repoPath, pk)
+}
+
+// setRunRetentionOptions configures run store retention & compaction from the
+// automation configuration
+func setRunRetentionOptions(opts *automation.OrchestratorOptions, cfg *config.Automation) error {
+	maxAge, err := cfg.RunStoreMaxAgeDuration()
+	if err != nil {
+		return err
+	}
+	maxOutput, err := cfg.RunOutputMaxSizeBytes()
+	if err != nil {
+		return err
+	}
+	interval, err := cfg.RunStoreCompactIntervalDuration()
+	if err != nil {
+		return err
+	}
+	opts.RunRetention = run.RetentionPolicy{
+		MaxRunsPerWorkflow: cfg.RunStoreMaxRuns,
+		MaxAge:             maxAge,
+		MaxOutputSize:      maxOutput,
+	}
+	opts.RunCompactInterval = interval
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/automation"
 	"github.com/qri-io/qri/automation/run"
 	"github.com/qri-io/qri/automation/workflow"
 	"github.com/qri-io/qri/event"
@@ -84,6 +85,76 @@ dataset.commit(ds)
 	}
 }
 
+func TestFollowRun(t *testing.T) {
+	tr := newTestRunner(t)
+	defer tr.Delete()
+
+	res, err := tr.Instance.Automation().Apply(tr.Ctx, &ApplyParams{
+		Wait: true,
+		Transform: &dataset.Transform{
+			Text: `
+print("hello")
+ds = dataset.latest()
+dataset.commit(ds)
+`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []automation.RunEvent{}
+	p := &FollowRunParams{
+		ID: res.RunID,
+		Events: func(e automation.RunEvent) error {
+			got = append(got, e)
+			return nil
+		},
+	}
+	if err := tr.Instance.Automation().FollowRun(tr.Ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) < 3 {
+		t.Fatalf("expected at least 3 run events, got %d", len(got))
+	}
+	if got[0].Event.Type != event.ETTransformStart {
+		t.Errorf("expected first event to be %q, got %q", event.ETTransformStart, got[0].Event.Type)
+	}
+	if last := got[len(got)-1].Event.Type; last != event.ETTransformStop {
+		t.Errorf("expected last event to be %q, got %q", event.ETTransformStop, last)
+	}
+	printed := false
+	for _, e := range got {
+		if msg, ok := e.Event.Payload.(event.TransformMessage); ok && e.Event.Type == event.ETTransformPrint && msg.Msg == "hello" {
+			printed = true
+		}
+	}
+	if !printed {
+		t.Errorf("expected run events to include script output")
+	}
+
+	resumed := 0
+	p = &FollowRunParams{
+		ID:          res.RunID,
+		LastEventID: got[len(got)-2].ID,
+		Events: func(e automation.RunEvent) error {
+			resumed++
+			return nil
+		},
+	}
+	if err := tr.Instance.Automation().FollowRun(tr.Ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if resumed != 1 {
+		t.Errorf("expected 1 event resuming after the second to last event, got %d", resumed)
+	}
+
+	p = &FollowRunParams{ID: "not_a_run", Events: func(automation.RunEvent) error { return nil }}
+	if err := tr.Instance.Automation().FollowRun(tr.Ctx, p); !errors.Is(err, automation.ErrRunEventsNotFound) {
+		t.Errorf("expected following an unknown run to return automation.ErrRunEventsNotFound, got: %v", err)
+	}
+}
+
 func TestApplyTransformValidationFailure(t *testing.T) {
 	tr := newTestRunner(t)
 	defer tr.Delete()
@@ -254,6 +325,21 @@ dataset.commit(ds)
 	}
 }
 
+func TestApplyParamsValidate(t *testing.T) {
+	p := &ApplyParams{Ref: "me/ds", Record: true}
+	if err := p.Validate(); err == nil {
+		t.Errorf("expected validation error for `ApplyParams` that record without waiting, got nil")
+	}
+	p.Wait = true
+	if err := p.Validate(); err != nil {
+		t.Errorf("expected recording while waiting to be valid, got: %s", err)
+	}
+	p.Replay = "run_id"
+	if err := p.Validate(); err == nil {
+		t.Errorf("expected validation error for `ApplyParams` that record a replay, got nil")
+	}
+}
+
 func TestRunParamsValidate(t *testing.T) {
 	p := &RunParams{}
 	if err := p.Validate(); err == nil {
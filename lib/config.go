@@ -26,8 +26,8 @@ func (m ConfigMethods) Name() string {
 func (m ConfigMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
 		// config methods are not allowed over HTTP nor RPC
-		"getconfig":     {Endpoint: qhttp.DenyHTTP},
-		"getconfigkeys": {Endpoint: qhttp.DenyHTTP},
+		"getconfig":     {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
+		"getconfigkeys": {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
 		"setconfig":     {Endpoint: qhttp.DenyHTTP},
 	}
 }
@@ -13,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	"github.com/qri-io/dag"
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/detect"
@@ -21,17 +23,20 @@ import (
 	"github.com/qri-io/jsonschema"
 	"github.com/qri-io/qfs"
 	"github.com/qri-io/qfs/localfs"
+	"github.com/qri-io/qri/auth/key"
 	"github.com/qri-io/qri/automation/run"
 	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/base/archive"
 	"github.com/qri-io/qri/base/dsfs"
 	"github.com/qri-io/qri/base/fill"
+	"github.com/qri-io/qri/base/linkfile"
 	"github.com/qri-io/qri/base/params"
 	"github.com/qri-io/qri/dsref"
 	qrierr "github.com/qri-io/qri/errors"
 	"github.com/qri-io/qri/event"
 	qhttp "github.com/qri-io/qri/lib/http"
 	"github.com/qri-io/qri/logbook"
+	"github.com/qri-io/qri/profile"
 	"github.com/qri-io/qri/remote"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/transform"
@@ -52,21 +57,23 @@ func (m DatasetMethods) Name() string {
 // Attributes defines attributes for each method
 func (m DatasetMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"get":             {Endpoint: qhttp.AEGet, HTTPVerb: "POST"},
-		"getcsv":          {Endpoint: qhttp.DenyHTTP}, // getcsv is not part of the json api, but is handled in a separate `GetBodyCSVHandler` function
-		"getzip":          {Endpoint: qhttp.DenyHTTP}, // getzip is not part of the json api, but is handled is a separate `GetHandler` function
-		"activity":        {Endpoint: qhttp.AEActivity, HTTPVerb: "POST"},
+		"get":             {Endpoint: qhttp.AEGet, HTTPVerb: "POST", ReadOnly: true},
+		"getcsv":          {Endpoint: qhttp.DenyHTTP, ReadOnly: true}, // getcsv is not part of the json api, but is handled in a separate `GetBodyCSVHandler` function
+		"getzip":          {Endpoint: qhttp.DenyHTTP, ReadOnly: true}, // getzip is not part of the json api, but is handled is a separate `GetHandler` function
+		"getparquet":      {Endpoint: qhttp.DenyHTTP, ReadOnly: true}, // getparquet returns binary data & is not part of the json api
+		"activity":        {Endpoint: qhttp.AEActivity, HTTPVerb: "POST", ReadOnly: true},
 		"rename":          {Endpoint: qhttp.AERename, HTTPVerb: "POST", DefaultSource: "local"},
 		"save":            {Endpoint: qhttp.AESave, HTTPVerb: "POST"},
 		"pull":            {Endpoint: qhttp.AEPull, HTTPVerb: "POST", DefaultSource: "network"},
 		"push":            {Endpoint: qhttp.AEPush, HTTPVerb: "POST", DefaultSource: "local"},
-		"render":          {Endpoint: qhttp.AERender, HTTPVerb: "POST"},
+		"render":          {Endpoint: qhttp.AERender, HTTPVerb: "POST", ReadOnly: true},
 		"remove":          {Endpoint: qhttp.AERemove, HTTPVerb: "POST", DefaultSource: "local"},
-		"validate":        {Endpoint: qhttp.AEValidate, HTTPVerb: "POST", DefaultSource: "local"},
-		"manifest":        {Endpoint: qhttp.AEManifest, HTTPVerb: "POST", DefaultSource: "local"},
-		"manifestmissing": {Endpoint: qhttp.AEManifestMissing, HTTPVerb: "POST", DefaultSource: "local"},
-		"daginfo":         {Endpoint: qhttp.AEDAGInfo, HTTPVerb: "POST", DefaultSource: "local"},
-		"whatchanged":     {Endpoint: qhttp.AEWhatChanged, HTTPVerb: "POST", DefaultSource: "local"},
+		"validate":        {Endpoint: qhttp.AEValidate, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"verify":          {Endpoint: qhttp.AEVerify, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"manifest":        {Endpoint: qhttp.AEManifest, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"manifestmissing": {Endpoint: qhttp.AEManifestMissing, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"daginfo":         {Endpoint: qhttp.AEDAGInfo, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
+		"whatchanged":     {Endpoint: qhttp.AEWhatChanged, HTTPVerb: "POST", DefaultSource: "local", ReadOnly: true},
 	}
 }
 
@@ -160,6 +167,15 @@ func (m DatasetMethods) GetCSV(ctx context.Context, p *GetParams) ([]byte, error
 	return nil, dispatchReturnError(got, err)
 }
 
+// GetParquet fetches the body as an Apache Parquet file, it recognizes Limit, Offset, and All list params
+func (m DatasetMethods) GetParquet(ctx context.Context, p *GetParams) ([]byte, error) {
+	got, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "getparquet"), p)
+	if res, ok := got.([]byte); ok {
+		return res, err
+	}
+	return nil, dispatchReturnError(got, err)
+}
+
 // GetZipResults is returned by `GetZip`
 // It contains a byte slice of the compressed data as well as a generated name based on the dataset
 type GetZipResults struct {
@@ -260,6 +276,10 @@ type SaveParams struct {
 	BodyPath string `json:"bodyPath" qri:"fspath"`
 	// absolute path or URL to the list of dataset files or components to load
 	FilePaths []string `json:"filePaths" qri:"fspath"`
+	// linked working directory to save component files from. The files replace
+	// the previous version entirely, and the directory is re-linked to the new
+	// version. Not available over the JSON API
+	WorkingDir string `json:"workingDir" qri:"fspath"`
 	// secrets for transform execution. Should be a set of key: value pairs
 	Secrets map[string]string `json:"secrets"`
 	// optional writer to have transform script record standard output to
@@ -287,6 +307,9 @@ type SaveParams struct {
 	ShouldRender bool `json:"shouldRender"`
 	// new dataset only, don't create a commit on an existing dataset, name will be unused
 	NewName bool `json:"newName"`
+	// refuse to save a body that violates the table constraints declared in
+	// the structure schema
+	EnforceConstraints bool `json:"enforceConstraints"`
 }
 
 // SetNonZeroDefaults sets basic save path params to defaults
@@ -357,6 +380,8 @@ type PullParams struct {
 	Ref string `json:"ref"`
 	// only fetch logbook data
 	LogsOnly bool `json:"logsOnly"`
+	// skip verifying the pulled version against the public key of its author
+	Insecure bool `json:"insecure"`
 }
 
 // Pull downloads and stores an existing dataset to a peer's repository via
@@ -412,6 +437,22 @@ func (m DatasetMethods) Validate(ctx context.Context, p *ValidateParams) (*Valid
 	return nil, dispatchReturnError(got, err)
 }
 
+// VerifyParams defines parameters for verifying dataset history
+type VerifyParams struct {
+	Ref string `json:"ref"`
+}
+
+// Verify checks the logbook & commit signature of every locally stored version
+// of a dataset against the public key of the dataset author. Verification
+// problems are recorded in the result, not returned as an error
+func (m DatasetMethods) Verify(ctx context.Context, p *VerifyParams) (*base.Verification, error) {
+	got, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "verify"), p)
+	if res, ok := got.(*base.Verification); ok {
+		return res, err
+	}
+	return nil, dispatchReturnError(got, err)
+}
+
 // ManifestParams encapsulates parameters to the manifest command
 type ManifestParams struct {
 	Ref string `json:"ref"`
@@ -505,6 +546,10 @@ func (m DatasetMethods) Render(ctx context.Context, p *RenderParams) ([]byte, er
 // WhatChangedParams are parameters for the whatchanged command
 type WhatChangedParams struct {
 	Ref string `json:"ref"`
+	// WorkingDir compares the files in a linked working directory to the
+	// version it was checked out from, instead of a version to the one before it.
+	// Not available over the JSON API
+	WorkingDir string `json:"workingDir" qri:"fspath"`
 }
 
 // WhatChanged gets what components have changed at a version in history
@@ -641,6 +686,24 @@ func (datasetImpl) GetCSV(scope scope, p *GetParams) ([]byte, error) {
 	return bodyBytes, nil
 }
 
+func (datasetImpl) GetParquet(scope scope, p *GetParams) ([]byte, error) {
+	_, ds, err := openAndLoadDataset(scope, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureValidGetSize(ds, p.Limit, p.All); err != nil {
+		return nil, err
+	}
+
+	bodyBytes, err := base.ReadBodyBytes(ds, base.ParquetFormat, nil, p.Limit, p.Offset, p.All)
+	if err != nil {
+		log.Debugf("lib.GetParquet, base.ReadBodyBytes %q failed, error: %s", ds, err)
+		return nil, err
+	}
+	return bodyBytes, nil
+}
+
 func (datasetImpl) GetZip(scope scope, p *GetParams) (*GetZipResults, error) {
 	ref, ds, err := openAndLoadDataset(scope, p)
 	if err != nil {
@@ -784,6 +847,9 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 	if p.Private {
 		return nil, fmt.Errorf("option to make dataset private not yet implemented, refer to https://github.com/qri-io/qri/issues/291 for updates")
 	}
+	if p.WorkingDir != "" && isAPICall(scope.Context()) {
+		return nil, ErrWorkingDirectoryOverAPI
+	}
 
 	// If the dscache doesn't exist yet, it will only be created if the appropriate flag enables it.
 	if scope.UseDscache() {
@@ -814,6 +880,23 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 		ds = dsf
 	}
 
+	var link dsref.Ref
+	if p.WorkingDir != "" {
+		var err error
+		if link, err = ReadLink(p.WorkingDir); err != nil {
+			return nil, err
+		}
+		wds, err := base.ReadWorkingDirectory(scope.Context(), scope.Filesystem(), p.WorkingDir)
+		if err != nil {
+			return nil, err
+		}
+		wds.Assign(ds)
+		ds = wds
+		if p.Ref == "" {
+			p.Ref = link.Human()
+		}
+	}
+
 	manualChanges := make(map[string]struct{})
 	for comp := range ds.PathMap("dataset") {
 		manualChanges[comp] = struct{}{}
@@ -849,6 +932,15 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 		}
 	}()
 
+	if p.WorkingDir != "" {
+		if ref.Username != link.Username || ref.Name != link.Name {
+			return nil, fmt.Errorf("working directory is linked to %s, not %s", link.Human(), ref.Human())
+		}
+		if ref.Path != link.Path {
+			return nil, qrierr.New(ErrWorkingDirectoryOutOfDate, fmt.Sprintf("working directory was checked out from %s, but the latest version of %s is %s", link.Path, ref.Human(), ref.Path))
+		}
+	}
+
 	ds.Name = ref.Name
 	ds.Peername = ref.Username
 
@@ -914,9 +1006,15 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 		// TODO(dustmop): Get actual size info from the proper place
 		sizeInfo := transform.SizeInfo{}
 
+		limits, err := transformLimits(scope.Config())
+		if err != nil {
+			return nil, err
+		}
+
 		// apply the transform
 		shouldWait := true
-		transformer := transform.NewTransformer(scope.AppContext(), scope.Filesystem(), scope.Loader(), scope.Bus(), sizeInfo)
+		transformer := transform.NewTransformer(scope.AppContext(), scope.Filesystem(), scope.Loader(), scope.Logbook(), scope.Bus(), sizeInfo)
+		transformer.SetLimits(limits)
 		if err := transformer.Commit(scope.Context(), ref.InitID, ds, runID, shouldWait, secrets); err != nil {
 			log.Errorw("transform run error", "err", err.Error())
 			runState.Message = err.Error()
@@ -949,13 +1047,15 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 
 	switches := base.SaveSwitches{
 		FileHint:            fileHint,
-		Replace:             p.Replace,
+		Replace:             p.Replace || p.WorkingDir != "",
 		Pin:                 true,
 		ConvertFormatToPrev: p.ConvertFormatToPrev,
 		ForceIfNoChanges:    p.Force,
 		ShouldRender:        p.ShouldRender,
 		NewName:             p.NewName,
 		Drop:                p.Drop,
+		EnforceConstraints:  p.EnforceConstraints,
+		ConstraintLoader:    scope.Loader(),
 	}
 	savedDs, err := base.SaveDataset(scope.Context(), scope.Repo(), writeDest, author, ref.InitID, ref.Path, ds, runState, switches)
 	if err != nil {
@@ -977,6 +1077,13 @@ func (datasetImpl) Save(scope scope, p *SaveParams) (*dataset.Dataset, error) {
 	success = true
 	*res = *savedDs
 
+	if p.WorkingDir != "" {
+		link.Path = savedDs.Path
+		if _, err := linkfile.WriteHiddenInDir(p.WorkingDir, link); err != nil {
+			return nil, fmt.Errorf("linking working directory to saved version: %w", err)
+		}
+	}
+
 	return res, nil
 }
 
@@ -1111,8 +1218,10 @@ func (datasetImpl) Pull(scope scope, p *PullParams) (*dataset.Dataset, error) {
 	}
 	log.Infof("pulling dataset from location: %s", location)
 
-	ds, err := scope.RemoteClient().PullDataset(scope.Context(), &ref, location)
-	if err != nil {
+	ds, err := scope.inst.pullVerified(scope.Context(), &ref, location, p.Insecure)
+	if errors.Is(err, base.ErrVerificationFailed) || errors.Is(err, ErrUnknownAuthorKey) {
+		return nil, fmt.Errorf("%w. use --insecure to pull anyway", err)
+	} else if err != nil {
 		log.Debugf("pulling dataset: %s", err)
 		return nil, err
 	}
@@ -1121,6 +1230,90 @@ func (datasetImpl) Pull(scope scope, p *PullParams) (*dataset.Dataset, error) {
 	return res, nil
 }
 
+// pullVerified pulls a dataset version & checks it against the public key of
+// its author, unless insecure is set. Versions that fail verification,
+// including versions whose author key is unknown, are discarded
+func (inst *Instance) pullVerified(ctx context.Context, ref *dsref.Ref, location string, insecure bool) (*dataset.Dataset, error) {
+	prev, err := repo.GetVersionInfoShim(inst.repo, *ref)
+	if err != nil {
+		prev = nil
+	}
+
+	ds, err := inst.remoteClient.PullDataset(ctx, ref, location)
+	if err != nil {
+		return nil, err
+	}
+	if insecure {
+		return ds, nil
+	}
+
+	pub, err := authorPubKey(ctx, inst.keystore, inst.profiles, ref.ProfileID)
+	if err == nil {
+		err = base.VerifyVersion(ctx, inst.repo, *ref, pub)
+	}
+	if err != nil {
+		log.Warnw("discarding pulled dataset that failed verification", "ref", ref.String(), "err", err)
+		if derr := inst.discardPulledVersion(ctx, *ref, prev); derr != nil {
+			log.Errorw("discarding pulled dataset", "ref", ref.String(), "err", derr)
+		}
+		return nil, err
+	}
+	return ds, nil
+}
+
+// discardPulledVersion removes a pulled dataset version. prev is the version
+// of the dataset stored before pulling, nil if there was none, in which case
+// the whole dataset is removed. Otherwise the reference is restored to prev
+func (inst *Instance) discardPulledVersion(ctx context.Context, ref dsref.Ref, prev *dsref.VersionInfo) error {
+	if prev == nil {
+		history := []dsref.VersionInfo{{Path: ref.Path}}
+		_, err := base.RemoveEntireDataset(ctx, inst.repo, inst.profiles.Owner(ctx), ref, history)
+		return err
+	}
+	if prev.Path == ref.Path {
+		return nil
+	}
+	if err := repo.PutVersionInfoShim(ctx, inst.repo, prev); err != nil {
+		return err
+	}
+	// merged logs can't be unmerged, the log stays & records the discarded
+	// version as the latest
+	return inst.repo.Filesystem().Delete(ctx, ref.Path)
+}
+
+// ErrUnknownAuthorKey indicates the public key of a dataset author can't be
+// found, so the dataset can't be verified
+var ErrUnknownAuthorKey = fmt.Errorf("unknown author public key")
+
+// authorPubKey finds the public key of a profile, checking profile data, then
+// the key store, then the profile ID itself, which embeds the public key for
+// small key types like Ed25519
+func authorPubKey(ctx context.Context, ks key.Store, profiles profile.Store, profileID string) (crypto.PubKey, error) {
+	if profileID == "" {
+		return nil, fmt.Errorf("%w: reference has no profile ID", ErrUnknownAuthorKey)
+	}
+	id, err := profile.IDB58Decode(profileID)
+	if err != nil {
+		return nil, err
+	}
+	keyID := key.ID(id)
+	if pro, err := profiles.GetProfile(ctx, id); err == nil {
+		if pro.PubKey != nil {
+			return pro.PubKey, nil
+		}
+		keyID = pro.GetKeyID()
+	}
+	if ks != nil {
+		if pub := ks.PubKey(ctx, keyID); pub != nil {
+			return pub, nil
+		}
+	}
+	if pub, err := peer.ID(id).ExtractPublicKey(); err == nil && pub != nil {
+		return pub, nil
+	}
+	return nil, fmt.Errorf("%w for profile %s", ErrUnknownAuthorKey, profileID)
+}
+
 // Push posts a dataset version to a remote
 func (datasetImpl) Push(scope scope, p *PushParams) (*dsref.Ref, error) {
 	if scope.SourceName() != "local" {
@@ -1150,6 +1343,19 @@ func (datasetImpl) Push(scope scope, p *PushParams) (*dsref.Ref, error) {
 	return &ref, nil
 }
 
+// Verify checks the history of a dataset against the public key of its author
+func (datasetImpl) Verify(scope scope, p *VerifyParams) (*base.Verification, error) {
+	ref, _, err := scope.ParseAndResolveRef(scope.Context(), p.Ref)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := authorPubKey(scope.Context(), scope.KeyStore(), scope.Profiles(), ref.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	return base.VerifyDataset(scope.Context(), scope.Repo(), ref, pub)
+}
+
 // Validate gives a dataset of errors and issues for a given dataset
 func (datasetImpl) Validate(scope scope, p *ValidateParams) (*ValidateResponse, error) {
 	res := &ValidateResponse{}
@@ -1259,7 +1465,43 @@ func (datasetImpl) Validate(scope scope, p *ValidateParams) (*ValidateResponse,
 		}
 	}
 
+	constraints, err := base.ParseConstraints(st)
+	if err != nil {
+		return nil, err
+	}
+	// table constraints are checked over a copy of the body, teed off while
+	// the schema validation pass reads it
+	type constraintsResult struct {
+		errs []jsonschema.KeyError
+		err  error
+	}
+	var (
+		constraintsPipe *io.PipeWriter
+		constraintsDone chan constraintsResult
+	)
+	if !constraints.IsEmpty() && body != nil {
+		pr, pw := io.Pipe()
+		constraintBody := qfs.NewMemfileReader(body.FileName(), pr)
+		body = qfs.NewMemfileReader(body.FileName(), io.TeeReader(body, pw))
+		constraintsPipe = pw
+		constraintsDone = make(chan constraintsResult, 1)
+		go func() {
+			errs, err := base.ValidateConstraints(scope.Context(), scope.Loader(), constraintBody, st)
+			// drain the pipe so an early return doesn't block the schema pass
+			io.Copy(ioutil.Discard, pr)
+			constraintsDone <- constraintsResult{errs: errs, err: err}
+		}()
+	}
+
 	valerrs, err := base.Validate(scope.Context(), scope.Repo(), body, st)
+	if constraintsPipe != nil {
+		constraintsPipe.CloseWithError(err)
+		cr := <-constraintsDone
+		if err == nil && cr.err != nil {
+			return nil, cr.err
+		}
+		valerrs = append(valerrs, cr.errs...)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1360,6 +1602,17 @@ func (datasetImpl) Render(scope scope, p *RenderParams) (res []byte, err error)
 
 // WhatChanged gets what components changed for the given version
 func (datasetImpl) WhatChanged(scope scope, p *WhatChangedParams) ([]base.StatusItem, error) {
+	if p.WorkingDir != "" {
+		if isAPICall(scope.Context()) {
+			return nil, ErrWorkingDirectoryOverAPI
+		}
+		link, err := ReadLink(p.WorkingDir)
+		if err != nil {
+			return nil, err
+		}
+		return scope.ComponentStatus().WorkingDirectoryStatus(scope.Context(), link, p.WorkingDir)
+	}
+
 	ref, err := dsref.Parse(p.Ref)
 	if err != nil {
 		return nil, err
@@ -2,13 +2,22 @@ package lib
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/tabular"
 	"github.com/qri-io/deepdiff"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/base/component"
 	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/changes"
 	"github.com/qri-io/qri/dsref"
 	qerr "github.com/qri-io/qri/errors"
 	qhttp "github.com/qri-io/qri/lib/http"
@@ -27,8 +36,9 @@ func (m DiffMethods) Name() string {
 // Attributes defines attributes for each method
 func (m DiffMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"changes": {Endpoint: qhttp.AEChanges, HTTPVerb: "POST"},
-		"diff":    {Endpoint: qhttp.AEDiff, HTTPVerb: "POST"},
+		"changes": {Endpoint: qhttp.AEChanges, HTTPVerb: "POST", ReadOnly: true},
+		"diff":    {Endpoint: qhttp.AEDiff, HTTPVerb: "POST", ReadOnly: true},
+		"rows":    {Endpoint: qhttp.AEDiffRows, HTTPVerb: "POST", ReadOnly: true},
 	}
 }
 
@@ -54,10 +64,28 @@ type DiffParams struct {
 
 	// Which component or part of a dataset to compare
 	Selector string
+	// Stats compares column statistics of two dataset versions instead of
+	// the versions themselves, without comparing bodies element-by-element
+	Stats bool `schema:"stats" json:"stats"`
 }
 
 // diffMode determinse
 func (p *DiffParams) diffMode() (DiffMode, error) {
+	diffMode, err := p.sourcesDiffMode()
+	if err != nil || !p.Stats {
+		return diffMode, err
+	}
+	switch diffMode {
+	case DatasetRefDiffMode:
+		return StatsDiffMode, nil
+	case PrevVersionDiffMode:
+		return PrevVersionStatsDiffMode, nil
+	}
+	return InvalidDiffMode, fmt.Errorf("stats can only be compared between dataset versions")
+}
+
+// sourcesDiffMode determines the diff mode from the sources being compared
+func (p *DiffParams) sourcesDiffMode() (DiffMode, error) {
 	// Check parameters to make sure they fit one of the three cases that diff allows.
 	if p.LeftSide == "" && p.RightSide == "" {
 		return InvalidDiffMode, fmt.Errorf("nothing to diff")
@@ -103,8 +131,14 @@ type DiffResponse struct {
 	SchemaStat *DiffStat `json:"schemaStat,omitempty"`
 	Schema     []*Delta  `json:"schema,omitempty"`
 	Diff       []*Delta  `json:"diff,omitempty"`
+	// Stats is the column-by-column change in statistics, only populated
+	// when diffing with the Stats param
+	Stats *StatsChange `json:"stats,omitempty"`
 }
 
+// StatsChange is the change in statistics between two dataset versions
+type StatsChange = changes.StatsChangeComponent
+
 // DiffMode is one of the methods that diff can perform
 type DiffMode int
 
@@ -119,6 +153,11 @@ const (
 	WorkingDirectoryDiffMode
 	// PrevVersionDiffMode will diff a dataset head against its previous version
 	PrevVersionDiffMode
+	// StatsDiffMode will diff the column statistics of two dataset references
+	StatsDiffMode
+	// PrevVersionStatsDiffMode will diff the column statistics of a dataset
+	// head against its previous version
+	PrevVersionStatsDiffMode
 )
 
 // Diff computes the diff of two sources
@@ -130,6 +169,65 @@ func (m DiffMethods) Diff(ctx context.Context, p *DiffParams) (*DiffResponse, er
 	return nil, dispatchReturnError(got, err)
 }
 
+// RowDiffParams defines parameters for a row-keyed body diff. Rows are matched
+// on the primary key declared in the structure schema of the right side
+// dataset, unless KeyColumns is set
+type RowDiffParams struct {
+	params.List
+	// References to the datasets to compare
+	LeftSide  string `schema:"leftPath" json:"leftPath"`
+	RightSide string `schema:"rightPath" json:"rightPath"`
+	// Whether to get the previous version of the left parameter
+	UseLeftPrevVersion bool `json:"useLeftPrevVersion,omitempty"`
+	// Columns to match rows on, overriding the schema primary key
+	KeyColumns []string `json:"keyColumns,omitempty"`
+	// Cursor identifies an open row diff to continue reading from. It's set
+	// by the cursor returned with a page of results
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// SetNonZeroDefaults sets a default limit and offset. A limit of -1 returns
+// all rows
+func (p *RowDiffParams) SetNonZeroDefaults() {
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Limit == 0 {
+		p.Limit = params.DefaultListLimit
+	}
+}
+
+// Validate returns an error if RowDiffParams fields are in an invalid state
+func (p *RowDiffParams) Validate() error {
+	if !dsref.IsRefString(p.LeftSide) {
+		return fmt.Errorf("row diffs require a dataset reference")
+	}
+	if p.RightSide == "" && !p.UseLeftPrevVersion {
+		return fmt.Errorf("nothing to diff")
+	}
+	if p.RightSide != "" && p.UseLeftPrevVersion {
+		return fmt.Errorf("cannot use previous version when comparing two sources")
+	}
+	if p.RightSide != "" && !dsref.IsRefString(p.RightSide) {
+		return fmt.Errorf("row diffs require a dataset reference")
+	}
+	return nil
+}
+
+// RowDelta is a single row that differs between two dataset bodies
+type RowDelta = base.RowDelta
+
+// Rows compares the bodies of two versions of a tabular dataset row-by-row,
+// streaming bodies instead of loading them into memory. Rows are matched by
+// key, and each added, removed & modified row is returned in key order
+func (m DiffMethods) Rows(ctx context.Context, p *RowDiffParams) ([]*RowDelta, Cursor, error) {
+	got, cur, err := m.d.Dispatch(ctx, dispatchMethodName(m, "rows"), p)
+	if res, ok := got.([]*RowDelta); ok {
+		return res, cur, err
+	}
+	return nil, nil, dispatchReturnError(got, err)
+}
+
 func schemaDiff(ctx context.Context, left, right *component.BodyComponent) ([]*Delta, *DiffStat, error) {
 	dd := deepdiff.New()
 	if left.Format == ".csv" && right.Format == ".csv" {
@@ -168,6 +266,10 @@ func (diffImpl) Diff(scope scope, p *DiffParams) (*DiffResponse, error) {
 		return nil, err
 	}
 
+	if diffMode == StatsDiffMode || diffMode == PrevVersionStatsDiffMode {
+		return statsDiff(scope, p, diffMode)
+	}
+
 	if diffMode == FilepathDiffMode {
 		// Compare body files.
 		leftComp := component.NewBodyComponent(p.LeftSide)
@@ -336,3 +438,225 @@ func (diffImpl) Diff(scope scope, p *DiffParams) (*DiffResponse, error) {
 	}
 	return res, nil
 }
+
+// statsDiff compares the column statistics of two dataset versions
+func statsDiff(scope scope, p *DiffParams, diffMode DiffMode) (*DiffResponse, error) {
+	if p.Selector != "" {
+		return nil, fmt.Errorf("cannot use a component selector when comparing stats")
+	}
+
+	var left, right *dataset.Dataset
+	var err error
+	switch diffMode {
+	case StatsDiffMode:
+		if left, err = loadDiffDataset(scope, p.LeftSide); err != nil {
+			return nil, err
+		}
+		if right, err = loadDiffDataset(scope, p.RightSide); err != nil {
+			return nil, err
+		}
+	case PrevVersionStatsDiffMode:
+		if right, err = loadDiffDataset(scope, p.LeftSide); err != nil {
+			return nil, err
+		}
+		if right.PreviousPath == "" {
+			return nil, fmt.Errorf("dataset has only one version, nothing to diff against")
+		}
+		if left, err = dsfs.LoadDataset(scope.Context(), scope.Filesystem(), right.PreviousPath); err != nil {
+			return nil, err
+		}
+		if err = base.OpenDataset(scope.Context(), scope.Filesystem(), left); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("stats can only be compared between dataset versions")
+	}
+
+	svc := changes.New(scope.Loader(), scope.Stats())
+	stats, err := svc.StatsDiff(scope.Context(), left, right)
+	if err != nil {
+		return nil, err
+	}
+	return &DiffResponse{Stats: stats}, nil
+}
+
+func loadDiffDataset(scope scope, refstr string) (*dataset.Dataset, error) {
+	ds, err := scope.Loader().LoadDataset(scope.Context(), refstr)
+	if err != nil {
+		if errors.Is(err, dsref.ErrNoHistory) {
+			return nil, qerr.New(err, fmt.Sprintf("dataset %s has no versions, nothing to diff against", refstr))
+		}
+		return nil, err
+	}
+	return ds, nil
+}
+
+// Rows computes a row-keyed diff of two dataset bodies
+func (diffImpl) Rows(scope scope, p *RowDiffParams) ([]*RowDelta, Cursor, error) {
+	p.SetNonZeroDefaults()
+	ctx := scope.Context()
+	sig := p.signature()
+	if d := scope.inst.rowDiffs.take(p.Cursor, sig, p.Offset); d != nil {
+		return rowDiffPage(scope, p, sig, d)
+	}
+
+	var left, right *dataset.Dataset
+	var err error
+	if p.UseLeftPrevVersion {
+		if right, err = loadDiffDataset(scope, p.LeftSide); err != nil {
+			return nil, nil, err
+		}
+		if right.PreviousPath == "" {
+			return nil, nil, fmt.Errorf("dataset has only one version, nothing to diff against")
+		}
+		if left, err = dsfs.LoadDataset(scope.Context(), scope.Filesystem(), right.PreviousPath); err != nil {
+			return nil, nil, err
+		}
+		if err = base.OpenDataset(scope.Context(), scope.Filesystem(), left); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if left, err = loadDiffDataset(scope, p.LeftSide); err != nil {
+			return nil, nil, err
+		}
+		if right, err = loadDiffDataset(scope, p.RightSide); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key := p.KeyColumns
+	if len(key) == 0 {
+		if key, err = base.PrimaryKey(right.Structure); err != nil {
+			return nil, nil, err
+		}
+		if len(key) == 0 {
+			return nil, nil, fmt.Errorf("dataset schema has no primaryKey, specify key columns to compare rows")
+		}
+	}
+
+	d, err := base.NewRowDiff(ctx, left, right, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := d.Skip(ctx, p.Offset); err != nil {
+		d.Close()
+		return nil, nil, err
+	}
+	return rowDiffPage(scope, p, sig, d)
+}
+
+// rowDiffPage reads a page of results from an open row diff. When more
+// results remain the diff is kept open & the returned cursor points at it, so
+// the next page continues where this one stopped
+func rowDiffPage(scope scope, p *RowDiffParams, sig string, d *base.RowDiff) ([]*RowDelta, Cursor, error) {
+	deltas, err := d.Next(scope.Context(), p.Limit)
+	if err != nil {
+		d.Close()
+		return nil, nil, err
+	}
+	if p.Limit < 0 || d.Done() || len(deltas) < p.Limit {
+		d.Close()
+		return deltas, nil, nil
+	}
+
+	next := *p
+	next.Offset += len(deltas)
+	next.Cursor = scope.inst.rowDiffs.put(sig, d)
+	return deltas, scope.MakeCursor(len(deltas), &next), nil
+}
+
+// signature identifies the datasets & key a row diff compares, so a cursor is
+// only resumed by requests for the same comparison
+func (p *RowDiffParams) signature() string {
+	return fmt.Sprintf("%s\x00%s\x00%t\x00%s", p.LeftSide, p.RightSide, p.UseLeftPrevVersion, strings.Join(p.KeyColumns, ","))
+}
+
+const (
+	// maxOpenRowDiffs is the most row diffs kept open between pages
+	maxOpenRowDiffs = 32
+	// rowDiffIdleTimeout is how long a row diff is kept open waiting for a
+	// request for the next page
+	rowDiffIdleTimeout = 5 * time.Minute
+)
+
+// rowDiffCache holds row diffs open between page requests. The zero value is
+// ready to use
+type rowDiffCache struct {
+	lk    sync.Mutex
+	diffs map[string]*openRowDiff
+}
+
+type openRowDiff struct {
+	sig      string
+	diff     *base.RowDiff
+	lastUsed time.Time
+}
+
+// take removes & returns the open row diff for a cursor, if it compares the
+// same datasets & is positioned at offset
+func (c *rowDiffCache) take(cursor, sig string, offset int) *base.RowDiff {
+	if cursor == "" {
+		return nil
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.expireNoLock()
+	o, ok := c.diffs[cursor]
+	if !ok || o.sig != sig || o.diff.Position() != offset {
+		return nil
+	}
+	delete(c.diffs, cursor)
+	return o.diff
+}
+
+// put stores an open row diff, returning the cursor that resumes it. The
+// least recently used diff is closed when too many are open
+func (c *rowDiffCache) put(sig string, d *base.RowDiff) string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		d.Close()
+		return ""
+	}
+	id := hex.EncodeToString(buf)
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.diffs == nil {
+		c.diffs = map[string]*openRowDiff{}
+	}
+	c.expireNoLock()
+	for len(c.diffs) >= maxOpenRowDiffs {
+		oldest := ""
+		for k, o := range c.diffs {
+			if oldest == "" || o.lastUsed.Before(c.diffs[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		c.diffs[oldest].diff.Close()
+		delete(c.diffs, oldest)
+	}
+	c.diffs[id] = &openRowDiff{sig: sig, diff: d, lastUsed: time.Now()}
+	return id
+}
+
+// expireNoLock closes row diffs that have been idle too long. Only call this
+// with the lock held
+func (c *rowDiffCache) expireNoLock() {
+	now := time.Now()
+	for k, o := range c.diffs {
+		if now.Sub(o.lastUsed) > rowDiffIdleTimeout {
+			o.diff.Close()
+			delete(c.diffs, k)
+		}
+	}
+}
+
+// closeAll closes every open row diff
+func (c *rowDiffCache) closeAll() {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for k, o := range c.diffs {
+		o.diff.Close()
+		delete(c.diffs, k)
+	}
+}
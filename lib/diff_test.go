@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/changes"
 	"github.com/qri-io/qri/dsref"
 )
 
@@ -238,6 +240,130 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestDiffStats(t *testing.T) {
+	run := newTestRunner(t)
+	defer run.Delete()
+
+	run.MustSaveFromBody(t, "test_cities", "testdata/cities_2/body.csv")
+	run.MustSaveFromBody(t, "test_more", "testdata/cities_2/body_more.csv")
+
+	res, err := run.Instance.Diff().Diff(run.Ctx, &DiffParams{
+		LeftSide:  "me/test_cities",
+		RightSide: "me/test_more",
+		Stats:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats == nil {
+		t.Fatal("expected stats diff to populate stats")
+	}
+	if res.Diff != nil {
+		t.Errorf("expected stats diff to skip element-wise diff")
+	}
+
+	titles := []string{}
+	cols := map[string]*changes.ChangeReportDeltaComponent{}
+	for _, col := range res.Stats.Columns {
+		titles = append(titles, col.Title)
+		cols[col.Title] = col
+	}
+	expectTitles := []string{"avg_age", "city", "in_usa", "pop"}
+	if diff := cmp.Diff(expectTitles, titles); diff != "" {
+		t.Errorf("column titles mismatch (-want +got):\n%s", diff)
+	}
+
+	expectCity := &changes.DistributionDelta{
+		Categories: []*changes.CategoryDelta{
+			{Value: "los angeles", Right: 1, Delta: 1},
+			{Value: "mexico city", Right: 1, Delta: 1},
+		},
+		AddedCategories: 2,
+	}
+	if diff := cmp.Diff(expectCity, cols["city"].Distribution); diff != "" {
+		t.Errorf("city distribution mismatch (-want +got):\n%s", diff)
+	}
+	if h := cols["avg_age"].Distribution.Histogram; h == nil || h.Left == nil || h.Right == nil {
+		t.Errorf("expected avg_age distribution to compare histograms, got: %#v", cols["avg_age"].Distribution)
+	}
+	if cols["in_usa"].Distribution != nil {
+		t.Errorf("expected boolean column to have no distribution, got: %#v", cols["in_usa"].Distribution)
+	}
+
+	_, err = run.Instance.Diff().Diff(run.Ctx, &DiffParams{
+		LeftSide:  "testdata/cities_2/body.csv",
+		RightSide: "testdata/cities_2/body_more.csv",
+		Stats:     true,
+	})
+	expectErr := `stats can only be compared between dataset versions`
+	if diff := cmp.Diff(expectErr, errorMessage(err)); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffParamsStatsDiffMode(t *testing.T) {
+	cases := []struct {
+		p      *DiffParams
+		expect DiffMode
+	}{
+		{&DiffParams{LeftSide: "me/a", RightSide: "me/b"}, DatasetRefDiffMode},
+		{&DiffParams{LeftSide: "me/a", RightSide: "me/b", Stats: true}, StatsDiffMode},
+		{&DiffParams{LeftSide: "me/a", UseLeftPrevVersion: true}, PrevVersionDiffMode},
+		{&DiffParams{LeftSide: "me/a", UseLeftPrevVersion: true, Stats: true}, PrevVersionStatsDiffMode},
+	}
+	for _, c := range cases {
+		got, err := c.p.diffMode()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("diff mode mismatch for %#v. expected %d, got %d", c.p, c.expect, got)
+		}
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	run := newTestRunner(t)
+	defer run.Delete()
+
+	run.MustSaveFromBody(t, "test_cities", "testdata/cities_2/body.csv")
+	run.MustSaveFromBody(t, "test_more", "testdata/cities_2/body_more.csv")
+
+	p := &RowDiffParams{
+		LeftSide:  "me/test_cities",
+		RightSide: "me/test_more",
+	}
+	if _, _, err := run.Instance.Diff().Rows(run.Ctx, p); err == nil {
+		t.Error("expected diffing rows without a primary key to error")
+	}
+
+	p.KeyColumns = []string{"city"}
+	p.Limit = 1
+	res, cur, err := run.Instance.Diff().Rows(run.Ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Type != base.RowAdded || res[0].Key[0] != "los angeles" {
+		t.Errorf("expected first page to add los angeles, got: %#v", res)
+	}
+	if cur == nil {
+		t.Fatal("expected a full page to return a cursor")
+	}
+	if n := len(run.Instance.rowDiffs.diffs); n != 1 {
+		t.Errorf("expected the row diff to stay open for the next page, got %d open diffs", n)
+	}
+
+	got, err := cur.Next(run.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = got.([]*RowDelta)
+	if len(res) != 1 || res[0].Type != base.RowAdded || res[0].Key[0] != "mexico city" {
+		t.Errorf("expected second page to add mexico city, got: %#v", res)
+	}
+}
+
 // Test that diffing a dataset with only one version produces an error
 func TestDiffOnlyOneRevision(t *testing.T) {
 	run := newTestRunner(t)
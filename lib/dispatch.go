@@ -13,6 +13,7 @@ import (
 	"github.com/qri-io/qri/event"
 	qhttp "github.com/qri-io/qri/lib/http"
 	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/remote/access"
 )
 
 var (
@@ -53,6 +54,10 @@ type AttributeSet struct {
 	DefaultSource string
 	// whether to deny RPC for this endpoint, normal HTTP may still be allowed
 	DenyRPC bool
+	// whether the method only reads state. Read-only methods are checked by
+	// access control as the action "read:group:method", all others as
+	// "write:group:method"
+	ReadOnly bool
 }
 
 // Dispatch is a system for handling calls to lib. Should only be called by top-level lib methods.
@@ -76,6 +81,26 @@ func (inst *Instance) Dispatch(ctx context.Context, method string, param interfa
 	return inst.dispatchMethodCall(ctx, method, param, source)
 }
 
+// rpcContext adds an auth token to the context for requests made with the
+// instance http client. If no token exists, one is created from the configured
+// profile private key
+func (inst *Instance) rpcContext(ctx context.Context) (context.Context, error) {
+	if tok := token.FromCtx(ctx); tok != "" {
+		return ctx, nil
+	}
+	// TODO(b5): we're falling back to the configured user to make requests,
+	// is this the right default?
+	p, err := profile.NewProfile(inst.cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+	tokstr, err := token.NewPrivKeyAuthToken(p.PrivKey, p.ID.Encode(), time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return token.AddToContext(ctx, tokstr), nil
+}
+
 // Dispatch calls the same instance Dispatch but with an explicit source for ref resolution
 func (isw *InstanceSourceWrap) Dispatch(ctx context.Context, method string, param interface{}) (res interface{}, cur Cursor, err error) {
 	return isw.inst.dispatchMethodCall(ctx, method, param, isw.source)
@@ -100,20 +125,8 @@ func (inst *Instance) dispatchMethodCall(ctx context.Context, method string, par
 	// If the http rpc layer is engaged, use it to dispatch methods
 	// This happens when another process is running `qri connect`
 	if inst.http != nil {
-		if tok := token.FromCtx(ctx); tok == "" {
-			// If no token exists, create one from configured profile private key &
-			// add it to the request context
-			// TODO(b5): we're falling back to the configured user to make requests,
-			// is this the right default?
-			p, err := profile.NewProfile(inst.cfg.Profile)
-			if err != nil {
-				return nil, nil, err
-			}
-			tokstr, err := token.NewPrivKeyAuthToken(p.PrivKey, p.ID.Encode(), time.Minute)
-			if err != nil {
-				return nil, nil, err
-			}
-			ctx = token.AddToContext(ctx, tokstr)
+		if ctx, err = inst.rpcContext(ctx); err != nil {
+			return nil, nil, err
 		}
 
 		if c, ok := inst.regMethods.lookup(method); ok {
@@ -164,6 +177,11 @@ func (inst *Instance) dispatchMethodCall(ctx context.Context, method string, par
 		if err != nil {
 			return nil, nil, err
 		}
+		// Check the call is permitted by the access token scopes & the access
+		// control policy
+		if err := inst.authorizeMethodCall(scope, c.Action, param); err != nil {
+			return nil, nil, err
+		}
 
 		// Handle filepaths in the params by calling qfs.Abs on each of them
 		param = normalizeInputParams(param)
@@ -255,6 +273,7 @@ type callable struct {
 	Verb      string
 	Source    string
 	DenyRPC   bool
+	Action    access.Action
 }
 
 // AllMethods returns a method set for documentation purposes
@@ -267,6 +286,7 @@ func (inst *Instance) AllMethods() []MethodSet {
 		inst.Config(),
 		inst.Dataset(),
 		inst.Diff(),
+		inst.FSI(),
 		inst.Log(),
 		inst.Peer(),
 		inst.Profile(),
@@ -287,6 +307,7 @@ func (inst *Instance) RegisterMethods() {
 	inst.registerOne("config", inst.Config(), configImpl{}, reg)
 	inst.registerOne("dataset", inst.Dataset(), datasetImpl{}, reg)
 	inst.registerOne("diff", inst.Diff(), diffImpl{}, reg)
+	inst.registerOne("fsi", inst.FSI(), fsiImpl{}, reg)
 	inst.registerOne("log", inst.Log(), logImpl{}, reg)
 	inst.registerOne("peer", inst.Peer(), peerImpl{}, reg)
 	inst.registerOne("profile", inst.Profile(), profileImpl{}, reg)
@@ -441,6 +462,7 @@ func (inst *Instance) registerOne(ourName string, methods MethodSet, impl interf
 			Verb:      methodAttrs.HTTPVerb,
 			Source:    methodAttrs.DefaultSource,
 			DenyRPC:   methodAttrs.DenyRPC,
+			Action:    methodAction(ourName, lowerName, methodAttrs),
 		}
 	}
 
@@ -451,6 +473,15 @@ func (inst *Instance) registerOne(ourName string, methods MethodSet, impl interf
 	}
 }
 
+// methodAction maps the attributes of a method to the action access control
+// checks calls to the method against
+func methodAction(groupName, methodName string, attrs AttributeSet) access.Action {
+	if attrs.ReadOnly {
+		return access.Action{"read", groupName, methodName}
+	}
+	return access.Action{"write", groupName, methodName}
+}
+
 func regFail(fstr string, vals ...interface{}) {
 	panic(fmt.Sprintf(fstr, vals...))
 }
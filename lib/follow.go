@@ -22,7 +22,7 @@ func (m FollowMethods) Name() string {
 // Attributes defines attributes for each method
 func (m FollowMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"get":    {Endpoint: qhttp.AERegistryGetFollowing, HTTPVerb: "POST"},
+		"get":    {Endpoint: qhttp.AERegistryGetFollowing, HTTPVerb: "POST", ReadOnly: true},
 		"follow": {Endpoint: qhttp.AERegistryFollow, HTTPVerb: "POST"},
 	}
 }
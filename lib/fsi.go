@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/base/component"
+	"github.com/qri-io/qri/base/linkfile"
+	"github.com/qri-io/qri/dsref"
+	qerr "github.com/qri-io/qri/errors"
+	qhttp "github.com/qri-io/qri/lib/http"
+)
+
+var (
+	// ErrNotLinked indicates a directory isn't a working directory linked to a
+	// dataset
+	ErrNotLinked = fmt.Errorf("directory is not linked to a dataset")
+	// ErrWorkingDirectoryOutOfDate indicates a dataset has versions newer than
+	// the one its working directory was checked out from
+	ErrWorkingDirectoryOutOfDate = fmt.Errorf("working directory is out of date")
+	// ErrWorkingDirectoryOverAPI indicates a request tried to read or write a
+	// working directory through the JSON API, which must not reach into the
+	// server's filesystem
+	ErrWorkingDirectoryOverAPI = fmt.Errorf("working directories can't be used over the JSON API")
+)
+
+// FSIMethods groups together methods for linking datasets to working
+// directories. FSI stands for "filesystem integration"
+type FSIMethods struct {
+	d dispatcher
+}
+
+// Name returns the name of this method group
+func (m FSIMethods) Name() string {
+	return "fsi"
+}
+
+// Attributes defines attributes for each method
+func (m FSIMethods) Attributes() map[string]AttributeSet {
+	return map[string]AttributeSet{
+		// checkout & restore write to the local filesystem & aren't part of the
+		// JSON API
+		"checkout": {Endpoint: qhttp.DenyHTTP, DefaultSource: "local"},
+		"restore":  {Endpoint: qhttp.DenyHTTP, DefaultSource: "local"},
+	}
+}
+
+// CheckoutParams provides parameters to Checkout
+type CheckoutParams struct {
+	// dataset reference to check out; e.g. "b5/world_bank_population"
+	Ref string `json:"ref"`
+	// directory to write component files to, created if it doesn't exist
+	Dir string `json:"dir" qri:"fspath"`
+}
+
+// Validate returns an error if CheckoutParams fields are in an invalid state
+func (p *CheckoutParams) Validate() error {
+	if p.Ref == "" {
+		return fmt.Errorf("checkout params: ref required")
+	}
+	if p.Dir == "" {
+		return fmt.Errorf("checkout params: dir required")
+	}
+	return nil
+}
+
+// Checkout writes the components of a dataset's head version to files in a
+// directory & links the directory to the dataset
+func (m FSIMethods) Checkout(ctx context.Context, p *CheckoutParams) (*dsref.Ref, error) {
+	got, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "checkout"), p)
+	if res, ok := got.(*dsref.Ref); ok {
+		return res, err
+	}
+	return nil, dispatchReturnError(got, err)
+}
+
+// RestoreParams provides parameters to Restore
+type RestoreParams struct {
+	// dataset reference the working directory is linked to
+	Ref string `json:"ref"`
+	// linked working directory to restore a component in
+	Dir string `json:"dir" qri:"fspath"`
+	// name of the component to restore; e.g. "meta"
+	Component string `json:"component"`
+}
+
+// Validate returns an error if RestoreParams fields are in an invalid state
+func (p *RestoreParams) Validate() error {
+	if p.Ref == "" {
+		return fmt.Errorf("restore params: ref required")
+	}
+	if p.Dir == "" {
+		return fmt.Errorf("restore params: dir required")
+	}
+	if p.Component == "" {
+		return fmt.Errorf("restore params: component required")
+	}
+	return nil
+}
+
+// Restore discards working directory changes to a component, rewriting its
+// file from the version the directory was checked out from
+func (m FSIMethods) Restore(ctx context.Context, p *RestoreParams) error {
+	_, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "restore"), p)
+	return dispatchReturnError(nil, err)
+}
+
+// fsiImpl holds the method implementations for FSIMethods
+type fsiImpl struct{}
+
+// Checkout writes the components of a dataset's head version to files in a
+// directory & links the directory to the dataset
+func (fsiImpl) Checkout(scope scope, p *CheckoutParams) (*dsref.Ref, error) {
+	ref, _, err := scope.ParseAndResolveRef(scope.Context(), p.Ref)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Path == "" {
+		return nil, qerr.New(dsref.ErrNoHistory, fmt.Sprintf("can't check out dataset %q, it has no saved versions", ref.Human()))
+	}
+
+	if linkfile.ExistsInDir(p.Dir) {
+		return nil, fmt.Errorf("directory %q is already linked to a dataset", p.Dir)
+	}
+	if _, err := component.ListDirectoryComponents(p.Dir); err == nil {
+		return nil, fmt.Errorf("directory %q already contains dataset files", p.Dir)
+	}
+	if err := os.MkdirAll(p.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if err := base.WriteWorkingDirectory(scope.Context(), scope.Filesystem(), ref.Path, p.Dir); err != nil {
+		return nil, err
+	}
+	link := dsref.Ref{Username: ref.Username, Name: ref.Name, Path: ref.Path}
+	if _, err := linkfile.WriteHiddenInDir(p.Dir, link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Restore discards working directory changes to a component, rewriting its
+// file from the version the directory was checked out from
+func (fsiImpl) Restore(scope scope, p *RestoreParams) error {
+	link, err := ReadLink(p.Dir)
+	if err != nil {
+		return err
+	}
+	ref, _, err := scope.ParseAndResolveRef(scope.Context(), p.Ref)
+	if err != nil {
+		return err
+	}
+	if ref.Username != link.Username || ref.Name != link.Name {
+		return fmt.Errorf("directory %q is linked to %s, not %s", p.Dir, link.Human(), ref.Human())
+	}
+	return base.RestoreComponent(scope.Context(), scope.Filesystem(), link.Path, p.Dir, p.Component)
+}
+
+// ReadLink reads the reference a working directory is linked to, including the
+// path of the version it was checked out from
+func ReadLink(dir string) (dsref.Ref, error) {
+	ref, err := linkfile.Read(filepath.Join(dir, linkfile.RefLinkHiddenFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return ref, fmt.Errorf("%w: %s", ErrNotLinked, dir)
+	}
+	return ref, err
+}
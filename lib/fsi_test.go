@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/base"
+)
+
+func TestCheckoutStatusRestoreSave(t *testing.T) {
+	run := newTestRunner(t)
+	defer run.Delete()
+
+	ds := run.MustSaveFromBody(t, "cities_ds", "testdata/cities_2/body.csv")
+	if _, err := run.SaveWithParams(&SaveParams{
+		Ref:     "me/cities_ds",
+		Dataset: &dataset.Dataset{Meta: &dataset.Meta{Title: "city data"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(run.TmpDir, "cities_ds")
+	link, err := run.Instance.FSI().Checkout(run.Ctx, &CheckoutParams{Ref: "me/cities_ds", Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Username != ds.Peername || link.Name != "cities_ds" || link.Path == "" {
+		t.Errorf("unexpected checkout link: %#v", link)
+	}
+	if _, err := run.Instance.FSI().Checkout(run.Ctx, &CheckoutParams{Ref: "me/cities_ds", Dir: dir}); err == nil {
+		t.Error("expected checking out into a linked directory to fail")
+	}
+
+	status := func() []base.StatusItem {
+		t.Helper()
+		items, err := run.Instance.Dataset().WhatChanged(run.Ctx, &WhatChangedParams{WorkingDir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+
+	expect := []base.StatusItem{
+		{Component: "meta", Type: "unmodified"},
+		{Component: "structure", Type: "unmodified"},
+		{Component: "body", Type: "unmodified"},
+	}
+	if diff := cmp.Diff(expect, status()); diff != "" {
+		t.Errorf("fresh checkout status mismatch (-want +got):\n%s", diff)
+	}
+
+	run.MustWriteFile(t, filepath.Join(dir, "meta.json"), `{"title":"edited city data"}`)
+	run.MustWriteFile(t, filepath.Join(dir, "readme.md"), "# Cities\n")
+	expect = []base.StatusItem{
+		{Component: "meta", Type: "modified"},
+		{Component: "structure", Type: "unmodified"},
+		{Component: "readme", Type: "add"},
+		{Component: "body", Type: "unmodified"},
+	}
+	if diff := cmp.Diff(expect, status()); diff != "" {
+		t.Errorf("edited status mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := run.Instance.FSI().Restore(run.Ctx, &RestoreParams{Ref: "me/cities_ds", Dir: dir, Component: "readme"}); err != nil {
+		t.Fatal(err)
+	}
+	if run.Instance.FSI().Restore(run.Ctx, &RestoreParams{Ref: "me/cities_ds", Dir: dir, Component: "commit"}) == nil {
+		t.Error("expected restoring the commit to fail")
+	}
+	run.MustSaveFromBody(t, "other_ds", "testdata/cities_2/body.csv")
+	if run.Instance.FSI().Restore(run.Ctx, &RestoreParams{Ref: "me/other_ds", Dir: dir, Component: "meta"}) == nil {
+		t.Error("expected restoring with a reference the directory isn't linked to to fail")
+	}
+
+	apiCtx := context.WithValue(run.Ctx, apiCallCtxKey, true)
+	if _, err := run.Instance.Dataset().WhatChanged(apiCtx, &WhatChangedParams{WorkingDir: dir}); !errors.Is(err, ErrWorkingDirectoryOverAPI) {
+		t.Errorf("expected ErrWorkingDirectoryOverAPI for a working directory status over the API. got: %v", err)
+	}
+	if _, err := run.Instance.Dataset().Save(apiCtx, &SaveParams{WorkingDir: dir}); !errors.Is(err, ErrWorkingDirectoryOverAPI) {
+		t.Errorf("expected ErrWorkingDirectoryOverAPI for a working directory save over the API. got: %v", err)
+	}
+
+	saved, err := run.Instance.Dataset().Save(run.Ctx, &SaveParams{WorkingDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Meta == nil || saved.Meta.Title != "edited city data" {
+		t.Errorf("expected saved meta title from the working directory. got: %v", saved.Meta)
+	}
+	if saved.Readme != nil {
+		t.Errorf("expected restored readme to not be saved. got: %v", saved.Readme)
+	}
+
+	relinked, err := ReadLink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relinked.Path != saved.Path {
+		t.Errorf("expected working directory to be linked to the saved version %q. got: %q", saved.Path, relinked.Path)
+	}
+	for _, si := range status() {
+		if si.Type != base.STUnmodified {
+			t.Errorf("expected a clean status after saving. %s is %s", si.Component, si.Type)
+		}
+	}
+
+	// saving from a working directory that isn't checked out from the latest
+	// version fails
+	if _, err := run.SaveWithParams(&SaveParams{
+		Ref:     "me/cities_ds",
+		Dataset: &dataset.Dataset{Meta: &dataset.Meta{Title: "newer city data"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	run.MustWriteFile(t, filepath.Join(dir, "meta.json"), `{"title":"stale city data"}`)
+	if _, err := run.Instance.Dataset().Save(run.Ctx, &SaveParams{WorkingDir: dir}); !errors.Is(err, ErrWorkingDirectoryOutOfDate) {
+		t.Errorf("expected ErrWorkingDirectoryOutOfDate saving a stale working directory. got: %v", err)
+	}
+
+	if _, err := run.Instance.Dataset().WhatChanged(run.Ctx, &WhatChangedParams{WorkingDir: run.TmpDir}); !errors.Is(err, ErrNotLinked) {
+		t.Errorf("expected ErrNotLinked for a directory without a link. got: %v", err)
+	}
+}
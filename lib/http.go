@@ -2,6 +2,7 @@ package lib
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +14,27 @@ import (
 	qhttp "github.com/qri-io/qri/lib/http"
 )
 
+// ctxKey defines a distinct type for context keys used by the lib package
+type ctxKey string
+
+// apiCallCtxKey marks a context as belonging to a JSON API request
+const apiCallCtxKey ctxKey = "APICall"
+
+// APICallMiddleware marks requests as JSON API calls. When an access control
+// policy is configured, dispatch checks API calls against the policy
+func APICallMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), apiCallCtxKey, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isAPICall returns true if the context belongs to a JSON API request
+func isAPICall(ctx context.Context) bool {
+	is, _ := ctx.Value(apiCallCtxKey).(bool)
+	return is
+}
+
 // NewHTTPRequestHandler creates a JSON-API endpoint for a registered dispatch
 // method
 func NewHTTPRequestHandler(inst *Instance, libMethod string) http.HandlerFunc {
@@ -38,6 +38,8 @@ const (
 	AECollectionGet APIEndpoint = "/collection/get"
 	// AEDiff is an endpoint for generating dataset diffs
 	AEDiff APIEndpoint = "/diff"
+	// AEDiffRows is an endpoint for generating row-keyed dataset body diffs
+	AEDiffRows APIEndpoint = "/diff/rows"
 	// AEChanges is an endpoint for generating dataset change reports
 	AEChanges APIEndpoint = "/changes"
 
@@ -45,6 +47,10 @@ const (
 
 	// AECreateAuthToken creates an auth token for a user
 	AECreateAuthToken APIEndpoint = "/access/token"
+	// AEListAuthTokens lists auth tokens created by this node
+	AEListAuthTokens APIEndpoint = "/access/token/list"
+	// AERevokeAuthToken revokes an auth token
+	AERevokeAuthToken APIEndpoint = "/access/token/revoke"
 
 	// automation endpoints
 
@@ -56,6 +62,8 @@ const (
 	AERun APIEndpoint = "/auto/run"
 	// AERunInfo fetches the full run info for a workflow run
 	AERunInfo APIEndpoint = "/auto/runinfo"
+	// AERunEvents streams the events of a run as server-sent events
+	AERunEvents APIEndpoint = "/auto/run/{id}/events"
 	// AECancel cancels a run
 	AECancel APIEndpoint = "/auto/cancel"
 	// AEWorkflow fetches a workflow
@@ -64,6 +72,12 @@ const (
 	AERemoveWorkflow APIEndpoint = "/auto/remove"
 	// AEAnalyzeTransform performs static analysis on a starlark transform script
 	AEAnalyzeTransform APIEndpoint = "/auto/analyze-transform"
+	// AESetSecret stores a workflow secret
+	AESetSecret APIEndpoint = "/auto/secret/set"
+	// AESecrets lists the names of a workflow's secrets
+	AESecrets APIEndpoint = "/auto/secret/list"
+	// AERemoveSecret deletes a workflow secret
+	AERemoveSecret APIEndpoint = "/auto/secret/remove"
 
 	// dataset endpoints
 
@@ -85,6 +99,9 @@ const (
 	AERemove APIEndpoint = "/ds/remove"
 	// AEValidate is an endpoint for validating datasets
 	AEValidate APIEndpoint = "/ds/validate"
+	// AEVerify is an endpoint for verifying dataset history against the public
+	// key of its author
+	AEVerify APIEndpoint = "/ds/verify"
 	// AEManifest generates a manifest for a dataset path
 	AEManifest APIEndpoint = "/ds/manifest"
 	// AEManifestMissing generates a manifest of blocks that are not present on this repo for a given manifest
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/qri/auth/token"
+	"github.com/qri-io/qri/automation"
+	"github.com/qri-io/qri/event"
+)
+
+const (
+	// EventStreamMimeType is the server-sent events content type header value
+	EventStreamMimeType = "text/event-stream"
+	// LastEventIDHeader is the header server-sent event clients use to resume
+	// a stream after the last event they received
+	LastEventIDHeader = "Last-Event-ID"
+)
+
+// runEventData is the data field of a run server-sent event
+type runEventData struct {
+	Type      event.Type      `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	SessionID string          `json:"sessionID"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// RunEventsPath returns the path of the server-sent event stream for a run
+func RunEventsPath(runID string) string {
+	return strings.Replace(AERunEvents.String(), "{id}", url.PathEscape(runID), 1)
+}
+
+// WriteRunEvent writes a run event to w in the server-sent event format
+func WriteRunEvent(w io.Writer, e automation.RunEvent) error {
+	payload, err := json.Marshal(e.Event.Payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(runEventData{
+		Type:      e.Event.Type,
+		Timestamp: e.Event.Timestamp,
+		SessionID: e.Event.SessionID,
+		Payload:   payload,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Event.Type, data)
+	return err
+}
+
+// ReadRunEvents reads server-sent run events from r, calling fn with each
+// event until r is exhausted or fn returns an error
+func ReadRunEvents(r io.Reader, fn func(automation.RunEvent) error) error {
+	var (
+		id   int
+		data []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// a blank line dispatches the event
+			if len(data) > 0 {
+				e, err := decodeRunEvent(id, strings.Join(data, "\n"))
+				if err != nil {
+					return err
+				}
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i != -1 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid event id %q: %w", value, err)
+			}
+			id = n
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
+
+func decodeRunEvent(id int, data string) (automation.RunEvent, error) {
+	red := runEventData{}
+	if err := json.Unmarshal([]byte(data), &red); err != nil {
+		return automation.RunEvent{}, fmt.Errorf("decoding run event %d: %w", id, err)
+	}
+
+	e := event.Event{
+		Type:      red.Type,
+		Timestamp: red.Timestamp,
+		SessionID: red.SessionID,
+	}
+	var err error
+	switch e.Type {
+	case event.ETTransformStart, event.ETTransformStop, event.ETTransformCanceled:
+		p := event.TransformLifecycle{}
+		err = json.Unmarshal(red.Payload, &p)
+		e.Payload = p
+	case event.ETTransformStepStart, event.ETTransformStepStop, event.ETTransformStepSkip:
+		p := event.TransformStepLifecycle{}
+		err = json.Unmarshal(red.Payload, &p)
+		e.Payload = p
+	case event.ETTransformPrint, event.ETTransformError:
+		p := event.TransformMessage{}
+		err = json.Unmarshal(red.Payload, &p)
+		e.Payload = p
+	default:
+		var p interface{}
+		err = json.Unmarshal(red.Payload, &p)
+		e.Payload = p
+	}
+	if err != nil {
+		return automation.RunEvent{}, fmt.Errorf("decoding run event %d payload: %w", id, err)
+	}
+	return automation.RunEvent{ID: id, Event: e}, nil
+}
+
+// FollowRun streams the events of a run after lastEventID, calling fn with
+// each event until the run finishes
+func (c Client) FollowRun(ctx context.Context, runID string, lastEventID int, fn func(automation.RunEvent) error) error {
+	addr := fmt.Sprintf("%s://%s%s", c.Protocol, c.Address, RunEventsPath(runID))
+	log.Debugf("http: %s - %s", http.MethodGet, addr)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", EventStreamMimeType)
+	if lastEventID > 0 {
+		req.Header.Set(LastEventIDHeader, strconv.Itoa(lastEventID))
+	}
+	req, added := token.AddContextTokenToRequest(ctx, req)
+	if !added {
+		log.Debugw("No token was set on an http client request. Unauthenticated requests may fail", "httpMethod", http.MethodGet, "addr", addr)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		return c.checkError(res, body, true)
+	}
+	return ReadRunEvents(res.Body, fn)
+}
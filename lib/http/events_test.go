@@ -0,0 +1,44 @@
+package http
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/automation"
+	"github.com/qri-io/qri/event"
+)
+
+func TestRunEventsRoundTrip(t *testing.T) {
+	events := []automation.RunEvent{
+		{ID: 1, Event: event.Event{Type: event.ETTransformStart, Timestamp: 1, SessionID: "run", Payload: event.TransformLifecycle{RunID: "run"}}},
+		{ID: 2, Event: event.Event{Type: event.ETTransformStepStart, Timestamp: 2, SessionID: "run", Payload: event.TransformStepLifecycle{Name: "setup"}}},
+		{ID: 3, Event: event.Event{Type: event.ETTransformPrint, Timestamp: 3, SessionID: "run", Payload: event.TransformMessage{Msg: "hello\nworld"}}},
+		{ID: 4, Event: event.Event{Type: event.ETTransformStop, Timestamp: 4, SessionID: "run", Payload: event.TransformLifecycle{Status: "succeeded"}}},
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(": comments are ignored\n\n")
+	for _, e := range events {
+		if err := WriteRunEvent(buf, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := []automation.RunEvent{}
+	if err := ReadRunEvents(buf, func(e automation.RunEvent) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(events, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunEventsPath(t *testing.T) {
+	if got, expect := RunEventsPath("abc"), "/auto/run/abc/events"; got != expect {
+		t.Errorf("path mismatch. want: %q, got: %q", expect, got)
+	}
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/qri-io/qri/profile"
 	"github.com/qri-io/qri/registry/regclient"
 	"github.com/qri-io/qri/remote"
+	"github.com/qri-io/qri/remote/access"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/buildrepo"
 	"github.com/qri-io/qri/stats"
@@ -89,6 +90,7 @@ type InstanceOptions struct {
 	bus                     event.Bus
 	collectionSet           collection.Set
 	tokenProvider           token.Provider
+	policy                  *access.Policy
 	logAll                  bool
 	automationOptions       *automation.OrchestratorOptions
 
@@ -291,6 +293,15 @@ func OptTokenProvider(t token.Provider) Option {
 	}
 }
 
+// OptAccessControlPolicy sets the policy JSON API calls are checked against,
+// overriding any policy file set in configuration
+func OptAccessControlPolicy(p *access.Policy) Option {
+	return func(o *InstanceOptions) error {
+		o.policy = p
+		return nil
+	}
+}
+
 // OptOrchestratorOptions provides orchestrator options for the creation of an Orchestrator
 func OptOrchestratorOptions(a *automation.OrchestratorOptions) Option {
 	return func(o *InstanceOptions) error {
@@ -445,6 +456,7 @@ func NewInstance(ctx context.Context, repoPath string, opts ...Option) (qri *Ins
 		logbook:       o.logbook,
 		keystore:      o.keyStore,
 		tokenProvider: o.tokenProvider,
+		policy:        o.policy,
 		dscache:       o.dscache,
 		profiles:      o.profiles,
 		bus:           o.bus,
@@ -525,12 +537,24 @@ func NewInstance(ctx context.Context, repoPath string, opts ...Option) (qri *Ins
 		}
 	}
 
+	if inst.tokens == nil {
+		if inst.tokens, inst.revokedTokens, err = newTokenStores(inst.qfs, inst.repoPath); err != nil {
+			return nil, fmt.Errorf("initializing token store: %w", err)
+		}
+	}
+
 	if inst.tokenProvider == nil {
-		if inst.tokenProvider, err = token.NewProvider(inst.profiles, inst.keystore); err != nil {
+		if inst.tokenProvider, err = token.NewProvider(inst.profiles, inst.keystore, inst.revokedTokens); err != nil {
 			return nil, fmt.Errorf("initializing token provider: %w", err)
 		}
 	}
 
+	if inst.policy == nil && cfg.API != nil && cfg.API.AccessControlPolicy != "" {
+		if inst.policy, err = loadAccessControlPolicy(cfg.API.AccessControlPolicy, inst.repoPath); err != nil {
+			return nil, fmt.Errorf("loading access control policy: %w", err)
+		}
+	}
+
 	pro := inst.profiles.Owner(ctx)
 
 	if inst.logbook == nil {
@@ -649,14 +673,35 @@ func NewInstance(ctx context.Context, repoPath string, opts ...Option) (qri *Ins
 		}
 	}
 
+	if inst.qfs != nil {
+		if inst.localSearch, err = newLocalSearch(inst.bus, inst.qfs, inst.repoPath); err != nil {
+			return nil, err
+		}
+	}
+
 	if o.automationOptions == nil {
 		// TODO(ramfox): using `DefaultOrchestratorOptions` func for now to generate
 		// basic orchestrator options. When we get the automation configuration settled
 		// we will build a more robust solution
-		orchestratorOpts, err := automation.DefaultOrchestratorOptions(inst.bus, inst.repoPath)
+		storeType := ""
+		if cfg.Automation != nil {
+			storeType = cfg.Automation.StoreType
+		}
+		orchestratorOpts, err := automation.DefaultOrchestratorOptions(inst.bus, inst.repoPath, storeType)
 		if err != nil {
 			return nil, err
 		}
+		if cfg.Automation != nil {
+			if err := setRunRetentionOptions(&orchestratorOpts, cfg.Automation); err != nil {
+				return nil, err
+			}
+			if err := setTransformAnalyzerOptions(&orchestratorOpts, cfg.Automation, inst); err != nil {
+				return nil, err
+			}
+		}
+		if orchestratorOpts.SecretStore, err = newSecretStore(ctx, inst); err != nil {
+			return nil, err
+		}
 		o.automationOptions = &orchestratorOpts
 	}
 	inst.automation, err = automation.NewOrchestrator(ctx, inst.bus, &runner{owner: inst}, *o.automationOptions)
@@ -707,6 +752,18 @@ func newLogbook(fs qfs.Filesystem, cfg *config.Config, bus event.Bus, pro *profi
 	return logbook.NewJournal(*pro, bus, fs, logbookPath)
 }
 
+func newTokenStores(fs qfs.Filesystem, repoPath string) (token.IssuedTokens, token.RevocationList, error) {
+	tokens, err := token.NewIssuedTokens(filepath.Join(repoPath, "tokens.json"), fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	revoked, err := token.NewRevocationList(filepath.Join(repoPath, "revoked_tokens.json"), fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, revoked, nil
+}
+
 func newDscache(ctx context.Context, fs qfs.Filesystem, bus event.Bus, username, repoPath string) (*dscache.Dscache, error) {
 	dscachePath := filepath.Join(repoPath, "dscache.qfb")
 	return dscache.NewDscache(ctx, fs, bus, username, dscachePath), nil
@@ -833,6 +890,18 @@ func NewInstanceFromConfigAndNodeAndBusAndOrchestratorOpts(ctx context.Context,
 		panic(err)
 	}
 
+	inst.localSearch, err = newLocalSearch(inst.bus, inst.qfs, "")
+	if err != nil {
+		cancel()
+		panic(err)
+	}
+
+	inst.tokens, inst.revokedTokens, err = newTokenStores(qfs.NewMemFS(), "")
+	if err != nil {
+		cancel()
+		panic(err)
+	}
+
 	inst.releasers.Add(1)
 	go func() {
 		<-inst.remoteClient.Done()
@@ -865,9 +934,14 @@ type Instance struct {
 	logbook       *logbook.Book
 	dscache       *dscache.Dscache
 	collections   *collection.SetMaintainer
+	localSearch   *localSearch
+	rowDiffs      rowDiffCache
 	automation    *automation.Orchestrator
 	compStat      *base.ComponentStatus
 	tokenProvider token.Provider
+	tokens        token.IssuedTokens
+	revokedTokens token.RevocationList
+	policy        *access.Policy
 	bus           event.Bus
 	appCtx        context.Context
 
@@ -992,6 +1066,11 @@ func (inst *Instance) Diff() DiffMethods {
 	return DiffMethods{d: inst}
 }
 
+// FSI returns the FSIMethods that Instance has registered
+func (inst *Instance) FSI() FSIMethods {
+	return FSIMethods{d: inst}
+}
+
 // Log returns the LogMethods that Instance has registered
 func (inst *Instance) Log() LogMethods {
 	return LogMethods{d: inst}
@@ -1193,6 +1272,14 @@ func (inst *Instance) TokenProvider() token.Provider {
 	return inst.tokenProvider
 }
 
+// RevokedTokens exposes the instance list of revoked access tokens
+func (inst *Instance) RevokedTokens() token.RevocationList {
+	if inst == nil {
+		return nil
+	}
+	return inst.revokedTokens
+}
+
 // KeyStore exposes the instance key.Store
 func (inst *Instance) KeyStore() key.Store {
 	if inst == nil {
@@ -1208,24 +1295,40 @@ func (inst *Instance) activeProfile(ctx context.Context) (pro *profile.Profile,
 		return nil, fmt.Errorf("no instance")
 	}
 
+	claims, err := inst.authTokenClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return inst.activeProfileFromClaims(ctx, claims)
+}
+
+// authTokenClaims parses & validates any access token in the passed-in
+// context, returning nil claims if the context has no token
+func (inst *Instance) authTokenClaims(ctx context.Context) (*token.Claims, error) {
+	tokenString := token.FromCtx(ctx)
+	if tokenString == "" {
+		return nil, nil
+	}
+	tok, err := token.ParseAuthToken(ctx, tokenString, inst.keystore, inst.revokedTokens)
+	if err != nil {
+		return nil, err
+	}
+	claims, _ := tok.Claims.(*token.Claims)
+	return claims, nil
+}
+
+// activeProfileFromClaims resolves the active profile from the context,
+// falling back to the subject of token claims, then the repo owner
+func (inst *Instance) activeProfileFromClaims(ctx context.Context, claims *token.Claims) (*profile.Profile, error) {
 	// try to get the profileID from the context
 	profileIDString := profile.IDFromCtx(ctx)
-	if profileIDString == "" {
-		if tokenString := token.FromCtx(ctx); tokenString != "" {
-			tok, err := token.ParseAuthToken(ctx, tokenString, inst.keystore)
-			if err != nil {
-				return nil, err
-			}
-
-			if claims, ok := tok.Claims.(*token.Claims); ok {
-				// TODO(b5): at this point we have a valid signature of a profileID string
-				// but no proof that this profile is owned by the key that signed the
-				// token. We either need ProfileID == KeyID, or we need a UCAN. we need to
-				// check for those, ideally in a method within the profile package that
-				// abstracts over profile & key agreement
-				profileIDString = claims.Subject
-			}
-		}
+	if profileIDString == "" && claims != nil {
+		// TODO(b5): at this point we have a valid signature of a profileID string
+		// but no proof that this profile is owned by the key that signed the
+		// token. We either need ProfileID == KeyID, or we need a UCAN. we need to
+		// check for those, ideally in a method within the profile package that
+		// abstracts over profile & key agreement
+		profileIDString = claims.Subject
 	}
 
 	if profileIDString != "" {
@@ -1270,6 +1373,7 @@ Error:
 
 func (inst *Instance) waitForAllDone() {
 	inst.releasers.Wait()
+	inst.rowDiffs.closeAll()
 	log.Debug("closing instance")
 	close(inst.doneCh)
 }
@@ -90,7 +90,7 @@ func (d *datasetLoader) loadRefFromLocation(ctx context.Context, ref dsref.Ref,
 	// loadLocalDataset call entirely. For that to work dsfs.LoadDataset &
 	// inst.loadLocalDataset would have to behave in exactly the same way, and
 	// currently they don't
-	if _, err := d.inst.remoteClient.PullDataset(ctx, &ref, location); err != nil {
+	if _, err := d.inst.pullVerified(ctx, &ref, location, false); err != nil {
 		return nil, err
 	}
 
@@ -21,9 +21,9 @@ func (m LogMethods) Name() string {
 // Attributes defines attributes for each method
 func (m LogMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"log":            {Endpoint: qhttp.DenyHTTP},
-		"rawlogbook":     {Endpoint: qhttp.DenyHTTP},
-		"logbooksummary": {Endpoint: qhttp.DenyHTTP},
+		"log":            {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
+		"rawlogbook":     {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
+		"logbooksummary": {Endpoint: qhttp.DenyHTTP, ReadOnly: true},
 	}
 }
 
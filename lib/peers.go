@@ -28,12 +28,12 @@ func (m PeerMethods) Name() string { return "peer" }
 // Attributes defines attributes for each method
 func (m PeerMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"list":                 {Endpoint: qhttp.AEPeers, HTTPVerb: "POST"},
-		"info":                 {Endpoint: qhttp.AEPeer, HTTPVerb: "POST"},
+		"list":                 {Endpoint: qhttp.AEPeers, HTTPVerb: "POST", ReadOnly: true},
+		"info":                 {Endpoint: qhttp.AEPeer, HTTPVerb: "POST", ReadOnly: true},
 		"connect":              {Endpoint: qhttp.AEConnect, HTTPVerb: "POST"},
 		"disconnect":           {Endpoint: qhttp.AEDisconnect, HTTPVerb: "POST"},
-		"connections":          {Endpoint: qhttp.AEConnections, HTTPVerb: "POST"},
-		"connectedqriprofiles": {Endpoint: qhttp.AEConnectedQriProfiles, HTTPVerb: "POST"},
+		"connections":          {Endpoint: qhttp.AEConnections, HTTPVerb: "POST", ReadOnly: true},
+		"connectedqriprofiles": {Endpoint: qhttp.AEConnectedQriProfiles, HTTPVerb: "POST", ReadOnly: true},
 	}
 }
 
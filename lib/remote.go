@@ -27,8 +27,8 @@ func (m RemoteMethods) Name() string {
 // Attributes defines attributes for each method
 func (m RemoteMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"feeds":   {Endpoint: qhttp.AEFeeds, HTTPVerb: "POST"},
-		"preview": {Endpoint: qhttp.AEPreview, HTTPVerb: "POST"},
+		"feeds":   {Endpoint: qhttp.AEFeeds, HTTPVerb: "POST", ReadOnly: true},
+		"preview": {Endpoint: qhttp.AEPreview, HTTPVerb: "POST", ReadOnly: true},
 		"remove":  {Endpoint: qhttp.AERemoteRemove, HTTPVerb: "POST", DefaultSource: "network"},
 	}
 }
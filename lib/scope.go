@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/qri-io/qfs/muxfs"
+	"github.com/qri-io/qri/auth/key"
+	"github.com/qri-io/qri/auth/token"
 	"github.com/qri-io/qri/automation"
 	"github.com/qri-io/qri/automation/workflow"
 	"github.com/qri-io/qri/base"
@@ -31,12 +33,17 @@ type scope struct {
 	ctx    context.Context
 	inst   *Instance
 	pro    *profile.Profile
+	claims *token.Claims
 	method string
 	source string
 }
 
 func newScope(ctx context.Context, inst *Instance, method, source string) (scope, error) {
-	pro, err := inst.activeProfile(ctx)
+	claims, err := inst.authTokenClaims(ctx)
+	if err != nil {
+		return scope{}, err
+	}
+	pro, err := inst.activeProfileFromClaims(ctx, claims)
 	if err != nil {
 		return scope{}, err
 	}
@@ -48,6 +55,7 @@ func newScope(ctx context.Context, inst *Instance, method, source string) (scope
 		inst:   inst,
 		method: method,
 		pro:    pro,
+		claims: claims,
 		source: source,
 	}, nil
 }
@@ -161,6 +169,11 @@ func (s *scope) GetVersionInfoShim(ref dsref.Ref) (*dsref.VersionInfo, error) {
 	return repo.GetVersionInfoShim(r, ref)
 }
 
+// KeyStore returns the instance key store
+func (s *scope) KeyStore() key.Store {
+	return s.inst.keystore
+}
+
 // Loader returns a loader that can load datasets
 func (s *scope) Loader() dsref.Loader {
 	username := s.inst.cfg.Profile.Peername
@@ -200,6 +213,11 @@ func (s *scope) RegistryClient() *regclient.Client {
 	return s.inst.registry
 }
 
+// LocalSearch returns the search index of datasets in the user's repo
+func (s *scope) LocalSearch() *localSearch {
+	return s.inst.localSearch
+}
+
 // RemoteClient exposes the instance client for making requests to remotes
 func (s *scope) RemoteClient() remote.Client {
 	return s.inst.remoteClient
@@ -241,6 +259,16 @@ func (s *scope) Stats() *stats.Service {
 	return s.inst.stats
 }
 
+// Tokens returns the record of access tokens issued by this instance
+func (s *scope) Tokens() token.IssuedTokens {
+	return s.inst.tokens
+}
+
+// RevokedTokens returns the list of revoked access tokens
+func (s *scope) RevokedTokens() token.RevocationList {
+	return s.inst.revokedTokens
+}
+
 // UseDscache returns whether dscache should be generated
 // TODO(dustmop): Add a config option or environment variable to experimentally
 // enable the dscache
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/qri-io/qri/base/params"
 	qhttp "github.com/qri-io/qri/lib/http"
@@ -23,7 +24,7 @@ func (m SearchMethods) Name() string {
 // Attributes defines attributes for each method
 func (m SearchMethods) Attributes() map[string]AttributeSet {
 	return map[string]AttributeSet{
-		"search": {Endpoint: qhttp.AESearch, HTTPVerb: "POST"},
+		"search": {Endpoint: qhttp.AESearch, HTTPVerb: "POST", ReadOnly: true},
 	}
 }
 
@@ -31,6 +32,10 @@ func (m SearchMethods) Attributes() map[string]AttributeSet {
 type SearchParams struct {
 	params.List
 	Query string `json:"q"`
+	// Source is where to search, either "registry" or "local". local searches
+	// datasets in the user's own repo & works offline. Defaults to the source
+	// the method is called with, falling back to the registry
+	Source string `json:"source"`
 }
 
 // SetNonZeroDefaults sets a default limit and offset
@@ -43,6 +48,15 @@ func (p *SearchParams) SetNonZeroDefaults() {
 	}
 }
 
+// Validate returns an error if SearchParams fields are in an invalid state
+func (p *SearchParams) Validate() error {
+	switch p.Source {
+	case "", "registry", "local":
+		return nil
+	}
+	return fmt.Errorf("invalid search source %q, must be one of 'registry' or 'local'", p.Source)
+}
+
 // Search queries for items on qri related to given parameters
 func (m SearchMethods) Search(ctx context.Context, p *SearchParams) ([]registry.SearchResult, error) {
 	got, _, err := m.d.Dispatch(ctx, dispatchMethodName(m, "search"), p)
@@ -59,6 +73,14 @@ type searchImpl struct{}
 
 // Search queries for items on qri related to given parameters
 func (searchImpl) Search(scope scope, p *SearchParams) ([]registry.SearchResult, error) {
+	source := p.Source
+	if source == "" {
+		source = scope.SourceName()
+	}
+	if source == "local" {
+		return searchLocal(scope, p)
+	}
+
 	client := scope.RegistryClient()
 	if client == nil {
 		return nil, repo.ErrNoRegistry
@@ -75,3 +97,17 @@ func (searchImpl) Search(scope scope, p *SearchParams) ([]registry.SearchResult,
 	}
 	return regResults, nil
 }
+
+// searchLocal queries the index of datasets in the user's repo
+func searchLocal(scope scope, p *SearchParams) ([]registry.SearchResult, error) {
+	ls := scope.LocalSearch()
+	if ls == nil {
+		return nil, fmt.Errorf("local search is not available")
+	}
+	params := registry.SearchParams{
+		Q:      p.Query,
+		Limit:  p.Limit,
+		Offset: p.Offset,
+	}
+	return ls.Search(scope.Context(), scope.ActiveProfile(), scope.CollectionSet(), scope.Dscache(), params)
+}
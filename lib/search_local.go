@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/base/dsfs"
+	"github.com/qri-io/qri/base/params"
+	"github.com/qri-io/qri/collection"
+	"github.com/qri-io/qri/dscache"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/profile"
+	"github.com/qri-io/qri/registry"
+)
+
+// localSearchIndexFilename is the name of the local search index file, stored
+// in the repo directory
+const localSearchIndexFilename = "search_index.json"
+
+// localSearch is a full-text search index of the datasets in a user's repo.
+// The index is built from the collection set (or dscache if no collection
+// exists) on first use & kept current by listening for save & rename events
+type localSearch struct {
+	fs    qfs.Filesystem
+	index *registry.SearchIndex
+
+	buildLock sync.Mutex
+}
+
+func newLocalSearch(bus event.Bus, fs qfs.Filesystem, repoPath string) (*localSearch, error) {
+	path := ""
+	if repoPath != "" {
+		path = filepath.Join(repoPath, localSearchIndexFilename)
+	}
+
+	index, err := registry.NewSearchIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	ls := &localSearch{
+		fs:    fs,
+		index: index,
+	}
+
+	bus.SubscribeTypes(ls.handleEvent,
+		event.ETDatasetSaveCompleted,
+		event.ETDatasetRename,
+		event.ETDatasetDeleteAll,
+	)
+	return ls, nil
+}
+
+func (ls *localSearch) handleEvent(_ context.Context, e event.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	switch e.Type {
+	case event.ETDatasetSaveCompleted:
+		if save, ok := e.Payload.(event.DsSaveEvent); ok && save.Error == nil && save.Path != "" {
+			if err := ls.indexVersion(ctx, save.InitID, save.Username, save.Name, save.Path); err != nil {
+				log.Debugw("indexing saved dataset", "path", save.Path, "err", err)
+				return err
+			}
+		}
+	case event.ETDatasetRename:
+		if rename, ok := e.Payload.(event.DsRename); ok {
+			if err := ls.index.RenameDataset(rename.InitID, rename.NewName); err != nil && !errors.Is(err, registry.ErrNotFound) {
+				log.Debugw("renaming indexed dataset", "initID", rename.InitID, "err", err)
+				return err
+			}
+		}
+	case event.ETDatasetDeleteAll:
+		if initID, ok := e.Payload.(string); ok {
+			if err := ls.index.UnindexDatasets([]*dataset.Dataset{{ID: initID}}); err != nil {
+				log.Debugw("removing dataset from search index", "initID", initID, "err", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Search queries the local index, building the index first if necessary
+func (ls *localSearch) Search(ctx context.Context, pro *profile.Profile, set collection.Set, cache *dscache.Dscache, p registry.SearchParams) ([]registry.SearchResult, error) {
+	if err := ls.ensureBuilt(ctx, pro, set, cache); err != nil {
+		return nil, err
+	}
+	return ls.index.Search(p)
+}
+
+// ensureBuilt indexes every dataset in the user's collection if the index
+// hasn't been built before
+func (ls *localSearch) ensureBuilt(ctx context.Context, pro *profile.Profile, set collection.Set, cache *dscache.Dscache) error {
+	ls.buildLock.Lock()
+	defer ls.buildLock.Unlock()
+	if ls.index.Built() {
+		return nil
+	}
+
+	if set != nil {
+		infos, err := set.List(ctx, pro.ID, params.ListAll)
+		if err != nil {
+			return fmt.Errorf("listing datasets to index: %w", err)
+		}
+		for _, vi := range infos {
+			if vi.Path == "" {
+				continue
+			}
+			if err := ls.indexVersion(ctx, vi.InitID, vi.Username, vi.Name, vi.Path); err != nil {
+				log.Debugw("indexing dataset", "ref", vi.SimpleRef().String(), "err", err)
+			}
+		}
+	} else if cache != nil && !cache.IsEmpty() {
+		refs, err := cache.ListRefs()
+		if err != nil {
+			return fmt.Errorf("listing datasets to index: %w", err)
+		}
+		for _, ref := range refs {
+			if ref.Path == "" {
+				continue
+			}
+			initID := ""
+			if vi, err := cache.LookupByName(dsref.Ref{Username: ref.Peername, Name: ref.Name}); err == nil {
+				initID = vi.InitID
+			}
+			if err := ls.indexVersion(ctx, initID, ref.Peername, ref.Name, ref.Path); err != nil {
+				log.Debugw("indexing dataset", "ref", ref.String(), "err", err)
+			}
+		}
+	} else {
+		return fmt.Errorf("local search requires a collection or dscache")
+	}
+
+	return ls.index.MarkBuilt()
+}
+
+// indexVersion loads a dataset version & adds it to the index
+func (ls *localSearch) indexVersion(ctx context.Context, initID, username, name, path string) error {
+	ds, err := dsfs.LoadDataset(ctx, ls.fs, path)
+	if err != nil {
+		return err
+	}
+	ds.ID = initID
+	ds.Peername = username
+	ds.Name = name
+	if ds.Readme != nil && ds.Readme.ScriptPath != "" && len(ds.Readme.ScriptBytes) == 0 {
+		if err := ds.Readme.OpenScriptFile(ctx, ls.fs); err == nil && ds.Readme.ScriptFile() != nil {
+			ds.Readme.ScriptBytes, _ = ioutil.ReadAll(ds.Readme.ScriptFile())
+		}
+	}
+	return ls.index.IndexDatasets([]*dataset.Dataset{ds})
+}
@@ -2,10 +2,13 @@ package lib
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
 	"github.com/qri-io/qri/base/params"
 	"github.com/qri-io/qri/config"
 	testcfg "github.com/qri-io/qri/config/test"
@@ -46,6 +49,75 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearchLocal(t *testing.T) {
+	run := newTestRunner(t)
+	defer run.Delete()
+
+	pro := run.MustOwner(t)
+	popBody := run.MustWriteTmpFile(t, "pop.csv", "country,population\nusa,330\ncanada,38\n")
+	_, err := run.SaveWithParams(&SaveParams{
+		Ref:      fmt.Sprintf("%s/world_pop", pro.Peername),
+		BodyPath: popBody,
+		Dataset:  &dataset.Dataset{Meta: &dataset.Meta{Title: "World Population", Keywords: []string{"demographics"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = run.SaveWithParams(&SaveParams{
+		Ref:     fmt.Sprintf("%s/rainfall", pro.Peername),
+		Message: "add monthly totals",
+		Dataset: &dataset.Dataset{Meta: &dataset.Meta{Title: "Rainfall", Description: "precipitation by month"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(q string) []string {
+		t.Helper()
+		res, err := run.Instance.Search().Search(run.Ctx, &SearchParams{Query: q, Source: "local"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, r := range res {
+			names = append(names, r.Value.Name)
+		}
+		return names
+	}
+
+	cases := []struct {
+		query  string
+		expect []string
+	}{
+		{"population", []string{"world_pop"}},
+		{"columns:country", []string{"world_pop"}},
+		{"monthly", []string{"rainfall"}},
+		{"demographics", []string{"world_pop"}},
+		{"zebra", []string{}},
+	}
+	for _, c := range cases {
+		if diff := cmp.Diff(c.expect, search(c.query)); diff != "" {
+			t.Errorf("query %q result mismatch (-want +got):\n%s", c.query, diff)
+		}
+	}
+
+	// renaming keeps the index current
+	_, err = run.Instance.Dataset().Rename(run.Ctx, &RenameParams{
+		Current: fmt.Sprintf("%s/rainfall", pro.Peername),
+		Next:    fmt.Sprintf("%s/precipitation", pro.Peername),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"precipitation"}, search("name:precipitation")); diff != "" {
+		t.Errorf("renamed result mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := run.Instance.Search().Search(run.Ctx, &SearchParams{Query: "x", Source: "elsewhere"}); err == nil {
+		t.Error("expected invalid source to error")
+	}
+}
+
 var mockResponse = []byte(`{"data":[
   {
     "type": "dataset",
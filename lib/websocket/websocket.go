@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"reflect"
 	"sync"
 
 	"github.com/google/uuid"
@@ -19,6 +21,15 @@ import (
 
 const qriWebsocketProtocol = "qri-websocket"
 
+const (
+	// DefaultReplayBufferSize is the number of recent events kept for clients
+	// resuming from a sequence number
+	DefaultReplayBufferSize = 1024
+	// DefaultReplayBufferBytes caps the total encoded size of events kept for
+	// clients resuming from a sequence number
+	DefaultReplayBufferBytes = 8 << 20
+)
+
 var (
 	errNotFound = fmt.Errorf("connection not found")
 
@@ -47,7 +58,14 @@ type Handler interface {
 	ConnectionHandler(w http.ResponseWriter, r *http.Request)
 }
 
-type connectionSet map[string]struct{}
+// AuthorizeFunc checks the holder of an access token is permitted to
+// subscribe to events. It returns a function reporting if the subscription may
+// receive events about a dataset init ID, or nil if it may receive all events.
+// Events that can't be tied to a dataset are checked with an empty init ID
+type AuthorizeFunc func(ctx context.Context, tokenString string) (permit func(initID string) bool, err error)
+
+// connectionSet maps connection IDs to the filter of each subscription
+type connectionSet map[string]*filter
 
 // connections maintains the set of active websocket connections & associated
 // connection metadata
@@ -55,27 +73,67 @@ type connections struct {
 	conns         map[string]*conn
 	connsLock     sync.Mutex
 	keystore      key.Store
+	revoked       token.RevocationList
+	authorize     AuthorizeFunc
 	subscriptions map[string]connectionSet
 	subsLock      sync.Mutex
+
+	// bufLock must be acquired before subsLock when both are held
+	bufLock     sync.Mutex
+	seq         uint64
+	buffer      []*bufferedEvent
+	bufferSize  int
+	bufferBytes int
+	bufferCap   int
+	// runInitIDs maps the IDs of active runs to the dataset they run on, so
+	// transform events that only carry a run ID can be tied to a dataset
+	runInitIDs map[string]string
+}
+
+// runInitIDLimit caps the number of active runs tracked for events
+const runInitIDLimit = 1000
+
+// bufferedEvent is an event encoded for sending, along with the fields
+// subscriptions filter on
+type bufferedEvent struct {
+	seq       uint64
+	typ       event.Type
+	profileID string
+	initID    string
+	runID     string
+	data      []byte
 }
 
 type conn struct {
 	id        string
 	profileID string
 	conn      *websocket.Conn
+
+	// while a subscription is being set up live events are held, so they're
+	// sent after the subscription result & any replayed events
+	holdLk  sync.Mutex
+	holding bool
+	held    []*bufferedEvent
 }
 
 var _ Handler = (*connections)(nil)
 
 // NewHandler creates a new connections instance that clients
-// can connect to in order to get realtime events
-func NewHandler(ctx context.Context, bus event.Bus, keystore key.Store) (Handler, error) {
+// can connect to in order to get realtime events. Tokens listed in revoked
+// are refused, and subscriptions must be permitted by authorize. revoked &
+// authorize may be nil
+func NewHandler(ctx context.Context, bus event.Bus, keystore key.Store, revoked token.RevocationList, authorize AuthorizeFunc) (Handler, error) {
 	ws := &connections{
 		conns:         map[string]*conn{},
 		connsLock:     sync.Mutex{},
 		keystore:      keystore,
+		revoked:       revoked,
+		authorize:     authorize,
 		subscriptions: map[string]connectionSet{},
 		subsLock:      sync.Mutex{},
+		bufferSize:    DefaultReplayBufferSize,
+		bufferCap:     DefaultReplayBufferBytes,
+		runInitIDs:    map[string]string{},
 	}
 
 	bus.SubscribeAll(ws.messageHandler)
@@ -105,39 +163,162 @@ func (h *connections) ConnectionHandler(w http.ResponseWriter, r *http.Request)
 
 func (h *connections) messageHandler(_ context.Context, e event.Event) error {
 	ctx := context.Background()
-	evt := map[string]interface{}{
-		"type":      string(e.Type),
-		"ts":        e.Timestamp,
-		"sessionID": e.SessionID,
-		"data":      e.Payload,
-	}
-
-	profileIDString := e.ProfileID
-	if profileIDString == "" {
+	if e.ProfileID == "" {
 		return nil
 	}
-	connIDs, err := h.getConnIDs(profileIDString)
+
+	be, subs, err := h.bufferEvent(e)
 	if err != nil {
-		log.Errorf("profile %q: %w", profileIDString, err)
+		log.Errorf("encoding event %q: %s", e.Type, err)
 		return nil
 	}
 
-	for connID := range connIDs {
+	for connID, f := range subs {
+		if !f.match(be) {
+			continue
+		}
 		c, err := h.getConn(connID)
 		if err != nil {
-			h.unsubscribeConn(profileIDString, connID)
-			log.Errorf("connection %q, profile %q: %w", connID, profileIDString, err)
-			return nil
-		}
-		log.Debugf("sending event %q to websocket conns %q", e.Type, profileIDString)
-		if err := wsjson.Write(ctx, c.conn, evt); err != nil {
-			log.Errorf("connection %q: wsjson write error: %s", profileIDString, err)
-			return nil
+			h.unsubscribeConn(e.ProfileID, connID)
+			log.Errorf("connection %q, profile %q: %s", connID, e.ProfileID, err)
+			continue
 		}
+		log.Debugf("sending event %q to websocket conn %q", e.Type, connID)
+		h.sendEvent(ctx, c, be)
 	}
 	return nil
 }
 
+// bufferEvent assigns the next sequence number to an event, encodes it &
+// adds it to the replay buffer. It returns the subscriptions of the event
+// profile at the moment the event was sequenced
+func (h *connections) bufferEvent(e event.Event) (*bufferedEvent, connectionSet, error) {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	initID, runID := payloadIDs(e.Payload)
+	if runID == "" {
+		// transform events use the run ID as the session ID
+		runID = e.SessionID
+	}
+
+	h.bufLock.Lock()
+	defer h.bufLock.Unlock()
+
+	initID = h.runInitID(e.Type, initID, runID)
+	h.seq++
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      string(e.Type),
+		"ts":        e.Timestamp,
+		"sessionID": e.SessionID,
+		"seq":       h.seq,
+		"data":      json.RawMessage(payload),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	be := &bufferedEvent{
+		seq:       h.seq,
+		typ:       e.Type,
+		profileID: e.ProfileID,
+		initID:    initID,
+		runID:     runID,
+		data:      data,
+	}
+	if h.bufferSize > 0 {
+		h.buffer = append(h.buffer, be)
+		h.bufferBytes += len(be.data)
+		drop := 0
+		for drop < len(h.buffer) && (len(h.buffer)-drop > h.bufferSize || (h.bufferCap > 0 && h.bufferBytes > h.bufferCap)) {
+			h.bufferBytes -= len(h.buffer[drop].data)
+			h.buffer[drop] = nil
+			drop++
+		}
+		h.buffer = h.buffer[drop:]
+	}
+
+	return be, h.subscribers(e.ProfileID), nil
+}
+
+// runInitID resolves the dataset init ID of an event from the run it belongs
+// to. Runs are tracked from the first event that carries both IDs until the
+// transform stops or is canceled. bufLock must be held
+func (h *connections) runInitID(typ event.Type, initID, runID string) string {
+	if runID == "" {
+		return initID
+	}
+	if initID == "" {
+		initID = h.runInitIDs[runID]
+	} else if _, ok := h.runInitIDs[runID]; !ok {
+		if len(h.runInitIDs) >= runInitIDLimit {
+			for id := range h.runInitIDs {
+				delete(h.runInitIDs, id)
+				break
+			}
+		}
+		h.runInitIDs[runID] = initID
+	}
+	if typ == event.ETTransformStop || typ == event.ETTransformCanceled {
+		delete(h.runInitIDs, runID)
+	}
+	return initID
+}
+
+// payloadIDs picks the dataset init ID & run ID subscriptions filter on out of
+// an event payload. Payloads that aren't structs don't have identifiers
+func payloadIDs(payload interface{}) (initID, runID string) {
+	v := reflect.ValueOf(payload)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", ""
+	}
+	if f := v.FieldByName("InitID"); f.IsValid() && f.Kind() == reflect.String {
+		initID = f.String()
+	}
+	if f := v.FieldByName("RunID"); f.IsValid() && f.Kind() == reflect.String {
+		runID = f.String()
+	}
+	return initID, runID
+}
+
+// subscribers returns a copy of the subscriptions for a profile
+func (h *connections) subscribers(profileID string) connectionSet {
+	h.subsLock.Lock()
+	defer h.subsLock.Unlock()
+	subs := connectionSet{}
+	for connID, f := range h.subscriptions[profileID] {
+		subs[connID] = f
+	}
+	return subs
+}
+
+// replayEvents returns buffered events for a profile with sequence numbers
+// after since, up to & including upTo, that match the given filter. missed is
+// true if events after since have been dropped from the buffer
+func (h *connections) replayEvents(profileID string, f *filter, since, upTo uint64) (events []*bufferedEvent, missed bool) {
+	h.bufLock.Lock()
+	defer h.bufLock.Unlock()
+
+	if since < upTo && (len(h.buffer) == 0 || h.buffer[0].seq > since+1) {
+		missed = true
+	}
+	for _, be := range h.buffer {
+		if be.seq <= since || be.seq > upTo {
+			continue
+		}
+		if be.profileID == profileID && f.match(be) {
+			events = append(events, be)
+		}
+	}
+	return events, missed
+}
+
 // getConn gets a *conn from the map of connections
 func (h *connections) getConn(id string) (*conn, error) {
 	h.connsLock.Lock()
@@ -168,41 +349,66 @@ func (h *connections) getConnIDs(profileID string) (connectionSet, error) {
 }
 
 // subscribeConn authenticates the given token and adds the connID to the map
-// of "subscribed" connections
-func (h *connections) subscribeConn(connID, tokenString string) error {
+// of "subscribed" connections, sending events that match the given filter.
+// A nil filter matches all events. Token scopes further limit the events a
+// subscription receives. subscribeConn returns the filter in effect & the
+// sequence number of the last event sequenced before the subscription was
+// added. Live events are held until release is called on the connection.
+// Subscribing an already-subscribed connection replaces its filter
+func (h *connections) subscribeConn(connID, tokenString string, f *filter) (*filter, uint64, error) {
 	ctx := context.TODO()
-	tok, err := token.ParseAuthToken(ctx, tokenString, h.keystore)
+	tok, err := token.ParseAuthToken(ctx, tokenString, h.keystore, h.revoked)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	claims, ok := tok.Claims.(*token.Claims)
 	if !ok || claims.Subject == "" {
 		h.removeConn(connID)
-		return fmt.Errorf("cannot get profile.ID from token")
+		return nil, 0, fmt.Errorf("cannot get profile.ID from token")
 	}
 	// TODO(b5): at this point we have a valid signature of a profileID string
 	// but no proof that this profile is owned by the key that signed the
 	// token. We either need ProfileID == KeyID, or we need a UCAN. we need to
 	// check for those, ideally in a method within the profile package that
 	// abstracts over profile & key agreement
+	if h.authorize != nil {
+		permit, err := h.authorize(ctx, tokenString)
+		if err != nil {
+			return nil, 0, err
+		}
+		if permit != nil {
+			if f == nil {
+				f = &filter{}
+			}
+			f.permit = permit
+		}
+	}
 
 	c, err := h.getConn(connID)
 	if err != nil {
-		return fmt.Errorf("connection %q: %w", connID, err)
+		return nil, 0, fmt.Errorf("connection %q: %w", connID, err)
+	}
+	if c.profileID != "" && c.profileID != claims.Subject {
+		h.unsubscribeConn(c.profileID, connID)
 	}
 	c.profileID = claims.Subject
+	c.hold()
 
+	// hold the buffer lock while subscribing so each event is either sent to
+	// this connection or sequenced at or before the returned sequence number
+	h.bufLock.Lock()
+	defer h.bufLock.Unlock()
 	h.subsLock.Lock()
 	defer h.subsLock.Unlock()
 	connIDs, ok := h.subscriptions[claims.Subject]
 	if !ok || connIDs == nil {
 		connIDs = connectionSet{}
 	}
-	connIDs[connID] = struct{}{}
+	connIDs[connID] = f
 	h.subscriptions[claims.Subject] = connIDs
 	log.Debugw("subscribeConn", "id", connID)
-	return nil
+	return f, h.seq, nil
 }
 
 // unsubscribeConn remove the profileID and connID from the map of "subscribed"
@@ -286,12 +492,36 @@ func (h *connections) handleMessage(ctx context.Context, c *conn, msg *message)
 			h.write(ctx, c, &message{Type: subscribeFailure, Error: err})
 			return
 		}
-		if err := h.subscribeConn(c.id, subMsg.Token); err != nil {
+		f := subMsg.filter()
+		if err := f.validate(); err != nil {
+			log.Debugw("websocket subscription filter", "error", err, "connection id", c.id, "msg", msg)
+			h.write(ctx, c, &message{Type: subscribeFailure, Error: err})
+			return
+		}
+		f, seq, err := h.subscribeConn(c.id, subMsg.Token, f)
+		if err != nil {
 			log.Debugw("subscribeConn", "error", err, "connection id", c.id, "msg", msg)
 			h.write(ctx, c, &message{Type: subscribeFailure, Error: err})
 			return
 		}
-		h.write(ctx, c, &message{Type: subscribeSuccess})
+		// subscribeConn holds live events. send them once the subscription
+		// result & replayed events are written
+		defer h.release(ctx, c)
+
+		res := subscribeResult{Seq: seq}
+		var replay []*bufferedEvent
+		if subMsg.Since != nil {
+			replay, res.Missed = h.replayEvents(c.profileID, f, *subMsg.Since, seq)
+			res.Replayed = len(replay)
+		}
+		payload, err := json.Marshal(res)
+		if err != nil {
+			log.Debugw("encoding subscribe result", "error", err)
+		}
+		h.write(ctx, c, &message{Type: subscribeSuccess, Payload: payload})
+		for _, be := range replay {
+			h.writeEvent(ctx, c, be)
+		}
 	case unsubscribeRequest:
 		h.unsubscribeConn(c.profileID, c.id)
 	default:
@@ -311,6 +541,53 @@ func (h *connections) write(ctx context.Context, c *conn, msg *message) {
 	}
 }
 
+// sendEvent sends a live event over the connection, or holds it if the
+// connection's subscription is being set up
+func (h *connections) sendEvent(ctx context.Context, c *conn, be *bufferedEvent) {
+	c.holdLk.Lock()
+	if c.holding {
+		c.held = append(c.held, be)
+		c.holdLk.Unlock()
+		return
+	}
+	c.holdLk.Unlock()
+	h.writeEvent(ctx, c, be)
+}
+
+// release sends events held while a subscription was set up, then resumes
+// sending live events as they occur
+func (h *connections) release(ctx context.Context, c *conn) {
+	for {
+		c.holdLk.Lock()
+		held := c.held
+		c.held = nil
+		if len(held) == 0 {
+			c.holding = false
+			c.holdLk.Unlock()
+			return
+		}
+		c.holdLk.Unlock()
+		for _, be := range held {
+			h.writeEvent(ctx, c, be)
+		}
+	}
+}
+
+// hold starts holding live events sent to the connection
+func (c *conn) hold() {
+	c.holdLk.Lock()
+	defer c.holdLk.Unlock()
+	c.holding = true
+}
+
+// writeEvent sends an encoded event over the connection
+func (h *connections) writeEvent(ctx context.Context, c *conn, be *bufferedEvent) {
+	if err := c.conn.Write(ctx, websocket.MessageText, be.data); err != nil {
+		log.Errorf("connection %q: write error: %s", c.id, err)
+		h.removeConn(c.id)
+	}
+}
+
 // msgType is the type of message that we receive on the
 type msgType string
 
@@ -321,7 +598,7 @@ const (
 	subscribeRequest = msgType("subscribe:request")
 	// subscribeSuccess indicates that the connection successfully
 	// upgraded to an authenticated connection
-	// payload is nil
+	// payload is a `subscribeResult`
 	subscribeSuccess = msgType("subscribe:success")
 	// subscribeFailure indicates that the connection did not
 	// upgrade to an authenticated connection
@@ -341,7 +618,92 @@ type message struct {
 }
 
 // subscribeMessage is the expected structure of an incoming "subscribe"
-// message
+// message. Subscriptions receive all events for the token subject unless
+// limited by Types, InitIDs or RunIDs. Clients resuming after a reconnect set
+// Since to the sequence number of the last event they received
 type subscribeMessage struct {
 	Token string `json:"token"`
+	// event type patterns to send, eg: "tf:*", "automation:*"
+	Types []string `json:"types,omitempty"`
+	// dataset init IDs to send events for
+	InitIDs []string `json:"initIDs,omitempty"`
+	// run IDs to send events for
+	RunIDs []string `json:"runIDs,omitempty"`
+	// replay buffered events with sequence numbers greater than Since
+	Since *uint64 `json:"since,omitempty"`
+}
+
+func (m *subscribeMessage) filter() *filter {
+	return &filter{Types: m.Types, InitIDs: m.InitIDs, RunIDs: m.RunIDs}
+}
+
+// subscribeResult is the payload of a "subscribe:success" message
+type subscribeResult struct {
+	// sequence number of the last event sent before the subscription began
+	Seq uint64 `json:"seq"`
+	// number of buffered events replayed after this message
+	Replayed int `json:"replayed"`
+	// true if events after the requested sequence number are no longer
+	// buffered & can't be replayed
+	Missed bool `json:"missed,omitempty"`
+}
+
+// filter limits the events sent to a subscription. Events must match one of
+// Types if any are set, and one of InitIDs or RunIDs if any are set. Events
+// must also be permitted by the access token scopes of the subscription. A nil
+// filter matches all events
+type filter struct {
+	Types   []string
+	InitIDs []string
+	RunIDs  []string
+
+	permit func(initID string) bool
+}
+
+// validate checks all type patterns are well-formed
+func (f *filter) validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, pattern := range f.Types {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid event type pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (f *filter) match(be *bufferedEvent) bool {
+	if f == nil {
+		return true
+	}
+	if f.permit != nil && !f.permit(be.initID) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		matched := false
+		for _, pattern := range f.Types {
+			if ok, _ := path.Match(pattern, string(be.typ)); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.InitIDs) == 0 && len(f.RunIDs) == 0 {
+		return true
+	}
+	return (be.initID != "" && stringsContain(f.InitIDs, be.initID)) ||
+		(be.runID != "" && stringsContain(f.RunIDs, be.runID))
+}
+
+func stringsContain(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -36,7 +37,7 @@ func TestWebsocket(t *testing.T) {
 	subsCount := bus.NumSubscribers()
 
 	// create Handler
-	websocketHandler, err := NewHandler(ctx, bus, ks)
+	websocketHandler, err := NewHandler(ctx, bus, ks, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,8 +65,19 @@ func TestWebsocket(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// subscriptions must be authorized
+	wsh.authorize = func(context.Context, string) (func(string) bool, error) { return nil, fmt.Errorf("denied") }
+	if _, _, err := wsh.subscribeConn(connID, tokenStr, nil); err == nil {
+		t.Error("expected connections.subscribeConn to refuse an unauthorized subscription")
+	}
+	if _, err := wsh.getConnIDs(kd.KeyID.String()); err == nil {
+		t.Error("connections.subscribeConn added an unauthorized subscription")
+	}
+	wsh.authorize = nil
+
 	// upgrade connection w/ valid token
-	wsh.subscribeConn(connID, tokenStr)
+	wsh.subscribeConn(connID, tokenStr, nil)
+	wsh.release(ctx, wsh.conns[connID])
 	proID := kd.KeyID.String()
 	gotConnIDs, err := wsh.getConnIDs(proID)
 	if err != nil {
@@ -115,7 +127,7 @@ func TestWebsocketUnsubscribe(t *testing.T) {
 	subsCount := bus.NumSubscribers()
 
 	// create Handler
-	websocketHandler, err := NewHandler(ctx, bus, ks)
+	websocketHandler, err := NewHandler(ctx, bus, ks, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,7 +157,7 @@ func TestWebsocketUnsubscribe(t *testing.T) {
 	}
 	proID := kd.KeyID.String()
 
-	err = wsh.subscribeConn(connID, tokenStr)
+	_, _, err = wsh.subscribeConn(connID, tokenStr, nil)
 	if err == nil {
 		t.Fatal("connections.subscribeConn subscribed connection with no profileID to subscriptions map")
 	}
@@ -162,6 +174,193 @@ func TestWebsocketUnsubscribe(t *testing.T) {
 	}
 }
 
+func TestFilterMatch(t *testing.T) {
+	cases := []struct {
+		description string
+		f           *filter
+		be          *bufferedEvent
+		expect      bool
+	}{
+		{"nil filter", nil, &bufferedEvent{typ: event.ETTransformPrint}, true},
+		{"empty filter", &filter{}, &bufferedEvent{typ: event.ETTransformPrint}, true},
+		{"type prefix", &filter{Types: []string{"tf:*"}}, &bufferedEvent{typ: event.ETTransformPrint}, true},
+		{"type prefix mismatch", &filter{Types: []string{"automation:*"}}, &bufferedEvent{typ: event.ETTransformPrint}, false},
+		{"exact type", &filter{Types: []string{"automation:*", string(event.ETTransformPrint)}}, &bufferedEvent{typ: event.ETTransformPrint}, true},
+		{"init ID", &filter{InitIDs: []string{"init_a"}}, &bufferedEvent{initID: "init_a"}, true},
+		{"init ID mismatch", &filter{InitIDs: []string{"init_a"}}, &bufferedEvent{initID: "init_b"}, false},
+		{"run ID", &filter{InitIDs: []string{"init_a"}, RunIDs: []string{"run_a"}}, &bufferedEvent{runID: "run_a"}, true},
+		{"no IDs", &filter{RunIDs: []string{"run_a"}}, &bufferedEvent{}, false},
+		{"type & run ID", &filter{Types: []string{"tf:*"}, RunIDs: []string{"run_a"}}, &bufferedEvent{typ: event.ETAutomationWorkflowStarted, runID: "run_a"}, false},
+		{"permitted init ID", &filter{permit: permitInitID("init_a")}, &bufferedEvent{initID: "init_a"}, true},
+		{"unpermitted init ID", &filter{permit: permitInitID("init_a")}, &bufferedEvent{initID: "init_b"}, false},
+		{"unpermitted run ID", &filter{RunIDs: []string{"run_a"}, permit: permitInitID("init_a")}, &bufferedEvent{initID: "init_b", runID: "run_a"}, false},
+		{"no init ID", &filter{permit: permitInitID("init_a")}, &bufferedEvent{runID: "run_a"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			if got := c.f.match(c.be); got != c.expect {
+				t.Errorf("result mismatch. want: %t, got: %t", c.expect, got)
+			}
+		})
+	}
+
+	if err := (&filter{Types: []string{"tf:["}}).validate(); err == nil {
+		t.Errorf("expected malformed type pattern to fail validation")
+	}
+}
+
+func permitInitID(initID string) func(string) bool {
+	return func(id string) bool { return id == initID }
+}
+
+func TestHoldEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	websocketHandler, err := NewHandler(ctx, event.NewBus(ctx), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsh := websocketHandler.(*connections)
+
+	c := &conn{id: "conn_a"}
+	c.hold()
+	wsh.sendEvent(ctx, c, &bufferedEvent{seq: 1})
+	wsh.sendEvent(ctx, c, &bufferedEvent{seq: 2})
+	if len(c.held) != 2 || c.held[0].seq != 1 || c.held[1].seq != 2 {
+		t.Errorf("expected live events to be held in order while subscribing. got: %v", c.held)
+	}
+}
+
+func TestReplayBufferBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	websocketHandler, err := NewHandler(ctx, event.NewBus(ctx), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsh := websocketHandler.(*connections)
+
+	e := event.Event{Type: event.ETTransformPrint, ProfileID: "profile_a", Payload: strings.Repeat("a", 100)}
+	be, _, err := wsh.bufferEvent(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsh.bufferCap = len(be.data) * 2
+	for i := 0; i < 4; i++ {
+		if _, _, err := wsh.bufferEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(wsh.buffer) != 2 {
+		t.Errorf("expected buffer to be limited to 2 events by size. got: %d", len(wsh.buffer))
+	}
+	if wsh.bufferBytes > wsh.bufferCap {
+		t.Errorf("expected buffered bytes to be at most %d. got: %d", wsh.bufferCap, wsh.bufferBytes)
+	}
+	if wsh.buffer[len(wsh.buffer)-1].seq != 5 {
+		t.Errorf("expected the newest events to be kept. got seq: %d", wsh.buffer[len(wsh.buffer)-1].seq)
+	}
+
+	if initID, runID := payloadIDs(&event.WorkflowStartedEvent{InitID: "init_a", RunID: "run_a"}); initID != "init_a" || runID != "run_a" {
+		t.Errorf("expected identifiers to be read from pointer payloads. got initID: %q, runID: %q", initID, runID)
+	}
+}
+
+func TestRunInitIDs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	websocketHandler, err := NewHandler(ctx, event.NewBus(ctx), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsh := websocketHandler.(*connections)
+
+	events := []event.Event{
+		{Type: event.ETTransformStart, ProfileID: "profile_a", SessionID: "run_a", Payload: event.TransformLifecycle{RunID: "run_a", InitID: "init_a"}},
+		{Type: event.ETTransformPrint, ProfileID: "profile_a", SessionID: "run_a", Payload: event.TransformMessage{Msg: "hello"}},
+		{Type: event.ETTransformStop, ProfileID: "profile_a", SessionID: "run_a", Payload: event.TransformLifecycle{RunID: "run_a", InitID: "init_a"}},
+	}
+	for _, e := range events {
+		be, _, err := wsh.bufferEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if be.initID != "init_a" {
+			t.Errorf("expected %q event to be tied to the run's dataset. got: %q", e.Type, be.initID)
+		}
+	}
+	if len(wsh.runInitIDs) != 0 {
+		t.Errorf("expected stopped runs to no longer be tracked. got: %v", wsh.runInitIDs)
+	}
+
+	be, _, err := wsh.bufferEvent(event.Event{Type: event.ETTransformPrint, ProfileID: "profile_a", SessionID: "run_b", Payload: event.TransformMessage{Msg: "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if be.initID != "" {
+		t.Errorf("expected an event of an unknown run to have no init ID. got: %q", be.initID)
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := event.NewBus(ctx)
+	websocketHandler, err := NewHandler(ctx, bus, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsh := websocketHandler.(*connections)
+	wsh.bufferSize = 3
+
+	events := []event.Event{
+		{Type: event.ETTransformStart, ProfileID: "profile_a", SessionID: "run_a", Payload: event.TransformLifecycle{RunID: "run_a"}},
+		{Type: event.ETTransformPrint, ProfileID: "profile_a", SessionID: "run_a"},
+		{Type: event.ETDatasetSaveCompleted, ProfileID: "profile_b", Payload: event.DsSaveEvent{InitID: "init_a"}},
+		{Type: event.ETAutomationWorkflowStarted, ProfileID: "profile_a", Payload: event.WorkflowStartedEvent{InitID: "init_a", RunID: "run_b"}},
+	}
+	for _, e := range events {
+		if _, _, err := wsh.bufferEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(wsh.buffer) != 3 {
+		t.Fatalf("expected buffer to be limited to 3 events. got: %d", len(wsh.buffer))
+	}
+	if wsh.buffer[0].runID != "run_a" {
+		t.Errorf("expected transform session ID to be used as the run ID. got: %q", wsh.buffer[0].runID)
+	}
+	if wsh.buffer[2].initID != "init_a" || wsh.buffer[2].runID != "run_b" {
+		t.Errorf("expected payload identifiers to be extracted. got initID: %q, runID: %q", wsh.buffer[2].initID, wsh.buffer[2].runID)
+	}
+
+	got, missed := wsh.replayEvents("profile_a", nil, 1, 4)
+	if missed {
+		t.Errorf("expected no missed events resuming from a buffered sequence number")
+	}
+	if len(got) != 2 || got[0].seq != 2 || got[1].seq != 4 {
+		t.Errorf("expected events 2 & 4 to be replayed. got: %v", got)
+	}
+
+	got, missed = wsh.replayEvents("profile_a", &filter{Types: []string{"automation:*"}}, 0, 4)
+	if !missed {
+		t.Errorf("expected missed events resuming from a dropped sequence number")
+	}
+	if len(got) != 1 || got[0].seq != 4 {
+		t.Errorf("expected event 4 to be replayed. got: %v", got)
+	}
+
+	if got, _ := wsh.replayEvents("profile_a", nil, 1, 3); len(got) != 1 {
+		t.Errorf("expected events after the subscription sequence number to be excluded. got: %v", got)
+	}
+}
+
 func mockWriterAndRequest() (http.ResponseWriter, *http.Request) {
 	w := mockHijacker{
 		ResponseWriter: httptest.NewRecorder(),
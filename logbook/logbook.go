@@ -20,6 +20,7 @@ import (
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/auth/key"
 	"github.com/qri-io/qri/automation/run"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
@@ -40,6 +41,9 @@ var (
 	// ErrAccessDenied indicates insufficent privileges to perform a logbook
 	// operation
 	ErrAccessDenied = fmt.Errorf("access denied")
+	// ErrInvalidLog indicates a log failed verification against the public key
+	// of its author
+	ErrInvalidLog = fmt.Errorf("logbook: invalid log")
 
 	// NewTimestamp generates the current unix nanosecond time.
 	// This is mainly here for tests to override
@@ -364,6 +368,10 @@ func (book *Book) WriteDatasetInit(ctx context.Context, author *profile.Profile,
 		Timestamp: NewTimestamp(),
 	})
 
+	if err := book.signLogs(author, dsLog, branch); err != nil {
+		return "", err
+	}
+
 	dsLog.AddChild(branch)
 	authorLog.AddChild(dsLog)
 	initID := dsLog.ID()
@@ -408,6 +416,9 @@ func (book *Book) WriteDatasetRename(ctx context.Context, author *profile.Profil
 		Name:      newName,
 		Timestamp: NewTimestamp(),
 	})
+	if err := book.signLogs(author, dsLog.l); err != nil {
+		return err
+	}
 
 	err = book.publisher.Publish(ctx, event.ETDatasetRename, event.DsRename{
 		InitID:  initID,
@@ -519,6 +530,77 @@ func (book *Book) hasWriteAccess(ctx context.Context, log *oplog.Log, pro *profi
 	return nil
 }
 
+// signLogs signs dataset & branch logs with the author's private key. Logs are
+// re-signed on every write, so a log can always be checked against its author
+func (book *Book) signLogs(author *profile.Profile, logs ...*oplog.Log) error {
+	pk := author.PrivKey
+	if pk == nil && author.ID.Encode() == book.owner.ID.Encode() {
+		pk = book.owner.PrivKey
+	}
+	if pk == nil {
+		return fmt.Errorf("logbook: private key is required to sign logs")
+	}
+	for _, lg := range logs {
+		if err := lg.Sign(pk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyDatasetLog checks the log of a dataset was written by the holder of a
+// public key. The author log must belong to the profile the key identifies,
+// dataset & branch logs must be authored by that author log, and every dataset
+// & branch log must carry a signature that verifies against the key.
+// Signatures on author logs are made by the peer that sent the log, and are
+// checked by MergeLog when logs are synced
+func (book *Book) VerifyDatasetLog(ctx context.Context, initID string, pub crypto.PubKey) error {
+	if book == nil {
+		return ErrNoLogbook
+	}
+	profileID, err := key.IDFromPubKey(pub)
+	if err != nil {
+		return err
+	}
+	authorLog, err := book.UserDatasetBranchesLog(ctx, initID)
+	if err != nil {
+		return err
+	}
+	if authorLog == nil || authorLog.Model() != UserModel {
+		return fmt.Errorf("%w: dataset %q has no author log", ErrInvalidLog, initID)
+	}
+	if id := authorLog.FirstOpAuthorID(); id != profileID {
+		return fmt.Errorf("%w: author log belongs to profile %q, not key %q", ErrInvalidLog, id, profileID)
+	}
+
+	authorLogID := authorLog.ID()
+	var verify func(lg *oplog.Log) error
+	verify = func(lg *oplog.Log) error {
+		if id := lg.FirstOpAuthorID(); id != authorLogID {
+			return fmt.Errorf("%w: %s log %q is authored by %q, expected %q", ErrInvalidLog, ModelString(lg.Model()), lg.ID(), id, authorLogID)
+		}
+		if len(lg.Signature) == 0 {
+			return fmt.Errorf("%w: %s log %q is unsigned", ErrInvalidLog, ModelString(lg.Model()), lg.ID())
+		}
+		if err := lg.Verify(pub); err != nil {
+			return fmt.Errorf("%w: %s log %q: %s", ErrInvalidLog, ModelString(lg.Model()), lg.ID(), err)
+		}
+		for _, child := range lg.Logs {
+			if err := verify(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, dsLog := range authorLog.Logs {
+		if err := verify(dsLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WriteDatasetDeleteAll closes a dataset, marking it as deleted
 func (book *Book) WriteDatasetDeleteAll(ctx context.Context, pro *profile.Profile, initID string) error {
 	if book == nil {
@@ -540,6 +622,9 @@ func (book *Book) WriteDatasetDeleteAll(ctx context.Context, pro *profile.Profil
 		Model:     DatasetModel,
 		Timestamp: NewTimestamp(),
 	})
+	if err := book.signLogs(pro, dsLog.l); err != nil {
+		return err
+	}
 
 	err = book.publisher.Publish(ctx, event.ETDatasetDeleteAll, initID)
 	if err != nil {
@@ -578,6 +663,9 @@ func (book *Book) WriteVersionSave(ctx context.Context, author *profile.Profile,
 	}
 
 	book.appendVersionSave(branchLog, ds)
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return err
+	}
 	// TODO(dlong): Think about how to handle a failure exactly here, what needs to be rolled back?
 	err = book.save(ctx, nil, branchLog)
 	if err != nil {
@@ -633,6 +721,9 @@ func (book *Book) WriteTransformRun(ctx context.Context, author *profile.Profile
 	}
 
 	book.appendTransformRun(branchLog, rs)
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return err
+	}
 	vi := dsref.VersionInfo{
 		InitID:      initID,
 		RunID:       rs.ID,
@@ -716,6 +807,9 @@ func (book *Book) WriteVersionAmend(ctx context.Context, author *profile.Profile
 		Timestamp: ds.Commit.Timestamp.UnixNano(),
 		Note:      ds.Commit.Title,
 	})
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return err
+	}
 
 	return book.save(ctx, nil, branchLog)
 }
@@ -743,6 +837,9 @@ func (book *Book) WriteVersionDelete(ctx context.Context, author *profile.Profil
 		Size:  int64(revisions),
 		// TODO (b5) - finish
 	})
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return err
+	}
 
 	// Calculate the commits after collapsing deletions found at the tail of history (most recent).
 	items := branchToVersionInfos(branchLog, dsref.Ref{}, false)
@@ -784,6 +881,9 @@ func (book *Book) WriteRemotePush(ctx context.Context, author *profile.Profile,
 		Size:      int64(revisions),
 		Relations: []string{remoteAddr},
 	})
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return nil, nil, err
+	}
 
 	if err = book.save(ctx, nil, nil); err != nil {
 		return nil, nil, err
@@ -808,6 +908,9 @@ func (book *Book) WriteRemotePush(ctx context.Context, author *profile.Profile,
 			// we should consider returning copies, and adding explicit methods for
 			// modification.
 			branchLog.l.Ops = branchLog.l.Ops[:len(branchLog.l.Ops)-1]
+			if rollbackError = book.signLogs(author, branchLog.l); rollbackError != nil {
+				return
+			}
 			rollbackError = book.save(ctx, nil, nil)
 		})
 		return rollbackError
@@ -845,6 +948,9 @@ func (book *Book) WriteRemoteDelete(ctx context.Context, author *profile.Profile
 		Size:      int64(revisions),
 		Relations: []string{remoteAddr},
 	})
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return nil, nil, err
+	}
 
 	if err = book.save(ctx, nil, nil); err != nil {
 		return nil, nil, err
@@ -863,6 +969,9 @@ func (book *Book) WriteRemoteDelete(ctx context.Context, author *profile.Profile
 				return
 			}
 			branchLog.l.Ops = branchLog.l.Ops[:len(branchLog.l.Ops)-1]
+			if rollbackError = book.signLogs(author, branchLog.l); rollbackError != nil {
+				return
+			}
 			rollbackError = book.save(ctx, nil, nil)
 		})
 		return rollbackError
@@ -1193,6 +1302,9 @@ func (book *Book) ConstructDatasetLog(ctx context.Context, author *profile.Profi
 	for _, ds := range history {
 		book.appendVersionSave(branchLog, ds)
 	}
+	if err := book.signLogs(author, branchLog.l); err != nil {
+		return err
+	}
 	return book.save(ctx, nil, nil)
 }
 
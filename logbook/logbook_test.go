@@ -454,6 +454,53 @@ func TestProfileCanWrite(t *testing.T) {
 
 }
 
+func TestVerifyDatasetLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tr, cleanup := newTestRunner(t)
+	defer cleanup()
+
+	otherLogbook := tr.foreignLogbook(t, "janelle")
+	other := otherLogbook.Owner()
+
+	initID, log := GenerateExampleOplog(ctx, t, otherLogbook, "atmospheric_particulates", "/ipld/QmExample")
+	if err := tr.Book.MergeLog(ctx, other.PubKey, log); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.Book.VerifyDatasetLog(ctx, initID, other.PubKey); err != nil {
+		t.Errorf("expected log to verify against the author's key, got: %s", err)
+	}
+	if err := tr.Book.VerifyDatasetLog(ctx, initID, tr.Owner.PubKey); !errors.Is(err, logbook.ErrInvalidLog) {
+		t.Errorf("expected verifying against another key to return a wrap of logbook.ErrInvalidLog, got: %v", err)
+	}
+
+	// a branch log signed by someone other than the author fails
+	branch, err := tr.Book.BranchRef(ctx, dsref.Ref{Username: other.Peername, Name: "atmospheric_particulates"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := branch.Sign(tr.Owner.PrivKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Book.VerifyDatasetLog(ctx, initID, other.PubKey); !errors.Is(err, logbook.ErrInvalidLog) {
+		t.Errorf("expected log with a mismatched branch signature to return a wrap of logbook.ErrInvalidLog, got: %v", err)
+	}
+
+	// a branch log with its signature stripped fails
+	branch.Signature = nil
+	if err := tr.Book.VerifyDatasetLog(ctx, initID, other.PubKey); !errors.Is(err, logbook.ErrInvalidLog) {
+		t.Errorf("expected log with an unsigned branch to return a wrap of logbook.ErrInvalidLog, got: %v", err)
+	}
+
+	// logs written locally are signed by the author as they're written
+	localID := tr.WriteWorldBankExample(t)
+	if err := tr.Book.VerifyDatasetLog(ctx, localID, tr.Owner.PubKey); err != nil {
+		t.Errorf("expected locally written log to verify against the author's key, got: %s", err)
+	}
+}
+
 func TestPushModel(t *testing.T) {
 	tr, cleanup := newTestRunner(t)
 	defer cleanup()
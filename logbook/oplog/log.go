@@ -626,12 +626,15 @@ func (lg *Log) AddChild(l *Log) {
 // Merging relies on comparison of initialization operations, which
 // must be present to constitute a match
 func (lg *Log) Merge(l *Log) {
-	// if the incoming log has more operations, use it & clear the cache
+	// if the incoming log has more operations, use it & clear the cache. the
+	// incoming signature covers the incoming operations, so it comes along
 	if len(l.Ops) > len(lg.Ops) {
 		lg.Ops = l.Ops
 		lg.name = ""
 		lg.authorID = ""
-		lg.Signature = nil
+		lg.Signature = l.Signature
+	} else if len(lg.Signature) == 0 && len(l.Ops) == len(lg.Ops) {
+		lg.Signature = l.Signature
 	}
 
 LOOP:
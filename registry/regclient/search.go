@@ -2,6 +2,8 @@ package regclient
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 
 	"github.com/qri-io/qri/registry"
 )
@@ -38,15 +40,33 @@ func (c Client) Search(ctx context.Context, p *SearchParams) ([]registry.SearchR
 	}
 
 	params := &registry.SearchParams{
-		Q: p.Query,
-		//Filters: p.Filters,
-		Limit:  p.Limit,
-		Offset: p.Offset,
+		Q:       p.Query,
+		Filters: equalityFilters(p.Filters),
+		Limit:   p.Limit,
+		Offset:  p.Offset,
 	}
 	res := []registry.SearchResult{}
-	err := c.httpClient.CallMethod(ctx, "/registry/search", "GET", "", params, &res)
+	// search params are sent as a JSON body, GET requests only encode string
+	// maps as query params
+	err := c.httpClient.CallMethod(ctx, "/registry/search", http.MethodPost, "", params, &res)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
+
+// equalityFilters converts "eq" filters to registry field filters. Registries
+// only support matching field values, other relations are dropped
+func equalityFilters(filters []SearchFilter) map[string]string {
+	var res map[string]string
+	for _, f := range filters {
+		if f.Relation != "" && f.Relation != "eq" {
+			continue
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[f.Key] = fmt.Sprintf("%v", f.Value)
+	}
+	return res
+}
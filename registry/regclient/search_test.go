@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/qri-io/dataset"
 	"github.com/qri-io/qri/registry"
 	"github.com/qri-io/qri/registry/regserver/handlers"
 )
@@ -29,3 +30,43 @@ func TestSearchMethods(t *testing.T) {
 		t.Errorf("error executing search: %s", err)
 	}
 }
+
+func TestSearchSendsQueryAndFilters(t *testing.T) {
+	ctx := context.Background()
+
+	idx, err := registry.NewSearchIndex("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.IndexDatasets([]*dataset.Dataset{
+		{Peername: "b5", Name: "population", Meta: &dataset.Meta{Title: "World Population", Keywords: []string{"demographics"}}},
+		{Peername: "b5", Name: "population_density", Meta: &dataset.Meta{Title: "Population Density", Keywords: []string{"geography"}}},
+		{Peername: "nasa", Name: "meteorites", Meta: &dataset.Meta{Title: "Meteorite Landings"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handlers.NewRoutes(registry.Registry{Search: idx, Indexer: idx}))
+	c := NewClient(&Config{Location: srv.URL})
+
+	res, err := c.Search(ctx, &SearchParams{Query: "population", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Errorf("expected query to match 2 datasets, got %d", len(res))
+	}
+
+	res, err = c.Search(ctx, &SearchParams{
+		Query:   "population",
+		Filters: []SearchFilter{{Key: "keywords", Relation: "eq", Value: "geography"}},
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Value.Name != "population_density" {
+		t.Errorf("expected filter to match population_density, got: %#v", res)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	apiutil "github.com/qri-io/qri/api/util"
 	qhttp "github.com/qri-io/qri/lib/http"
@@ -30,6 +31,7 @@ func NewSearchHandler(s registry.Searchable) http.HandlerFunc {
 			p.Limit = apiutil.ReqParamInt(r, "limit", defaultLimit)
 			p.Offset = apiutil.ReqParamInt(r, "offset", defaultOffset)
 			p.Q = r.FormValue("q")
+			p.Filters = searchFilterParams(r)
 		}
 		results, err := s.Search(*p)
 		if err != nil {
@@ -39,3 +41,22 @@ func NewSearchHandler(s registry.Searchable) http.HandlerFunc {
 		apiutil.WriteResponse(w, results)
 	}
 }
+
+// searchFilterParamPrefix prefixes query params that filter search results by
+// field, eg: "filter.keywords=health"
+const searchFilterParamPrefix = "filter."
+
+// searchFilterParams reads field filters from request query params
+func searchFilterParams(r *http.Request) map[string]string {
+	var filters map[string]string
+	for key, vals := range r.URL.Query() {
+		if !strings.HasPrefix(key, searchFilterParamPrefix) || len(vals) == 0 {
+			continue
+		}
+		if filters == nil {
+			filters = map[string]string{}
+		}
+		filters[strings.TrimPrefix(key, searchFilterParamPrefix)] = vals[0]
+	}
+	return filters
+}
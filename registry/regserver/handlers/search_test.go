@@ -60,3 +60,17 @@ func TestSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestSearchFilterParams(t *testing.T) {
+	r := httptest.NewRequest("GET", "/registry/search?q=population&filter.keywords=health&filter.title=world&limit=10", nil)
+	got := searchFilterParams(r)
+	expect := map[string]string{"keywords": "health", "title": "world"}
+	if len(got) != len(expect) {
+		t.Fatalf("expected %d filters, got %d: %v", len(expect), len(got), got)
+	}
+	for k, v := range expect {
+		if got[k] != v {
+			t.Errorf("filter %q: expected %q, got %q", k, v, got[k])
+		}
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"context"
 	"net/http/httptest"
 
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qfs"
 	"github.com/qri-io/qri/auth/key"
 	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/base/dsfs"
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/profile"
 	"github.com/qri-io/qri/registry"
 	"github.com/qri-io/qri/registry/regclient"
 	"github.com/qri-io/qri/registry/regserver/handlers"
@@ -40,9 +44,38 @@ func NewMockServerRegistry(reg registry.Registry) (*regclient.Client, *httptest.
 
 // NewMemRegistry creates a new in-memory registry
 func NewMemRegistry(rem *remote.Server) registry.Registry {
+	// an in-memory search index can't fail to open
+	idx, _ := registry.NewSearchIndex("")
 	return registry.Registry{
 		Remote:   rem,
 		Profiles: registry.NewMemProfiles(),
+		Search:   idx,
+		Indexer:  idx,
+	}
+}
+
+// SearchIndexHooks returns remote options that keep a search index in sync
+// with the datasets pushed to & removed from a remote. Pushed versions are
+// read from fs
+func SearchIndexHooks(idx registry.Indexer, fs qfs.Filesystem) remote.OptionsFunc {
+	return func(o *remote.Options) {
+		o.DatasetPushed = func(ctx context.Context, pid profile.ID, ref dsref.Ref) error {
+			ds, err := dsfs.LoadDataset(ctx, fs, ref.Path)
+			if err != nil {
+				return err
+			}
+			ds.ID = ref.InitID
+			ds.Peername = ref.Username
+			ds.Name = ref.Name
+			return idx.IndexDatasets([]*dataset.Dataset{ds})
+		}
+		o.DatasetRemoved = func(ctx context.Context, pid profile.ID, ref dsref.Ref) error {
+			return idx.UnindexDatasets([]*dataset.Dataset{{
+				ID:       ref.InitID,
+				Peername: ref.Username,
+				Name:     ref.Name,
+			}})
+		}
 	}
 }
 
@@ -83,7 +116,12 @@ func NewTempRegistry(ctx context.Context, peername, tmpDirPrefix string, g key.C
 		AllowRemoves:     true,
 	}
 
-	rem, err := remote.NewServer(node, remoteCfg, node.Repo.Logbook(), r.Bus())
+	idx, err := registry.NewSearchIndex("")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rem, err := remote.NewServer(node, remoteCfg, node.Repo.Logbook(), r.Bus(), SearchIndexHooks(idx, r.Filesystem()))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -91,7 +129,8 @@ func NewTempRegistry(ctx context.Context, peername, tmpDirPrefix string, g key.C
 	reg := &registry.Registry{
 		Remote:   rem,
 		Profiles: registry.NewMemProfiles(),
-		Search:   MockRepoSearch{Repo: r},
+		Search:   idx,
+		Indexer:  idx,
 	}
 
 	return reg, teardown, nil
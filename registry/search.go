@@ -23,7 +23,10 @@ type Indexer interface {
 
 // SearchParams encapsulates parameters provided to Searchable.Search
 type SearchParams struct {
-	Q             string
+	Q string
+	// Filters restrict results to datasets where the named field contains
+	// every word of the filter value, eg: {"keywords": "health"}
+	Filters       map[string]string `json:",omitempty"`
 	Limit, Offset int
 }
 
@@ -0,0 +1,583 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/tabular"
+)
+
+// Fields a SearchIndex indexes. Query terms can be restricted to a single field
+// by prefixing the term with the field name, eg: "title:population"
+const (
+	SearchFieldName        = "name"
+	SearchFieldTitle       = "title"
+	SearchFieldDescription = "description"
+	SearchFieldKeywords    = "keywords"
+	SearchFieldColumns     = "columns"
+	SearchFieldReadme      = "readme"
+	SearchFieldCommit      = "commit"
+)
+
+// searchFields lists indexed fields in the order scores are summed
+var searchFields = []string{
+	SearchFieldName,
+	SearchFieldTitle,
+	SearchFieldKeywords,
+	SearchFieldColumns,
+	SearchFieldDescription,
+	SearchFieldReadme,
+	SearchFieldCommit,
+}
+
+// searchFieldBoosts weights matches by the field they occur in
+var searchFieldBoosts = map[string]float64{
+	SearchFieldName:        3,
+	SearchFieldTitle:       3,
+	SearchFieldKeywords:    2,
+	SearchFieldColumns:     1.5,
+	SearchFieldDescription: 1,
+	SearchFieldReadme:      0.5,
+	SearchFieldCommit:      0.5,
+}
+
+// SearchIndex is an inverted full-text index of datasets persisted to a file
+// on disk. SearchIndex implements both the Indexer and Searchable interfaces.
+// Datasets are indexed by ID, or username & name if the dataset has no ID.
+// Indexing a new version of a dataset replaces the previous version
+//
+// Changes are appended to a journal file next to the index, and folded into
+// the index file once the journal grows larger than the index
+type SearchIndex struct {
+	path string
+	// journalLen is the number of changes in the journal file
+	journalLen int
+
+	lock sync.RWMutex
+	docs map[string]*searchDoc
+	// postings maps field -> term -> document key -> term frequency
+	postings map[string]map[string]map[string]int
+	// fieldTerms counts all terms in a field across all documents, used to
+	// normalize scores by field length
+	fieldTerms map[string]int
+	// built records that every dataset of a collection has been indexed
+	built bool
+}
+
+var (
+	_ Indexer    = (*SearchIndex)(nil)
+	_ Searchable = (*SearchIndex)(nil)
+)
+
+// searchIndexFile is the format a SearchIndex is persisted in
+type searchIndexFile struct {
+	Built bool                  `json:"built"`
+	Docs  map[string]*searchDoc `json:"docs"`
+}
+
+// searchIndexChange is a single change appended to the journal of a
+// SearchIndex. Put replaces any document with the same key
+type searchIndexChange struct {
+	Put    *searchDoc `json:"put,omitempty"`
+	Remove string     `json:"remove,omitempty"`
+	Built  bool       `json:"built,omitempty"`
+}
+
+// minJournalLen is the fewest changes the journal holds before it's folded
+// into the index file
+const minJournalLen = 64
+
+// searchDoc is a single indexed dataset
+type searchDoc struct {
+	Key     string              `json:"key"`
+	Dataset *dataset.Dataset    `json:"dataset"`
+	Terms   map[string][]string `json:"terms"`
+}
+
+// NewSearchIndex opens a search index stored at path, creating the index if
+// no file exists. An empty path creates an in-memory index
+func NewSearchIndex(path string) (*SearchIndex, error) {
+	idx := &SearchIndex{
+		path:       path,
+		docs:       map[string]*searchDoc{},
+		postings:   map[string]map[string]map[string]int{},
+		fieldTerms: map[string]int{},
+	}
+	if path == "" {
+		return idx, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	f := searchIndexFile{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("reading search index: %w", err)
+	}
+	if f.Docs == nil {
+		// indexes written before the built marker are a bare map of documents
+		if err := json.Unmarshal(data, &f.Docs); err != nil {
+			return nil, fmt.Errorf("reading search index: %w", err)
+		}
+	}
+	for _, doc := range f.Docs {
+		idx.addDocNoLock(doc)
+	}
+	idx.built = f.Built
+	if err := idx.replayJournalNoLock(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *SearchIndex) journalPath() string {
+	return idx.path + ".journal"
+}
+
+// replayJournalNoLock applies changes from the journal file. A change that was
+// only partly written when the process stopped ends the journal. Only use this
+// when you have a surrounding lock
+func (idx *SearchIndex) replayJournalNoLock() error {
+	f, err := os.Open(idx.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		c := searchIndexChange{}
+		if err := dec.Decode(&c); err == io.EOF {
+			return nil
+		} else if err != nil {
+			// rewrite the index so later changes aren't appended after the
+			// partial change
+			f.Close()
+			return idx.writeToFileNoLock()
+		}
+		idx.applyNoLock(c)
+		idx.journalLen++
+	}
+}
+
+// applyNoLock applies a change to the in-memory index. Only use this when you
+// have a surrounding lock
+func (idx *SearchIndex) applyNoLock(c searchIndexChange) {
+	if c.Remove != "" {
+		idx.removeDocNoLock(c.Remove)
+	}
+	if c.Put != nil {
+		idx.removeDocNoLock(c.Put.Key)
+		idx.addDocNoLock(c.Put)
+	}
+	if c.Built {
+		idx.built = true
+	}
+}
+
+// Built returns true if the index has been marked as containing every dataset
+// of a collection
+func (idx *SearchIndex) Built() bool {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return idx.built
+}
+
+// MarkBuilt records that every dataset of a collection has been indexed. The
+// marker is persisted with the index
+func (idx *SearchIndex) MarkBuilt() error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.built = true
+	return idx.writeChangesNoLock([]searchIndexChange{{Built: true}})
+}
+
+// IndexDatasets adds datasets to the index
+func (idx *SearchIndex) IndexDatasets(dss []*dataset.Dataset) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	changes := make([]searchIndexChange, 0, len(dss))
+	for _, ds := range dss {
+		key, err := idx.docKeyNoLock(ds)
+		if err != nil {
+			return err
+		}
+		sum := searchSummary(ds)
+		if prev, ok := idx.docs[key]; ok && sum.ID == "" {
+			sum.ID = prev.Dataset.ID
+		}
+		c := searchIndexChange{
+			Put: &searchDoc{
+				Key:     key,
+				Dataset: sum,
+				Terms:   searchTerms(ds),
+			},
+		}
+		if ds.ID != "" {
+			// drop any version of this dataset indexed before it's ID was known
+			if nameKey := fmt.Sprintf("%s/%s", ds.Peername, ds.Name); idx.docs[nameKey] != nil {
+				c.Remove = nameKey
+			}
+		}
+		idx.applyNoLock(c)
+		changes = append(changes, c)
+	}
+	return idx.writeChangesNoLock(changes)
+}
+
+// UnindexDatasets removes datasets from the index
+func (idx *SearchIndex) UnindexDatasets(dss []*dataset.Dataset) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	changes := make([]searchIndexChange, 0, len(dss))
+	for _, ds := range dss {
+		key, err := idx.docKeyNoLock(ds)
+		if err != nil {
+			return err
+		}
+		if _, ok := idx.docs[key]; !ok {
+			continue
+		}
+		c := searchIndexChange{Remove: key}
+		idx.applyNoLock(c)
+		changes = append(changes, c)
+	}
+	return idx.writeChangesNoLock(changes)
+}
+
+// Search finds datasets that contain every term in the query, ranked by
+// relevance. Terms prefixed with a field name & filters only match within
+// that field. Searching with no query & no filters lists all datasets
+func (idx *SearchIndex) Search(p SearchParams) ([]SearchResult, error) {
+	q, err := parseSearchQuery(p)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	type scored struct {
+		doc   *searchDoc
+		score float64
+	}
+	var matches []scored
+	for key, doc := range idx.docs {
+		score, ok := idx.scoreNoLock(key, q)
+		if !ok {
+			continue
+		}
+		matches = append(matches, scored{doc: doc, score: score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return searchDocName(matches[i].doc) < searchDocName(matches[j].doc)
+	})
+
+	if p.Offset > 0 {
+		if p.Offset >= len(matches) {
+			return []SearchResult{}, nil
+		}
+		matches = matches[p.Offset:]
+	}
+	if p.Limit > 0 && len(matches) > p.Limit {
+		matches = matches[:p.Limit]
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, SearchResult{
+			Type:  "dataset",
+			ID:    m.doc.Dataset.Path,
+			Value: m.doc.Dataset,
+		})
+	}
+	return results, nil
+}
+
+// RenameDataset updates the name of an indexed dataset
+func (idx *SearchIndex) RenameDataset(id, newName string) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	doc, ok := idx.docs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	idx.removeDocNoLock(id)
+	ds := *doc.Dataset
+	ds.Name = newName
+	doc.Dataset = &ds
+	doc.Terms[SearchFieldName] = tokenize(ds.Peername + " " + ds.Name)
+	idx.addDocNoLock(doc)
+	return idx.writeChangesNoLock([]searchIndexChange{{Put: doc}})
+}
+
+// Len returns the number of indexed datasets
+func (idx *SearchIndex) Len() int {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return len(idx.docs)
+}
+
+// searchTerm is a single word of a query. An empty field matches any field
+type searchTerm struct {
+	field string
+	term  string
+}
+
+func parseSearchQuery(p SearchParams) ([]searchTerm, error) {
+	var terms []searchTerm
+	for _, word := range strings.Fields(p.Q) {
+		field := ""
+		if i := strings.Index(word, ":"); i > 0 {
+			if _, ok := searchFieldBoosts[strings.ToLower(word[:i])]; ok {
+				field = strings.ToLower(word[:i])
+				word = word[i+1:]
+			}
+		}
+		for _, t := range tokenize(word) {
+			terms = append(terms, searchTerm{field: field, term: t})
+		}
+	}
+
+	for field, value := range p.Filters {
+		field = strings.ToLower(field)
+		if _, ok := searchFieldBoosts[field]; !ok {
+			return nil, fmt.Errorf("unknown search field %q", field)
+		}
+		for _, t := range tokenize(value) {
+			terms = append(terms, searchTerm{field: field, term: t})
+		}
+	}
+	return terms, nil
+}
+
+// scoreNoLock scores a document against query terms using tf-idf weighted by
+// field boost & normalized by field length. ok is false if any term is
+// missing from the document
+func (idx *SearchIndex) scoreNoLock(key string, q []searchTerm) (score float64, ok bool) {
+	n := float64(len(idx.docs))
+	for _, st := range q {
+		matched := false
+		for _, field := range searchFields {
+			if st.field != "" && st.field != field {
+				continue
+			}
+			boost := searchFieldBoosts[field]
+			docs := idx.postings[field][st.term]
+			tf, found := docs[key]
+			if !found {
+				continue
+			}
+			matched = true
+			idf := math.Log(1 + n/float64(len(docs)))
+			length := float64(len(idx.docs[key].Terms[field]))
+			avgLength := float64(idx.fieldTerms[field]) / n
+			norm := 1.0
+			if avgLength > 0 {
+				norm = math.Sqrt(length / avgLength)
+			}
+			score += boost * (1 + math.Log(float64(tf))) * idf / math.Max(norm, 1)
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+func (idx *SearchIndex) addDocNoLock(doc *searchDoc) {
+	idx.docs[doc.Key] = doc
+	for field, terms := range doc.Terms {
+		if idx.postings[field] == nil {
+			idx.postings[field] = map[string]map[string]int{}
+		}
+		for _, t := range terms {
+			if idx.postings[field][t] == nil {
+				idx.postings[field][t] = map[string]int{}
+			}
+			idx.postings[field][t][doc.Key]++
+		}
+		idx.fieldTerms[field] += len(terms)
+	}
+}
+
+func (idx *SearchIndex) removeDocNoLock(key string) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for field, terms := range doc.Terms {
+		for _, t := range terms {
+			delete(idx.postings[field][t], key)
+			if len(idx.postings[field][t]) == 0 {
+				delete(idx.postings[field], t)
+			}
+		}
+		idx.fieldTerms[field] -= len(terms)
+	}
+	delete(idx.docs, key)
+}
+
+// writeChangesNoLock appends changes to the journal, folding the journal into
+// the index file once it holds more changes than the index has documents.
+// Only use this when you have a surrounding lock
+func (idx *SearchIndex) writeChangesNoLock(changes []searchIndexChange) error {
+	if idx.path == "" || len(changes) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(idx.journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	idx.journalLen += len(changes)
+
+	if idx.journalLen < minJournalLen || idx.journalLen <= len(idx.docs) {
+		return nil
+	}
+	return idx.writeToFileNoLock()
+}
+
+// writeToFileNoLock persists indexed documents & empties the journal. The
+// journal is removed after the index file is written, so replaying the journal
+// over either version of the index file produces the same documents. postings
+// are rebuilt when the index is opened. Only use this when you have a
+// surrounding lock
+func (idx *SearchIndex) writeToFileNoLock() error {
+	if idx.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(searchIndexFile{Built: idx.built, Docs: idx.docs})
+	if err != nil {
+		return err
+	}
+	tmp := idx.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return err
+	}
+	if err := os.Remove(idx.journalPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	idx.journalLen = 0
+	return nil
+}
+
+func searchDocKey(ds *dataset.Dataset) (string, error) {
+	if ds != nil && ds.ID != "" {
+		return ds.ID, nil
+	}
+	if ds == nil || ds.Peername == "" || ds.Name == "" {
+		return "", fmt.Errorf("indexing a dataset requires a username and name")
+	}
+	return fmt.Sprintf("%s/%s", ds.Peername, ds.Name), nil
+}
+
+// docKeyNoLock returns the key a dataset is indexed under. A dataset without
+// an ID uses the key of an indexed document with the same username & name, so
+// indexing the same dataset with & without an ID doesn't add duplicates
+func (idx *SearchIndex) docKeyNoLock(ds *dataset.Dataset) (string, error) {
+	key, err := searchDocKey(ds)
+	if err != nil || ds.ID != "" {
+		return key, err
+	}
+	if _, ok := idx.docs[key]; ok {
+		return key, nil
+	}
+	for k, doc := range idx.docs {
+		if doc.Dataset.Peername == ds.Peername && doc.Dataset.Name == ds.Name {
+			return k, nil
+		}
+	}
+	return key, nil
+}
+
+func searchDocName(doc *searchDoc) string {
+	return fmt.Sprintf("%s/%s", doc.Dataset.Peername, doc.Dataset.Name)
+}
+
+// searchTerms extracts the terms of each indexed field from a dataset
+func searchTerms(ds *dataset.Dataset) map[string][]string {
+	terms := map[string][]string{
+		SearchFieldName: tokenize(ds.Peername + " " + ds.Name),
+	}
+	if ds.Meta != nil {
+		terms[SearchFieldTitle] = tokenize(ds.Meta.Title)
+		terms[SearchFieldDescription] = tokenize(ds.Meta.Description)
+		terms[SearchFieldKeywords] = tokenize(strings.Join(ds.Meta.Keywords, " "))
+	}
+	if ds.Structure != nil && ds.Structure.Schema != nil {
+		if cols, _, err := tabular.ColumnsFromJSONSchema(ds.Structure.Schema); err == nil {
+			terms[SearchFieldColumns] = tokenize(strings.Join(cols.Titles(), " "))
+		}
+	}
+	if ds.Readme != nil {
+		terms[SearchFieldReadme] = tokenize(string(ds.Readme.ScriptBytes))
+	}
+	if ds.Commit != nil {
+		terms[SearchFieldCommit] = tokenize(ds.Commit.Title + " " + ds.Commit.Message)
+	}
+	return terms
+}
+
+// searchSummary drops dataset fields that aren't returned in search results
+func searchSummary(ds *dataset.Dataset) *dataset.Dataset {
+	sum := &dataset.Dataset{
+		ID:        ds.ID,
+		Peername:  ds.Peername,
+		Name:      ds.Name,
+		ProfileID: ds.ProfileID,
+		Path:      ds.Path,
+		Meta:      ds.Meta,
+		Commit:    ds.Commit,
+	}
+	if ds.Structure != nil {
+		sum.Structure = &dataset.Structure{
+			Format:   ds.Structure.Format,
+			Length:   ds.Structure.Length,
+			Entries:  ds.Structure.Entries,
+			ErrCount: ds.Structure.ErrCount,
+		}
+	}
+	return sum
+}
+
+// tokenize splits text into lowercase words
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,289 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+)
+
+func searchIndexTestDatasets() []*dataset.Dataset {
+	return []*dataset.Dataset{
+		{
+			Peername: "b5",
+			Name:     "world_bank_population",
+			Path:     "/ipfs/QmPop",
+			Meta: &dataset.Meta{
+				Title:       "World Bank Population",
+				Description: "population counts for every country by year",
+				Keywords:    []string{"population", "demographics"},
+			},
+			Structure: &dataset.Structure{
+				Format: "csv",
+				Schema: map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "array",
+						"items": []interface{}{
+							map[string]interface{}{"title": "country_code", "type": "string"},
+							map[string]interface{}{"title": "year", "type": "integer"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Peername: "nasa",
+			Name:     "meteorite_landings",
+			Path:     "/ipfs/QmMet",
+			Meta: &dataset.Meta{
+				Title:    "Meteorite Landings",
+				Keywords: []string{"space"},
+			},
+			Readme: &dataset.Readme{ScriptBytes: []byte("# Meteorites\nlandings near population centers by year")},
+		},
+		{
+			Peername: "who",
+			Name:     "life_expectancy",
+			Path:     "/ipfs/QmLife",
+			Meta: &dataset.Meta{
+				Title:       "Life Expectancy",
+				Description: "life expectancy at birth",
+				Keywords:    []string{"health", "demographics"},
+			},
+		},
+	}
+}
+
+func searchResultIDs(res []SearchResult) []string {
+	ids := []string{}
+	for _, r := range res {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestSearchIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qri_test_search_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "search_index.json")
+
+	idx, err := NewSearchIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexDatasets(searchIndexTestDatasets()); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		description string
+		params      SearchParams
+		expect      []string
+	}{
+		{"title matches rank above readme matches", SearchParams{Q: "population"}, []string{"/ipfs/QmPop", "/ipfs/QmMet"}},
+		{"all terms must match", SearchParams{Q: "population landings"}, []string{"/ipfs/QmMet"}},
+		{"column names", SearchParams{Q: "country"}, []string{"/ipfs/QmPop"}},
+		{"field prefix", SearchParams{Q: "readme:population"}, []string{"/ipfs/QmMet"}},
+		{"filter", SearchParams{Filters: map[string]string{"keywords": "demographics"}}, []string{"/ipfs/QmPop", "/ipfs/QmLife"}},
+		{"filter & query", SearchParams{Q: "life", Filters: map[string]string{"keywords": "demographics"}}, []string{"/ipfs/QmLife"}},
+		{"paging", SearchParams{Limit: 1, Offset: 1}, []string{"/ipfs/QmMet"}},
+		{"offset past end", SearchParams{Offset: 10}, []string{}},
+		{"no match", SearchParams{Q: "zebra"}, []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			res, err := idx.Search(c.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.expect, searchResultIDs(res)); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := idx.Search(SearchParams{Filters: map[string]string{"color": "blue"}}); err == nil {
+		t.Error("expected unknown filter field to error")
+	}
+
+	// re-indexing a dataset replaces the previous version
+	updated := searchIndexTestDatasets()[0]
+	updated.Path = "/ipfs/QmPop2"
+	updated.Meta.Title = "World Bank Census"
+	if err := idx.IndexDatasets([]*dataset.Dataset{updated}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.UnindexDatasets([]*dataset.Dataset{{Peername: "nasa", Name: "meteorite_landings"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen the index from disk
+	idx, err = NewSearchIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := idx.Search(SearchParams{Q: "census"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/ipfs/QmPop2"}, searchResultIDs(res)); diff != "" {
+		t.Errorf("result mismatch after reopening (-want +got):\n%s", diff)
+	}
+	if res, _ = idx.Search(SearchParams{Q: "meteorite"}); len(res) != 0 {
+		t.Errorf("expected unindexed dataset to be removed, got %v", searchResultIDs(res))
+	}
+
+	if err := idx.IndexDatasets([]*dataset.Dataset{{Name: "no_username"}}); err == nil {
+		t.Error("expected indexing a dataset without a username to error")
+	}
+}
+
+func TestSearchIndexBuiltMarker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qri_test_search_index_built")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "search_index.json")
+
+	// indexes written before the built marker load as unbuilt
+	legacy := `{"b5/population":{"key":"b5/population","dataset":{"peername":"b5","name":"population","path":"/ipfs/QmPop"},"terms":{"name":["b5","population"]}}}`
+	if err := ioutil.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := NewSearchIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Built() {
+		t.Error("expected legacy index to be unbuilt")
+	}
+	if idx.Len() != 1 {
+		t.Errorf("expected legacy index to load 1 dataset, got %d", idx.Len())
+	}
+
+	if err := idx.MarkBuilt(); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = NewSearchIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.Built() {
+		t.Error("expected built marker to persist")
+	}
+	if idx.Len() != 1 {
+		t.Errorf("expected reopened index to have 1 dataset, got %d", idx.Len())
+	}
+}
+
+func TestSearchIndexIDlessVersions(t *testing.T) {
+	idx, err := NewSearchIndex("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// indexing by name then by ID replaces the name-keyed document
+	if err := idx.IndexDatasets([]*dataset.Dataset{{Peername: "b5", Name: "population", Path: "/ipfs/QmA"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexDatasets([]*dataset.Dataset{{ID: "init_id", Peername: "b5", Name: "population", Path: "/ipfs/QmB"}}); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 1 {
+		t.Errorf("expected 1 indexed dataset, got %d", idx.Len())
+	}
+
+	// indexing by name after ID updates the ID-keyed document
+	if err := idx.IndexDatasets([]*dataset.Dataset{{Peername: "b5", Name: "population", Path: "/ipfs/QmC"}}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := idx.Search(SearchParams{Q: "population"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/ipfs/QmC"}, searchResultIDs(res)); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+	if err := idx.RenameDataset("init_id", "people"); err != nil {
+		t.Errorf("expected document to stay keyed by ID: %s", err)
+	}
+}
+
+func TestSearchIndexJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qri_test_search_index_journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "search_index.json")
+	journal := path + ".journal"
+
+	idx, err := NewSearchIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexDatasets(searchIndexTestDatasets()); err != nil {
+		t.Fatal(err)
+	}
+	// changes are appended to the journal instead of rewriting the index
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected indexing a few datasets not to write the index file, got: %v", err)
+	}
+	if _, err := os.Stat(journal); err != nil {
+		t.Errorf("expected indexing to write the journal: %s", err)
+	}
+	if idx, err = NewSearchIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != len(searchIndexTestDatasets()) {
+		t.Errorf("expected reopened index to replay %d datasets, got %d", len(searchIndexTestDatasets()), idx.Len())
+	}
+
+	// a partly written change ends the journal
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"put":{"key":"b5/par`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if idx, err = NewSearchIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexDatasets([]*dataset.Dataset{{Peername: "b5", Name: "partial", Path: "/ipfs/QmPartial"}}); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = NewSearchIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != len(searchIndexTestDatasets())+1 {
+		t.Errorf("expected changes after a partial change to be kept, got %d datasets", idx.Len())
+	}
+
+	// the journal is folded into the index file once it outgrows the index
+	compacted := false
+	for i := 0; i < minJournalLen && !compacted; i++ {
+		if err := idx.RenameDataset("b5/partial", "partial"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := os.Stat(journal)
+		compacted = os.IsNotExist(err)
+	}
+	if !compacted {
+		t.Error("expected journal to be folded into the index file")
+	}
+	if idx, err = NewSearchIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != len(searchIndexTestDatasets())+1 {
+		t.Errorf("expected compacted index to have %d datasets, got %d", len(searchIndexTestDatasets())+1, idx.Len())
+	}
+}
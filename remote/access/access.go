@@ -166,7 +166,7 @@ func ParseResource(str string) (Resource, error) {
 
 // MarshalJSON marshals the resource into a string separated by ":"
 func (r Resource) MarshalJSON() ([]byte, error) {
-	return []byte(strings.Join(r, ":")), nil
+	return json.Marshal(strings.Join(r, ":"))
 }
 
 // UnmarshalJSON unmarshals a slice of bytes into a Resource
@@ -269,7 +269,7 @@ func ParseAction(str string) (Action, error) {
 
 // MarshalJSON marshals the Action into a string separated by ":"
 func (a Action) MarshalJSON() ([]byte, error) {
-	return []byte(strings.Join(a, ":")), nil
+	return json.Marshal(strings.Join(a, ":"))
 }
 
 // UnmarshalJSON unmarshals the given slice of bytes into an Action
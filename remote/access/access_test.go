@@ -122,6 +122,24 @@ func TestPolicyJSON(t *testing.T) {
 			}
 		})
 	}
+
+	pol := Policy{{
+		Subject:   "*",
+		Resources: Resources{MustParseResource("dataset:_subject:*")},
+		Actions:   Actions{MustParseAction("remote:pull")},
+		Effect:    EffectAllow,
+	}}
+	data, err := json.Marshal(pol)
+	if err != nil {
+		t.Fatalf("marshaling policy: %s", err)
+	}
+	got := Policy{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshaling marshaled policy: %s", err)
+	}
+	if len(got) != 1 || !got[0].Resources.Contains(MustParseResource("dataset:b5:foo"), "b5") || !got[0].Actions.Contains(MustParseAction("remote:pull")) {
+		t.Errorf("policy didn't survive a JSON round trip. got: %#v", got)
+	}
 }
 
 func TestParseResource(t *testing.T) {
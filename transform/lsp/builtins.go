@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qri/transform/startf"
+	"go.starlark.net/starlark"
+)
+
+// builtin documents a predeclared name or a member of one
+type builtin struct {
+	name      string
+	kind      CompletionItemKind
+	signature string
+	doc       string
+}
+
+func (b builtin) completion() CompletionItem {
+	item := CompletionItem{Label: b.name, Kind: b.kind, Detail: b.signature}
+	if b.doc != "" {
+		item.Documentation = &MarkupContent{Kind: "markdown", Value: b.doc}
+	}
+	return item
+}
+
+func (b builtin) hover() string {
+	text := "```python\n" + b.signature + "\n```"
+	if b.doc != "" {
+		text += "\n\n" + b.doc
+	}
+	return text
+}
+
+// qriGlobals are the names qri predeclares in transform scripts
+var qriGlobals = []builtin{
+	{"load_dataset", KindFunction, "load_dataset(ref) Dataset", "load a version of another dataset by reference, eg: `load_dataset(\"b5/world_bank_population\")`"},
+	{"dataset", KindVariable, "dataset", "the dataset this transform writes to. Use `dataset.latest()` to read the current version & `dataset.commit(ds)` to save a new one"},
+	{"config", KindVariable, "config", "transform configuration values, a dict"},
+	{"secrets", KindVariable, "secrets", "secret values provided when running the transform, such as API keys. a dict"},
+	{"qri", KindModule, "qri", "qri builtins"},
+	{"error", KindFunction, "error(msg)", "halt the transform with an error message"},
+}
+
+// qriMembers are members of predeclared names, keyed by predeclared name
+var qriMembers = map[string][]builtin{
+	"dataset": {
+		{"latest", KindMethod, "dataset.latest() Dataset", "get the latest version of the dataset, a new Dataset if no versions exist"},
+		{"commit", KindMethod, "dataset.commit(ds)", "save a Dataset as the next version. commit can only be called once per transform"},
+	},
+	"qri": {
+		{"list_datasets", KindMethod, "qri.list_datasets() list", "list references to datasets in the local repo"},
+		{"history", KindMethod, "qri.history(ref, limit=25) list", "list versions of a dataset, newest first. each version is a dict with path, commit_time, commit_title, body_size, body_rows & run_id keys"},
+		{"load_version", KindMethod, "qri.load_version(ref, path) Dataset", "load a specific version of a dataset"},
+		{"diff", KindMethod, "qri.diff(a, b, key=None) list", "compare the bodies of two dataset versions row by row, matching rows by key columns. key defaults to the primaryKey of b's schema"},
+	},
+	"config": {
+		{"get", KindMethod, "config.get(key, default=None)", "get a configuration value"},
+	},
+	"secrets": {
+		{"get", KindMethod, "secrets.get(key, default=None)", "get a secret value"},
+	},
+}
+
+// datasetMembers are the fields & methods of a Dataset value
+var datasetMembers = []builtin{
+	{"body", KindField, "Dataset.body", "dataset body as a DataFrame. assign to body to set it"},
+	{"get_meta", KindMethod, "Dataset.get_meta() dict|None", "get dataset meta component"},
+	{"set_meta", KindMethod, "Dataset.set_meta(meta)", "set dataset meta component"},
+	{"get_structure", KindMethod, "Dataset.get_structure() dict|None", "get dataset structure component if one is defined"},
+	{"set_structure", KindMethod, "Dataset.set_structure(structure)", "set dataset structure component"},
+}
+
+// starlibModules lists modules transforms can load
+var starlibModules = []string{
+	"bsoup.star",
+	"dataframe.star",
+	"encoding/base64.star",
+	"encoding/csv.star",
+	"encoding/json.star",
+	"encoding/yaml.star",
+	"geo.star",
+	"hash.star",
+	"html.star",
+	"http.star",
+	"math.star",
+	"re.star",
+	"time.star",
+	"xlsx.star",
+	"zipfile.star",
+}
+
+var (
+	moduleMembersLock sync.Mutex
+	moduleMembersMemo = map[string][]string{}
+)
+
+// moduleMembers lists the attributes of a value exported by a starlib module
+func moduleMembers(module, name string) []string {
+	key := module + ":" + name
+	moduleMembersLock.Lock()
+	defer moduleMembersLock.Unlock()
+	if names, ok := moduleMembersMemo[key]; ok {
+		return names
+	}
+
+	var names []string
+	dict, err := startf.DefaultModuleLoader(&starlark.Thread{Name: "lsp"}, module)
+	if err != nil {
+		log.Debugw("loading module", "module", module, "err", err)
+	} else if v, ok := dict[name].(starlark.HasAttrs); ok {
+		names = v.AttrNames()
+		sort.Strings(names)
+	}
+	moduleMembersMemo[key] = names
+	return names
+}
+
+// universe documents starlark's builtin functions
+func universe() []builtin {
+	names := make([]string, 0, len(starlark.Universe))
+	for name := range starlark.Universe {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]builtin, 0, len(names))
+	for _, name := range names {
+		kind := KindFunction
+		if _, ok := starlark.Universe[name].(*starlark.Builtin); !ok {
+			kind = KindVariable
+		}
+		res = append(res, builtin{name: name, kind: kind, signature: name, doc: "starlark builtin"})
+	}
+	return res
+}
+
+func findBuiltin(list []builtin, name string) (builtin, bool) {
+	for _, b := range list {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return builtin{}, false
+}
@@ -0,0 +1,208 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/qri/transform/staticlark"
+)
+
+// completion suggests names for the expression before the cursor. Completing
+// after a dot suggests members of the receiver, otherwise predeclared names,
+// loaded modules & functions defined in the script are suggested
+func (s *Server) completion(p *TextDocumentPositionParams) (*CompletionList, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	runes := lineText(doc.text, p.Position.Line)
+	end := p.Position.Character
+	if end > len(runes) {
+		end = len(runes)
+	}
+	start := end
+	for start > 0 && (isIdentRune(runes[start-1]) || runes[start-1] == '.') {
+		start--
+	}
+	expr := string(runes[start:end])
+
+	var candidates []builtin
+	prefix := expr
+	if i := strings.LastIndex(expr, "."); i >= 0 {
+		prefix = expr[i+1:]
+		candidates = doc.members(expr[:i])
+	} else {
+		candidates = doc.globals()
+	}
+
+	items := []CompletionItem{}
+	seen := map[string]struct{}{}
+	for _, b := range candidates {
+		if _, ok := seen[b.name]; ok || !strings.HasPrefix(b.name, prefix) {
+			continue
+		}
+		seen[b.name] = struct{}{}
+		items = append(items, b.completion())
+	}
+	return &CompletionList{Items: items}, nil
+}
+
+// hover documents the name under the cursor
+func (s *Server) hover(p *TextDocumentPositionParams) (*Hover, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name, rng := wordAt(doc.text, p.Position)
+	if name == "" {
+		return nil, nil
+	}
+	text := doc.describe(name)
+	if text == "" {
+		return nil, nil
+	}
+	return &Hover{Contents: markdown(text), Range: &rng}, nil
+}
+
+// definition finds where the function under the cursor is defined
+func (s *Server) definition(p *TextDocumentPositionParams) ([]Location, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := wordAt(doc.text, p.Position)
+	fn, ok := doc.function(name)
+	if !ok {
+		return []Location{}, nil
+	}
+	return []Location{{URI: doc.uri, Range: wordRange(doc.text, fn.Pos)}}, nil
+}
+
+// globals lists names that can be referenced anywhere in a document
+func (doc *document) globals() []builtin {
+	res := []builtin{}
+	for _, fn := range doc.funcs {
+		res = append(res, functionBuiltin(fn))
+	}
+	aliases := make([]string, 0, len(doc.loads))
+	for alias := range doc.loads {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		res = append(res, doc.loadedBuiltin(alias))
+	}
+	res = append(res, qriGlobals...)
+	return append(res, universe()...)
+}
+
+// members lists attributes of a receiver expression. Receivers that aren't
+// modules or predeclared names are assumed to be Datasets
+func (doc *document) members(recv string) []builtin {
+	if bind, ok := doc.loads[recv]; ok {
+		res := []builtin{}
+		for _, name := range moduleMembers(bind.module, bind.name) {
+			res = append(res, builtin{
+				name:      name,
+				kind:      KindMethod,
+				signature: fmt.Sprintf("%s.%s", recv, name),
+				doc:       fmt.Sprintf("from %s", bind.module),
+			})
+		}
+		return res
+	}
+	if members, ok := qriMembers[recv]; ok {
+		return members
+	}
+	return datasetMembers
+}
+
+// describe returns hover documentation for a name, which may be dotted
+func (doc *document) describe(name string) string {
+	if fn, ok := doc.function(name); ok {
+		return describeFunction(fn)
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		recv, member := name[:i], name[i+1:]
+		if b, ok := findBuiltin(doc.members(recv), member); ok {
+			return b.hover()
+		}
+		return ""
+	}
+	if _, ok := doc.loads[name]; ok {
+		return doc.loadedBuiltin(name).hover()
+	}
+	if b, ok := findBuiltin(qriGlobals, name); ok {
+		return b.hover()
+	}
+	if b, ok := findBuiltin(universe(), name); ok {
+		return b.hover()
+	}
+	return ""
+}
+
+func (doc *document) loadedBuiltin(alias string) builtin {
+	bind := doc.loads[alias]
+	return builtin{
+		name:      alias,
+		kind:      KindModule,
+		signature: alias,
+		doc:       fmt.Sprintf("`%s` loaded from %s", bind.name, bind.module),
+	}
+}
+
+func functionBuiltin(fn staticlark.Function) builtin {
+	return builtin{
+		name:      fn.Name,
+		kind:      KindFunction,
+		signature: functionSignature(fn),
+		doc:       fn.Doc,
+	}
+}
+
+func functionSignature(fn staticlark.Function) string {
+	return fmt.Sprintf("def %s(%s)", fn.Name, strings.Join(fn.Params, ", "))
+}
+
+// describeFunction documents a script function, including the functions it
+// calls from the call graph
+func describeFunction(fn staticlark.Function) string {
+	text := "```python\n" + functionSignature(fn) + "\n```"
+	if fn.Doc != "" {
+		text += "\n\n" + fn.Doc
+	}
+	if len(fn.Calls) > 0 {
+		calls := make([]string, len(fn.Calls))
+		for i, c := range fn.Calls {
+			calls[i] = fmt.Sprintf("`%s`", c)
+		}
+		text += "\n\ncalls " + strings.Join(calls, ", ")
+	}
+	return text
+}
+
+// wordAt returns the possibly dotted name under a position & it's range. The
+// name extends left across dots to include the receiver, but only right to the
+// end of the identifier under the cursor
+func wordAt(text string, pos Position) (string, Range) {
+	runes := lineText(text, pos.Line)
+	col := pos.Character
+	if col > len(runes) {
+		col = len(runes)
+	}
+	start := col
+	for start > 0 && (isIdentRune(runes[start-1]) || runes[start-1] == '.') {
+		start--
+	}
+	end := col
+	for end < len(runes) && isIdentRune(runes[end]) {
+		end++
+	}
+	name := strings.Trim(string(runes[start:end]), ".")
+	rng := Range{
+		Start: Position{Line: pos.Line, Character: start},
+		End:   Position{Line: pos.Line, Character: end},
+	}
+	return name, rng
+}
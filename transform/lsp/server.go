@@ -0,0 +1,438 @@
+// Package lsp implements a language server for qri transform scripts. The
+// server speaks the Language Server Protocol over a reader & writer pair,
+// usually stdin & stdout, publishing diagnostics from static analysis and
+// providing completion, hover docs & go-to-definition
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	golog "github.com/ipfs/go-log"
+	"github.com/qri-io/qri/transform/staticlark"
+	"go.starlark.net/syntax"
+)
+
+var log = golog.Logger("lsp")
+
+// diagnosticSource names the server in diagnostics
+const diagnosticSource = "qri"
+
+// analyzeScript performs static analysis on a document (tests may override)
+var analyzeScript = staticlark.AnalyzeScript
+
+// ErrExitWithoutShutdown is returned by Serve when the client sends an exit
+// notification without first requesting shutdown
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server is a language server for starlark transform scripts. Documents are
+// kept in memory & analyzed in full each time they change
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	writeLock sync.Mutex
+	docs      map[string]*document
+	shutdown  bool
+}
+
+// document is an open text document
+type document struct {
+	uri     string
+	version int
+	text    string
+	// funcs & loads are kept from the last version of the document that
+	// parsed, so completion keeps working while a script is being edited
+	funcs []staticlark.Function
+	loads map[string]loadBinding
+}
+
+// loadBinding is a name bound by a load statement
+type loadBinding struct {
+	module string
+	name   string
+}
+
+// NewServer creates a server that reads requests from in & writes responses
+// to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*document{},
+	}
+}
+
+// Serve handles messages until the client exits, the input closes or the
+// context is cancelled. Serve returns nil after a shutdown request followed by
+// an exit notification
+func (s *Server) Serve(ctx context.Context) error {
+	msgs := make(chan *message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := readMessage(s.in)
+			if err != nil {
+				var rerr *responseError
+				if errors.As(err, &rerr) {
+					s.reply(nil, nil, rerr)
+					continue
+				}
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case msg := <-msgs:
+			if msg.Method == "exit" {
+				if !s.shutdown {
+					return ErrExitWithoutShutdown
+				}
+				return nil
+			}
+			s.handle(msg)
+		}
+	}
+}
+
+// handle dispatches a single message, replying to requests
+func (s *Server) handle(msg *message) {
+	var (
+		res interface{}
+		err error
+	)
+	switch msg.Method {
+	case "initialize":
+		res = s.initialize()
+	case "initialized":
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		p := &DidOpenTextDocumentParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			s.didOpen(p)
+		}
+	case "textDocument/didChange":
+		p := &DidChangeTextDocumentParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			s.didChange(p)
+		}
+	case "textDocument/didClose":
+		p := &DidCloseTextDocumentParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			s.didClose(p)
+		}
+	case "textDocument/completion":
+		p := &TextDocumentPositionParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			res, err = s.completion(p)
+		}
+	case "textDocument/hover":
+		p := &TextDocumentPositionParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			res, err = s.hover(p)
+		}
+	case "textDocument/definition":
+		p := &TextDocumentPositionParams{}
+		if err = unmarshalParams(msg, p); err == nil {
+			res, err = s.definition(p)
+		}
+	default:
+		if msg.isRequest() {
+			err = &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+		} else {
+			log.Debugw("ignoring notification", "method", msg.Method)
+		}
+	}
+
+	if !msg.isRequest() {
+		if err != nil {
+			log.Debugw("handling notification", "method", msg.Method, "err", err)
+		}
+		return
+	}
+	var rerr *responseError
+	if err != nil && !errors.As(err, &rerr) {
+		rerr = &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	s.reply(msg.ID, res, rerr)
+}
+
+func unmarshalParams(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// reply writes a response to a request
+func (s *Server) reply(id *json.RawMessage, res interface{}, rerr *responseError) {
+	msg := &message{ID: id, Error: rerr}
+	if id == nil {
+		// responses to unparsable messages have a null id
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+	if rerr == nil {
+		data, err := json.Marshal(res)
+		if err != nil {
+			msg.Error = &responseError{Code: codeInternalError, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	s.write(msg)
+}
+
+// notify sends a notification to the client
+func (s *Server) notify(method string, params interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		log.Debugw("encoding notification", "method", method, "err", err)
+		return
+	}
+	s.write(&message{Method: method, Params: data})
+}
+
+func (s *Server) write(msg *message) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := writeMessage(s.out, msg); err != nil {
+		log.Debugw("writing message", "err", err)
+	}
+}
+
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			// full document sync
+			"textDocumentSync": 1,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"."},
+			},
+			"hoverProvider":      true,
+			"definitionProvider": true,
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "qri",
+		},
+	}
+}
+
+func (s *Server) didOpen(p *DidOpenTextDocumentParams) {
+	doc := &document{uri: p.TextDocument.URI}
+	s.docs[doc.uri] = doc
+	s.update(doc, p.TextDocument.Version, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(p *DidChangeTextDocumentParams) {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		doc = &document{uri: p.TextDocument.URI}
+		s.docs[doc.uri] = doc
+	}
+	if len(p.ContentChanges) == 0 {
+		return
+	}
+	s.update(doc, p.TextDocument.Version, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(p *DidCloseTextDocumentParams) {
+	delete(s.docs, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update replaces the text of a document, analyzes it & publishes diagnostics
+func (s *Server) update(doc *document, version int, text string) {
+	doc.version = version
+	doc.text = text
+	filename := uriFilename(doc.uri)
+
+	if funcs, err := staticlark.Functions(filename, []byte(text)); err == nil {
+		doc.funcs = funcs
+		doc.loads = parseLoads(filename, text)
+	}
+
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     version,
+		Diagnostics: s.diagnostics(doc, filename),
+	})
+}
+
+// diagnostics analyzes a document, converting staticlark diagnostics & syntax
+// errors to LSP diagnostics
+func (s *Server) diagnostics(doc *document, filename string) []Diagnostic {
+	diags := []Diagnostic{}
+	results, err := analyzeScript(filename, []byte(doc.text), nil)
+	if err != nil {
+		var serr syntax.Error
+		if errors.As(err, &serr) {
+			return append(diags, Diagnostic{
+				Range:    wordRange(doc.text, serr.Pos),
+				Severity: SeverityError,
+				Code:     "syntax",
+				Source:   diagnosticSource,
+				Message:  serr.Msg,
+			})
+		}
+		// analysis can fail part way through, publish the failure along with
+		// whatever results were computed
+		log.Debugw("analyzing document", "uri", doc.uri, "err", err)
+		diags = append(diags, Diagnostic{
+			Range:    wordRange(doc.text, syntax.Position{}),
+			Severity: SeverityError,
+			Code:     "analysis",
+			Source:   diagnosticSource,
+			Message:  fmt.Sprintf("static analysis failed: %s", err),
+		})
+	}
+
+	for _, d := range results {
+		switch d.Category {
+		case staticlark.CategoryLeak:
+			diags = append(diags, Diagnostic{
+				Range:    wordRange(doc.text, d.Pos),
+				Severity: SeverityWarning,
+				Code:     d.Category,
+				Source:   diagnosticSource,
+				Message:  d.Message,
+			})
+		case staticlark.CategoryUnused:
+			fn, ok := doc.function(d.Message)
+			if !ok {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Range:    wordRange(doc.text, fn.Pos),
+				Severity: SeverityHint,
+				Code:     d.Category,
+				Source:   diagnosticSource,
+				Message:  fmt.Sprintf("function %s is never called", d.Message),
+			})
+		default:
+			diags = append(diags, Diagnostic{
+				Range:    wordRange(doc.text, d.Pos),
+				Severity: SeverityInformation,
+				Code:     d.Category,
+				Source:   diagnosticSource,
+				Message:  d.Message,
+			})
+		}
+	}
+	return diags
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", uri)}
+	}
+	return doc, nil
+}
+
+// function finds a function defined in the document
+func (doc *document) function(name string) (staticlark.Function, bool) {
+	for _, fn := range doc.funcs {
+		if fn.Name == name {
+			return fn, true
+		}
+	}
+	return staticlark.Function{}, false
+}
+
+// parseLoads maps names bound by load statements to the module & name they
+// load
+func parseLoads(filename, text string) map[string]loadBinding {
+	loads := map[string]loadBinding{}
+	f, err := syntax.Parse(filename, text, 0)
+	if err != nil {
+		return loads
+	}
+	for _, stmt := range f.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+		module, _ := load.Module.Value.(string)
+		for i, to := range load.To {
+			loads[to.Name] = loadBinding{module: module, name: load.From[i].Name}
+		}
+	}
+	return loads
+}
+
+// uriFilename converts a file URI to a path, falling back to the raw URI
+func uriFilename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// lineText returns a zero-indexed line of text as runes
+func lineText(text string, line int) []rune {
+	lines := strings.Split(text, "\n")
+	if line < 0 || line >= len(lines) {
+		return nil
+	}
+	return []rune(strings.TrimSuffix(lines[line], "\r"))
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// wordRange converts a starlark position to a range spanning the identifier
+// that begins there. Starlark positions are 1-based & count runes, LSP
+// positions are 0-based. Characters are treated as runes, which matches UTF-16
+// offsets for text in the basic multilingual plane
+func wordRange(text string, pos syntax.Position) Range {
+	line := int(pos.Line) - 1
+	col := int(pos.Col) - 1
+	if line < 0 {
+		line = 0
+	}
+	if col < 0 {
+		col = 0
+	}
+	end := col
+	runes := lineText(text, line)
+	for end < len(runes) && isIdentRune(runes[end]) {
+		end++
+	}
+	if end == col && end < len(runes) {
+		end++
+	}
+	return Range{
+		Start: Position{Line: line, Character: col},
+		End:   Position{Line: line, Character: end},
+	}
+}
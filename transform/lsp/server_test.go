@@ -0,0 +1,269 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/qri/transform/staticlark"
+	"go.starlark.net/syntax"
+)
+
+const testScript = `load("http.star", "http")
+
+def helper(rows):
+  """counts rows"""
+  return len(rows)
+
+def upload(data):
+  http.post("https://example.com", json_body=data)
+
+def transform(ds, ctx):
+  token = ctx.get_secret("api_token")
+  upload(token)
+  n = helper(ds.body)
+  print(n)
+
+def unused():
+  pass
+
+transform()
+`
+
+// testClient sends messages to a server & reads what it writes back
+type testClient struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	ids int
+}
+
+func newTestClient(t *testing.T) (*testClient, <-chan error) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	s := NewServer(serverR, serverW)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(context.Background())
+		serverW.Close()
+	}()
+	return &testClient{t: t, w: clientW, r: bufio.NewReader(clientR)}, done
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := writeMessage(c.w, &message{Method: method, Params: data}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// call sends a request & decodes the result into res
+func (c *testClient) call(method string, params, res interface{}) *responseError {
+	c.t.Helper()
+	c.ids++
+	id := json.RawMessage(strconv.Itoa(c.ids))
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := writeMessage(c.w, &message{ID: &id, Method: method, Params: data}); err != nil {
+		c.t.Fatal(err)
+	}
+	msg := c.read()
+	if msg.ID == nil || string(*msg.ID) != string(id) {
+		c.t.Fatalf("expected response to request %s, got: %#v", id, msg)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if res != nil {
+		if err := json.Unmarshal(msg.Result, res); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+func (c *testClient) read() *message {
+	c.t.Helper()
+	msg, err := readMessage(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// readDiagnostics reads a publishDiagnostics notification
+func (c *testClient) readDiagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got: %#v", msg)
+	}
+	p := PublishDiagnosticsParams{}
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+func TestServer(t *testing.T) {
+	c, done := newTestClient(t)
+	uri := "file:///home/analyst/transform.star"
+
+	caps := map[string]interface{}{}
+	if err := c.call("initialize", map[string]interface{}{}, &caps); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := caps["capabilities"]; !ok {
+		t.Errorf("expected initialize result to include capabilities, got: %v", caps)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "starlark", Version: 1, Text: testScript},
+	})
+	diags := c.readDiagnostics()
+	codes := []string{}
+	for _, d := range diags.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	if diff := cmp.Diff([]string{"leak", "unused"}, codes); diff != "" {
+		t.Errorf("diagnostic codes mismatch (-want +got):\n%s", diff)
+	}
+	expectLeak := Range{Start: Position{Line: 11, Character: 2}, End: Position{Line: 11, Character: 8}}
+	if diff := cmp.Diff(expectLeak, diags.Diagnostics[0].Range); diff != "" {
+		t.Errorf("leak range mismatch (-want +got):\n%s", diff)
+	}
+	expectUnused := Range{Start: Position{Line: 15, Character: 4}, End: Position{Line: 15, Character: 10}}
+	if diff := cmp.Diff(expectUnused, diags.Diagnostics[1].Range); diff != "" {
+		t.Errorf("unused range mismatch (-want +got):\n%s", diff)
+	}
+
+	// introducing a syntax error reports it
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testScript + "def broken(:\n"}},
+	})
+	diags = c.readDiagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Severity != SeverityError {
+		t.Errorf("expected a single syntax error, got: %v", diags.Diagnostics)
+	}
+
+	completionLabels := func(line, char int) []string {
+		t.Helper()
+		list := &CompletionList{}
+		if err := c.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: line, Character: char},
+		}, list); err != nil {
+			t.Fatal(err)
+		}
+		labels := []string{}
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	// completion uses functions from the last version of the script that parsed
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testScript + "hel\ndataset.\nhttp.po\nload_\n"}},
+	})
+	c.readDiagnostics()
+	if diff := cmp.Diff([]string{"helper"}, completionLabels(19, 3)); diff != "" {
+		t.Errorf("function completion mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"latest", "commit"}, completionLabels(20, 8)); diff != "" {
+		t.Errorf("dataset completion mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"post"}, completionLabels(21, 7)); diff != "" {
+		t.Errorf("module completion mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"load_dataset"}, completionLabels(22, 5)); diff != "" {
+		t.Errorf("global completion mismatch (-want +got):\n%s", diff)
+	}
+
+	// hover a function call
+	hov := &Hover{}
+	if err := c.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 12, Character: 7},
+	}, hov); err != nil {
+		t.Fatal(err)
+	}
+	expectHover := "```python\ndef helper(rows)\n```\n\ncounts rows\n\ncalls `len`"
+	if diff := cmp.Diff(expectHover, hov.Contents.Value); diff != "" {
+		t.Errorf("hover mismatch (-want +got):\n%s", diff)
+	}
+
+	// go to the definition of a function call
+	locs := []Location{}
+	if err := c.call("textDocument/definition", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 11, Character: 3},
+	}, &locs); err != nil {
+		t.Fatal(err)
+	}
+	expectLocs := []Location{{URI: uri, Range: Range{Start: Position{Line: 6, Character: 4}, End: Position{Line: 6, Character: 10}}}}
+	if diff := cmp.Diff(expectLocs, locs); diff != "" {
+		t.Errorf("definition mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := c.call("textDocument/formatting", map[string]interface{}{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected unknown method to error with method not found, got: %v", err)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean exit, got: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for server to exit")
+	}
+}
+
+func TestDiagnosticsAnalysisError(t *testing.T) {
+	prev := analyzeScript
+	defer func() { analyzeScript = prev }()
+	analyzeScript = func(filename string, src []byte, axioms *staticlark.Axioms) ([]staticlark.Diagnostic, error) {
+		return []staticlark.Diagnostic{
+			{Pos: syntax.MakePosition(&filename, 16, 1), Category: staticlark.CategoryUnused, Message: "unused"},
+		}, errors.New("oh noes")
+	}
+
+	funcs, err := staticlark.Functions("transform.star", []byte(testScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &document{uri: "file:///transform.star", text: testScript, funcs: funcs}
+	diags := (&Server{}).diagnostics(doc, "transform.star")
+
+	// a failed analysis is reported along with the results that were computed
+	codes := []string{}
+	for _, d := range diags {
+		codes = append(codes, d.Code)
+	}
+	if diff := cmp.Diff([]string{"analysis", "unused"}, codes); diff != "" {
+		t.Fatalf("diagnostic codes mismatch (-want +got):\n%s", diff)
+	}
+	if diags[0].Severity != SeverityError || diags[0].Message != "static analysis failed: oh noes" {
+		t.Errorf("unexpected analysis diagnostic: %v", diags[0])
+	}
+}
@@ -343,6 +343,21 @@ func (d *Dataset) setBody(val starlark.Value) error {
 	return nil
 }
 
+// SetBodyRows replaces the dataset body with rows of tabular data, marking the
+// body as changed. columns names each column of the rows
+func (d *Dataset) SetBodyRows(rows [][]interface{}, columns []string) error {
+	if d.frozen {
+		return fmt.Errorf("cannot set, Dataset is frozen")
+	}
+	df, err := dataframe.NewDataFrame(rows, columns, nil, d.outconf)
+	if err != nil {
+		return err
+	}
+	d.bodyFrame = df
+	d.changes["body"] = struct{}{}
+	return nil
+}
+
 // writeStructure determines the destination data structure for writing a
 // dataset body, falling back to a default json structure based on input values
 // if no prior structure exists
@@ -0,0 +1,313 @@
+package startf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/qri-io/qri/event"
+	starhttp "github.com/qri-io/starlib/http"
+	"go.starlark.net/starlark"
+)
+
+// ErrNoRecordedResponse is returned when replaying a request that wasn't
+// recorded
+var ErrNoRecordedResponse = errors.New("no recorded response")
+
+// httpRecorderLocal is the starlark thread local key the recorder is stored
+// under
+const httpRecorderLocal = "qri.HTTPRecorder"
+
+// MaxHTTPFixturesSize caps the total number of response body bytes a recorder
+// stores. Once the cap is reached the recorder stops recording & marks its
+// fixtures as truncated. Responses are still streamed to the script in full
+const MaxHTTPFixturesSize = 10 << 20
+
+// redactedHeaders are response headers that are never recorded, they commonly
+// carry credentials
+var redactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authenticate",
+	"Set-Cookie",
+	"Www-Authenticate",
+}
+
+// HTTPRecorder captures HTTP requests made by transform scripts & the
+// responses they receive. A recorder created with NewHTTPReplayer serves
+// previously recorded responses instead of making requests
+type HTTPRecorder struct {
+	replay bool
+
+	lock     sync.Mutex
+	fixtures *event.HTTPFixtures
+	// size is the number of response body bytes recorded so far
+	size int64
+	// used tracks which exchanges have been served when replaying
+	used map[int]bool
+}
+
+// NewHTTPRecorder creates a recorder that makes requests & records them
+func NewHTTPRecorder() *HTTPRecorder {
+	return &HTTPRecorder{
+		fixtures: &event.HTTPFixtures{
+			Exchanges: []event.HTTPExchange{},
+			Blobs:     map[string][]byte{},
+		},
+	}
+}
+
+// NewHTTPReplayer creates a recorder that serves responses from fixtures.
+// Each recorded exchange is served once, in the order it was recorded. No
+// requests are made over the network
+func NewHTTPReplayer(fixtures *event.HTTPFixtures) *HTTPRecorder {
+	if fixtures == nil {
+		fixtures = &event.HTTPFixtures{}
+	}
+	return &HTTPRecorder{
+		replay:   true,
+		fixtures: fixtures,
+		used:     map[int]bool{},
+	}
+}
+
+// Replaying is true if the recorder serves recorded responses
+func (r *HTTPRecorder) Replaying() bool {
+	return r.replay
+}
+
+// Fixtures returns the exchanges recorded so far
+func (r *HTTPRecorder) Fixtures() *event.HTTPFixtures {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	f := &event.HTTPFixtures{
+		Exchanges: make([]event.HTTPExchange, len(r.fixtures.Exchanges)),
+		Blobs:     make(map[string][]byte, len(r.fixtures.Blobs)),
+		Truncated: r.fixtures.Truncated,
+	}
+	copy(f.Exchanges, r.fixtures.Exchanges)
+	for hash, blob := range r.fixtures.Blobs {
+		f.Blobs[hash] = blob
+	}
+	return f
+}
+
+func (r *HTTPRecorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	key := requestKey(req.Method, req.URL.String(), reqBody)
+
+	if r.replay {
+		return r.replayResponse(req, key)
+	}
+
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	remaining := MaxHTTPFixturesSize - r.size
+	truncated := r.fixtures.Truncated
+	r.lock.Unlock()
+	if truncated {
+		return res, nil
+	}
+
+	// read at most one byte past the remaining budget, stitching whatever was
+	// read back onto the unread rest of the body so the script sees the full
+	// response either way
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, remaining+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > remaining {
+		res.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		r.lock.Lock()
+		r.fixtures.Truncated = true
+		r.lock.Unlock()
+		return res, nil
+	}
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	bodyHash := hashBytes(body)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.fixtures.Truncated || r.size+int64(len(body)) > MaxHTTPFixturesSize {
+		// a concurrent request used up the budget first
+		r.fixtures.Truncated = true
+		return res, nil
+	}
+	r.size += int64(len(body))
+	r.fixtures.Blobs[bodyHash] = body
+	r.fixtures.Exchanges = append(r.fixtures.Exchanges, event.HTTPExchange{
+		RequestKey: key,
+		Method:     req.Method,
+		URL:        redactURL(req),
+		StatusCode: res.StatusCode,
+		Header:     redactHeader(res.Header),
+		BodyHash:   bodyHash,
+	})
+	return res, nil
+}
+
+// readCloser combines a reader with the closer of the body it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactURL returns the URL of a request without credentials, query or
+// fragment, which commonly carry API keys. The full URL is still part of the
+// request key, which is a hash
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// redactHeader copies a response header, dropping redactedHeaders
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, key := range redactedHeaders {
+		h.Del(key)
+	}
+	return h
+}
+
+// replayResponse serves the first unused exchange that matches a request key
+func (r *HTTPRecorder) replayResponse(req *http.Request, key string) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, ex := range r.fixtures.Exchanges {
+		if r.used[i] || ex.RequestKey != key {
+			continue
+		}
+		body, ok := r.fixtures.Blobs[ex.BodyHash]
+		if !ok {
+			return nil, fmt.Errorf("recorded response body %s is missing", ex.BodyHash)
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", ex.StatusCode, http.StatusText(ex.StatusCode)),
+			StatusCode:    ex.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        ex.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w for %s %s", ErrNoRecordedResponse, req.Method, req.URL)
+}
+
+// requestKey identifies a request by method, URL & body
+func requestKey(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type (
+	httpRecorderCtxKey struct{}
+	budgetCtxKey       struct{}
+)
+
+// starlarkTransport routes requests through the recorder attached to the
+// request context, if any, and counts bytes against the run budget attached to
+// the request context
+type starlarkTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *starlarkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if b, ok := req.Context().Value(budgetCtxKey{}).(*budget); ok && b != nil {
+		// count bytes where they cross the network, below the recorder, so
+		// bytes the recorder reads count against the budget & replayed
+		// responses don't
+		next = &budgetTransport{next: next, b: b}
+	}
+	if rec, ok := req.Context().Value(httpRecorderCtxKey{}).(*HTTPRecorder); ok {
+		return rec.roundTrip(req, next)
+	}
+	return next.RoundTrip(req)
+}
+
+// budgetTransport counts request & response body bytes against a budget
+type budgetTransport struct {
+	next http.RoundTripper
+	b    *budget
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.b.addNetworkBytes(req.ContentLength); err != nil {
+		return nil, err
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = &budgetBody{ReadCloser: res.Body, b: t.b}
+	return res, nil
+}
+
+// withThreadLocals binds a request to the context of the step running on a
+// starlark thread, so requests are interrupted when the step is canceled or
+// exceeds a limit, and attaches the recorder & run budget stored on the thread
+func withThreadLocals(thread *starlark.Thread, req *http.Request) *http.Request {
+	if thread == nil {
+		return req
+	}
+	ctx := req.Context()
+	if stepCtx, ok := thread.Local(contextLocal).(context.Context); ok && stepCtx != nil {
+		ctx = stepCtx
+	}
+	if rec, ok := thread.Local(httpRecorderLocal).(*HTTPRecorder); ok && rec != nil {
+		ctx = context.WithValue(ctx, httpRecorderCtxKey{}, rec)
+	}
+	if b, ok := thread.Local(budgetLocal).(*budget); ok && b != nil {
+		ctx = context.WithValue(ctx, budgetCtxKey{}, b)
+	}
+	if ctx == req.Context() {
+		return req
+	}
+	return req.WithContext(ctx)
+}
+
+func init() {
+	// route starlib http requests through the starlark transport, which is a
+	// passthrough unless a recorder or budget is attached to the request
+	starhttp.Client = &http.Client{Transport: &starlarkTransport{next: http.DefaultTransport}}
+}
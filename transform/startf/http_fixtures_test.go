@@ -0,0 +1,148 @@
+package startf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"go.starlark.net/starlark"
+)
+
+func TestRecordReplayHTTP(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"foo":[["bar","baz","bat"]]}`))
+	}))
+	url := s.URL
+
+	run := func(rec *HTTPRecorder) (*dataset.Dataset, error) {
+		ds := &dataset.Dataset{Transform: &dataset.Transform{}}
+		ds.Transform.SetScriptFile(scriptFile(t, "testdata/fetch.star"))
+		err := ExecScript(ctx, ds, RecordHTTP(rec), func(o *ExecOpts) {
+			o.Globals["test_server_url"] = starlark.String(url)
+		})
+		return ds, err
+	}
+	body := func(ds *dataset.Dataset) string {
+		data, err := ioutil.ReadAll(ds.BodyFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	rec := NewHTTPRecorder()
+	recorded, err := run(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if requests != 1 {
+		t.Fatalf("expected 1 request to be made, got: %d", requests)
+	}
+
+	fixtures := rec.Fixtures()
+	if len(fixtures.Exchanges) != 1 {
+		t.Fatalf("expected 1 recorded exchange, got: %d", len(fixtures.Exchanges))
+	}
+	ex := fixtures.Exchanges[0]
+	if ex.Method != http.MethodGet || ex.URL != url || ex.StatusCode != http.StatusOK {
+		t.Errorf("unexpected exchange: %#v", ex)
+	}
+	if got := string(fixtures.Blobs[ex.BodyHash]); got != `{"foo":[["bar","baz","bat"]]}` {
+		t.Errorf("recorded body mismatch. got: %q", got)
+	}
+
+	// replaying serves the recorded response with the server closed
+	replayed, err := run(NewHTTPReplayer(fixtures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected replay not to make requests, got %d total requests", requests)
+	}
+	if a, b := body(recorded), body(replayed); a != b {
+		t.Errorf("replayed body mismatch. recorded: %q, replayed: %q", a, b)
+	}
+
+	// each exchange is served once
+	rep := NewHTTPReplayer(fixtures)
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	if _, err := rep.roundTrip(req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rep.roundTrip(req, nil); !errors.Is(err, ErrNoRecordedResponse) {
+		t.Errorf("expected replaying an unrecorded request to error with ErrNoRecordedResponse, got: %v", err)
+	}
+}
+
+func TestRecordHTTPRedactsAndCaps(t *testing.T) {
+	big := bytes.Repeat([]byte("a"), MaxHTTPFixturesSize+1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/big" {
+			w.Write(big)
+			return
+		}
+		w.Write([]byte("small"))
+	}))
+	defer s.Close()
+
+	rec := NewHTTPRecorder()
+	get := func(url string) []byte {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.RequestURI = ""
+		res, err := rec.roundTrip(req, http.DefaultTransport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	get(s.URL + "/small?api_key=secret")
+	f := rec.Fixtures()
+	if len(f.Exchanges) != 1 {
+		t.Fatalf("expected 1 recorded exchange, got: %d", len(f.Exchanges))
+	}
+	ex := f.Exchanges[0]
+	if strings.Contains(ex.URL, "secret") {
+		t.Errorf("expected recorded URL to drop the query string, got: %q", ex.URL)
+	}
+	if ex.Header.Get("Set-Cookie") != "" {
+		t.Errorf("expected recorded header to drop Set-Cookie, got: %v", ex.Header)
+	}
+	if ex.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("expected recorded header to keep Content-Type, got: %v", ex.Header)
+	}
+
+	// responses past the cap are streamed in full, but not recorded
+	if got := get(s.URL + "/big"); len(got) != len(big) {
+		t.Errorf("expected the full body to be read. want %d bytes, got %d", len(big), len(got))
+	}
+	f = rec.Fixtures()
+	if !f.Truncated {
+		t.Error("expected fixtures to be marked truncated")
+	}
+	if len(f.Exchanges) != 1 {
+		t.Errorf("expected the capped exchange not to be recorded, got %d exchanges", len(f.Exchanges))
+	}
+	get(s.URL + "/small")
+	if f = rec.Fixtures(); len(f.Exchanges) != 1 {
+		t.Errorf("expected recording to stop once truncated, got %d exchanges", len(f.Exchanges))
+	}
+}
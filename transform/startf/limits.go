@@ -0,0 +1,256 @@
+package startf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.starlark.net/starlark"
+)
+
+// ErrLimitExceeded matches errors from transform runs that exceed a resource
+// limit, use errors.Is to check. The error itself will be a *LimitError
+var ErrLimitExceeded = errors.New("resource limit exceeded")
+
+// Resources that can be limited
+const (
+	// LimitSteps limits starlark execution steps
+	LimitSteps = "steps"
+	// LimitMemory limits allocated bytes
+	LimitMemory = "memory"
+	// LimitDuration limits wall clock time
+	LimitDuration = "duration"
+	// LimitNetwork limits bytes sent & received over HTTP
+	LimitNetwork = "network"
+)
+
+// memoryCheckInterval is how often memory limits are checked
+var memoryCheckInterval = time.Second
+
+// heapObjectsMetric is the runtime metric memory limits are checked against.
+// Unlike runtime.ReadMemStats, reading it doesn't stop the world
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+const (
+	// budgetLocal is the starlark thread local key the run budget is stored
+	// under
+	budgetLocal = "qri.Budget"
+	// contextLocal is the starlark thread local key the context of the running
+	// step is stored under
+	contextLocal = "qri.Context"
+)
+
+// Limits caps the resources a transform run may use across all of it's steps.
+// Zero values are unlimited
+type Limits struct {
+	// MaxSteps is the maximum number of starlark execution steps
+	MaxSteps uint64
+	// MaxAllocBytes is the maximum growth of the live heap while steps are
+	// running. Go can't attribute heap use to a goroutine, so the heap is
+	// measured for the whole process & the limit is approximate when runs
+	// execute concurrently
+	MaxAllocBytes uint64
+	// MaxDuration is the maximum wall clock time
+	MaxDuration time.Duration
+	// MaxNetworkBytes is the maximum number of HTTP request & response body
+	// bytes
+	MaxNetworkBytes int64
+}
+
+// IsZero is true when no limits are set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// LimitError describes a resource limit a run exceeded
+type LimitError struct {
+	Resource string
+	Limit    string
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("transform exceeded %s limit of %s", e.Resource, e.Limit)
+}
+
+// Is makes LimitError match ErrLimitExceeded
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// budget tracks resource use of a run against limits, cancelling the starlark
+// thread when a limit is exceeded
+type budget struct {
+	limits Limits
+	thread *starlark.Thread
+
+	lock      sync.Mutex
+	started   time.Time
+	heapStart uint64
+	netBytes  int64
+	// steps counts work done outside the starlark interpreter
+	steps uint64
+	// cancel cancels the context of the running step
+	cancel context.CancelFunc
+	err    *LimitError
+}
+
+func newBudget(limits Limits, thread *starlark.Thread) *budget {
+	if limits.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(limits.MaxSteps)
+	}
+	b := &budget{limits: limits, thread: thread}
+	if limits.MaxNetworkBytes > 0 {
+		thread.SetLocal(budgetLocal, b)
+	}
+	return b
+}
+
+// watch checks wall clock & memory use until the returned stop function is
+// called, returning a context for the running step that is canceled when a
+// limit is exceeded. The wall clock starts with the first call to watch
+func (b *budget) watch(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	b.lock.Lock()
+	if b.started.IsZero() {
+		b.started = time.Now()
+		if b.limits.MaxAllocBytes > 0 {
+			b.heapStart = heapObjectBytes()
+		}
+	}
+	b.cancel = cancel
+	started := b.started
+	b.lock.Unlock()
+
+	var timer *time.Timer
+	if d := b.limits.MaxDuration; d > 0 {
+		timer = time.AfterFunc(d-time.Since(started), func() {
+			b.exceed(LimitDuration, d.String())
+		})
+	}
+
+	done := make(chan struct{})
+	if b.limits.MaxAllocBytes > 0 {
+		go func() {
+			ticker := time.NewTicker(memoryCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					b.checkMemory()
+				}
+			}
+		}()
+	}
+
+	return ctx, func() {
+		close(done)
+		if timer != nil {
+			timer.Stop()
+		}
+		cancel()
+	}
+}
+
+func (b *budget) checkMemory() {
+	max := b.limits.MaxAllocBytes
+	if heap := heapObjectBytes(); heap > b.heapStart && heap-b.heapStart > max {
+		b.exceed(LimitMemory, humanize.Bytes(max))
+	}
+}
+
+// addNetworkBytes counts bytes sent or received over the network, erroring if
+// the network limit is exceeded
+func (b *budget) addNetworkBytes(n int64) error {
+	max := b.limits.MaxNetworkBytes
+	if max == 0 || n <= 0 {
+		return nil
+	}
+	b.lock.Lock()
+	b.netBytes += n
+	over := b.netBytes > max
+	b.lock.Unlock()
+	if over {
+		return b.exceed(LimitNetwork, humanize.Bytes(uint64(max)))
+	}
+	return nil
+}
+
+// addSteps counts work done outside the starlark interpreter, eg: rows
+// processed by SQL steps, as execution steps, erroring if the step limit is
+// exceeded
+func (b *budget) addSteps(n uint64) error {
+	max := b.limits.MaxSteps
+	if max == 0 {
+		return nil
+	}
+	b.lock.Lock()
+	b.steps += n
+	over := b.steps+b.thread.ExecutionSteps() > max
+	b.lock.Unlock()
+	if over {
+		return b.exceed(LimitSteps, strconv.FormatUint(max, 10))
+	}
+	return nil
+}
+
+// exceed records the first exceeded limit & cancels the thread
+func (b *budget) exceed(resource, limit string) *LimitError {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err == nil {
+		b.err = &LimitError{Resource: resource, Limit: limit}
+		b.thread.Cancel(b.err.Error())
+		if b.cancel != nil {
+			b.cancel()
+		}
+	}
+	return b.err
+}
+
+// exceeded returns the limit the run exceeded, nil if no limit was exceeded
+func (b *budget) exceeded() *LimitError {
+	b.lock.Lock()
+	steps := b.steps
+	b.lock.Unlock()
+	if max := b.limits.MaxSteps; max > 0 && steps+b.thread.ExecutionSteps() >= max {
+		b.exceed(LimitSteps, strconv.FormatUint(max, 10))
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err
+}
+
+// heapObjectBytes is the number of bytes occupied by heap objects of the
+// process, live or not yet swept
+func heapObjectBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// budgetBody counts bytes read from a response body against a budget
+type budgetBody struct {
+	io.ReadCloser
+	b *budget
+}
+
+func (bb *budgetBody) Read(p []byte) (int, error) {
+	n, err := bb.ReadCloser.Read(p)
+	if lerr := bb.b.addNetworkBytes(int64(n)); lerr != nil {
+		return n, lerr
+	}
+	return n, err
+}
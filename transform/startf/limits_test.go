@@ -0,0 +1,105 @@
+package startf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"go.starlark.net/starlark"
+)
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	prevInterval := memoryCheckInterval
+	memoryCheckInterval = time.Millisecond
+	defer func() { memoryCheckInterval = prevInterval }()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			// respond only once the client gives up
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(strings.Repeat("a", 1024)))
+	}))
+	defer s.Close()
+
+	loop := "for i in range(1000000000):\n  x = i\n"
+	fetch := "load(\"http.star\", \"http\")\nres = http.get(test_server_url)\nbody = res.body()\n"
+	hang := "load(\"http.star\", \"http\")\nres = http.get(test_server_url + \"/hang\")\n"
+
+	cases := []struct {
+		resource string
+		limits   Limits
+		script   string
+	}{
+		{LimitSteps, Limits{MaxSteps: 1000}, loop},
+		{LimitDuration, Limits{MaxDuration: 10 * time.Millisecond}, loop},
+		// the wall clock limit interrupts requests the script is waiting on
+		{LimitDuration, Limits{MaxDuration: 10 * time.Millisecond}, hang},
+		{LimitNetwork, Limits{MaxNetworkBytes: 100}, fetch},
+	}
+
+	for _, c := range cases {
+		t.Run(c.resource, func(t *testing.T) {
+			ds := &dataset.Dataset{
+				Transform: &dataset.Transform{
+					Steps: []*dataset.TransformStep{
+						{Name: "transform", Syntax: "starlark", Script: c.script},
+					},
+				},
+			}
+			runner := NewStepRunner(ds, SetLimits(c.limits), func(o *ExecOpts) {
+				o.Globals["test_server_url"] = starlark.String(s.URL)
+			})
+			err := runner.RunStep(ctx, ds, ds.Transform.Steps[0])
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected error to match ErrLimitExceeded, got: %v", err)
+			}
+			var lerr *LimitError
+			if !errors.As(err, &lerr) || lerr.Resource != c.resource {
+				t.Errorf("expected %s limit to be exceeded, got: %v", c.resource, err)
+			}
+		})
+	}
+
+	// bytes read by the recorder count against the network limit
+	ds := &dataset.Dataset{
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Name: "transform", Syntax: "starlark", Script: fetch},
+			},
+		},
+	}
+	rec := NewHTTPRecorder()
+	runner := NewStepRunner(ds, SetLimits(Limits{MaxNetworkBytes: 100}), RecordHTTP(rec), func(o *ExecOpts) {
+		o.Globals["test_server_url"] = starlark.String(s.URL)
+	})
+	if err := runner.RunStep(ctx, ds, ds.Transform.Steps[0]); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected recording run to exceed the network limit, got: %v", err)
+	}
+	if f := rec.Fixtures(); len(f.Exchanges) != 0 {
+		t.Errorf("expected no exchanges to be recorded, got: %d", len(f.Exchanges))
+	}
+
+	// scripts within their limits succeed
+	ds = &dataset.Dataset{
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Name: "transform", Syntax: "starlark", Script: fetch},
+			},
+		},
+	}
+	limits := Limits{MaxSteps: 100000, MaxDuration: time.Minute, MaxNetworkBytes: 4096}
+	runner = NewStepRunner(ds, SetLimits(limits), func(o *ExecOpts) {
+		o.Globals["test_server_url"] = starlark.String(s.URL)
+	})
+	if err := runner.RunStep(ctx, ds, ds.Transform.Steps[0]); err != nil {
+		t.Fatal(err)
+	}
+}
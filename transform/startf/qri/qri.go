@@ -1,11 +1,19 @@
 package qri
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/dsref"
+	"github.com/qri-io/qri/logbook"
 	"github.com/qri-io/qri/repo"
+	stards "github.com/qri-io/qri/transform/startf/ds"
+	"github.com/qri-io/starlib/dataframe"
+	"github.com/qri-io/starlib/util"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 )
@@ -19,15 +27,31 @@ var (
 	qriModule starlark.StringDict
 )
 
-// NewModule creates a new qri module instance
-func NewModule(repo repo.Repo) *Module {
-	return &Module{repo: repo}
+// DefaultHistoryLimit is the number of versions qri.history returns when no
+// limit is given
+const DefaultHistoryLimit = 25
+
+// NewModule creates a new qri module instance. target is the dataset the
+// transform writes to, versions loaded by the module are recorded as it's
+// transform resources. Any of repo, book & loader may be nil, builtins that
+// depend on them error when called
+func NewModule(ctx context.Context, target *dataset.Dataset, repo repo.Repo, book *logbook.Book, loader dsref.Loader) *Module {
+	return &Module{
+		ctx:    ctx,
+		repo:   repo,
+		book:   book,
+		loader: loader,
+		ds:     target,
+	}
 }
 
 // Module encapsulates state for a qri starlark module
 type Module struct {
-	repo repo.Repo
-	ds   *dataset.Dataset
+	ctx    context.Context
+	repo   repo.Repo
+	book   *logbook.Book
+	loader dsref.Loader
+	ds     *dataset.Dataset
 }
 
 // Namespace produces this module's exported namespace
@@ -45,6 +69,9 @@ func (m *Module) Struct() *starlarkstruct.Struct {
 // AddAllMethods augments a starlark.StringDict with all qri builtins. Should really only be used during "transform" step
 func (m *Module) AddAllMethods(sd starlark.StringDict) starlark.StringDict {
 	sd["list_datasets"] = starlark.NewBuiltin("list_datasets", m.ListDatasets)
+	sd["history"] = starlark.NewBuiltin("history", m.History)
+	sd["load_version"] = starlark.NewBuiltin("load_version", m.LoadVersion)
+	sd["diff"] = starlark.NewBuiltin("diff", m.Diff)
 	return sd
 }
 
@@ -65,3 +92,174 @@ func (m *Module) ListDatasets(thread *starlark.Thread, _ *starlark.Builtin, args
 	}
 	return l, nil
 }
+
+// History lists versions of a dataset, newest first. Each version is a dict
+// with path, commit_time, commit_title, body_size, body_rows & run_id keys
+func (m *Module) History(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var (
+		refstr string
+		limit  = DefaultHistoryLimit
+	)
+	if err := starlark.UnpackArgs("history", args, kwargs, "ref", &refstr, "limit?", &limit); err != nil {
+		return starlark.None, err
+	}
+	if m.book == nil {
+		return starlark.None, fmt.Errorf("no logbook available to read dataset history")
+	}
+
+	ref, err := dsref.Parse(refstr)
+	if err != nil {
+		return starlark.None, fmt.Errorf("%q is not a valid dataset reference: %w", refstr, err)
+	}
+	if ref.Username == "me" {
+		ref.Username = m.book.Owner().Peername
+	}
+
+	items, err := m.book.Items(m.context(), ref, 0, limit, "history")
+	if err != nil {
+		return starlark.None, fmt.Errorf("getting history of %s: %w", ref.Human(), err)
+	}
+
+	versions := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		versions = append(versions, map[string]interface{}{
+			"path":         item.Path,
+			"commit_time":  item.CommitTime.UTC().Format(time.RFC3339),
+			"commit_title": item.CommitTitle,
+			"body_size":    item.BodySize,
+			"body_rows":    item.BodyRows,
+			"run_id":       item.RunID,
+		})
+	}
+	return util.Marshal(versions)
+}
+
+// LoadVersion loads a specific version of a dataset by reference & path
+func (m *Module) LoadVersion(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var refstr, path string
+	if err := starlark.UnpackArgs("load_version", args, kwargs, "ref", &refstr, "path", &path); err != nil {
+		return starlark.None, err
+	}
+
+	ds, err := m.loadVersion(refstr, path)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	if m.ds != nil && m.ds.Transform != nil {
+		if m.ds.Transform.Resources == nil {
+			m.ds.Transform.Resources = map[string]*dataset.TransformResource{}
+		}
+		m.ds.Transform.Resources[ds.Path] = &dataset.TransformResource{
+			Path: fmt.Sprintf("%s/%s@%s", ds.Peername, ds.Name, ds.Path),
+		}
+	}
+
+	outconf, _ := thread.Local("OutputConfig").(*dataframe.OutputConfig)
+	return stards.NewDataset(ds, outconf), nil
+}
+
+// Diff compares the bodies of two dataset versions row by row, matching rows
+// by key columns. a & b are dataset references, which may include a version
+// path. key defaults to the primaryKey of b's schema. Returns a list of dicts
+// with type ("add", "remove" or "modify"), key, left & right keys
+func (m *Module) Diff(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var (
+		a, b   string
+		keyVal starlark.Value
+	)
+	if err := starlark.UnpackArgs("diff", args, kwargs, "a", &a, "b", &b, "key?", &keyVal); err != nil {
+		return starlark.None, err
+	}
+
+	key, err := keyColumns(keyVal)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	left, err := m.loadVersion(a, "")
+	if err != nil {
+		return starlark.None, err
+	}
+	right, err := m.loadVersion(b, "")
+	if err != nil {
+		return starlark.None, err
+	}
+
+	if len(key) == 0 {
+		if key, err = base.PrimaryKey(right.Structure); err != nil {
+			return starlark.None, err
+		}
+		if len(key) == 0 {
+			return starlark.None, fmt.Errorf("dataset schema has no primaryKey, specify key columns to compare rows")
+		}
+	}
+
+	deltas, err := base.DiffBodiesByKey(m.context(), left, right, key, 0, -1)
+	if err != nil {
+		return starlark.None, err
+	}
+
+	res := make([]interface{}, 0, len(deltas))
+	for _, d := range deltas {
+		row := map[string]interface{}{
+			"type":  d.Type,
+			"key":   d.Key,
+			"left":  nil,
+			"right": nil,
+		}
+		if d.Left != nil {
+			row["left"] = d.Left
+		}
+		if d.Right != nil {
+			row["right"] = d.Right
+		}
+		res = append(res, row)
+	}
+	return util.Marshal(res)
+}
+
+// loadVersion loads a dataset, setting the version path if path is non-empty
+func (m *Module) loadVersion(refstr, path string) (*dataset.Dataset, error) {
+	if m.loader == nil {
+		return nil, fmt.Errorf("no dataset loader available to load versions")
+	}
+	if path != "" {
+		ref, err := dsref.Parse(refstr)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid dataset reference: %w", refstr, err)
+		}
+		ref.Path = path
+		refstr = ref.String()
+	}
+	return m.loader.LoadDataset(m.context(), refstr)
+}
+
+func (m *Module) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// keyColumns converts a key argument, a column title or list of titles, to a
+// list of column titles
+func keyColumns(v starlark.Value) ([]string, error) {
+	switch x := v.(type) {
+	case nil, starlark.NoneType:
+		return nil, nil
+	case starlark.String:
+		return []string{x.GoString()}, nil
+	case *starlark.List:
+		key := make([]string, x.Len())
+		for i := 0; i < x.Len(); i++ {
+			s, ok := starlark.AsString(x.Index(i))
+			if !ok {
+				return nil, fmt.Errorf("key must be a column title or list of column titles")
+			}
+			key[i] = s
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be a column title or list of column titles, got %s", v.Type())
+}
@@ -21,12 +21,14 @@ type HTTPGuard struct {
 	NetworkEnabled bool
 }
 
-// Allowed implements starlib/http RequestGuard
-func (h *HTTPGuard) Allowed(_ *starlark.Thread, req *http.Request) (*http.Request, error) {
+// Allowed implements starlib/http RequestGuard. Requests made from a thread
+// with an HTTPRecorder are recorded or replayed, requests from a thread with a
+// network limit count against it
+func (h *HTTPGuard) Allowed(thread *starlark.Thread, req *http.Request) (*http.Request, error) {
 	if !h.NetworkEnabled {
 		return nil, ErrNtwkDisabled
 	}
-	return req, nil
+	return withThreadLocals(thread, req), nil
 }
 
 // EnableNtwk allows network calls
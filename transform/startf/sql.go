@@ -0,0 +1,369 @@
+package startf
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/tabular"
+	"github.com/qri-io/qri/dsref"
+	stards "github.com/qri-io/qri/transform/startf/ds"
+	"github.com/qri-io/starlib/dataframe"
+	// register the pure-go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLBodyTable is the name of the table SQL steps use to query the body of
+// the dataset being transformed
+const SQLBodyTable = "body"
+
+// SQLRefPrefix marks a double-quoted identifier in a SQL step as a dataset
+// reference, eg: "qri:me/cities"
+const SQLRefPrefix = "qri:"
+
+// sqlRefPattern matches double-quoted identifiers that start with SQLRefPrefix
+var sqlRefPattern = regexp.MustCompile(`"` + SQLRefPrefix + `([^"\s]+)"`)
+
+// sqlStringPattern matches single-quoted SQL string literals
+var sqlStringPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// sqlWordPattern matches SQL keywords & unquoted identifiers
+var sqlWordPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$]*`)
+
+// sqlDeniedWords are keywords & functions that write to the database, or
+// reach files outside of it. Queries that use them are refused, even in
+// positions SQLite would read them as identifiers
+var sqlDeniedWords = map[string]bool{
+	"ALTER":          true,
+	"ANALYZE":        true,
+	"ATTACH":         true,
+	"CREATE":         true,
+	"DELETE":         true,
+	"DETACH":         true,
+	"DROP":           true,
+	"INSERT":         true,
+	"LOAD_EXTENSION": true,
+	"PRAGMA":         true,
+	"REINDEX":        true,
+	"REPLACE":        true,
+	"UPDATE":         true,
+	"VACUUM":         true,
+}
+
+// ErrSQLNotReadOnly indicates a SQL step isn't a single read-only query
+var ErrSQLNotReadOnly = fmt.Errorf("sql steps must be a single SELECT query")
+
+// RunSQLStep runs a transform step written in SQL. The query runs against a
+// temporary SQLite database with the current body of the dataset loaded as the
+// "body" table. Datasets referenced by double-quoted identifiers prefixed with
+// SQLRefPrefix are loaded as tables named by the identifier, eg:
+// SELECT * FROM body JOIN "qri:me/cities". Steps must be a single read-only
+// query, statements that write or attach databases are refused with
+// ErrSQLNotReadOnly. Query results replace the dataset
+// body, committed the same way a starlark dataset.commit call is. Each row
+// loaded or returned counts as an execution step against the run's limits
+func (r *StepRunner) RunSQLStep(ctx context.Context, ds *dataset.Dataset, st *dataset.TransformStep) (err error) {
+	query, ok := st.Script.(string)
+	if !ok {
+		return fmt.Errorf("sql step Script must be a string. got %T", st.Script)
+	}
+	if err := checkSQLReadOnly(query); err != nil {
+		return err
+	}
+
+	ctx, stopWatch := r.budget.watch(ctx)
+	defer stopWatch()
+	defer func() {
+		if err == nil {
+			return
+		}
+		if lerr := r.budget.exceeded(); lerr != nil {
+			err = lerr
+		}
+	}()
+
+	// tables are stored in a temp file instead of memory, so bodies larger
+	// than memory can be queried
+	dir, err := ioutil.TempDir("", "qri_sql_step")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "step.db"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := r.loadSQLBody(ctx, db, SQLBodyTable, ds); err != nil {
+		return fmt.Errorf("loading dataset body: %w", err)
+	}
+
+	for _, ref := range sqlDatasetRefs(query) {
+		if r.dsLoader == nil {
+			return fmt.Errorf("cannot query %q, loading datasets is not enabled", ref)
+		}
+		if _, err := dsref.Parse(ref); err != nil {
+			return fmt.Errorf("invalid dataset reference %q: %w", ref, err)
+		}
+		refDs, err := r.dsLoader.LoadDataset(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("loading %q: %w", ref, err)
+		}
+		addTransformResource(ds, refDs)
+		if err := r.loadSQLBody(ctx, db, SQLRefPrefix+ref, refDs); err != nil {
+			return fmt.Errorf("loading %q: %w", ref, err)
+		}
+	}
+
+	// queries can't write to the database, even if they get past
+	// checkSQLReadOnly
+	if _, err := db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return err
+	}
+	columns, rows, err := r.querySQL(ctx, db, query)
+	if err != nil {
+		return err
+	}
+
+	outconf, _ := r.thread.Local("OutputConfig").(*dataframe.OutputConfig)
+	starDs := stards.NewDataset(ds, outconf)
+	if err := starDs.SetBodyRows(rows, columns); err != nil {
+		return err
+	}
+	return r.onCommit(starDs)
+}
+
+// checkSQLReadOnly returns ErrSQLNotReadOnly unless a query is a single
+// statement that only reads tables. Statements like ATTACH DATABASE or
+// VACUUM INTO would let a transform read & write files on the host
+func checkSQLReadOnly(query string) error {
+	code := strings.TrimSpace(sqlCode(query))
+	code = strings.TrimSpace(strings.TrimSuffix(code, ";"))
+	if strings.Contains(code, ";") {
+		return fmt.Errorf("%w: found more than one statement", ErrSQLNotReadOnly)
+	}
+	locs := sqlWordPattern.FindAllStringIndex(code, -1)
+	if len(locs) == 0 {
+		return fmt.Errorf("%w: query is empty", ErrSQLNotReadOnly)
+	}
+	switch first := strings.ToUpper(code[locs[0][0]:locs[0][1]]); first {
+	case "SELECT", "WITH", "VALUES":
+	default:
+		return fmt.Errorf("%w: %s statements aren't allowed", ErrSQLNotReadOnly, first)
+	}
+	for _, loc := range locs {
+		word := strings.ToUpper(code[loc[0]:loc[1]])
+		if word == "REPLACE" && strings.HasPrefix(strings.TrimSpace(code[loc[1]:]), "(") {
+			// the replace() string function
+			continue
+		}
+		if sqlDeniedWords[word] {
+			return fmt.Errorf("%w: %s isn't allowed", ErrSQLNotReadOnly, word)
+		}
+	}
+	return nil
+}
+
+// sqlCode blanks out the comments, string literals & quoted identifiers of a
+// query, leaving the keywords & unquoted identifiers SQLite parses
+func sqlCode(query string) string {
+	code := make([]byte, 0, len(query))
+	for i := 0; i < len(query); {
+		var opening, closing string
+		switch {
+		case query[i] == '\'', query[i] == '"', query[i] == '`':
+			opening, closing = query[i:i+1], query[i:i+1]
+		case query[i] == '[':
+			opening, closing = "[", "]"
+		case strings.HasPrefix(query[i:], "--"):
+			opening, closing = "--", "\n"
+		case strings.HasPrefix(query[i:], "/*"):
+			opening, closing = "/*", "*/"
+		default:
+			code = append(code, query[i])
+			i++
+			continue
+		}
+
+		j := i + len(opening)
+		for {
+			k := strings.Index(query[j:], closing)
+			if k == -1 {
+				j = len(query)
+				break
+			}
+			j += k + len(closing)
+			// doubled quotes escape a quote within a literal or identifier
+			if closing == opening && j < len(query) && query[j:j+1] == closing {
+				j++
+				continue
+			}
+			break
+		}
+		code = append(code, ' ')
+		i = j
+	}
+	return string(code)
+}
+
+// sqlDatasetRefs returns the unique dataset references a query names, in the
+// order they first appear. Identifiers within string literals are ignored
+func sqlDatasetRefs(query string) []string {
+	refs := []string{}
+	seen := map[string]bool{}
+	query = sqlStringPattern.ReplaceAllString(query, "''")
+	for _, match := range sqlRefPattern.FindAllStringSubmatch(query, -1) {
+		if ref := match[1]; !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// loadSQLBody creates a table from a dataset body, streaming rows from the
+// body file. Bodies must be tabular. Datasets without a body create no table
+func (r *StepRunner) loadSQLBody(ctx context.Context, db *sql.DB, table string, ds *dataset.Dataset) error {
+	file := ds.BodyFile()
+	if file == nil {
+		return nil
+	}
+	if ds.Structure == nil {
+		return fmt.Errorf("dataset has no structure")
+	}
+	cols, _, err := tabular.ColumnsFromJSONSchema(ds.Structure.Schema)
+	if err != nil {
+		return fmt.Errorf("sql steps require a tabular body: %w", err)
+	}
+	titles := cols.Titles()
+
+	// the body file is consumed. SQL steps always commit their results as the
+	// new body, so it's never read again
+	rr, err := dsio.NewEntryReader(ds.Structure, file)
+	if err != nil {
+		return fmt.Errorf("error allocating data reader: %w", err)
+	}
+	defer rr.Close()
+
+	quoted := make([]string, len(titles))
+	params := make([]string, len(titles))
+	for i, title := range titles {
+		quoted[i] = quoteSQLIdent(title)
+		params[i] = "?"
+	}
+	create := fmt.Sprintf("CREATE TABLE %s (%s)", quoteSQLIdent(table), strings.Join(quoted, ", "))
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	insert := fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteSQLIdent(table), strings.Join(params, ", "))
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for {
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			tx.Rollback()
+			return err
+		}
+		row, ok := ent.Value.([]interface{})
+		if !ok {
+			tx.Rollback()
+			return fmt.Errorf("sql steps require rows to be arrays, entry %d is %T", ent.Index, ent.Value)
+		}
+		args := make([]interface{}, len(titles))
+		for i := range args {
+			if i < len(row) {
+				if args[i], err = sqlValue(row[i]); err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := r.budget.addSteps(1); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// querySQL runs a query, returning result column names & rows
+func (r *StepRunner) querySQL(ctx context.Context, db *sql.DB, query string) ([]string, [][]interface{}, error) {
+	res, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Close()
+
+	columns, err := res.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := [][]interface{}{}
+	for res.Next() {
+		vals := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := res.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		rows = append(rows, vals)
+		if err := r.budget.addSteps(1); err != nil {
+			return nil, nil, err
+		}
+	}
+	return columns, rows, res.Err()
+}
+
+// sqlValue converts a body value into a value SQLite can store. Objects &
+// arrays are stored as JSON text
+func sqlValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return v, nil
+	}
+}
+
+// quoteSQLIdent quotes a SQL identifier
+func quoteSQLIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
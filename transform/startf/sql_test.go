@@ -0,0 +1,164 @@
+package startf
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/tabular"
+	"github.com/qri-io/qfs"
+	"github.com/qri-io/qri/base"
+)
+
+func TestRunSQLStep(t *testing.T) {
+	ctx := context.Background()
+
+	ds := &dataset.Dataset{
+		Structure: &dataset.Structure{
+			Format: "csv",
+			Schema: map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "array",
+					"items": []interface{}{
+						map[string]interface{}{"title": "id", "type": "integer"},
+						map[string]interface{}{"title": "name", "type": "string"},
+					},
+				},
+			},
+		},
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Name: "transform", Syntax: "sql", Script: "SELECT id * 10 AS tens, upper(name) AS name FROM body WHERE id > 1 ORDER BY id"},
+			},
+		},
+	}
+	ds.SetBodyFile(qfs.NewMemfileBytes("body.csv", []byte("1,a\n2,b\n3,c\n")))
+
+	runner := NewStepRunner(ds)
+	if err := runner.RunSQLStep(ctx, ds, ds.Transform.Steps[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !runner.CommitCalled() {
+		t.Error("expected sql step to commit")
+	}
+	data, err := ioutil.ReadAll(ds.BodyFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("20,B\n30,C\n", string(data)); diff != "" {
+		t.Errorf("body mismatch (-want +got):\n%s", diff)
+	}
+	if ds.Structure.Entries != 2 {
+		t.Errorf("expected 2 entries, got: %d", ds.Structure.Entries)
+	}
+	cols, _, err := tabular.ColumnsFromJSONSchema(ds.Structure.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"tens", "name"}, cols.Titles()); diff != "" {
+		t.Errorf("column titles mismatch (-want +got):\n%s", diff)
+	}
+
+	// referenced datasets are loaded as tables & recorded as resources
+	r := testRepo(t)
+	ds = &dataset.Dataset{
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Name: "transform", Syntax: "sql", Script: `SELECT count(*) AS movies FROM "qri:peer/movies"`},
+			},
+		},
+	}
+	runner = NewStepRunner(ds, func(o *ExecOpts) {
+		o.DatasetLoader = base.NewTestDatasetLoader(r.Filesystem(), r)
+	})
+	if err := runner.RunSQLStep(ctx, ds, ds.Transform.Steps[0]); err != nil {
+		t.Fatal(err)
+	}
+	if ds.Structure == nil || ds.Structure.Entries != 1 {
+		t.Errorf("expected a single row result, got structure: %v", ds.Structure)
+	}
+	if len(ds.Transform.Resources) != 1 {
+		t.Errorf("expected queried dataset to be recorded as a transform resource, got: %v", ds.Transform.Resources)
+	}
+
+	// queries fail without a dataset loader
+	ds.Transform.Resources = nil
+	if err := NewStepRunner(ds).RunSQLStep(ctx, ds, ds.Transform.Steps[0]); err == nil {
+		t.Error("expected querying a dataset reference without a loader to error")
+	}
+}
+
+func TestSQLDatasetRefs(t *testing.T) {
+	got := sqlDatasetRefs(`SELECT * FROM body b JOIN "qri:me/cities" c ON b.city = c.name JOIN "qri:me/cities" USING (id) WHERE "a/b" = 'a/b' AND x = '"qri:me/not_a_table"'`)
+	if diff := cmp.Diff([]string{"me/cities"}, got); diff != "" {
+		t.Errorf("refs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckSQLReadOnly(t *testing.T) {
+	good := []string{
+		"SELECT * FROM body",
+		"select id, replace(name, 'a', 'b') AS name FROM body;",
+		"WITH t AS (SELECT id FROM body) SELECT * FROM t",
+		"VALUES (1), (2)",
+		`SELECT "update", 'ATTACH DATABASE' FROM body -- VACUUM INTO
+		/* ; DROP TABLE body */`,
+	}
+	for _, q := range good {
+		if err := checkSQLReadOnly(q); err != nil {
+			t.Errorf("expected %q to be allowed. got: %s", q, err)
+		}
+	}
+
+	bad := []string{
+		"",
+		"ATTACH DATABASE '/etc/passwd' AS host",
+		"VACUUM INTO '/tmp/out.db'",
+		"SELECT 1; ATTACH DATABASE '/etc/passwd' AS host",
+		"PRAGMA query_only = OFF",
+		"WITH t AS (SELECT 1) INSERT INTO body SELECT * FROM t",
+		"WITH t AS (SELECT 1) REPLACE INTO body SELECT * FROM t",
+		"SELECT load_extension('/tmp/ext.so')",
+		"DROP TABLE body",
+	}
+	for _, q := range bad {
+		if err := checkSQLReadOnly(q); !errors.Is(err, ErrSQLNotReadOnly) {
+			t.Errorf("expected %q to be refused with ErrSQLNotReadOnly. got: %v", q, err)
+		}
+	}
+}
+
+func TestRunSQLStepLimits(t *testing.T) {
+	ctx := context.Background()
+	ds := &dataset.Dataset{
+		Structure: &dataset.Structure{
+			Format: "csv",
+			Schema: map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "array",
+					"items": []interface{}{
+						map[string]interface{}{"title": "id", "type": "integer"},
+					},
+				},
+			},
+		},
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Name: "transform", Syntax: "sql", Script: "SELECT id FROM body"},
+			},
+		},
+	}
+	ds.SetBodyFile(qfs.NewMemfileBytes("body.csv", []byte("1\n2\n3\n")))
+
+	runner := NewStepRunner(ds, SetLimits(Limits{MaxSteps: 4}))
+	err := runner.RunSQLStep(ctx, ds, ds.Transform.Steps[0])
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Resource != LimitSteps {
+		t.Errorf("expected rows loaded & returned to exceed the step limit, got: %v", err)
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"github.com/qri-io/qfs"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/logbook"
 	"github.com/qri-io/qri/repo"
 	stards "github.com/qri-io/qri/transform/startf/ds"
+	"github.com/qri-io/qri/transform/startf/qri"
 	"github.com/qri-io/qri/version"
 	"github.com/qri-io/starlib"
 	"github.com/qri-io/starlib/dataframe"
@@ -41,6 +43,8 @@ type ExecOpts struct {
 	Filesystem qfs.Filesystem
 	// supply a repo to make the 'qri' module available in starlark
 	Repo repo.Repo
+	// logbook for reading dataset history with the 'qri' module
+	Logbook *logbook.Book
 	// allow floating-point numbers
 	AllowFloat bool
 	// allow set data type
@@ -64,6 +68,10 @@ type ExecOpts struct {
 	// the size of the output area, for stringifying large objects
 	OutputWidth  int
 	OutputHeight int
+	// records or replays HTTP requests made by the script
+	HTTPRecorder *HTTPRecorder
+	// resource limits for the run
+	Limits Limits
 }
 
 // AddDatasetLoader is required to enable the load_dataset starlark builtin
@@ -88,6 +96,13 @@ func AddQriRepo(repo repo.Repo) func(o *ExecOpts) {
 	}
 }
 
+// AddLogbook provides a logbook, enabling history builtins of the 'qri' module
+func AddLogbook(book *logbook.Book) func(o *ExecOpts) {
+	return func(o *ExecOpts) {
+		o.Logbook = book
+	}
+}
+
 // AddEventsChannel sets an event channel to send events on
 func AddEventsChannel(eventsCh chan event.Event) func(o *ExecOpts) {
 	return func(o *ExecOpts) {
@@ -133,6 +148,21 @@ func SizeInfo(outWidth, outHeight int) func(o *ExecOpts) {
 	}
 }
 
+// RecordHTTP routes HTTP requests made by the script through a recorder,
+// which either records requests & responses or replays recorded responses
+func RecordHTTP(rec *HTTPRecorder) func(o *ExecOpts) {
+	return func(o *ExecOpts) {
+		o.HTTPRecorder = rec
+	}
+}
+
+// SetLimits caps the resources the script may use
+func SetLimits(limits Limits) func(o *ExecOpts) {
+	return func(o *ExecOpts) {
+		o.Limits = limits
+	}
+}
+
 // DefaultExecOpts applies default options to an ExecOpts pointer
 func DefaultExecOpts(o *ExecOpts) {
 	o.AllowFloat = true
@@ -149,12 +179,16 @@ type StepRunner struct {
 	secrets      map[string]interface{}
 	fs           qfs.Filesystem
 	dsLoader     dsref.Loader
+	repo         repo.Repo
+	book         *logbook.Book
 	stards       *stards.BoundDataset
 	globals      starlark.StringDict
 	eventsCh     chan event.Event
 	writer       io.Writer
 	thread       *starlark.Thread
 	changeSet    map[string]struct{}
+	httpRecorder *HTTPRecorder
+	budget       *budget
 	commitCalled bool
 }
 
@@ -198,17 +232,29 @@ func NewStepRunner(target *dataset.Dataset, opts ...func(o *ExecOpts)) *StepRunn
 	// Store the OutputConfig on the starlark thread. This allows functions
 	// such as the DataFrame constructor to get this configuration
 	outconf := dataframe.SetOutputSize(thread, o.OutputWidth, o.OutputHeight)
+	if o.HTTPRecorder != nil {
+		thread.SetLocal(httpRecorderLocal, o.HTTPRecorder)
+	}
+
+	book := o.Logbook
+	if book == nil && o.Repo != nil {
+		book = o.Repo.Logbook()
+	}
 
 	r := &StepRunner{
-		config:    target.Transform.Config,
-		secrets:   o.Secrets,
-		fs:        o.Filesystem,
-		dsLoader:  o.DatasetLoader,
-		eventsCh:  o.EventsCh,
-		writer:    o.ErrWriter,
-		thread:    thread,
-		globals:   starlark.StringDict{},
-		changeSet: o.ChangeSet,
+		config:       target.Transform.Config,
+		secrets:      o.Secrets,
+		fs:           o.Filesystem,
+		dsLoader:     o.DatasetLoader,
+		repo:         o.Repo,
+		book:         book,
+		eventsCh:     o.EventsCh,
+		writer:       o.ErrWriter,
+		thread:       thread,
+		globals:      starlark.StringDict{},
+		changeSet:    o.ChangeSet,
+		httpRecorder: o.HTTPRecorder,
+		budget:       newBudget(o.Limits, thread),
 	}
 	r.stards = stards.NewBoundDataset(target, outconf, r.onCommit)
 
@@ -217,10 +263,17 @@ func NewStepRunner(target *dataset.Dataset, opts ...func(o *ExecOpts)) *StepRunn
 
 // RunStep runs the single transform step using the dataset
 func (r *StepRunner) RunStep(ctx context.Context, ds *dataset.Dataset, st *dataset.TransformStep) (err error) {
+	// the step context is canceled when a limit is exceeded, interrupting
+	// requests the script is waiting on
+	ctx, stopWatch := r.budget.watch(ctx)
+	defer stopWatch()
+	r.thread.SetLocal(contextLocal, ctx)
+
 	r.globals["load_dataset"] = starlark.NewBuiltin("load_dataset", r.loadDatasetFunc(ctx, ds))
 	r.globals["dataset"] = r.stards
 	r.globals["config"] = config(r.config)
 	r.globals["secrets"] = secrets(r.secrets)
+	r.globals["qri"] = qri.NewModule(ctx, ds, r.repo, r.book, r.dsLoader).Struct()
 
 	script, ok := st.Script.(string)
 	if !ok {
@@ -247,6 +300,9 @@ func (r *StepRunner) RunStep(ctx context.Context, ds *dataset.Dataset, st *datas
 
 	globals, err := mod.Init(r.thread, r.globals)
 	if err != nil {
+		if lerr := r.budget.exceeded(); lerr != nil {
+			return lerr
+		}
 		if evalErr, ok := err.(*starlark.EvalError); ok {
 			return fmt.Errorf(evalErr.Backtrace())
 		}
@@ -285,6 +341,15 @@ func (r *StepRunner) CommitCalled() bool {
 	return r.commitCalled
 }
 
+// HTTPFixtures returns the HTTP requests & responses recorded by the runner,
+// nil if the runner isn't recording
+func (r *StepRunner) HTTPFixtures() *event.HTTPFixtures {
+	if r.httpRecorder == nil || r.httpRecorder.Replaying() {
+		return nil
+	}
+	return r.httpRecorder.Fixtures()
+}
+
 // globalFunc checks if a global function is defined
 func (r *StepRunner) globalFunc(name string) (fn *starlark.Function, err error) {
 	x, ok := r.globals[name]
@@ -315,23 +380,29 @@ func (r *StepRunner) loadDatasetFunc(ctx context.Context, target *dataset.Datase
 			return starlark.None, err
 		}
 
-		if target.Transform.Resources == nil {
-			target.Transform.Resources = map[string]*dataset.TransformResource{}
-		}
-
-		target.Transform.Resources[ds.Path] = &dataset.TransformResource{
-			// TODO(b5) - this should be a method on dataset.Dataset
-			// we should add an ID field to dataset, set that to the InitID, and
-			// add fields to dataset.TransformResource that effectively make it the
-			// same data structure as dsref.Ref
-			Path: fmt.Sprintf("%s/%s@%s", ds.Peername, ds.Name, ds.Path),
-		}
+		addTransformResource(target, ds)
 
 		outconf, _ := thread.Local("OutputConfig").(*dataframe.OutputConfig)
 		return stards.NewDataset(ds, outconf), nil
 	}
 }
 
+// addTransformResource records a dataset loaded by a transform as a resource
+// of the target transform
+func addTransformResource(target *dataset.Dataset, ds *dataset.Dataset) {
+	if target.Transform.Resources == nil {
+		target.Transform.Resources = map[string]*dataset.TransformResource{}
+	}
+
+	target.Transform.Resources[ds.Path] = &dataset.TransformResource{
+		// TODO(b5) - this should be a method on dataset.Dataset
+		// we should add an ID field to dataset, set that to the InitID, and
+		// add fields to dataset.TransformResource that effectively make it the
+		// same data structure as dsref.Ref
+		Path: fmt.Sprintf("%s/%s@%s", ds.Peername, ds.Name, ds.Path),
+	}
+}
+
 // func (r *StepRunner) print(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 // 	var (
 // 		str string
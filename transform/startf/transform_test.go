@@ -206,6 +206,28 @@ func TestLoadDataset(t *testing.T) {
 	}
 }
 
+func TestQriModule(t *testing.T) {
+	ctx := context.Background()
+	r := testRepo(t)
+
+	ds := &dataset.Dataset{
+		Transform: &dataset.Transform{},
+	}
+	ds.Transform.SetScriptFile(scriptFile(t, "testdata/qri_module.star"))
+
+	err := ExecScript(ctx, ds, func(o *ExecOpts) {
+		o.Repo = r
+		o.ModuleLoader = testModuleLoader(t)
+		o.DatasetLoader = base.NewTestDatasetLoader(r.Filesystem(), r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Transform.Resources) != 1 {
+		t.Errorf("expected loaded version to be recorded as a transform resource, got: %v", ds.Transform.Resources)
+	}
+}
+
 func TestGetMetaNilPrev(t *testing.T) {
 	ctx := context.Background()
 	ds := &dataset.Dataset{
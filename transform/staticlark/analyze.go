@@ -8,33 +8,67 @@ import (
 
 var log = golog.Logger("staticlark")
 
-// AnalyzeFile performs static analysis and returns diagnostic results
-func AnalyzeFile(filename string) ([]Diagnostic, error) {
+// Diagnostic categories
+const (
+	// CategoryLeak marks sensitive data that may reach a sink
+	CategoryLeak = "leak"
+	// CategoryUnused marks functions that are never called
+	CategoryUnused = "unused"
+)
+
+// AnalyzeFile performs static analysis and returns diagnostic results. Nil
+// axioms uses DefaultAxioms. If analysis fails after the script is parsed, any
+// diagnostics that could be computed are returned alongside the error
+func AnalyzeFile(filename string, axioms *Axioms) ([]Diagnostic, error) {
+	return analyze(filename, nil, axioms)
+}
+
+// AnalyzeScript performs static analysis on script source code. filename is
+// only used to report positions. Like AnalyzeFile, a failed analysis may still
+// return diagnostics
+func AnalyzeScript(filename string, src []byte, axioms *Axioms) ([]Diagnostic, error) {
+	return analyze(filename, src, axioms)
+}
+
+func analyze(filename string, src interface{}, axioms *Axioms) ([]Diagnostic, error) {
+	if axioms == nil {
+		axioms = DefaultAxioms()
+	}
 	// Parse the script to abstract syntax
-	f, err := syntax.Parse(filename, nil, 0)
+	f, err := syntax.Parse(filename, src, 0)
 	if err != nil {
 		return nil, err
 	}
-	// Collect function definitions and top level function calls
-	funcs, topLevel, err := collectFuncDefsTopLevelCalls(f.Stmts)
+	// Collect function definitions, and analyze top level statements as the
+	// function that calls them
+	funcs, _, err := collectFuncDefsTopLevelCalls(f.Stmts)
 	if err != nil {
 		return nil, err
 	}
+	entry := topLevelFunc(f.Stmts)
+	funcs = append(funcs, entry)
 	// Constuct pre-defined global symbols
 	globals := newSymtable(starlark.Universe)
-	// Build a graph of all calls, using top level calls and pre-defined globals
-	callGraph := buildCallGraph(funcs, topLevel, globals)
-
-	// Trace sensitive data using dataflow analysis
-	dataflowDiags, err := analyzeSensitiveDataflow(callGraph, nil)
-	if err != nil {
-		return nil, err
+	// Axioms are also pre-defined, and may replace globals
+	axiomNodes := axioms.funcNodes()
+	for name, fn := range axiomNodes {
+		globals[name] = fn
 	}
+	// Build a graph of all calls, using top level code as the entry point and
+	// pre-defined globals
+	callGraph := buildCallGraph(funcs, []string{entry.name}, globals)
 
-	// Return any unused functions
+	// Find any unused functions
 	// TODO(dustmop): As more analysis steps are introduced, refactor this
 	// into a generic interface that creates Diagnostics
 	unusedDiags := callGraph.findUnusedFuncs()
+
+	// Trace sensitive data using dataflow analysis. The call graph is complete
+	// even if dataflow analysis fails, so unused functions are still returned
+	dataflowDiags, err := analyzeSensitiveDataflow(callGraph, axiomNodes)
+	if err != nil {
+		return unusedDiags, err
+	}
 	return append(dataflowDiags, unusedDiags...), nil
 }
 
@@ -45,6 +79,17 @@ type Diagnostic struct {
 	Message  string
 }
 
+// Leaks filters diagnostics to those that report sensitive data leaking
+func Leaks(diags []Diagnostic) []Diagnostic {
+	leaks := []Diagnostic{}
+	for _, d := range diags {
+		if d.Category == CategoryLeak {
+			leaks = append(leaks, d)
+		}
+	}
+	return leaks
+}
+
 func newSymtable(symbols starlark.StringDict) map[string]*funcNode {
 	table := make(map[string]*funcNode)
 	for name := range symbols {
@@ -0,0 +1,134 @@
+package staticlark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Axioms declare how functions the analyzer can't see inside of handle data.
+// Sources are functions that return sensitive data, like private datasets or
+// secrets. Sinks are functions that do something dangerous with their
+// parameters, like sending them over the network. Dataflow analysis reports
+// any path from a source to a sink
+type Axioms struct {
+	Sources []AxiomSource `json:"sources"`
+	Sinks   []AxiomSink   `json:"sinks"`
+}
+
+// AxiomSource is a function that returns sensitive data
+type AxiomSource struct {
+	// Name of the function, dotted for module members, eg: "ctx.get_secret"
+	Name string `json:"name"`
+}
+
+// AxiomSink is a function that may leak data passed to it
+type AxiomSink struct {
+	// Name of the function, dotted for module members, eg: "http.post"
+	Name string `json:"name"`
+	// Params lists parameter names in positional order
+	Params []string `json:"params"`
+	// Dangerous lists the names of parameters that leak data. Empty means
+	// every parameter is dangerous
+	Dangerous []string `json:"dangerous,omitempty"`
+	// Reason describes how data leaks, shown in diagnostics
+	Reason string `json:"reason,omitempty"`
+}
+
+// httpParams are the parameters of functions in the starlib http module
+var httpParams = []string{"url", "params", "headers", "body", "form_body", "form_encoding", "json_body", "auth"}
+
+// DefaultAxioms are the axioms used when none are provided. Reading other
+// datasets & secrets are sources, network requests & printing to run output
+// are sinks
+func DefaultAxioms() *Axioms {
+	return &Axioms{
+		Sources: []AxiomSource{
+			{Name: "load_dataset"},
+			{Name: "ctx.get_secret"},
+			{Name: "secrets.get"},
+		},
+		Sinks: []AxiomSink{
+			{Name: "http.get", Params: httpParams, Reason: "http.get sends data over the network"},
+			{Name: "http.post", Params: httpParams, Reason: "http.post sends data over the network"},
+			{Name: "http.put", Params: httpParams, Reason: "http.put sends data over the network"},
+			{Name: "http.patch", Params: httpParams, Reason: "http.patch sends data over the network"},
+			{Name: "http.delete", Params: httpParams, Reason: "http.delete sends data over the network"},
+			{Name: "print", Params: []string{"message"}, Reason: "print writes data to run output"},
+		},
+	}
+}
+
+// LoadAxioms reads axioms from a JSON file
+func LoadAxioms(path string) (*Axioms, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Axioms{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("reading axioms file %q: %w", path, err)
+	}
+	if err := a.Validate(); err != nil {
+		return nil, fmt.Errorf("reading axioms file %q: %w", path, err)
+	}
+	return a, nil
+}
+
+// Validate returns an error if axioms are malformed
+func (a *Axioms) Validate() error {
+	for _, src := range a.Sources {
+		if src.Name == "" {
+			return fmt.Errorf("source name is required")
+		}
+	}
+	for _, sink := range a.Sinks {
+		if sink.Name == "" {
+			return fmt.Errorf("sink name is required")
+		}
+		if len(sink.Params) == 0 {
+			return fmt.Errorf("sink %q must list params", sink.Name)
+		}
+		for _, d := range sink.Dangerous {
+			if !arrayContains(sink.Params, d) {
+				return fmt.Errorf("sink %q dangerous param %q is not in params", sink.Name, d)
+			}
+		}
+	}
+	return nil
+}
+
+// funcNodes converts axioms to the function nodes used by dataflow analysis
+func (a *Axioms) funcNodes() map[string]*funcNode {
+	nodes := make(map[string]*funcNode)
+	if a == nil {
+		return nodes
+	}
+	for _, src := range a.Sources {
+		nodes[src.Name] = &funcNode{
+			name:            src.Name,
+			sensitiveReturn: true,
+		}
+	}
+	for _, sink := range a.Sinks {
+		fn := nodes[sink.Name]
+		if fn == nil {
+			fn = &funcNode{name: sink.Name}
+			nodes[sink.Name] = fn
+		}
+		why := sink.Reason
+		if why == "" {
+			why = fmt.Sprintf("%s is a sink", sink.Name)
+		}
+		fn.params = sink.Params
+		fn.dangerousParams = make([]bool, len(sink.Params))
+		fn.reasonParams = make([]reason, len(sink.Params))
+		for i, p := range sink.Params {
+			if len(sink.Dangerous) == 0 || arrayContains(sink.Dangerous, p) {
+				fn.dangerousParams[i] = true
+				fn.reasonParams[i] = reason{lines: []string{why}}
+			}
+		}
+	}
+	return nodes
+}
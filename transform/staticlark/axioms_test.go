@@ -0,0 +1,155 @@
+package staticlark
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.starlark.net/syntax"
+)
+
+func TestAnalyzeFileDefaultAxioms(t *testing.T) {
+	filename := "testdata/leaky_transform.star"
+	diags, err := AnalyzeFile(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectDiags := []Diagnostic{
+		{
+			Pos:      syntax.MakePosition(&filename, 8, 3),
+			Category: CategoryLeak,
+			Message: `secrets may leak, variable token is secret
+leaky_transform.star:4: upload passes data to http.post argument json_body
+http.post sends data over the network`,
+		},
+	}
+
+	ignoreCmp := cmpopts.IgnoreUnexported(syntax.Position{})
+	if diff := cmp.Diff(expectDiags, Leaks(diags), ignoreCmp); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadAxioms(t *testing.T) {
+	axioms, err := LoadAxioms("testdata/axioms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := "testdata/leaky_transform.star"
+	diags, err := AnalyzeFile(filename, axioms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectDiags := []Diagnostic{
+		{
+			Pos:      syntax.MakePosition(&filename, 8, 3),
+			Category: CategoryLeak,
+			Message:  "secrets may leak, variable token is secret\nupload is not trusted",
+		},
+	}
+
+	ignoreCmp := cmpopts.IgnoreUnexported(syntax.Position{})
+	if diff := cmp.Diff(expectDiags, Leaks(diags), ignoreCmp); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := LoadAxioms("testdata/not_a_file.json"); err == nil {
+		t.Error("expected loading a missing axioms file to error")
+	}
+}
+
+func TestAxiomsValidate(t *testing.T) {
+	bad := []*Axioms{
+		{Sources: []AxiomSource{{}}},
+		{Sinks: []AxiomSink{{Name: "http.post"}}},
+		{Sinks: []AxiomSink{{Name: "http.post", Params: []string{"url"}, Dangerous: []string{"body"}}}},
+	}
+	for i, a := range bad {
+		if err := a.Validate(); err == nil {
+			t.Errorf("case %d: expected error, got nil", i)
+		}
+	}
+	if err := DefaultAxioms().Validate(); err != nil {
+		t.Errorf("default axioms: %s", err)
+	}
+}
+
+func TestAnalyzeScriptKeywordArgs(t *testing.T) {
+	axioms := &Axioms{
+		Sources: []AxiomSource{{Name: "get_secret"}},
+		Sinks:   []AxiomSink{{Name: "send", Params: []string{"url", "body"}, Dangerous: []string{"body"}, Reason: "send leaks body"}},
+	}
+
+	// a secret passed by keyword to a safe parameter doesn't leak
+	safe := []byte("def f():\n  s = get_secret()\n  send(url=s)\n\nf()\n")
+	diags, err := AnalyzeScript("safe.star", safe, axioms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaks := Leaks(diags); len(leaks) != 0 {
+		t.Errorf("expected no leaks, got: %v", leaks)
+	}
+
+	leaky := []byte("def f():\n  s = get_secret()\n  send(\"https://example.com\", body=s)\n\nf()\n")
+	diags, err = AnalyzeScript("leaky.star", leaky, axioms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaks := Leaks(diags); len(leaks) != 1 {
+		t.Errorf("expected keyword argument to leak, got: %v", leaks)
+	}
+
+	// functions the analyzer can't see inside of are neither sources nor sinks
+	unknown := []byte("def f():\n  s = get_secret()\n  mystery(s, flag=True)\n  send(\"https://example.com\", body=s, timeout=5)\n\nf()\n")
+	diags, err = AnalyzeScript("unknown.star", unknown, axioms)
+	if err != nil {
+		t.Fatalf("expected unknown functions & keyword arguments to be skipped, got: %s", err)
+	}
+	if leaks := Leaks(diags); len(leaks) != 1 {
+		t.Errorf("expected one leak, got: %v", leaks)
+	}
+}
+
+func TestAnalyzeScriptTopLevel(t *testing.T) {
+	// transform steps run as top level code
+	step := []byte(`load("http.star", "http")
+load("encoding/json.star", "json")
+
+k = secrets.get("api_key")
+config = json.decode("{}")
+for row in config:
+  if row:
+    payload = k
+  else:
+    pass
+http.post("https://example.com", body=payload, form_encoding="application/x-www-form-urlencoded")
+`)
+	diags, err := AnalyzeScript("step.star", step, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := "step.star"
+	expectDiags := []Diagnostic{
+		{
+			Pos:      syntax.MakePosition(&filename, 11, 1),
+			Category: CategoryLeak,
+			Message:  "secrets may leak, variable payload is secret\nhttp.post sends data over the network",
+		},
+	}
+	ignoreCmp := cmpopts.IgnoreUnexported(syntax.Position{})
+	if diff := cmp.Diff(expectDiags, Leaks(diags), ignoreCmp); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	safe := []byte("k = secrets.get(\"api_key\")\nprint(\"done\")\n")
+	diags, err = AnalyzeScript("safe.star", safe, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaks := Leaks(diags); len(leaks) != 0 {
+		t.Errorf("expected no leaks, got: %v", leaks)
+	}
+}
@@ -56,6 +56,7 @@ func addToCallGraph(f *funcNode, graph *callGraph, symtable map[string]*funcNode
 	me = &funcNode{
 		name:   f.name,
 		params: f.params,
+		pos:    f.pos,
 		body:   f.body,
 		calls:  make([]*funcNode, 0),
 	}
@@ -101,7 +102,7 @@ func (cg *callGraph) findUnusedFuncs() []Diagnostic {
 	results := make([]Diagnostic, 0, len(unusedNames))
 	for fname := range unusedNames {
 		results = append(results, Diagnostic{
-			Category: "unused",
+			Category: CategoryUnused,
 			Message:  fname,
 		})
 	}
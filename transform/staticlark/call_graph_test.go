@@ -1,6 +1,7 @@
 package staticlark
 
 import (
+	"io/ioutil"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -88,3 +89,39 @@ func TestUnusedFunctions(t *testing.T) {
 		t.Errorf("mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestFunctions(t *testing.T) {
+	filename := "testdata/doc_funcs.star"
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcs, err := Functions(filename, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Function{
+		{
+			Name:   "helper",
+			Params: []string{"rows"},
+			Doc:    "counts rows",
+			Pos:    syntax.MakePosition(&filename, 1, 5),
+			Calls:  []string{"len"},
+		},
+		{
+			Name:   "transform",
+			Params: []string{"ds", "ctx"},
+			Pos:    syntax.MakePosition(&filename, 5, 5),
+			Calls:  []string{"helper", "print"},
+		},
+	}
+	ignoreCmp := cmpopts.IgnoreUnexported(syntax.Position{})
+	if diff := cmp.Diff(expect, funcs, ignoreCmp); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := Functions(filename, []byte("def broken(:\n")); err == nil {
+		t.Error("expected syntax error")
+	}
+}
@@ -137,6 +137,10 @@ func (b *codeBlock) isLinear() bool {
 	return len(b.edges) <= 1
 }
 
+func (b *codeBlock) isForLoop() bool {
+	return len(b.units) == 1 && b.units[0].atom == "for" && len(b.edges) > 0
+}
+
 func (b *codeBlock) isIfCondition() bool {
 	if len(b.units) == 1 {
 		u := b.units[0]
@@ -252,6 +256,9 @@ func (builder *cfBuilder) buildSingleNode(stmt syntax.Stmt) {
 		builder.put(assignUnit)
 
 	case *syntax.BranchStmt:
+		if item.Token == syntax.PASS {
+			return
+		}
 		// TODO(dustmop): support other operations, like `continue`
 		builder.ensureBlock()
 		builder.put(&unit{atom: "[break]"})
@@ -393,8 +400,7 @@ func exprStatementToUnit(expr *syntax.ExprStmt) *unit {
 		callName := simpleExprToFuncName(fn)
 		tail := []*unit{}
 		for _, e := range item.Args {
-			// TODO: exprToUnit(e).String() shouldn't collapse to string
-			tail = append(tail, &unit{atom: exprToUnit(e).String()})
+			tail = append(tail, callArgToUnit(e))
 		}
 		return &unit{atom: callName, tail: tail, where: getWhere(expr)}
 
@@ -444,6 +450,20 @@ func exprStatementToUnit(expr *syntax.ExprStmt) *unit {
 	return toUnitTODO("????()")
 }
 
+// callArgToUnit converts a call argument to a unit. Keyword arguments keep
+// the name of the parameter they're passed to, so `f(body=x)` passes x to the
+// body parameter
+func callArgToUnit(e syntax.Expr) *unit {
+	if kw, ok := e.(*syntax.BinaryExpr); ok && kw.Op == syntax.EQ {
+		if name, ok := kw.X.(*syntax.Ident); ok {
+			// TODO: exprToUnit(e).String() shouldn't collapse to string
+			return &unit{atom: exprToUnit(kw.Y).String(), keyword: name.Name}
+		}
+	}
+	// TODO: exprToUnit(e).String() shouldn't collapse to string
+	return &unit{atom: exprToUnit(e).String()}
+}
+
 func binaryOpToUnit(binExp *syntax.BinaryExpr) *unit {
 	res := &unit{}
 	res.atom = binExp.Op.String()
@@ -461,8 +481,7 @@ func exprToUnit(expr syntax.Expr) *unit {
 		callName := simpleExprToFuncName(fn)
 		tail := []*unit{}
 		for _, e := range item.Args {
-			// TODO: exprToUnit(e).String() shouldn't collapse to string
-			tail = append(tail, &unit{atom: exprToUnit(e).String()})
+			tail = append(tail, callArgToUnit(e))
 		}
 		return &unit{atom: callName, tail: tail, where: getWhere(expr)}
 
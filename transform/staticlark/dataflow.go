@@ -101,10 +101,46 @@ func (da *dataflowAnalyzer) analyzeFunction(fn *funcNode) error {
 func (da *dataflowAnalyzer) analyzeSequence(start, finish int, cf *controlFlow, env *environment, fn *funcNode) error {
 
 	index := start
-	for index >= 0 && index < finish {
+	// edges that point before start loop back to an enclosing for loop, which
+	// handles the next iteration
+	for index >= start && index < finish {
 		block := cf.blocks[index]
 
-		if block.isLinear() {
+		if block.isForLoop() {
+			// loops analyze their body twice, so that values assigned late in one
+			// iteration reach uses early in the next, then union the environments
+			bodyIdx := block.edges[0]
+			exitIdx := -1
+			if len(block.edges) > 1 {
+				exitIdx = block.edges[1]
+			}
+			bodyFinish := finish
+			if exitIdx > index {
+				bodyFinish = exitIdx
+			}
+
+			if err := da.analyzeBlock(block, env, fn); err != nil {
+				return err
+			}
+			// the loop variable takes its value from the iterated expression, which
+			// also influences any assignments in the body
+			loop := block.units[0]
+			iterSources := loop.tail[1].getSources()
+			if vars := loop.tail[0]; vars.tail == nil && !vars.todo {
+				env.assign(vars.atom, append(iterSources, da.controlSources()...))
+			}
+			da.pushControlBindings(iterSources)
+			for i := 0; i < 2; i++ {
+				bodyEnv := env.clone()
+				if err := da.analyzeSequence(bodyIdx, bodyFinish, cf, bodyEnv, fn); err != nil {
+					return err
+				}
+				env.copyFrom(env.union(bodyEnv))
+			}
+			da.popControlBindings()
+			index = exitIdx
+
+		} else if block.isLinear() {
 			// linear flow simply analyzes the block, then follows the edge
 			if err := da.analyzeBlock(block, env, fn); err != nil {
 				return err
@@ -122,6 +158,12 @@ func (da *dataflowAnalyzer) analyzeSequence(start, finish int, cf *controlFlow,
 			trueIdx := block.edges[0]
 			falseIdx := block.edges[1]
 			joinIdx := block.join
+			// branches that never join, like those ending a loop body or a
+			// function, run until the end of the sequence
+			branchFinish := joinIdx
+			if branchFinish < 0 {
+				branchFinish = finish
+			}
 
 			trueEnv := env.clone()
 			falseEnv := env.clone()
@@ -130,11 +172,15 @@ func (da *dataflowAnalyzer) analyzeSequence(start, finish int, cf *controlFlow,
 			// that happen in either branch
 			da.pushControlBindings(block.units[0].DataSources())
 
-			if err := da.analyzeSequence(trueIdx, joinIdx, cf, trueEnv, fn); err != nil {
+			if err := da.analyzeSequence(trueIdx, branchFinish, cf, trueEnv, fn); err != nil {
 				return err
 			}
-			if err := da.analyzeSequence(falseIdx, joinIdx, cf, falseEnv, fn); err != nil {
-				return err
+			// an if without an else that ends a loop body branches back to the
+			// loop, leaving the environment as it is
+			if falseIdx > index {
+				if err := da.analyzeSequence(falseIdx, branchFinish, cf, falseEnv, fn); err != nil {
+					return err
+				}
 			}
 
 			da.popControlBindings()
@@ -143,8 +189,6 @@ func (da *dataflowAnalyzer) analyzeSequence(start, finish int, cf *controlFlow,
 			index = joinIdx
 
 		} else {
-			// TODO(dustmop): Handle loops also - run the loop repeatedly
-			// until the environment reaches a fixed-point
 			return fmt.Errorf("TODO: block type %v not implemented", block)
 		}
 	}
@@ -202,7 +246,10 @@ func (da *dataflowAnalyzer) analyzeBlock(block *codeBlock, env *environment, fn
 
 			fn, ok := da.graph.lookup[inv.Name]
 			if !ok {
-				return fmt.Errorf("invoked function %s not found", inv.Name)
+				// methods of local values & functions from loaded modules that
+				// aren't declared by axioms are neither sources nor sinks
+				log.Debugw("skipping call to unknown function", "name", inv.Name)
+				continue
 			}
 
 			for i, arg := range inv.Args {
@@ -213,12 +260,11 @@ func (da *dataflowAnalyzer) analyzeBlock(block *codeBlock, env *environment, fn
 						// secret being sent to dangerous param
 						prev := fn.reasonParams[i]
 						msg := fmt.Sprintf("secrets may leak, variable %s is secret\n%s", arg, prev.String())
-						d := Diagnostic{
+						da.report(Diagnostic{
 							Pos:      unit.where,
-							Category: "leak",
+							Category: CategoryLeak,
 							Message:  msg,
-						}
-						da.diags = append(da.diags, d)
+						})
 					}
 					// taint vars so that the sources become dangerous
 					prev := fn.reasonParams[i]
@@ -226,14 +272,57 @@ func (da *dataflowAnalyzer) analyzeBlock(block *codeBlock, env *environment, fn
 					env.taint(arg, reason)
 				}
 			}
+
+			// keyword arguments are matched to parameters by name
+			for name, arg := range inv.Kwargs {
+				i := indexOf(fn.params, name)
+				if i == -1 {
+					log.Debugw("skipping unknown keyword argument", "name", inv.Name, "param", name)
+					continue
+				}
+				danger := fn.dangerousParams
+				if danger == nil || i >= len(danger) || !danger[i] {
+					continue
+				}
+				prev := fn.reasonParams[i]
+				if env.isSecret(arg) {
+					msg := fmt.Sprintf("secrets may leak, variable %s is secret\n%s", arg, prev.String())
+					da.report(Diagnostic{
+						Pos:      unit.where,
+						Category: CategoryLeak,
+						Message:  msg,
+					})
+				}
+				env.taint(arg, makeReason(unit.where, fname, arg, inv.Name, name, prev))
+			}
 		}
 	}
 
 	return nil
 }
 
+// report adds a diagnostic, skipping duplicates found by analyzing a loop
+// body more than once
+func (da *dataflowAnalyzer) report(d Diagnostic) {
+	for _, prev := range da.diags {
+		if prev.Pos == d.Pos && prev.Message == d.Message {
+			return
+		}
+	}
+	da.diags = append(da.diags, d)
+}
+
+func indexOf(arr []string, val string) int {
+	for i, v := range arr {
+		if v == val {
+			return i
+		}
+	}
+	return -1
+}
+
 func (da *dataflowAnalyzer) builtinOrControlStructure(name string) bool {
-	return name == "if"
+	return name == "if" || name == "for"
 }
 
 func (da *dataflowAnalyzer) pushControlBindings(sources []string) {
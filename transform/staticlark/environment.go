@@ -56,6 +56,10 @@ func (e *environment) union(other *environment) *environment {
 	result := e.clone()
 	for name := range other.vars {
 		otherDerivs := other.vars[name]
+		if result.vars[name] == nil {
+			result.vars[name] = otherDerivs.clone()
+			continue
+		}
 		result.vars[name].merge(otherDerivs)
 		// TODO(dustmop): union taints also
 	}
@@ -115,6 +119,17 @@ func (e *environment) isSecret(name string) bool {
 	return false
 }
 
+// isMethodCall returns true if a dotted function name calls a method of a
+// variable or parameter in the environment, eg: ds.set_body
+func (e *environment) isMethodCall(name string) bool {
+	i := strings.Index(name, ".")
+	if i <= 0 {
+		return false
+	}
+	_, ok := e.vars[name[:i]]
+	return ok
+}
+
 func (e *environment) taint(name string, reason reason) {
 	if lookup, ok := e.vars[name]; ok {
 		// variable used
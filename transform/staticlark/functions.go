@@ -27,6 +27,27 @@ func collectFuncDefsTopLevelCalls(stmts []syntax.Stmt) ([]*funcNode, []string, e
 	return functions, topLevel, nil
 }
 
+// topLevelFuncName names the function that holds a script's top level
+// statements. It can't collide with a function defined in starlark
+const topLevelFuncName = "<toplevel>"
+
+// build a function object from the statements outside of any function
+// definition. Transform steps run as top level code, so it's the entry point
+// of a script
+func topLevelFunc(stmts []syntax.Stmt) *funcNode {
+	body := []syntax.Stmt{}
+	for _, stmt := range stmts {
+		if _, ok := stmt.(*syntax.DefStmt); !ok {
+			body = append(body, stmt)
+		}
+	}
+	node := newFuncNode()
+	node.name = topLevelFuncName
+	node.body = body
+	node.callNames = getFuncCallsInStmtList(body)
+	return node
+}
+
 // build a function object, contains calls to other functions
 func analyzeFunction(def *syntax.DefStmt) (*funcNode, error) {
 	params := make([]string, len(def.Params))
@@ -42,6 +63,7 @@ func analyzeFunction(def *syntax.DefStmt) (*funcNode, error) {
 	res.name = def.Name.Name
 	res.params = params
 	res.body = def.Body
+	res.pos = def.Name.NamePos
 
 	return res, nil
 }
@@ -59,6 +81,7 @@ func parameterName(e syntax.Expr) string {
 type funcNode struct {
 	name   string
 	params []string
+	pos    syntax.Position
 	body   []syntax.Stmt
 	calls  []*funcNode
 	reach  bool
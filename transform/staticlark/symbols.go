@@ -0,0 +1,78 @@
+package staticlark
+
+import (
+	"strconv"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Function describes a function defined in a script
+type Function struct {
+	Name   string
+	Params []string
+	// Doc is the function's docstring, if it has one
+	Doc string
+	// Pos is the position of the function name in its definition
+	Pos syntax.Position
+	// Calls lists the names of functions this function calls, in order of
+	// first call
+	Calls []string
+}
+
+// Functions parses a script & returns the functions it defines in order of
+// definition. filename is only used to report positions
+func Functions(filename string, src []byte) ([]Function, error) {
+	f, err := syntax.Parse(filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	funcs, topLevel, err := collectFuncDefsTopLevelCalls(f.Stmts)
+	if err != nil {
+		return nil, err
+	}
+	graph := buildCallGraph(funcs, topLevel, newSymtable(starlark.Universe))
+
+	res := make([]Function, 0, len(funcs))
+	for _, fn := range funcs {
+		calls := []string{}
+		seen := map[string]struct{}{}
+		for _, call := range graph.lookup[fn.name].calls {
+			if _, ok := seen[call.name]; ok {
+				continue
+			}
+			seen[call.name] = struct{}{}
+			calls = append(calls, call.name)
+		}
+		res = append(res, Function{
+			Name:   fn.name,
+			Params: fn.params,
+			Doc:    docstring(fn.body),
+			Pos:    fn.pos,
+			Calls:  calls,
+		})
+	}
+	return res, nil
+}
+
+// docstring returns the string literal that begins a function body
+func docstring(body []syntax.Stmt) string {
+	if len(body) == 0 {
+		return ""
+	}
+	expr, ok := body[0].(*syntax.ExprStmt)
+	if !ok {
+		return ""
+	}
+	lit, ok := expr.X.(*syntax.Literal)
+	if !ok || lit.Token != syntax.STRING {
+		return ""
+	}
+	if s, ok := lit.Value.(string); ok {
+		return s
+	}
+	if s, err := strconv.Unquote(lit.Raw); err == nil {
+		return s
+	}
+	return ""
+}
@@ -16,6 +16,8 @@ type unit struct {
 	tail  []*unit
 	todo  bool
 	where syntax.Position
+	// keyword is the parameter name of a keyword argument to a call
+	keyword string
 }
 
 // String converts a unit into a prefix notation string
@@ -25,6 +27,9 @@ func (u *unit) String() string {
 		if u.todo {
 			return fmt.Sprintf("TODO:%s", u.atom)
 		}
+		if u.keyword != "" {
+			return fmt.Sprintf("%s=%s", u.keyword, u.atom)
+		}
 		return u.atom
 	}
 	// function with no args
@@ -87,6 +92,8 @@ func (u *unit) getSources() []string {
 type invoke struct {
 	Name string   `json:"name"`
 	Args []string `json:"args"`
+	// Kwargs maps parameter names to the keyword arguments passed to them
+	Kwargs map[string]string `json:"kwargs,omitempty"`
 }
 
 func (u *unit) Invocations() []invoke {
@@ -97,9 +104,19 @@ func (u *unit) Invocations() []invoke {
 		return u.invokeFromList()
 	}
 	if isIdent(u.atom) && u.tail != nil {
-		name := u.atom
-		args := u.getSources()
-		inv := invoke{Name: name, Args: args}
+		inv := invoke{Name: u.atom, Args: []string{}}
+		for _, t := range u.tail {
+			if t.keyword == "" {
+				inv.Args = append(inv.Args, t.getSources()...)
+				continue
+			}
+			if srcs := t.getSources(); len(srcs) > 0 {
+				if inv.Kwargs == nil {
+					inv.Kwargs = map[string]string{}
+				}
+				inv.Kwargs[t.keyword] = srcs[0]
+			}
+		}
 		return append([]invoke{inv}, u.invokeFromList()...)
 	}
 	return u.invokeFromList()
@@ -11,6 +11,7 @@ import (
 	"github.com/qri-io/qfs"
 	"github.com/qri-io/qri/dsref"
 	"github.com/qri-io/qri/event"
+	"github.com/qri-io/qri/logbook"
 	"github.com/qri-io/qri/profile"
 	"github.com/qri-io/qri/transform/startf"
 )
@@ -21,6 +22,8 @@ const (
 	// SyntaxStarlark identifies steps & scripts written in starlark syntax
 	// they're executed by the startf subpackage
 	SyntaxStarlark = "starlark"
+	// SyntaxSQL identifies steps written as SQL queries
+	SyntaxSQL = "sql"
 	// SyntaxQri is not currently in use. It's planned for deprecation & removal
 	SyntaxQri = "qri"
 )
@@ -36,6 +39,9 @@ const (
 	StatusFailed = "failed"
 	// StatusSkipped is the canonical constant for "skipped" execution state
 	StatusSkipped = "skipped"
+	// StatusLimitExceeded is the canonical constant for "limit_exceeded"
+	// execution state, a run that failed by exceeding a resource limit
+	StatusLimitExceeded = "limit_exceeded"
 )
 
 const (
@@ -53,10 +59,15 @@ const (
 type Transformer struct {
 	appCtx   context.Context
 	loader   dsref.Loader
+	book     *logbook.Book
 	fs       qfs.Filesystem
 	pub      event.Publisher
 	sizeInfo SizeInfo
 	changes  map[string]struct{}
+	record   bool
+	replay   *event.HTTPFixtures
+	fixtures *event.HTTPFixtures
+	limits   startf.Limits
 }
 
 // SizeInfo is info about the size of the area that output is displayed on
@@ -65,17 +76,44 @@ type SizeInfo struct {
 	OutputHeight int
 }
 
-// NewTransformer returns a new transformer
-func NewTransformer(appCtx context.Context, fs qfs.Filesystem, loader dsref.Loader, pub event.Publisher, info SizeInfo) *Transformer {
+// NewTransformer returns a new transformer. book may be nil, in which case
+// transforms can't read dataset history
+func NewTransformer(appCtx context.Context, fs qfs.Filesystem, loader dsref.Loader, book *logbook.Book, pub event.Publisher, info SizeInfo) *Transformer {
 	return &Transformer{
 		appCtx:   appCtx,
 		loader:   loader,
+		book:     book,
 		fs:       fs,
 		pub:      pub,
 		sizeInfo: info,
 	}
 }
 
+// RecordHTTP records HTTP requests made by transform scripts & the responses
+// they receive, up to startf.MaxHTTPFixturesSize bytes of response bodies.
+// Recordings are read with HTTPFixtures once a transform has been applied
+func (t *Transformer) RecordHTTP() {
+	t.record = true
+}
+
+// ReplayHTTP serves HTTP requests made by transform scripts from recorded
+// fixtures instead of the network
+func (t *Transformer) ReplayHTTP(fixtures *event.HTTPFixtures) {
+	t.replay = fixtures
+}
+
+// HTTPFixtures returns the HTTP requests recorded by the most recent
+// application with secret values redacted, nil if the transformer isn't
+// recording
+func (t *Transformer) HTTPFixtures() *event.HTTPFixtures {
+	return t.fixtures
+}
+
+// SetLimits caps the resources transform runs may use
+func (t *Transformer) SetLimits(limits startf.Limits) {
+	t.limits = limits
+}
+
 // Apply applies the transform script to a target dataset
 func (t *Transformer) Apply(
 	ctx context.Context,
@@ -150,13 +188,22 @@ func (t *Transformer) apply(
 	t.changes = make(map[string]struct{})
 	eventsCh := make(chan event.Event)
 
+	t.fixtures = nil
+
 	opts := []func(*startf.ExecOpts){
 		startf.SetSecrets(secrets),
 		startf.AddDatasetLoader(t.loader),
+		startf.AddLogbook(t.book),
 		startf.AddFilesystem(t.fs),
 		startf.AddEventsChannel(eventsCh),
 		startf.TrackChanges(t.changes),
 		startf.SizeInfo(t.sizeInfo.OutputWidth, t.sizeInfo.OutputHeight),
+		startf.SetLimits(t.limits),
+	}
+	if t.replay != nil {
+		opts = append(opts, startf.RecordHTTP(startf.NewHTTPReplayer(t.replay)))
+	} else if t.record {
+		opts = append(opts, startf.RecordHTTP(startf.NewHTTPRecorder()))
 	}
 
 	doneCh := make(chan error)
@@ -182,6 +229,10 @@ func (t *Transformer) apply(
 			for {
 				select {
 				case e := <-eventsCh:
+					// redact before publishing, so no subscriber sees secrets
+					if msg, ok := e.Payload.(event.TransformMessage); ok && len(secrets) > 0 {
+						e.Payload = msg.RedactSecrets(secrets)
+					}
 					t.pub.PublishID(ctx, e.Type, runID, e.Payload)
 					if e.Type == event.ETTransformStop {
 						receivedTransformStopEvt = true
@@ -287,21 +338,30 @@ func (t *Transformer) apply(
 			}
 
 			switch step.Syntax {
-			case SyntaxStarlark:
-				runErr = stepRunner.RunStep(ctx, target, step)
+			case SyntaxStarlark, SyntaxSQL:
+				if step.Syntax == SyntaxSQL {
+					runErr = stepRunner.RunSQLStep(ctx, target, step)
+				} else {
+					runErr = stepRunner.RunStep(ctx, target, step)
+				}
 				if runErr != nil {
 					log.Debugw("error running transform step", "runID", runID, "index", i, "err", runErr)
-					eventsCh <- event.Event{
-						Type: event.ETTransformError,
-						Payload: event.TransformMessage{
-							Lvl:  event.TransformMsgLvlError,
-							Msg:  runErr.Error(),
-							Mode: runMode,
-						},
+					msg := event.TransformMessage{
+						Lvl:  event.TransformMsgLvlError,
+						Msg:  runErr.Error(),
+						Mode: runMode,
 					}
 					status = StatusFailed
+					if errors.Is(runErr, startf.ErrLimitExceeded) {
+						msg.Category = event.TransformErrCategoryLimit
+						status = StatusLimitExceeded
+					}
+					eventsCh <- event.Event{
+						Type:    event.ETTransformError,
+						Payload: msg,
+					}
 				}
-				log.Debugw("ran starlark step", "runID", runID, "category", step.Category, "name", step.Name, "scriptLen", scriptLen(step))
+				log.Debugw("ran transform step", "runID", runID, "syntax", step.Syntax, "category", step.Category, "name", step.Name, "scriptLen", scriptLen(step))
 			default:
 				if step.Syntax == SyntaxQri && step.Name == "save" {
 					log.Infow("ignoring qri save step", "runID", runID)
@@ -331,7 +391,7 @@ func (t *Transformer) apply(
 		}
 
 		// warn user if commit wasn't called
-		if status != StatusFailed && !stepRunner.CommitCalled() {
+		if status == StatusSucceeded && !stepRunner.CommitCalled() {
 			eventsCh <- event.Event{
 				Type: event.ETTransformPrint,
 				Payload: event.TransformMessage{
@@ -341,6 +401,8 @@ func (t *Transformer) apply(
 			}
 		}
 
+		t.fixtures = stepRunner.HTTPFixtures().RedactSecrets(secrets)
+
 		eventsCh <- event.Event{
 			Type: event.ETTransformStop,
 			Payload: event.TransformLifecycle{
@@ -107,6 +107,44 @@ func TestApply(t *testing.T) {
 	}
 }
 
+func TestApplySQLStep(t *testing.T) {
+	tf := &dataset.Transform{
+		Steps: []*dataset.TransformStep{
+			{Syntax: "starlark", Script: "ds = dataset.latest()\nds.body = [[1,2],[3,4]]\ndataset.commit(ds)"},
+			{Syntax: SyntaxSQL, Script: "SELECT field_1 + field_2 AS total FROM body"},
+		},
+	}
+	log := applyNoHistoryTransform(t, "", tf, "apply_sql_step", "apply")
+
+	expect := []event.Type{
+		event.ETTransformStart,
+		event.ETTransformStepStart,
+		event.ETTransformDatasetPreview,
+		event.ETTransformStepStop,
+		event.ETTransformStepStart,
+		event.ETTransformDatasetPreview,
+		event.ETTransformStepStop,
+		event.ETTransformStop,
+	}
+	got := make([]event.Type, len(log))
+	for i, e := range log {
+		got[i] = e.Type
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Fatalf("event type mismatch (-want +got):\n%s", diff)
+	}
+	if stop, ok := log[len(log)-1].Payload.(event.TransformLifecycle); !ok || stop.Status != StatusSucceeded {
+		t.Errorf("expected run to succeed, got: %#v", log[len(log)-1].Payload)
+	}
+	preview, ok := log[5].Payload.(*dataset.Dataset)
+	if !ok {
+		t.Fatalf("expected dataset preview payload, got: %T", log[5].Payload)
+	}
+	if diff := cmp.Diff(`[[3],[7]]`, string(preview.Body.(json.RawMessage))); diff != "" {
+		t.Errorf("preview body mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestCommit(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -188,7 +226,7 @@ func applyNoHistoryTransform(t *testing.T, initID string, tf *dataset.Transform,
 	}, runID)
 
 	fs := qfs.NewMemFS()
-	transformer := NewTransformer(ctx, fs, loader, bus, SizeInfo{})
+	transformer := NewTransformer(ctx, fs, loader, nil, bus, SizeInfo{})
 	if runMode == "apply" {
 		if err := transformer.Apply(ctx, target, runID, false, nil); err != nil {
 			t.Fatal(err)
@@ -277,7 +315,7 @@ func TestApplyAssignsColumnsAndBody(t *testing.T) {
 	loader := &noHistoryLoader{}
 	bus := event.NewBus(ctx)
 	fs := qfs.NewMemFS()
-	transformer := NewTransformer(ctx, fs, loader, bus, SizeInfo{})
+	transformer := NewTransformer(ctx, fs, loader, nil, bus, SizeInfo{})
 
 	ds := &dataset.Dataset{Transform: &dataset.Transform{}}
 	ds.Transform.SetScriptFile(scriptFile(t, "startf/testdata/csv_with_header.star"))
@@ -311,3 +349,34 @@ func TestApplyAssignsColumnsAndBody(t *testing.T) {
 	}
 
 }
+
+func TestApplyRedactsSecretsBeforePublishing(t *testing.T) {
+	ctx := context.Background()
+
+	bus := event.NewBus(ctx)
+	runID := "redactedRunID"
+	msgs := []string{}
+	bus.SubscribeID(func(ctx context.Context, e event.Event) error {
+		if msg, ok := e.Payload.(event.TransformMessage); ok {
+			msgs = append(msgs, msg.Msg)
+		}
+		return nil
+	}, runID)
+
+	ds := &dataset.Dataset{
+		Transform: &dataset.Transform{
+			Steps: []*dataset.TransformStep{
+				{Syntax: "starlark", Script: `print("key is " + secrets.get("api_key"))`},
+			},
+		},
+	}
+	transformer := NewTransformer(ctx, qfs.NewMemFS(), &noHistoryLoader{}, nil, bus, SizeInfo{})
+	if err := transformer.Apply(ctx, ds, runID, true, map[string]string{"api_key": "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"key is " + event.RedactedSecret, "this script did not call dataset.commit, no changes will be saved"}
+	if diff := cmp.Diff(expect, msgs); diff != "" {
+		t.Errorf("published messages mismatch (-want +got):\n%s", diff)
+	}
+}